@@ -2,8 +2,17 @@ package api
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 	"go-invoice/internal/auth"
+	"go-invoice/internal/emaillog"
+	"go-invoice/internal/export"
+	"go-invoice/internal/outbox"
+	"go-invoice/internal/pdfcache"
+	"go-invoice/internal/pdfsign"
+	"go-invoice/internal/reminder"
+	"go-invoice/internal/render"
+	"go-invoice/internal/search"
 	"go-invoice/internal/storage"
 	"net/http"
 )
@@ -20,6 +29,17 @@ type Handler struct {
 	LocalBaseURL    string // localhost URL for internal PDF generation (ChromeDP)
 	EmailAuthMethod auth.AuthMethod
 	Version         string
+	SearchIndex     *search.Index          // full-text index over invoices, see BuildSearchIndex
+	Outbox          *outbox.Outbox         // queued outgoing emails, see StartOutbox
+	EmailLog        *emaillog.Log          // send attempts per invoice, see StartOutbox
+	Reminders       *reminder.Engine       // payment reminder schedule, see StartReminders
+	Sessions        *auth.FileSessionStore // server-side login sessions
+	PDF             *render.Renderers      // invoice PDF renderers, see render.Load
+	PDFCache        *pdfcache.Cache        // rendered PDFs, nil when disabled, see OpenPDFCache
+	Exports         *export.Tracker        // progress of ZIP exports, see OpenExports
+	ExportWorkers   int                    // invoices an export renders at once
+	SigningKeys     *pdfsign.KeyStore      // providers' PDF signing keys, see OpenSigning
+	SignatureCAs    *x509.CertPool         // CAs trusted when verifying signatures, nil for none
 }
 
 func (h *Handler) RegisterRoutesV1(mux *http.ServeMux) {
@@ -28,20 +48,47 @@ func (h *Handler) RegisterRoutesV1(mux *http.ServeMux) {
 	mux.HandleFunc(prefix+"/version", h.handleVersion)
 	mux.HandleFunc(prefix+"/providers", h.handleProvidersCollection)
 	mux.HandleFunc(prefix+"/providers/{id}", h.handleProvidersItem)
+	mux.HandleFunc(fmt.Sprintf("GET %s/providers/{id}/signing_key", prefix), h.handleGetSigningKey)
+	mux.HandleFunc(fmt.Sprintf("PUT %s/providers/{id}/signing_key", prefix), h.handleUpdateSigningKey)
+	mux.HandleFunc(fmt.Sprintf("DELETE %s/providers/{id}/signing_key", prefix), h.handleDeleteSigningKey)
 	mux.HandleFunc(prefix+"/clients", h.handleClientsCollection)
 	mux.HandleFunc(prefix+"/clients/{id}", h.handleClientsItem)
 	mux.HandleFunc(prefix+"/invoices", h.handleInvoicesCollection)
 	mux.HandleFunc(prefix+"/invoices/{id}", h.handleInvoicesItem)
 	mux.HandleFunc(prefix+"/invoices/count", h.handleInvoicesCount)
+	mux.HandleFunc(fmt.Sprintf("GET %s/invoices/export", prefix), h.handleExportInvoices)
+	mux.HandleFunc(fmt.Sprintf("GET %s/exports/{id}", prefix), h.handleGetExport)
 	mux.HandleFunc(prefix+"/invoices/{id}/pdf", h.handleInvoicePDF)
+	mux.HandleFunc(fmt.Sprintf("GET %s/invoices/{id}/facturx", prefix), h.handleInvoiceFacturX)
+	mux.HandleFunc(fmt.Sprintf("GET %s/invoices/{id}/facturx/validate", prefix), h.handleValidateFacturX)
 	mux.HandleFunc(fmt.Sprintf("POST %s/invoices/{id}/email", prefix), h.handleSendEmail)
-	mux.HandleFunc(fmt.Sprintf("GET %s/email_templates/{id}", prefix), h.handleEmailTemplate)
+	mux.HandleFunc(fmt.Sprintf("POST %s/invoices/{id}/email/preview", prefix), h.handleEmailPreview)
+	mux.HandleFunc(fmt.Sprintf("POST %s/invoices/{id}/duplicate", prefix), h.handleDuplicateInvoice)
+	mux.HandleFunc(fmt.Sprintf("GET %s/invoices/{id}/emails", prefix), h.handleInvoiceEmailLog)
+	mux.HandleFunc(fmt.Sprintf("GET %s/emails", prefix), h.handleSearchEmailLog)
+	mux.HandleFunc(fmt.Sprintf("POST %s/signatures/verify", prefix), h.handleVerifySignatures)
+	mux.HandleFunc(prefix+"/email_templates", h.handleEmailTemplatesCollection)
+	mux.HandleFunc(prefix+"/email_templates/{id}", h.handleEmailTemplatesItem)
+	mux.HandleFunc(fmt.Sprintf("GET %s/email_templates/variables", prefix), h.handleEmailTemplateVariables)
+
+	// outbox
+	mux.HandleFunc(fmt.Sprintf("GET %s/outbox", prefix), h.handleListOutbox)
+	mux.HandleFunc(fmt.Sprintf("GET %s/outbox/{id}", prefix), h.handleGetOutboxJob)
+	mux.HandleFunc(fmt.Sprintf("DELETE %s/outbox/{id}", prefix), h.handleDeleteOutboxJob)
+	mux.HandleFunc(fmt.Sprintf("POST %s/outbox/{id}/retry", prefix), h.handleRetryOutboxJob)
+
+	// reminders
+	mux.HandleFunc(fmt.Sprintf("GET %s/reminders/schedule", prefix), h.handleGetReminderSchedule)
+	mux.HandleFunc(fmt.Sprintf("PUT %s/reminders/schedule", prefix), h.handleUpdateReminderSchedule)
+	mux.HandleFunc(fmt.Sprintf("POST %s/reminders/run", prefix), h.handleRunReminders)
 
 	// mailer
 	mux.HandleFunc(prefix+"/mailer/auth/{provider}", h.handleMailerOAuth2Begin)
 	mux.HandleFunc(prefix+"/mailer/auth/{provider}/callback", h.handleMailerOAuth2Callback)
 	mux.HandleFunc(fmt.Sprintf("GET %s/mailer/session", prefix), h.handleMailerSession)
 	mux.HandleFunc(fmt.Sprintf("POST %s/mailer/logout", prefix), h.handleMailerLogout)
+	mux.HandleFunc(fmt.Sprintf("GET %s/mailer/sessions", prefix), h.handleListMailerSessions)
+	mux.HandleFunc(fmt.Sprintf("DELETE %s/mailer/sessions/{id}", prefix), h.handleRevokeMailerSession)
 }
 
 func (h *Handler) Root(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/storage"
+	"go-invoice/internal/types"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// DuplicateRequest holds the options for duplicating an invoice
+type DuplicateRequest struct {
+	Shift           string `json:"shift,omitempty"`            // period to move item dates and due date by, e.g. "1m" or "14d"
+	ClearQuantities bool   `json:"clear_quantities,omitempty"` // reset item quantities to zero
+	RefreshProfiles bool   `json:"refresh_profiles,omitempty"` // re-read provider and client details from the stored profiles
+}
+
+// handleDuplicateInvoice creates a new draft invoice from an existing one
+// POST /api/v1/invoices/{id}/duplicate
+func (h *Handler) handleDuplicateInvoice(w http.ResponseWriter, r *http.Request) {
+	logger := slog.With("url", r.RequestURI, "method", r.Method)
+	id := r.PathValue("id")
+	if id == "" {
+		writeRespErr(w, "invoice ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var req DuplicateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeRespErr(w, fmt.Sprintf("invalid duplicate options: %v", err), http.StatusBadRequest)
+		logger.Error("invalid duplicate options", "error", err)
+		return
+	}
+
+	opts := invoice.DuplicateOptions{ClearQuantities: req.ClearQuantities}
+	if req.Shift != "" {
+		shift, err := types.ParsePeriod(req.Shift)
+		if err != nil {
+			writeRespErr(w, fmt.Sprintf("invalid shift: %v", err), http.StatusBadRequest)
+			return
+		}
+		opts.Shift = shift
+	}
+
+	source, ok := h.loadInvoice(w, r, id)
+	if !ok {
+		return
+	}
+
+	dup := source.Duplicate(types.Today(), opts)
+
+	if req.RefreshProfiles {
+		provider, err := storage.LoadProviderData(h.StorageDir.Providers, source.Provider.Id)
+		if err != nil {
+			writeProfileLoadErr(w, ProviderType, source.Provider.Id, err)
+			logger.Error("failed to load provider profile", "provider", source.Provider.Id, "error", err)
+			return
+		}
+		client, err := storage.LoadClientData(h.StorageDir.Clients, source.Client.Id)
+		if err != nil {
+			writeProfileLoadErr(w, ClientType, source.Client.Id, err)
+			logger.Error("failed to load client profile", "client", source.Client.Id, "error", err)
+			return
+		}
+		provider.ApplyTo(dup)
+		client.ApplyTo(dup)
+	}
+
+	if err := h.createInvoice(dup, dup.Date); err != nil {
+		writeCreateInvoiceErr(w, err)
+		logger.Error("failed to save duplicated invoice", "error", err)
+		return
+	}
+	newID := dup.ID
+
+	writeRespWithStatus(w, fmt.Sprintf("duplicated invoice '%s' as '%s'", id, newID), dup, http.StatusCreated)
+	logger.Info("invoice duplicated", "source", id, "invoice", newID)
+}
+
+// writeCreateInvoiceErr writes the error response for an invoice that could not be created
+func writeCreateInvoiceErr(w http.ResponseWriter, err error) {
+	if errors.Is(err, os.ErrExist) {
+		writeRespErr(w, "invoice IDs of the day are being taken concurrently, retry", http.StatusConflict)
+		return
+	}
+	writeRespErr(w, "failed to create invoice", http.StatusInternalServerError)
+}
+
+// writeProfileLoadErr writes the error response for a profile that could not be loaded
+func writeProfileLoadErr(w http.ResponseWriter, resourceType resourceType, id string, err error) {
+	if errors.Is(err, os.ErrNotExist) {
+		writeRespErr(w, fmt.Sprintf("%s not found for '%s'", resourceType, id), http.StatusNotFound)
+		return
+	}
+	writeRespErr(w, fmt.Sprintf("failed to read %s '%s'", resourceType, id), http.StatusInternalServerError)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/types"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHandleDuplicateInvoice(t *testing.T) {
+	h := newTestHandler(t)
+	source := &invoice.Invoice{
+		ID:        "INV-25110101",
+		Status:    invoice.StatusPaid,
+		Date:      types.NewDate(time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC)),
+		Due:       types.NewDate(time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)),
+		Provider:  invoice.Party{Id: "acme", Name: "Acme"},
+		Client:    invoice.Party{Id: "globex", Name: "Globex"},
+		Items:     []invoice.ServiceItem{{Description: "Consulting", Quantity: 2, UnitPrice: 100, TotalPrice: 200}},
+		Reminders: []invoice.ReminderRecord{{Level: "due"}},
+	}
+	if err := invoice.SaveInvoice(h.StorageDir.Invoices, source); err != nil {
+		t.Fatal(err)
+	}
+	today := types.Today()
+	prefix := "INV-" + today.Format("060102")
+
+	resp := serve(t, h.handleDuplicateInvoice, http.MethodPost, `{"shift":"1m","clear_quantities":true}`, "id", source.ID)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("duplicate = %d %s, want 201", resp.Code, resp.Message)
+	}
+	dup := decodeData[invoice.Invoice](t, resp)
+	if dup.ID != prefix+"01" || dup.Status != invoice.StatusDraft || dup.Reminders != nil || dup.Date.String() != today.String() {
+		t.Errorf("duplicate = %s %s dated %s with reminders %+v, want a new draft of today", dup.ID, dup.Status, dup.Date.String(), dup.Reminders)
+	}
+	if dup.Due.String() != "2026-01-01" || dup.Items[0].Quantity != 0 || dup.Pricing.Total != 0 {
+		t.Errorf("duplicate due %s, items %+v, pricing %+v, want the options applied", dup.Due.String(), dup.Items, dup.Pricing)
+	}
+	if stored, err := invoice.LoadInvoice(h.StorageDir.Invoices, dup.ID); err != nil || stored.Status != invoice.StatusDraft {
+		t.Errorf("stored duplicate = %+v, %v", stored, err)
+	}
+
+	// a second duplicate takes the next ID instead of overwriting the first
+	resp = serve(t, h.handleDuplicateInvoice, http.MethodPost, "", "id", source.ID)
+	if second := decodeData[invoice.Invoice](t, resp); resp.Code != http.StatusCreated || second.ID != prefix+"02" {
+		t.Errorf("second duplicate = %d %s, want 201 with %s", resp.Code, second.ID, prefix+"02")
+	}
+	if first, err := invoice.LoadInvoice(h.StorageDir.Invoices, dup.ID); err != nil || first.Due.String() != "2026-01-01" {
+		t.Errorf("first duplicate after the second = %+v, %v, want it unchanged", first, err)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		body string
+		code int
+	}{
+		{"unknown invoice", "INV-00000000", "", http.StatusNotFound},
+		{"invalid shift", source.ID, `{"shift":"soon"}`, http.StatusBadRequest},
+		{"invalid body", source.ID, `{`, http.StatusBadRequest},
+		{"missing profiles", source.ID, `{"refresh_profiles":true}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := serve(t, h.handleDuplicateInvoice, http.MethodPost, tt.body, "id", tt.id); resp.Code != tt.code {
+				t.Errorf("duplicate = %d %s, want %d", resp.Code, resp.Message, tt.code)
+			}
+		})
+	}
+}
@@ -1,22 +1,24 @@
 package api
 
 import (
-	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"go-invoice/internal/auth"
+	"go-invoice/internal/emailtemplate"
 	"go-invoice/internal/invoice"
+	"go-invoice/internal/outbox"
 	"go-invoice/internal/services"
+	"go-invoice/internal/storage"
 	"go-invoice/internal/types"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/mail"
 	"os"
-	"strconv"
 	"strings"
-	"time"
 
 	"github.com/markbates/goth/gothic"
-	"golang.org/x/oauth2"
 )
 
 func (h *Handler) handleSendEmail(w http.ResponseWriter, r *http.Request) {
@@ -34,140 +36,311 @@ func (h *Handler) handleSendEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// retreive address and and origin from env
-	host := strings.TrimSpace(os.Getenv("SMTP_HOST"))
-	portStr := strings.TrimSpace(os.Getenv("SMTP_PORT"))
+	inv, ok := h.loadInvoice(w, r, id)
+	if !ok {
+		return
+	}
 
-	if host == "" || portStr == "" {
-		writeRespErr(w, "incomplete SMTP settings", http.StatusInternalServerError)
-		logger.Error("incomplete SMTP configuration", "error", "either SMTP_HOST or SMTP_PORT is not configured in environment variables")
+	// render the template server-side when no subject and body are given
+	if emailMessage.Subject == "" && emailMessage.Body == "" {
+		vars := emailtemplate.NewVars(inv)
+		if logo := h.providerLogo(inv); logo != nil {
+			vars["PROVIDER_LOGO"] = "cid:" + logo.ContentID
+		}
+		rendered, err := h.renderInvoiceEmail(inv, emailMessage.TemplateID, vars)
+		if err != nil {
+			writeRenderErr(w, err)
+			logger.Error("failed to render email template", "invoice", id, "error", err)
+			return
+		}
+		emailMessage.Subject = rendered.Subject
+		emailMessage.Body = rendered.Body
+		emailMessage.HTMLBody = rendered.HTMLBody
+		emailMessage.TemplateID = rendered.TemplateID
+	}
+	applyEmailDefaults(emailMessage, inv)
+	if len(emailMessage.To) == 0 {
+		writeRespErr(w, fmt.Sprintf("no recipients for invoice '%s', set 'to' or the invoice email target", id), http.StatusBadRequest)
 		return
 	}
-	port, err := strconv.Atoi(portStr)
-	if err != nil {
-		writeRespErr(w, "malformed SMTP settings", http.StatusInternalServerError)
-		logger.Error("failed to parse SMTP_PORT to interger")
+
+	identity := h.senderIdentity(inv)
+	snd, err := h.resolveSender(inv.Provider.Id, identity, func() (string, *outbox.Credentials, error) { return sessionSender(r) })
+	switch {
+	case err == nil:
+	case errors.Is(err, errSendingDisabled):
+		writeRespErr(w, err.Error(), http.StatusNotImplemented)
+		return
+	case errors.Is(err, errNotLoggedIn):
+		writeRespErr(w, "Unauthorized: Not logged in", http.StatusUnauthorized)
+		logger.Error("unauthorized: not logged in")
+		return
+	case errors.Is(err, errAccountNotSignedIn):
+		writeRespErr(w, err.Error(), http.StatusUnauthorized)
+		logger.Error("sender account is not signed in", "error", err)
+		return
+	default:
+		writeRespErr(w, fmt.Sprintf("failed to determine the sender: %v", err), http.StatusInternalServerError)
+		logger.Error("failed to determine the sender", "error", err)
 		return
 	}
 
-	var credential string
-	var from string
-	switch h.EmailAuthMethod {
-	case auth.AuthMethodNone:
-		writeRespErr(w, "email sending is not configured", http.StatusNotImplemented)
+	job := &outbox.Job{InvoiceID: id, Message: *emailMessage}
+	snd.apply(job)
+	creds := snd.Creds
+	applySenderIdentity(&job.Message, identity)
+
+	// fail fast on incomplete settings, the worker reads them again on delivery
+	if _, err := loadMailConfig(job, creds); err != nil {
+		writeRespErr(w, "incomplete mail transport settings", http.StatusInternalServerError)
+		logger.Error("incomplete mail transport configuration", "error", err)
 		return
-	case auth.AuthMethodPlain:
-		from = strings.TrimSpace(os.Getenv("SMTP_FROM"))
-		credential = strings.TrimSpace(os.Getenv("SMTP_PASSWORD"))
-		if credential == "" || from == "" {
-			writeRespErr(w, "incomplete SMTP configuration, either SMTP_FROM or SMTP_PASSWORD is not set", http.StatusInternalServerError)
-			logger.Error("incomplete SMTP configuration", "error", "SMTP_PASSWORD is not set")
-			return
-		}
+	}
 
-	case auth.AuthMethodOAuth2:
-		session, err := gothic.Store.Get(r, SessionName)
-		if err != nil {
-			writeRespErr(w, "failed to get session for oauth2 email sending", http.StatusInternalServerError)
-			logger.Error("failed to get session for oauth2 email sending", "error", err)
-			return
-		}
+	if err := finalizeRecipients(&job.Message, inv, job.From); err != nil {
+		writeRespErr(w, fmt.Sprintf("invalid email address for '%s': %v", id, err), http.StatusBadRequest)
+		return
+	}
 
-		val := session.Values[userKey]
-		sessionData, ok := val.(types.UserSessionData)
-		if !ok {
-			writeRespErr(w, "Unauthorized: Not logged in", http.StatusUnauthorized)
-			logger.Error("unauthorized: not logged in")
-			return
-		}
+	// queue for delivery, the invoice is marked as sent once the email is delivered
+	if err := h.Outbox.Enqueue(job, creds); err != nil {
+		writeRespErr(w, fmt.Sprintf("failed to queue email for invoice '%s': %v", id, err), http.StatusInternalServerError)
+		logger.Error("failed to queue email", "invoice", id, "error", err)
+		return
+	}
 
-		// refresh token
-		// rebuild oauth2 token from session data
-		storedToken := &oauth2.Token{
-			AccessToken:  sessionData.AccessToken,
-			RefreshToken: sessionData.RefreshToken,
-			Expiry:       sessionData.ExpiresAt,
-		}
+	writeRespWithStatus(w, fmt.Sprintf("email queued for invoice '%s'", id), job.Public(), http.StatusAccepted)
+	logger.Info("invoice email queued", "invoice", id, "job", job.ID, "from", job.From, "to", emailMessage.To)
+}
 
-		ctx := context.Background()
-		tokenSource := auth.GoogleOAuthConfig.TokenSource(ctx, storedToken)
-		validToken, err := tokenSource.Token()
-		if err != nil {
-			writeRespErr(w, "failed to refresh auth token", http.StatusUnauthorized)
-			logger.Error("failed to refresh oauth token", "error", err)
-			return
-		}
+// EmailPreviewRequest selects what to render for an email preview.
+// When Subject, Body or HTMLBody are set they are rendered instead of the stored template.
+type EmailPreviewRequest struct {
+	TemplateID string `json:"template_id,omitempty"` // defaults to the invoice's template
+	Subject    string `json:"subject,omitempty"`
+	Body       string `json:"body,omitempty"`
+	HTMLBody   string `json:"html_body,omitempty"`
+}
 
-		if validToken.AccessToken != storedToken.AccessToken {
-			logger.Info("OAuth token refreshed, saving new token to session")
-			sessionData.AccessToken = validToken.AccessToken
-			sessionData.RefreshToken = validToken.RefreshToken
-			sessionData.ExpiresAt = validToken.Expiry
-			if err := session.Save(r, w); err != nil {
-				writeRespErr(w, "failed to save refreshed token", http.StatusInternalServerError)
-				logger.Error("failed to save refreshed token", "error", err)
-				return
-			}
-		}
+// EmailPreviewResponse is an email rendered for an invoice
+type EmailPreviewResponse struct {
+	emailtemplate.Rendered
+	To      []string `json:"to"`
+	Cc      []string `json:"cc,omitempty"`
+	Bcc     []string `json:"bcc,omitempty"`
+	ReplyTo string   `json:"reply_to,omitempty"`
+}
 
-		from = sessionData.Email
-		credential = sessionData.AccessToken
+// handleEmailPreview renders the email for an invoice without sending it
+// POST /api/v1/invoices/{id}/email/preview
+func (h *Handler) handleEmailPreview(w http.ResponseWriter, r *http.Request) {
+	logger := slog.With("url", r.RequestURI, "method", r.Method)
+	id := r.PathValue("id")
 
-		logger.Info("OAuth2 email sending",
-			"from", from,
-			"host", host,
-			"port", port,
-			"token_length", len(credential),
-			"token_prefix", credential[:20]+"...")
+	var req EmailPreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeRespErr(w, fmt.Sprintf("invalid preview request: %v", err), http.StatusBadRequest)
+		return
 	}
 
-	smtp := services.NewSMTPService(from, host, port, credential, h.EmailAuthMethod)
+	inv, ok := h.loadInvoice(w, r, id)
+	if !ok {
+		return
+	}
 
-	// generate pdf attachment
-	chrome, err := services.NewChromeService()
+	// a browser cannot resolve cid: references, so the preview inlines the logo as a data URI
+	vars := emailtemplate.NewVars(inv)
+	if logo := h.providerLogo(inv); logo != nil {
+		vars["PROVIDER_LOGO"] = fmt.Sprintf("data:%s;base64,%s", logo.ContentType, base64.StdEncoding.EncodeToString(logo.Data))
+	}
+
+	var rendered *emailtemplate.Rendered
+	var err error
+	if req.Subject != "" || req.Body != "" || req.HTMLBody != "" {
+		rendered, err = emailtemplate.RenderEmail(&storage.EmailTemplate{
+			Id:       req.TemplateID,
+			Subject:  req.Subject,
+			Body:     req.Body,
+			HTMLBody: req.HTMLBody,
+		}, vars)
+	} else {
+		rendered, err = h.renderInvoiceEmail(inv, req.TemplateID, vars)
+	}
 	if err != nil {
-		writeRespErr(w, "failed to initialize chrome service for pdf generation", http.StatusInternalServerError)
-		logger.Error("failed to initialize chrome service", "error", err)
+		writeRenderErr(w, err)
+		logger.Error("failed to render email preview", "invoice", id, "error", err)
 		return
 	}
-	defer chrome.Close()
-	pdfData, err := chrome.GeneratePDF(fmt.Sprintf("%s/invoices/%s/print", h.LocalBaseURL, id), 10*time.Second, services.PaperSizeA3, id)
+
+	var defaults types.EmailMessage
+	applyEmailDefaults(&defaults, inv)
+	// show the signature as it will be sent
+	preview := types.EmailMessage{Body: rendered.Body, HTMLBody: rendered.HTMLBody, ReplyTo: defaults.ReplyTo}
+	applySenderIdentity(&preview, h.senderIdentity(inv))
+	rendered.Body, rendered.HTMLBody = preview.Body, preview.HTMLBody
+	writeRespOk(w, fmt.Sprintf("email preview for invoice '%s'", id), EmailPreviewResponse{
+		Rendered: *rendered,
+		To:       defaults.To,
+		Cc:       defaults.Cc,
+		Bcc:      defaults.Bcc,
+		ReplyTo:  preview.ReplyTo,
+	})
+}
+
+// handleEmailTemplateVariables lists the placeholders available to email templates
+// GET /api/v1/email_templates/variables
+func (h *Handler) handleEmailTemplateVariables(w http.ResponseWriter, r *http.Request) {
+	writeRespOk(w, "email template variables", emailtemplate.Variables)
+}
+
+// errTemplateNotFound is returned by renderInvoiceEmail when the template does not exist
+var errTemplateNotFound = errors.New("email template not found")
+
+// renderInvoiceEmail renders an email template for the invoice. An empty templateID
+// falls back to the invoice's template, then to the default template.
+func (h *Handler) renderInvoiceEmail(inv *invoice.Invoice, templateID string, vars emailtemplate.Vars) (*emailtemplate.Rendered, error) {
+	if templateID == "" {
+		templateID = inv.EmailTemplateID
+	}
+	if templateID == "" {
+		templateID = storage.DefaultEmailTemplateID
+	}
+	et, err := storage.LoadEmailTemplate(h.StorageDir.EmailTemplates, templateID)
 	if err != nil {
-		writeRespErr(w, "failed to generate pdf attachment", http.StatusInternalServerError)
-		logger.Error("failed to generate pdf attachment", "error", err)
-		return
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: '%s'", errTemplateNotFound, templateID)
+		}
+		return nil, err
+	}
+	return emailtemplate.RenderEmail(et, vars)
+}
+
+// providerLogo loads the logo of the invoice's provider as an inline image.
+// It returns nil when the provider has no logo or its profile cannot be read,
+// in which case the email is sent without it.
+func (h *Handler) providerLogo(inv *invoice.Invoice) *services.InlineImage {
+	provider := h.invoiceProvider(inv)
+	if provider == nil {
+		return nil
 	}
-	// send email with attachment
-	err = smtp.SendWithAttachment(
-		emailMessage.To,
-		emailMessage.Subject,
-		emailMessage.Body,
-		fmt.Sprintf("%s.pdf", id),
-		pdfData,
-		services.AttachmentTypePDF,
-	)
+	contentType, data, ok, err := provider.DecodeLogo()
 	if err != nil {
-		writeRespErr(w, "failed to send email", http.StatusInternalServerError)
-		logger.Error("failed to send email", "error", err)
+		slog.Warn("invalid provider logo", "provider", inv.Provider.Id, "error", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	ext := strings.TrimPrefix(contentType, "image/")
+	return &services.InlineImage{
+		ContentID:   emailtemplate.LogoContentID,
+		Filename:    fmt.Sprintf("logo.%s", ext),
+		ContentType: services.AttachmentType(contentType),
+		Data:        data,
+	}
+}
+
+// writeRenderErr writes the error response for a template that failed to render
+func writeRenderErr(w http.ResponseWriter, err error) {
+	if errors.Is(err, errTemplateNotFound) {
+		writeRespErr(w, err.Error(), http.StatusNotFound)
 		return
 	}
+	writeRespErr(w, fmt.Sprintf("failed to render email template: %v", err), http.StatusUnprocessableEntity)
+}
 
-	// update sent status
-	inv, err := invoice.LoadInvoice(h.StorageDir.Invoices, id)
+// applyEmailDefaults fills in the recipients a message leaves out from the invoice.
+// An explicitly empty cc or bcc list in the request suppresses the default.
+func applyEmailDefaults(msg *types.EmailMessage, inv *invoice.Invoice) {
+	if len(msg.To) == 0 {
+		msg.To = defaultRecipients(inv)
+	}
+	if msg.Cc == nil {
+		msg.Cc = splitAddresses(inv.EmailCc)
+	}
+	if msg.Bcc == nil {
+		msg.Bcc = splitAddresses(inv.EmailBcc)
+	}
+	if msg.ReplyTo == "" {
+		msg.ReplyTo = inv.EmailReplyTo
+	}
+}
+
+// defaultRecipients returns the invoice's email target as a list,
+// falling back to the client's email address
+func defaultRecipients(inv *invoice.Invoice) []string {
+	to := splitAddresses(inv.EmailTarget)
+	if len(to) == 0 && inv.Client.Email != "" {
+		to = append(to, inv.Client.Email)
+	}
+	return to
+}
+
+// splitAddresses splits a comma separated list of addresses
+func splitAddresses(list string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// finalizeRecipients sets the Reply-To default for the sender and normalizes all addresses
+func finalizeRecipients(msg *types.EmailMessage, inv *invoice.Invoice, from string) error {
+	// when sending from a shared mailbox, replies should reach the provider
+	if msg.ReplyTo == "" && inv.Provider.Email != "" && !strings.EqualFold(inv.Provider.Email, from) {
+		msg.ReplyTo = inv.Provider.Email
+	}
+	return normalizeAddresses(msg)
+}
+
+// errNotLoggedIn is returned by sessionSender when the request has no signed in user
+var errNotLoggedIn = errors.New("not logged in")
+
+// sessionSender returns the address and OAuth2 credentials of the signed in user
+func sessionSender(r *http.Request) (string, *outbox.Credentials, error) {
+	session, err := gothic.Store.Get(r, SessionName)
 	if err != nil {
-		writeRespErr(w, fmt.Sprintf("failed to load invoice '%s' to update sent status: %v", id, err), http.StatusInternalServerError)
-		logger.Error("failed to load invoice to update sent status", "invoice", id, "error", err)
-		return
+		return "", nil, err
 	}
-	inv.Status = invoice.StatusSent // <-- mark as sent
-	if err := invoice.SaveInvoice(h.StorageDir.Invoices, inv); err != nil {
-		writeRespErr(w, fmt.Sprintf("failed to save invoice '%s' to update sent status: %v", id, err), http.StatusInternalServerError)
-		logger.Error("failed to save invoice to update sent status", "invoice", id, "error", err)
-		return
+	sessionData, ok := session.Values[userKey].(types.UserSessionData)
+	if !ok {
+		return "", nil, errNotLoggedIn
+	}
+	return sessionData.Email, sessionCredentials(sessionData), nil
+}
+
+// sessionCredentials returns the OAuth2 tokens of a session for queueing
+func sessionCredentials(sessionData types.UserSessionData) *outbox.Credentials {
+	return &outbox.Credentials{
+		AccessToken:  sessionData.AccessToken,
+		RefreshToken: sessionData.RefreshToken,
+		Expiry:       sessionData.ExpiresAt,
+		Provider:     sessionData.Provider,
 	}
-	logger.Info("invoice sent status updated", "invoice", id)
+}
 
-	// success
-	writeRespOk(w, fmt.Sprintf("email sent for invoice '%s'", id), emailMessage)
-	logger.Info("invoice successfully sent", "invoice", id, "from", from, "to", emailMessage.To)
+// normalizeAddresses validates every address of the message and reduces it to
+// its bare form, e.g. "Acme <ap@acme.com>" becomes "ap@acme.com", as required
+// for the SMTP envelope. This also rejects header injection through addresses.
+func normalizeAddresses(msg *types.EmailMessage) error {
+	for _, list := range []*[]string{&msg.To, &msg.Cc, &msg.Bcc} {
+		for i, addr := range *list {
+			parsed, err := mail.ParseAddress(addr)
+			if err != nil {
+				return fmt.Errorf("'%s': %v", addr, err)
+			}
+			(*list)[i] = parsed.Address
+		}
+	}
+	if msg.ReplyTo != "" {
+		parsed, err := mail.ParseAddress(msg.ReplyTo)
+		if err != nil {
+			return fmt.Errorf("'%s': %v", msg.ReplyTo, err)
+		}
+		msg.ReplyTo = parsed.Address
+	}
+	return nil
 }
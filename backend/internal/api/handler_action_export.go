@@ -0,0 +1,132 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"go-invoice/internal/export"
+	"go-invoice/internal/facturx"
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/query"
+	"go-invoice/internal/services"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// exportLogEvery is how many invoices an export renders between progress logs
+const exportLogEvery = 25
+
+// OpenExports sets up invoice exports, rendering EXPORT_CONCURRENCY invoices at once
+func (h *Handler) OpenExports() error {
+	concurrency, err := export.LoadConcurrency()
+	if err != nil {
+		return err
+	}
+	h.Exports = export.NewTracker()
+	h.ExportWorkers = concurrency
+	return nil
+}
+
+// handleExportInvoices streams the PDFs of the invoices matching the filters
+// as a ZIP archive with a manifest. The X-Export-ID header names the export
+// whose progress GET /exports/{id} reports while the archive downloads.
+// GET /api/v1/invoices/export
+func (h *Handler) handleExportInvoices(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	base := pdfOutput{Renderer: values.Get("renderer")}
+	if _, err := h.PDF.Get(base.Renderer); err != nil {
+		writeRespErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := parsePDFFormat(values.Get("format"))
+	if err != nil {
+		writeRespErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	base.Format = format
+	// reject bad page parameters before the response starts
+	if _, err := query.ApplyPageQuery(services.DefaultPageSettings, values); err != nil {
+		writeRespErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	invoices, _, err := h.findInvoices(query.ParseInvoiceQuery(values))
+	if err != nil {
+		writeRespErr(w, "failed to list invoices", http.StatusInternalServerError)
+		slog.Error("failed to list invoices", "error", err)
+		return
+	}
+	if len(invoices) == 0 {
+		writeRespErr(w, "no invoices match the filters", http.StatusNotFound)
+		return
+	}
+	id, err := h.Exports.Start(len(invoices))
+	if err != nil {
+		writeRespErr(w, "failed to start export", http.StatusInternalServerError)
+		slog.Error("failed to start export", "error", err)
+		return
+	}
+	logger := slog.With("export", id)
+	logger.Info("exporting invoices", "count", len(invoices), "format", cmp.Or(base.Format, "pdf"))
+
+	render := func(ctx context.Context, inv *invoice.Invoice) ([]byte, error) {
+		if base.Format == pdfFormatFacturX {
+			if problems := facturx.Validate(inv); len(problems) > 0 {
+				return nil, &facturx.ValidationError{Problems: problems}
+			}
+		}
+		out := base
+		var err error
+		if out.Layout, err = h.pageLayout(inv, values); err != nil {
+			return nil, err
+		}
+		if out.Signing, err = h.pdfSigning(inv); err != nil {
+			return nil, err
+		}
+		key, err := h.pdfKey(inv, out)
+		if err != nil {
+			return nil, err
+		}
+		return h.renderPDF(ctx, inv, out, key, 30*time.Second)
+	}
+	done := 0
+	progress := func(entry export.Entry) {
+		h.Exports.Advance(id, entry)
+		if entry.Error != "" {
+			logger.Warn("invoice not exported", "invoice", entry.InvoiceID, "error", entry.Error)
+		}
+		if done++; done%exportLogEvery == 0 {
+			logger.Info("export progress", "done", done, "total", len(invoices))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"invoices-%s.zip\"", id))
+	w.Header().Set("X-Export-ID", id)
+	w.WriteHeader(http.StatusOK)
+	// send the headers now, the first PDF may take a while
+	http.NewResponseController(w).Flush()
+
+	start := time.Now()
+	err = export.Write(r.Context(), w, invoices, render, h.ExportWorkers, progress)
+	h.Exports.Finish(id, err)
+	if err != nil {
+		logger.Error("export failed", "done", done, "total", len(invoices), "error", err)
+		return
+	}
+	p, _ := h.Exports.Get(id)
+	logger.Info("export finished", "count", len(invoices), "failed", len(p.Failed), "duration", time.Since(start))
+}
+
+// handleGetExport reports the progress of an invoice export
+// GET /api/v1/exports/{id}
+func (h *Handler) handleGetExport(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	p, ok := h.Exports.Get(id)
+	if !ok {
+		writeRespErr(w, fmt.Sprintf("export not found for '%s'", id), http.StatusNotFound)
+		return
+	}
+	writeRespOk(w, fmt.Sprintf("export '%s'", id), p)
+}
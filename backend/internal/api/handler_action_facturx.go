@@ -0,0 +1,81 @@
+package api
+
+import (
+	"fmt"
+	"go-invoice/internal/facturx"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// output formats of /invoices/{id}/pdf, selected by the format query parameter
+const (
+	pdfFormatPlain   = ""
+	pdfFormatFacturX = "facturx" // PDF/A-3 with the Factur-X XML attached
+)
+
+// parsePDFFormat reads the format query parameter, "pdf" is the plain PDF
+func parsePDFFormat(value string) (string, error) {
+	switch value {
+	case "", "pdf":
+		return pdfFormatPlain, nil
+	case pdfFormatFacturX:
+		return pdfFormatFacturX, nil
+	default:
+		return "", fmt.Errorf("unknown pdf format '%s', expected pdf or facturx", value)
+	}
+}
+
+// FacturXValidation is the result of validating an invoice for Factur-X
+type FacturXValidation struct {
+	Valid    bool              `json:"valid"`
+	Problems []facturx.Problem `json:"problems"`
+}
+
+// writeFacturXProblems responds 422 with the mandatory fields the invoice is missing
+func writeFacturXProblems(w http.ResponseWriter, id string, problems []facturx.Problem) {
+	writeRespWithStatus(w, fmt.Sprintf("invoice '%s' is missing mandatory e-invoice fields", id),
+		FacturXValidation{Problems: problems}, http.StatusUnprocessableEntity)
+}
+
+// handleInvoiceFacturX returns the Factur-X (EN 16931) XML of an invoice
+// GET /api/v1/invoices/{id}/facturx
+func (h *Handler) handleInvoiceFacturX(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	inv, ok := h.loadInvoice(w, r, id)
+	if !ok {
+		return
+	}
+	if problems := facturx.Validate(inv); len(problems) > 0 {
+		writeFacturXProblems(w, id, problems)
+		return
+	}
+	data, err := facturx.XML(inv)
+	if err != nil {
+		writeRespErr(w, "error generating factur-x xml", http.StatusInternalServerError)
+		slog.Error("error generating factur-x xml", "invoice", id, "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-%s\"", id, facturx.FileName))
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if _, err := w.Write(data); err != nil {
+		slog.Error("error writing factur-x xml to response", "invoice", id, "error", err)
+	}
+}
+
+// handleValidateFacturX reports the mandatory e-invoice fields an invoice is missing
+// GET /api/v1/invoices/{id}/facturx/validate
+func (h *Handler) handleValidateFacturX(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	inv, ok := h.loadInvoice(w, r, id)
+	if !ok {
+		return
+	}
+	problems := facturx.Validate(inv)
+	if problems == nil {
+		problems = []facturx.Problem{}
+	}
+	writeRespOk(w, fmt.Sprintf("validated invoice '%s'", id), FacturXValidation{Valid: len(problems) == 0, Problems: problems})
+}
@@ -1,8 +1,10 @@
 package api
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
-	"go-invoice/internal/services"
+	"go-invoice/internal/facturx"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -22,17 +24,59 @@ func (h *Handler) handleInvoicePDF(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chromeService, err := services.NewChromeService()
+	inv, ok := h.loadInvoice(w, r, id)
+	if !ok {
+		return
+	}
+	out := pdfOutput{Renderer: r.URL.Query().Get("renderer")}
+	if _, err := h.PDF.Get(out.Renderer); err != nil {
+		writeRespErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := parsePDFFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		writeRespErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	out.Format = format
+	if out.Format == pdfFormatFacturX {
+		if problems := facturx.Validate(inv); len(problems) > 0 {
+			writeFacturXProblems(w, id, problems)
+			return
+		}
+	}
+	if out.Layout, err = h.pageLayout(inv, r.URL.Query()); err != nil {
+		writeRespErr(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if out.Signing, err = h.pdfSigning(inv); err != nil {
+		if errors.Is(err, errNoSigningKey) {
+			writeRespErr(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		writeRespErr(w, "error generating pdf", http.StatusInternalServerError)
+		slog.Error("error loading signing key", "invoice", id, "error", err)
+		return
+	}
+	key, err := h.pdfKey(inv, out)
 	if err != nil {
-		writeRespErr(w, "error creating chrome service", http.StatusInternalServerError)
-		slog.Error("error creating chrome service", "error", err)
+		writeRespErr(w, "error generating pdf", http.StatusInternalServerError)
+		slog.Error("error generating pdf", "error", err)
 		return
 	}
-	defer chromeService.Close() // <- finally close
+	if key != "" {
+		// cached PDFs are identical for identical keys, so the key is a strong validator
+		etag := pdfETag(key)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
 
-	url := fmt.Sprintf("%s/invoices/%s/print", h.LocalBaseURL, id)
-	slog.Info("generating pdf", "url", url)
-	pdf, err := chromeService.GeneratePDF(url, 30*time.Second, services.PaperSizeA3, id)
+	slog.Info("generating pdf", "invoice", id, "renderer", cmp.Or(out.Renderer, h.PDF.Default), "format", cmp.Or(out.Format, "pdf"), "signed", out.Signing != nil)
+	pdf, err := h.renderPDF(r.Context(), inv, out, key, 30*time.Second)
 	if err != nil {
 		writeRespErr(w, "error generating pdf", http.StatusInternalServerError)
 		slog.Error("error generating pdf", "error", err)
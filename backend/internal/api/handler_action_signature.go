@@ -0,0 +1,235 @@
+package api
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/pdfsign"
+	"go-invoice/internal/services"
+	"go-invoice/internal/storage"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	maxSigningKeySize = 1 << 20  // body of a signing key upload
+	maxVerifySize     = 20 << 20 // PDF uploaded for verification
+)
+
+// errNoSigningKey is returned when a provider signs its PDFs but has no key uploaded
+var errNoSigningKey = errors.New("pdf signing is enabled but no signing key is uploaded")
+
+// OpenSigning opens the store of the providers' signing keys, sealed with secret,
+// and reads the CAs trusted when verifying signatures from SIGNATURE_CA_FILE
+func (h *Handler) OpenSigning(secret []byte) error {
+	keys, err := pdfsign.NewKeyStore(h.StorageDir.SigningKeys, secret)
+	if err != nil {
+		return err
+	}
+	h.SigningKeys = keys
+	if caFile := strings.TrimSpace(os.Getenv("SIGNATURE_CA_FILE")); caFile != "" {
+		if h.SignatureCAs, err = services.LoadCertPool(caFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pdfSigning is the signature applied to a PDF after rendering
+type pdfSigning struct {
+	Key     *pdfsign.Key
+	Options pdfsign.Options // without the date, set when signing
+}
+
+// variant identifies the signature in cache keys
+func (s *pdfSigning) variant() string {
+	return fmt.Sprintf("signed:%s:%t:%q:%q", s.Key.Info().Fingerprint, s.Options.Visible, s.Options.Reason, s.Options.Location)
+}
+
+// pdfSigning returns the signature of the invoice's PDFs, nil when its provider
+// does not sign them
+func (h *Handler) pdfSigning(inv *invoice.Invoice) (*pdfSigning, error) {
+	provider := h.invoiceProvider(inv)
+	if provider == nil || provider.Signing == nil || !provider.Signing.Enabled {
+		return nil, nil
+	}
+	key, err := h.SigningKeys.Load(inv.Provider.Id)
+	if errors.Is(err, pdfsign.ErrNoKey) {
+		return nil, fmt.Errorf("provider '%s': %w", inv.Provider.Id, errNoSigningKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	settings := provider.Signing
+	return &pdfSigning{Key: key, Options: pdfsign.Options{
+		Reason:   settings.Reason,
+		Location: settings.Location,
+		Visible:  settings.Visible,
+	}}, nil
+}
+
+// sign signs a rendered PDF
+func (s *pdfSigning) sign(pdf []byte) ([]byte, error) {
+	opts := s.Options
+	opts.Date = time.Now()
+	return s.Key.SignPDF(pdf, opts)
+}
+
+// signatureTrust returns the certificates trusted when verifying: the CAs of
+// SIGNATURE_CA_FILE and the certificates of the providers' own keys
+func (h *Handler) signatureTrust() (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if h.SignatureCAs != nil {
+		pool = h.SignatureCAs.Clone()
+	}
+	certs, err := h.SigningKeys.Certificates()
+	if err != nil {
+		return nil, err
+	}
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return pool, nil
+}
+
+// signingKeyUpload is the body of PUT /providers/{id}/signing_key
+type signingKeyUpload struct {
+	Data     []byte `json:"data"` // PKCS#12 file or PEM key and certificates, base64 encoded
+	Password string `json:"password,omitempty"`
+}
+
+// handleGetSigningKey describes the certificate of a provider's signing key
+// GET /api/v1/providers/{id}/signing_key
+func (h *Handler) handleGetSigningKey(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	key, err := h.SigningKeys.Load(id)
+	if errors.Is(err, pdfsign.ErrNoKey) {
+		writeRespErr(w, fmt.Sprintf("provider '%s' has no signing key", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeRespErr(w, "failed to read signing key", http.StatusInternalServerError)
+		slog.Error("failed to read signing key", "provider", id, "error", err)
+		return
+	}
+	writeRespOk(w, fmt.Sprintf("signing key of provider '%s'", id), key.Info())
+}
+
+// handleUpdateSigningKey stores the signing key of a provider, replacing any previous one
+// PUT /api/v1/providers/{id}/signing_key
+func (h *Handler) handleUpdateSigningKey(w http.ResponseWriter, r *http.Request) {
+	logger := slog.With("url", r.RequestURI, "method", r.Method)
+	id := r.PathValue("id")
+	if _, err := storage.LoadProviderData(h.StorageDir.Providers, id); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			writeRespErr(w, fmt.Sprintf("provider not found for '%s'", id), http.StatusNotFound)
+		} else {
+			writeRespErr(w, fmt.Sprintf("failed to read provider '%s'", id), http.StatusInternalServerError)
+			logger.Error("failed to read provider", "provider", id, "error", err)
+		}
+		return
+	}
+
+	var upload signingKeyUpload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSigningKeySize)).Decode(&upload); err != nil {
+		writeRespErr(w, fmt.Sprintf("invalid signing key upload: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(upload.Data) == 0 {
+		writeRespErr(w, "signing key data is required", http.StatusBadRequest)
+		return
+	}
+	key, err := pdfsign.ParseKey(upload.Data, upload.Password)
+	if err != nil {
+		writeRespErr(w, fmt.Sprintf("invalid signing key: %v", err), http.StatusBadRequest)
+		return
+	}
+	cert := key.Certificate()
+	if now := time.Now(); now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		writeRespErr(w, fmt.Sprintf("certificate '%s' is only valid from %s to %s", key.Name(),
+			cert.NotBefore.Format(time.DateOnly), cert.NotAfter.Format(time.DateOnly)), http.StatusBadRequest)
+		return
+	}
+	if err := h.SigningKeys.Save(id, key); err != nil {
+		writeRespErr(w, "failed to store signing key", http.StatusInternalServerError)
+		logger.Error("failed to store signing key", "provider", id, "error", err)
+		return
+	}
+	// cached PDFs are keyed by the certificate, so the old signature is not served again
+	logger.Info("signing key stored", "provider", id, "subject", key.Info().Subject)
+	writeRespOk(w, fmt.Sprintf("stored signing key of provider '%s'", id), key.Info())
+}
+
+// handleDeleteSigningKey removes the signing key of a provider
+// DELETE /api/v1/providers/{id}/signing_key
+func (h *Handler) handleDeleteSigningKey(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := h.SigningKeys.Delete(id)
+	if errors.Is(err, pdfsign.ErrNoKey) {
+		writeRespErr(w, fmt.Sprintf("provider '%s' has no signing key", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeRespErr(w, "failed to delete signing key", http.StatusInternalServerError)
+		slog.Error("failed to delete signing key", "provider", id, "error", err)
+		return
+	}
+	writeRespOk(w, fmt.Sprintf("deleted signing key of provider '%s'", id), nil)
+}
+
+// providerDeleted is the resourceHook of provider deletes, the signing key and
+// sender account go with the provider
+func (h *Handler) providerDeleted(id string, _ ResourceData) {
+	if err := h.SigningKeys.Delete(id); err != nil && !errors.Is(err, pdfsign.ErrNoKey) {
+		slog.Warn("failed to delete signing key", "provider", id, "error", err)
+	}
+	h.deleteSenderAccount(id, nil)
+}
+
+// handleVerifySignatures checks the signatures of an uploaded PDF, sent as the
+// "file" field of a multipart form or as the request body
+// POST /api/v1/signatures/verify
+func (h *Handler) handleVerifySignatures(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxVerifySize)
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			writeRespErr(w, fmt.Sprintf("invalid upload, expected a pdf in the 'file' field: %v", err), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		writeRespErr(w, fmt.Sprintf("failed to read upload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	roots, err := h.signatureTrust()
+	if err != nil {
+		writeRespErr(w, "failed to read trusted certificates", http.StatusInternalServerError)
+		slog.Error("failed to read trusted certificates", "error", err)
+		return
+	}
+	report, err := pdfsign.Verify(data, roots)
+	if err != nil {
+		writeRespErr(w, fmt.Sprintf("invalid pdf: %v", err), http.StatusBadRequest)
+		return
+	}
+	msg := "the pdf is not signed"
+	switch {
+	case report.Valid:
+		msg = "the pdf signatures are valid"
+	case len(report.Signatures) > 0:
+		msg = "the pdf signatures are not valid"
+	}
+	writeRespOk(w, msg, report)
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"fmt"
+	"go-invoice/internal/emaillog"
+	"go-invoice/internal/query"
+	"log/slog"
+	"net/http"
+)
+
+// PaginatedEmailLog represents a paginated response of email log entries
+type PaginatedEmailLog struct {
+	Items      []emaillog.Entry `json:"items"`
+	Page       int              `json:"page"`
+	PageSize   int              `json:"page_size"`
+	TotalCount int              `json:"total_count"`
+	TotalPages int              `json:"total_pages"`
+}
+
+// handleInvoiceEmailLog lists the send attempts of an invoice, newest first
+// GET /api/v1/invoices/{id}/emails
+func (h *Handler) handleInvoiceEmailLog(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	entries, err := h.EmailLog.List(id)
+	if err != nil {
+		writeRespErr(w, fmt.Sprintf("failed to read email log for '%s': %v", id, err), http.StatusInternalServerError)
+		slog.Error("failed to read email log", "url", r.RequestURI, "invoice", id, "error", err)
+		return
+	}
+	writeRespOk(w, fmt.Sprintf("%d emails for invoice '%s'", len(entries), id), entries)
+}
+
+// handleSearchEmailLog searches the send attempts of all invoices, newest first
+// GET /api/v1/emails?q=...&invoice_id=...&status=sent|failed&recipient=...&from=...&to=...
+func (h *Handler) handleSearchEmailLog(w http.ResponseWriter, r *http.Request) {
+	params := query.ParseEmailLogQuery(r.URL.Query())
+	entries, err := h.EmailLog.Search(func(e *emaillog.Entry) bool {
+		return query.MatchEmailLogEntry(e, params)
+	})
+	if err != nil {
+		writeRespErr(w, fmt.Sprintf("failed to search email log: %v", err), http.StatusInternalServerError)
+		slog.Error("failed to search email log", "url", r.RequestURI, "error", err)
+		return
+	}
+
+	p := query.Paginate(len(entries), params.Page, params.PageSize)
+	writeRespOk(w, "email log", PaginatedEmailLog{
+		Items:      entries[p.Start:p.End],
+		Page:       p.Page,
+		PageSize:   params.PageSize,
+		TotalCount: len(entries),
+		TotalPages: p.TotalPages,
+	})
+}
@@ -4,33 +4,96 @@ import (
 	"errors"
 	"fmt"
 	"go-invoice/internal/storage"
+	"log/slog"
 	"net/http"
 	"os"
-	"path/filepath"
 )
 
-func (h *Handler) handleEmailTemplate(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+func (h *Handler) handleEmailTemplatesItem(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getResourceByID(w, r, h.StorageDir.EmailTemplates, EmailTemplateType, func() ResourceData {
+			return &storage.EmailTemplate{}
+		})
+	case http.MethodPut:
+		updateResourceByID(w, r, h.StorageDir.EmailTemplates, EmailTemplateType, func() ResourceData {
+			return &storage.EmailTemplate{}
+		}, nil)
+	case http.MethodDelete:
+		h.handleDeleteEmailTemplate(w, r)
+	default:
+		writeRespErr(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) handleEmailTemplatesCollection(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getAllResources(w, r, h.StorageDir.EmailTemplates, EmailTemplateType, func(dir string) (any, error) {
+			return getAllProfiles[*storage.EmailTemplate](dir)
+		})
+	case http.MethodPost:
+		createResource(w, r, h.StorageDir.EmailTemplates, EmailTemplateType, func() ResourceData {
+			return &storage.EmailTemplate{}
+		}, nil)
+	default:
 		writeRespErr(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
 	}
+}
 
+// handleDeleteEmailTemplate deletes an email template unless it is the built-in
+// default template or still referenced by a client, an invoice or the reminder schedule
+func (h *Handler) handleDeleteEmailTemplate(w http.ResponseWriter, r *http.Request) {
+	logger := slog.With("url", r.RequestURI, "method", r.Method)
 	id := r.PathValue("id")
-	if id == "" {
-		writeRespErr(w, "email template ID is required", http.StatusBadRequest)
+	if id == storage.DefaultEmailTemplateID {
+		writeRespErr(w, "the built-in default email template cannot be deleted", http.StatusForbidden)
 		return
 	}
 
-	filepath := filepath.Join(h.StorageDir.EmailTemplates, id+".json")
-	emailTemplate := &storage.EmailTemplate{}
-	if err := readJSON(filepath, emailTemplate); err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			writeRespErr(w, fmt.Sprintf("email template not found for '%s'", id), http.StatusNotFound)
-		} else {
-			writeRespErr(w, fmt.Sprintf("failed to read email template '%s'", id), http.StatusInternalServerError)
-		}
+	if schedule, err := h.Reminders.Schedule(); err == nil && schedule.UsesTemplate(id) {
+		writeRespErr(w, fmt.Sprintf("email template '%s' is still used by the reminder schedule", id), http.StatusConflict)
 		return
 	}
-	writeRespOk(w, fmt.Sprintf("email template '%s'", id), emailTemplate)
 
+	clients, invoices, err := h.emailTemplateReferences(id)
+	if err != nil {
+		writeRespErr(w, fmt.Sprintf("failed to check references to email template '%s'", id), http.StatusInternalServerError)
+		logger.Error("failed to check email template references", "template", id, "error", err)
+		return
+	}
+	if len(clients) > 0 || len(invoices) > 0 {
+		writeRespErr(w, fmt.Sprintf(
+			"email template '%s' is still used by %d client(s) and %d invoice(s)",
+			id, len(clients), len(invoices),
+		), http.StatusConflict)
+		logger.Warn("email template still referenced", "template", id, "clients", clients, "invoices", invoices)
+		return
+	}
+
+	deleteResourceByID(w, r, h.StorageDir.EmailTemplates, EmailTemplateType, nil)
+}
+
+// emailTemplateReferences returns the IDs of clients and invoices using the email template
+func (h *Handler) emailTemplateReferences(templateID string) (clientIDs, invoiceIDs []string, err error) {
+	clients, err := getAllProfiles[*storage.ClientData](h.StorageDir.Clients)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+	for _, c := range clients {
+		if c.EmailTemplateId == templateID {
+			clientIDs = append(clientIDs, c.Id)
+		}
+	}
+
+	invoices, err := getAllInvoices(h.StorageDir.Invoices, "*.json")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+	for _, inv := range invoices {
+		if inv.EmailTemplateID == templateID {
+			invoiceIDs = append(invoiceIDs, inv.ID)
+		}
+	}
+	return clientIDs, invoiceIDs, nil
 }
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/reminder"
+	"go-invoice/internal/storage"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestEmailTemplateRoutes(t *testing.T) {
+	h := newTestHandler(t)
+
+	created := serve(t, h.handleEmailTemplatesCollection, http.MethodPost,
+		`{"id":"gentle","name":"Gentle","subject":"Invoice {{INVOICE_ID}}","body":"Hello"}`)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("create = %d %s, want 201", created.Code, created.Message)
+	}
+	if resp := serve(t, h.handleEmailTemplatesCollection, http.MethodPost,
+		`{"id":"gentle","name":"Gentle","subject":"Again","body":"Hello"}`); resp.Code != http.StatusConflict {
+		t.Errorf("create of an existing template = %d %s, want 409", resp.Code, resp.Message)
+	}
+	if resp := serve(t, h.handleEmailTemplatesCollection, http.MethodPost, `{"id":"empty","name":"Empty"}`); resp.Code != http.StatusBadRequest {
+		t.Errorf("create without subject and body = %d %s, want 400", resp.Code, resp.Message)
+	}
+
+	list := serve(t, h.handleEmailTemplatesCollection, http.MethodGet, "")
+	templates := decodeData[[]storage.EmailTemplate](t, list)
+	ids := make(map[string]bool)
+	for _, et := range templates {
+		ids[et.Id] = true
+	}
+	if list.Code != http.StatusOK || !ids["gentle"] || !ids[storage.DefaultEmailTemplateID] {
+		t.Errorf("list = %d %+v, want the default and the created template", list.Code, templates)
+	}
+
+	updated := serve(t, h.handleEmailTemplatesItem, http.MethodPut,
+		`{"id":"gentle","name":"Gentle","subject":"Reminder {{INVOICE_ID}}","body":"Hello again"}`, "id", "gentle")
+	if updated.Code != http.StatusOK {
+		t.Fatalf("update = %d %s, want 200", updated.Code, updated.Message)
+	}
+	if et := decodeData[storage.EmailTemplate](t, serve(t, h.handleEmailTemplatesItem, http.MethodGet, "", "id", "gentle")); et.Subject != "Reminder {{INVOICE_ID}}" {
+		t.Errorf("template after update = %+v", et)
+	}
+	if resp := serve(t, h.handleEmailTemplatesItem, http.MethodPut,
+		`{"name":"Ghost","subject":"s","body":"b"}`, "id", "ghost"); resp.Code != http.StatusNotFound {
+		t.Errorf("update of an unknown template = %d %s, want 404", resp.Code, resp.Message)
+	}
+}
+
+func TestHandleDeleteEmailTemplate(t *testing.T) {
+	h := newTestHandler(t)
+	h.Reminders = reminder.NewEngine(filepath.Join(h.StorageDir.Config, "reminders.json"))
+	for _, id := range []string{"scheduled", "client_tpl", "invoice_tpl", "unused"} {
+		et := &storage.EmailTemplate{Id: id, Name: id, Subject: "Invoice", Body: "Hello"}
+		if err := writeJSON(filepath.Join(h.StorageDir.EmailTemplates, id+".json"), et, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	schedule := &reminder.Schedule{Levels: []reminder.Level{{ID: "due", TemplateID: "scheduled"}}}
+	if err := h.Reminders.SetSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	client := &storage.ClientData{Party: invoice.Party{Id: "globex", Name: "Globex"}, EmailTemplateId: "client_tpl"}
+	if err := writeJSON(filepath.Join(h.StorageDir.Clients, "globex.json"), client, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := invoice.SaveInvoice(h.StorageDir.Invoices, &invoice.Invoice{ID: "INV-25110101", EmailTemplateID: "invoice_tpl"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		code int
+	}{
+		{"built-in default", storage.DefaultEmailTemplateID, http.StatusForbidden},
+		{"used by the reminder schedule", "scheduled", http.StatusConflict},
+		{"used by a client", "client_tpl", http.StatusConflict},
+		{"used by an invoice", "invoice_tpl", http.StatusConflict},
+		{"unused", "unused", http.StatusOK},
+		{"already deleted", "unused", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := serve(t, h.handleEmailTemplatesItem, http.MethodDelete, "", "id", tt.id); resp.Code != tt.code {
+				t.Errorf("delete = %d %s, want %d", resp.Code, resp.Message, tt.code)
+			}
+		})
+	}
+	if _, err := storage.LoadEmailTemplate(h.StorageDir.EmailTemplates, "client_tpl"); err != nil {
+		t.Errorf("template in use was deleted: %v", err)
+	}
+}
@@ -9,7 +9,6 @@ import (
 
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
-	"golang.org/x/oauth2"
 )
 
 func (h *Handler) handleMailerOAuth2Begin(w http.ResponseWriter, r *http.Request) {
@@ -25,21 +24,17 @@ func (h *Handler) handleMailerOAuth2Begin(w http.ResponseWriter, r *http.Request
 	q.Add("provider", provider)
 	r.URL.RawQuery = q.Encode()
 
-	// Get state using Gothic's SetState (generates if not present)
-	state := gothic.SetState(r)
-
-	// Manually construct OAuth URL with prompt=select_account to force account selection
-	if auth.GoogleOAuthConfig == nil {
-		writeRespErr(w, "OAuth2 not configured", http.StatusInternalServerError)
-		slog.Error("GoogleOAuthConfig is nil")
+	p, err := auth.GetProvider(provider)
+	if err != nil {
+		writeRespErr(w, fmt.Sprintf("OAuth2 provider '%s' is not configured", provider), http.StatusNotFound)
 		return
 	}
 
-	authURL := auth.GoogleOAuthConfig.AuthCodeURL(
-		state,
-		oauth2.SetAuthURLParam("prompt", "select_account"),
-		oauth2.AccessTypeOffline,
-	)
+	// Get state using Gothic's SetState (generates if not present)
+	state := gothic.SetState(r)
+
+	// Manually construct OAuth URL with the provider's options, e.g. prompt=select_account
+	authURL := p.AuthCodeURL(state)
 
 	// Create a session object with the auth URL
 	// This needs to be stored so CompleteUserAuth can find it later
@@ -102,6 +97,7 @@ func (h *Handler) handleMailerOAuth2Callback(w http.ResponseWriter, r *http.Requ
 		RefreshToken: user.RefreshToken,
 		ExpiresAt:    user.ExpiresAt,
 		AvatarURL:    user.AvatarURL,
+		Provider:     provider,
 	}
 	sessions.Values[userKey] = sessionData
 
@@ -112,9 +108,18 @@ func (h *Handler) handleMailerOAuth2Callback(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	slog.Info("logged in", "user", user.Email)
+	slog.Info("logged in", "user", user.Email, "provider", provider)
 
 	// Redirect to auth success page (will close popup)
 	w.Header().Set("Location", fmt.Sprintf("%s/auth-success.html", h.FrontendBaseURL))
 	w.WriteHeader(http.StatusFound)
 }
+
+// sessionProvider returns the provider a session or queued job signed in with.
+// Sessions and jobs from before providers were configurable belong to google.
+func sessionProvider(name string) (*auth.Provider, error) {
+	if name == "" {
+		name = auth.ProviderGoogle
+	}
+	return auth.GetProvider(name)
+}
@@ -11,11 +11,28 @@ import (
 
 // SessionResponse represents the authentication session status
 type SessionResponse struct {
-	Authenticated bool   `json:"authenticated"`
-	Email         string `json:"email,omitempty"`
-	Method        string `json:"method"`
-	AvatarURL     string `json:"avatar_url,omitempty"`
-	Name          string `json:"name,omitempty"`
+	Authenticated bool             `json:"authenticated"`
+	Email         string           `json:"email,omitempty"`
+	Method        string           `json:"method"`
+	AvatarURL     string           `json:"avatar_url,omitempty"`
+	Name          string           `json:"name,omitempty"`
+	Provider      string           `json:"provider,omitempty"`  // provider of the signed in account
+	Providers     []OAuth2Provider `json:"providers,omitempty"` // providers offered at login
+}
+
+// OAuth2Provider is a provider the user can sign in with
+type OAuth2Provider struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// oauth2Providers lists the configured providers for the login choice
+func oauth2Providers() []OAuth2Provider {
+	var list []OAuth2Provider
+	for _, p := range auth.Providers() {
+		list = append(list, OAuth2Provider{Name: p.Name, DisplayName: p.DisplayName})
+	}
+	return list
 }
 
 // handleMailerSession checks if the user is authenticated and returns session info
@@ -34,6 +51,8 @@ func (h *Handler) handleMailerSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	providers := oauth2Providers()
+
 	// Get session
 	session, err := gothic.Store.Get(r, SessionName)
 	if err != nil {
@@ -41,6 +60,7 @@ func (h *Handler) handleMailerSession(w http.ResponseWriter, r *http.Request) {
 		writeRespOk(w, "session status", SessionResponse{
 			Authenticated: false,
 			Method:        methodStr,
+			Providers:     providers,
 		})
 		return
 	}
@@ -51,10 +71,16 @@ func (h *Handler) handleMailerSession(w http.ResponseWriter, r *http.Request) {
 		writeRespOk(w, "session status", SessionResponse{
 			Authenticated: false,
 			Method:        methodStr,
+			Providers:     providers,
 		})
 		return
 	}
 
+	// sessions from before Microsoft support belong to google
+	if userData.Provider == "" {
+		userData.Provider = auth.ProviderGoogle
+	}
+
 	// User is authenticated
 	writeRespOk(w, "session status", SessionResponse{
 		Authenticated: true,
@@ -62,6 +88,8 @@ func (h *Handler) handleMailerSession(w http.ResponseWriter, r *http.Request) {
 		Method:        methodStr,
 		AvatarURL:     userData.AvatarURL,
 		Name:          userData.Name,
+		Provider:      userData.Provider,
+		Providers:     providers,
 	})
 }
 
@@ -109,6 +137,8 @@ func authMethodToString(method auth.AuthMethod) string {
 		return "oauth2"
 	case auth.AuthMethodPlain:
 		return "plain"
+	case auth.AuthMethodLocal:
+		return "local"
 	case auth.AuthMethodNone:
 		return "none"
 	default:
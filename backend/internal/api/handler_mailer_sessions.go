@@ -0,0 +1,83 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"go-invoice/internal/auth"
+	"go-invoice/internal/types"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// MailerSession is a signed in mailer account, without its tokens
+type MailerSession struct {
+	ID         string    `json:"id"` // handle for revoking, not the cookie value
+	Email      string    `json:"email"`
+	Name       string    `json:"name,omitempty"`
+	Provider   string    `json:"provider"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"` // the session of the requesting browser
+}
+
+// handleListMailerSessions lists the active mailer sessions
+// GET /api/v1/mailer/sessions
+func (h *Handler) handleListMailerSessions(w http.ResponseWriter, r *http.Request) {
+	infos, err := h.Sessions.List()
+	if err != nil {
+		writeRespErr(w, "error listing sessions", http.StatusInternalServerError)
+		slog.Error("error listing sessions", "error", err)
+		return
+	}
+
+	current := ""
+	if session, err := h.Sessions.Get(r, SessionName); err == nil && session.ID != "" {
+		current = auth.SessionHandle(session.ID)
+	}
+
+	list := []MailerSession{}
+	for _, info := range infos {
+		if info.Name != SessionName {
+			continue // gothic's short-lived login state
+		}
+		userData, ok := info.Values[userKey].(types.UserSessionData)
+		if !ok || userData.Email == "" {
+			continue
+		}
+		if userData.Provider == "" {
+			userData.Provider = auth.ProviderGoogle
+		}
+		list = append(list, MailerSession{
+			ID:         info.Handle,
+			Email:      userData.Email,
+			Name:       userData.Name,
+			Provider:   userData.Provider,
+			UserAgent:  info.UserAgent,
+			CreatedAt:  info.CreatedAt,
+			LastUsedAt: info.UpdatedAt,
+			ExpiresAt:  info.ExpiresAt,
+			Current:    info.Handle == current,
+		})
+	}
+	writeRespOk(w, "mailer sessions", list)
+}
+
+// handleRevokeMailerSession signs a mailer session out, e.g. on a lost device
+// DELETE /api/v1/mailer/sessions/{id}
+func (h *Handler) handleRevokeMailerSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := h.Sessions.Revoke(id); err != nil {
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			writeRespErr(w, fmt.Sprintf("session not found for '%s'", id), http.StatusNotFound)
+			return
+		}
+		writeRespErr(w, "error revoking session", http.StatusInternalServerError)
+		slog.Error("error revoking session", "session", id, "error", err)
+		return
+	}
+	writeRespOk(w, fmt.Sprintf("session '%s' revoked", id), nil)
+	slog.Info("mailer session revoked", "session", id)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"go-invoice/internal/outbox"
+	"log/slog"
+	"net/http"
+)
+
+// handleListOutbox lists outbox jobs, newest first
+// GET /api/v1/outbox?invoice_id=...&status=queued|sending|sent|failed
+func (h *Handler) handleListOutbox(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.Outbox.List()
+	if err != nil {
+		writeRespErr(w, fmt.Sprintf("failed to list outbox: %v", err), http.StatusInternalServerError)
+		slog.Error("failed to list outbox", "url", r.RequestURI, "error", err)
+		return
+	}
+
+	invoiceID := r.URL.Query().Get("invoice_id")
+	status := outbox.Status(r.URL.Query().Get("status"))
+	result := make([]*outbox.Job, 0, len(jobs))
+	for _, job := range jobs {
+		if invoiceID != "" && job.InvoiceID != invoiceID {
+			continue
+		}
+		if status != "" && job.Status != status {
+			continue
+		}
+		result = append(result, job.Public())
+	}
+	writeRespOk(w, fmt.Sprintf("%d outbox jobs", len(result)), result)
+}
+
+// handleGetOutboxJob returns a single outbox job
+// GET /api/v1/outbox/{id}
+func (h *Handler) handleGetOutboxJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	job, err := h.Outbox.Get(id)
+	if err != nil {
+		writeOutboxErr(w, r, id, err)
+		return
+	}
+	writeRespOk(w, fmt.Sprintf("outbox job '%s'", id), job.Public())
+}
+
+// handleRetryOutboxJob queues a failed job again
+// POST /api/v1/outbox/{id}/retry
+func (h *Handler) handleRetryOutboxJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	job, err := h.Outbox.Retry(id)
+	if err != nil {
+		writeOutboxErr(w, r, id, err)
+		return
+	}
+	writeRespWithStatus(w, fmt.Sprintf("outbox job '%s' queued for retry", id), job.Public(), http.StatusAccepted)
+	slog.Info("outbox job queued for retry", "job", id, "invoice", job.InvoiceID)
+}
+
+// handleDeleteOutboxJob removes a job, cancelling it if it was not delivered yet
+// DELETE /api/v1/outbox/{id}
+func (h *Handler) handleDeleteOutboxJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := h.Outbox.Delete(id); err != nil {
+		writeOutboxErr(w, r, id, err)
+		return
+	}
+	writeRespOk(w, fmt.Sprintf("outbox job '%s' deleted", id), nil)
+	slog.Info("outbox job deleted", "job", id)
+}
+
+// writeOutboxErr writes the error response for a failed outbox operation
+func writeOutboxErr(w http.ResponseWriter, r *http.Request, id string, err error) {
+	switch {
+	case errors.Is(err, outbox.ErrNotFound):
+		writeRespErr(w, fmt.Sprintf("outbox job not found for '%s'", id), http.StatusNotFound)
+	case errors.Is(err, outbox.ErrInvalidState):
+		writeRespErr(w, err.Error(), http.StatusConflict)
+	default:
+		writeRespErr(w, fmt.Sprintf("outbox job '%s': %v", id, err), http.StatusInternalServerError)
+		slog.Error("outbox operation failed", "url", r.RequestURI, "job", id, "error", err)
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"go-invoice/internal/invoice"
 	"go-invoice/internal/storage"
 	"net/http"
 )
@@ -14,9 +15,9 @@ func (h *Handler) handleClientsItem(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		updateResourceByID(w, r, h.StorageDir.Clients, ClientType, func() ResourceData {
 			return &storage.ClientData{}
-		})
+		}, nil)
 	case http.MethodDelete:
-		deleteResourceByID(w, r, h.StorageDir.Clients, ClientType)
+		deleteResourceByID(w, r, h.StorageDir.Clients, ClientType, nil)
 	default:
 		writeRespErr(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
@@ -25,13 +26,20 @@ func (h *Handler) handleClientsItem(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) handleClientsCollection(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		getAllResources(w, r, h.StorageDir.Clients, ClientType, func(dir string) (any, error) {
-			return getAllProfiles[*storage.ClientData](dir)
+		// without query parameters the full list is returned for backward compatibility
+		if len(r.URL.Query()) == 0 {
+			getAllResources(w, r, h.StorageDir.Clients, ClientType, func(dir string) (any, error) {
+				return getAllProfiles[*storage.ClientData](dir)
+			})
+			return
+		}
+		listProfiles[*storage.ClientData](w, r, h.StorageDir.Clients, h.StorageDir.Invoices, ClientType, func(inv invoice.Invoice) invoice.Party {
+			return inv.Client
 		})
 	case http.MethodPost:
 		createResource(w, r, h.StorageDir.Clients, ClientType, func() ResourceData {
 			return &storage.ClientData{}
-		})
+		}, nil)
 	default:
 		writeRespErr(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
@@ -1,8 +1,16 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"go-invoice/internal/invoice"
 	"go-invoice/internal/query"
+	"go-invoice/internal/search"
+	"go-invoice/internal/storage"
+	"go-invoice/internal/types"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"sort"
@@ -15,6 +23,21 @@ type PaginatedInvoices struct {
 	PageSize   int               `json:"page_size"`
 	TotalCount int               `json:"total_count"`
 	TotalPages int               `json:"total_pages"`
+	// Highlights holds matched snippets per invoice ID when searching with q=
+	Highlights map[string][]search.Highlight `json:"highlights,omitempty"`
+}
+
+// CreateInvoiceRequest creates an invoice from stored client and provider profiles.
+// The server snapshots the profile details, tax rate, payment information and
+// email settings into the invoice.
+type CreateInvoiceRequest struct {
+	ClientID        string                `json:"client_id"`
+	ProviderID      string                `json:"provider_id"`
+	Items           []invoice.ServiceItem `json:"items"`
+	Status          invoice.InvoiceStatus `json:"status,omitempty"`            // defaults to draft
+	Date            types.Date            `json:"date,omitempty"`              // defaults to today
+	Due             types.Date            `json:"due,omitempty"`               // defaults to date + invoice.DefaultPaymentTerm
+	EmailTemplateID string                `json:"email_template_id,omitempty"` // overrides the client's template
 }
 
 func (h *Handler) handleInvoicesItem(w http.ResponseWriter, r *http.Request) {
@@ -26,9 +49,9 @@ func (h *Handler) handleInvoicesItem(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		updateResourceByID(w, r, h.StorageDir.Invoices, InvoiceType, func() ResourceData {
 			return &invoice.Invoice{}
-		})
+		}, h.invoiceWritten)
 	case http.MethodDelete:
-		deleteResourceByID(w, r, h.StorageDir.Invoices, InvoiceType)
+		deleteResourceByID(w, r, h.StorageDir.Invoices, InvoiceType, h.invoiceWritten)
 	default:
 		writeRespErr(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
@@ -37,71 +60,86 @@ func (h *Handler) handleInvoicesItem(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) handleInvoicesCollection(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		// TODO: Optimize filtering for large datasets (sqlite)
 		// Parse query parameters
 		queryParams := query.ParseInvoiceQuery(r.URL.Query())
 
-		// Get all invoices
-		invoices, err := getAllInvoices(h.StorageDir.Invoices, "*.json")
-		if err != nil && err != os.ErrNotExist {
+		invoices, searchResults, err := h.findInvoices(queryParams)
+		if err != nil {
 			writeRespErr(w, "failed to list invoice informations", http.StatusInternalServerError)
 			return
 		}
 
-		// Apply filters if any
-		if queryParams.HasFilters() {
-			invoices = query.FilterInvoices(invoices, queryParams)
-		}
-
-		// Sort invoices by date descending (newest first)
-		sort.Slice(invoices, func(i, j int) bool {
-			return invoices[i].Date.After(invoices[j].Date.Time)
-		})
-
 		// Calculate pagination
-		totalCount := len(invoices)
-		totalPages := (totalCount + queryParams.PageSize - 1) / queryParams.PageSize
-		if totalPages < 1 {
-			totalPages = 1
-		}
-
-		// Ensure page is within bounds
-		page := queryParams.Page
-		if page > totalPages {
-			page = totalPages
-		}
-
-		// Apply pagination slice
-		start := (page - 1) * queryParams.PageSize
-		end := start + queryParams.PageSize
-		if start > totalCount {
-			start = totalCount
-		}
-		if end > totalCount {
-			end = totalCount
-		}
-
-		paginatedItems := invoices[start:end]
+		p := query.Paginate(len(invoices), queryParams.Page, queryParams.PageSize)
+		paginatedItems := invoices[p.Start:p.End]
 
 		// Return paginated response
 		result := PaginatedInvoices{
 			Items:      paginatedItems,
-			Page:       page,
+			Page:       p.Page,
 			PageSize:   queryParams.PageSize,
-			TotalCount: totalCount,
-			TotalPages: totalPages,
+			TotalCount: len(invoices),
+			TotalPages: p.TotalPages,
+		}
+
+		if searchResults != nil {
+			result.Highlights = make(map[string][]search.Highlight, len(paginatedItems))
+			for _, inv := range paginatedItems {
+				result.Highlights[inv.ID] = searchResults[inv.ID].Highlights
+			}
 		}
 
 		writeRespOk(w, "list of invoices", result)
 	case http.MethodPost:
-		createResource(w, r, h.StorageDir.Invoices, InvoiceType, func() ResourceData {
-			return &invoice.Invoice{}
-		})
+		h.handleCreateInvoice(w, r)
 	default:
 		writeRespErr(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
+// findInvoices returns the invoices matching the filters and search of the
+// query, sorted and not paginated. Search results are nil without a search.
+func (h *Handler) findInvoices(queryParams *query.InvoiceQueryParams) ([]invoice.Invoice, map[string]search.Result, error) {
+	// TODO: Optimize filtering for large datasets (sqlite)
+	invoices, err := getAllInvoices(h.StorageDir.Invoices, "*.json")
+	if err != nil && err != os.ErrNotExist {
+		return nil, nil, err
+	}
+
+	// Apply filters if any
+	if queryParams.HasFilters() {
+		invoices = query.FilterInvoices(invoices, queryParams)
+	}
+
+	// Full-text search narrows the list down to matching invoices
+	var searchResults map[string]search.Result
+	if queryParams.Search != "" {
+		searchResults = make(map[string]search.Result)
+		for _, result := range h.SearchIndex.Search(queryParams.Search) {
+			searchResults[result.ID] = result
+		}
+		matched := invoices[:0]
+		for _, inv := range invoices {
+			if _, ok := searchResults[inv.ID]; ok {
+				matched = append(matched, inv)
+			}
+		}
+		invoices = matched
+	}
+
+	// Sort invoices by relevance when searching without an explicit sort order,
+	// otherwise by the requested keys (default: date descending)
+	if searchResults != nil && len(queryParams.Sort) == 0 {
+		query.SortInvoices(invoices, nil)
+		sort.SliceStable(invoices, func(i, j int) bool {
+			return searchResults[invoices[i].ID].Score > searchResults[invoices[j].ID].Score
+		})
+	} else {
+		query.SortInvoices(invoices, queryParams.Sort)
+	}
+	return invoices, searchResults, nil
+}
+
 func (h *Handler) handleInvoicesCount(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeRespErr(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -115,3 +153,92 @@ func (h *Handler) handleInvoicesCount(w http.ResponseWriter, r *http.Request) {
 	writeRespOk(w, "invoice count retrieved", map[string]int{"count": count})
 }
 
+// handleCreateInvoice creates an invoice either from a complete invoice body,
+// or from a CreateInvoiceRequest referencing stored profiles by ID
+// POST /api/v1/invoices
+func (h *Handler) handleCreateInvoice(w http.ResponseWriter, r *http.Request) {
+	logger := slog.With("url", r.RequestURI, "method", r.Method)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeRespErr(w, "failed to read request body", http.StatusBadRequest)
+		logger.Error("failed to read request body", "error", err)
+		return
+	}
+
+	var req CreateInvoiceRequest
+	if err := json.Unmarshal(body, &req); err != nil || (req.ClientID == "" && req.ProviderID == "") {
+		// not a profile based request, create from the complete invoice body
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		createResource(w, r, h.StorageDir.Invoices, InvoiceType, func() ResourceData {
+			return &invoice.Invoice{}
+		}, h.invoiceWritten)
+		return
+	}
+
+	if req.ClientID == "" || req.ProviderID == "" {
+		writeRespErr(w, "both client_id and provider_id are required", http.StatusBadRequest)
+		return
+	}
+
+	provider, err := storage.LoadProviderData(h.StorageDir.Providers, req.ProviderID)
+	if err != nil {
+		writeProfileLoadErr(w, ProviderType, req.ProviderID, err)
+		logger.Error("failed to load provider profile", "provider", req.ProviderID, "error", err)
+		return
+	}
+	client, err := storage.LoadClientData(h.StorageDir.Clients, req.ClientID)
+	if err != nil {
+		writeProfileLoadErr(w, ClientType, req.ClientID, err)
+		logger.Error("failed to load client profile", "client", req.ClientID, "error", err)
+		return
+	}
+
+	inv := &invoice.Invoice{
+		Status: req.Status,
+		Date:   req.Date,
+		Due:    req.Due,
+		Items:  req.Items,
+	}
+	if inv.Status == "" {
+		inv.Status = invoice.StatusDraft
+	}
+	if inv.Date.IsZero() {
+		inv.Date = types.Today()
+	}
+	if inv.Due.IsZero() {
+		inv.Due = inv.Date.AddPeriod(invoice.DefaultPaymentTerm)
+	}
+	if inv.Items == nil {
+		inv.Items = []invoice.ServiceItem{}
+	}
+	for i := range inv.Items {
+		if inv.Items[i].Date.IsZero() {
+			inv.Items[i].Date = inv.Date
+		}
+	}
+
+	provider.ApplyTo(inv)
+	client.ApplyTo(inv)
+	if req.EmailTemplateID != "" {
+		inv.EmailTemplateID = req.EmailTemplateID
+	}
+	if inv.EmailTemplateID == "" {
+		inv.EmailTemplateID = storage.DefaultEmailTemplateID
+	}
+	inv.Recalculate()
+
+	if !inv.HasRequiredFields() {
+		writeRespErr(w, "incomplete invoice data, check the provider payment information", http.StatusBadRequest)
+		logger.Error("incomplete invoice data from profiles", "provider", req.ProviderID, "client", req.ClientID)
+		return
+	}
+
+	if err := h.createInvoice(inv, types.Today()); err != nil {
+		writeCreateInvoiceErr(w, err)
+		logger.Error("failed to create invoice", "error", err)
+		return
+	}
+
+	writeRespWithStatus(w, fmt.Sprintf("created invoice '%s'", inv.ID), inv, http.StatusCreated)
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/storage"
+	"go-invoice/internal/types"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestHandleCreateInvoice(t *testing.T) {
+	h := newTestHandler(t)
+	provider := &storage.ProviderData{
+		Party:   invoice.Party{Id: "acme", Name: "Acme"},
+		Payment: invoice.PaymentInfo{Method: "Bank transfer", AccountName: "Acme", BSB: "123-456", AccountNumber: "12345678"},
+	}
+	client := &storage.ClientData{
+		Party:           invoice.Party{Id: "globex", Name: "Globex"},
+		TaxRate:         10,
+		EmailTarget:     "ap@globex.example",
+		EmailTemplateId: "monthly",
+	}
+	unpaid := &storage.ProviderData{Party: invoice.Party{Id: "unpaid", Name: "No Payment Info"}}
+	for path, profile := range map[string]any{
+		filepath.Join(h.StorageDir.Providers, "acme.json"):   provider,
+		filepath.Join(h.StorageDir.Providers, "unpaid.json"): unpaid,
+		filepath.Join(h.StorageDir.Clients, "globex.json"):   client,
+	} {
+		if err := writeJSON(path, profile, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	today := types.Today()
+	prefix := "INV-" + today.Format("060102")
+
+	resp := serve(t, h.handleCreateInvoice, http.MethodPost,
+		`{"client_id":"globex","provider_id":"acme","items":[{"description":"Consulting","quantity":3,"unit_price":100}]}`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("create = %d %s, want 201", resp.Code, resp.Message)
+	}
+	inv := decodeData[invoice.Invoice](t, resp)
+	if inv.ID != prefix+"01" || inv.Status != invoice.StatusDraft || inv.Date.String() != today.String() {
+		t.Errorf("created %s %s dated %s, want a draft of today", inv.ID, inv.Status, inv.Date.String())
+	}
+	if due := today.AddPeriod(invoice.DefaultPaymentTerm); inv.Due.String() != due.String() {
+		t.Errorf("due = %s, want the default term %s", inv.Due.String(), due.String())
+	}
+	if inv.Provider.Name != "Acme" || inv.Client.Name != "Globex" || inv.Payment != provider.Payment {
+		t.Errorf("profiles not copied: provider %+v, client %+v, payment %+v", inv.Provider, inv.Client, inv.Payment)
+	}
+	if inv.EmailTarget != "ap@globex.example" || inv.EmailTemplateID != "monthly" {
+		t.Errorf("email settings = %q, %q, want the client's", inv.EmailTarget, inv.EmailTemplateID)
+	}
+	if item := inv.Items[0]; item.TotalPrice != 300 || item.Date.String() != today.String() {
+		t.Errorf("item = %+v, want priced and dated today", item)
+	}
+	if p := inv.Pricing; p.Subtotal != 300 || p.TaxRate != 10 || p.TaxAmount != 30 || p.Total != 330 {
+		t.Errorf("pricing = %+v, want 300 + 10%% tax", p)
+	}
+	if stored, err := invoice.LoadInvoice(h.StorageDir.Invoices, inv.ID); err != nil || stored.Pricing.Total != 330 {
+		t.Errorf("stored invoice = %+v, %v", stored, err)
+	}
+
+	// given dates and template are kept, and the next ID is taken
+	resp = serve(t, h.handleCreateInvoice, http.MethodPost,
+		`{"client_id":"globex","provider_id":"acme","date":"2025-11-01","due":"2025-11-15","email_template_id":"default","items":[]}`)
+	second := decodeData[invoice.Invoice](t, resp)
+	if resp.Code != http.StatusCreated || second.ID != prefix+"02" {
+		t.Fatalf("second create = %d %s, want 201 with %s", resp.Code, second.ID, prefix+"02")
+	}
+	if second.Date.String() != "2025-11-01" || second.Due.String() != "2025-11-15" || second.EmailTemplateID != "default" {
+		t.Errorf("second create dated %s due %s with template %s, want the request's", second.Date.String(), second.Due.String(), second.EmailTemplateID)
+	}
+	if first, err := invoice.LoadInvoice(h.StorageDir.Invoices, inv.ID); err != nil || first.Pricing.Total != 330 {
+		t.Errorf("first invoice after the second = %+v, %v, want it unchanged", first, err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"unknown client", `{"client_id":"nobody","provider_id":"acme"}`, http.StatusNotFound},
+		{"unknown provider", `{"client_id":"globex","provider_id":"nobody"}`, http.StatusNotFound},
+		{"missing provider id", `{"client_id":"globex"}`, http.StatusBadRequest},
+		{"provider without payment info", `{"client_id":"globex","provider_id":"unpaid"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := serve(t, h.handleCreateInvoice, http.MethodPost, tt.body); resp.Code != tt.code {
+				t.Errorf("create = %d %s, want %d", resp.Code, resp.Message, tt.code)
+			}
+		})
+	}
+}
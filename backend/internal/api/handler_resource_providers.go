@@ -1,6 +1,7 @@
 package api
 
 import (
+	"go-invoice/internal/invoice"
 	"go-invoice/internal/storage"
 	"net/http"
 )
@@ -13,11 +14,15 @@ func (h *Handler) handleProvidersItem(w http.ResponseWriter, r *http.Request) {
 			return &storage.ProviderData{}
 		})
 	case http.MethodPut:
+		onWrite, ok := h.senderAccountHook(w, r, r.PathValue("id"))
+		if !ok {
+			return
+		}
 		updateResourceByID(w, r, h.StorageDir.Providers, ProviderType, func() ResourceData {
 			return &storage.ProviderData{}
-		})
+		}, onWrite)
 	case http.MethodDelete:
-		deleteResourceByID(w, r, h.StorageDir.Providers, ProviderType)
+		deleteResourceByID(w, r, h.StorageDir.Providers, ProviderType, h.providerDeleted)
 	default:
 		writeRespErr(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
@@ -26,13 +31,24 @@ func (h *Handler) handleProvidersItem(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) handleProvidersCollection(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		getAllResources(w, r, h.StorageDir.Providers, ProviderType, func(dir string) (any, error) {
-			return getAllProfiles[*storage.ProviderData](dir)
+		// without query parameters the full list is returned for backward compatibility
+		if len(r.URL.Query()) == 0 {
+			getAllResources(w, r, h.StorageDir.Providers, ProviderType, func(dir string) (any, error) {
+				return getAllProfiles[*storage.ProviderData](dir)
+			})
+			return
+		}
+		listProfiles[*storage.ProviderData](w, r, h.StorageDir.Providers, h.StorageDir.Invoices, ProviderType, func(inv invoice.Invoice) invoice.Party {
+			return inv.Provider
 		})
 	case http.MethodPost:
+		onWrite, ok := h.senderAccountHook(w, r, "")
+		if !ok {
+			return
+		}
 		createResource(w, r, h.StorageDir.Providers, ProviderType, func() ResourceData {
 			return &storage.ProviderData{}
-		})
+		}, onWrite)
 	default:
 		writeRespErr(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
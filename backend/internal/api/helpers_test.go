@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"go-invoice/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestHandler returns a handler storing its data in a temporary directory
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	storageDir, err := storage.NewStorageDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Handler{StorageDir: *storageDir}
+}
+
+// testResponse is a decoded API response, with the data left to decode
+type testResponse struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// serve calls handler with a request whose path values are set from pathValues, pairs of name and value
+func serve(t *testing.T, handler http.HandlerFunc, method, body string, pathValues ...string) testResponse {
+	t.Helper()
+	req := httptest.NewRequest(method, "/api/v1/test", strings.NewReader(body))
+	for i := 0; i+1 < len(pathValues); i += 2 {
+		req.SetPathValue(pathValues[i], pathValues[i+1])
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	var resp testResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+	}
+	if resp.Code != rec.Code {
+		t.Fatalf("response code %d differs from the status %d", resp.Code, rec.Code)
+	}
+	return resp
+}
+
+// decodeData decodes the data of a response
+func decodeData[T any](t *testing.T, resp testResponse) T {
+	t.Helper()
+	var data T
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		t.Fatalf("invalid response data %s: %v", resp.Data, err)
+	}
+	return data
+}
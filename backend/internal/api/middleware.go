@@ -34,6 +34,7 @@ func WithCORS(next http.Handler, allowedOrigins []string) http.Handler {
 		w.Header().Add("Vary", "Origin")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition, X-Export-ID")
 
 		// Handle preflight requests
 		if r.Method == "OPTIONS" {
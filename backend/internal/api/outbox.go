@@ -0,0 +1,251 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-invoice/internal/auth"
+	"go-invoice/internal/emaillog"
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/outbox"
+	"go-invoice/internal/services"
+	"log/slog"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// StartOutbox opens the email outbox and starts delivering queued emails in the
+// background until h.Context is cancelled. secret seals the credentials of queued jobs,
+// so jobs queued with OAuth2 can only be delivered while the secret stays the same.
+func (h *Handler) StartOutbox(secret []byte) error {
+	emailLog, err := emaillog.New(h.StorageDir.EmailLog)
+	if err != nil {
+		return err
+	}
+	h.EmailLog = emailLog
+
+	ob, err := outbox.New(h.StorageDir.Outbox, secret, h.deliverEmail)
+	if err != nil {
+		return err
+	}
+	ob.OnSent = h.markInvoiceSent
+	h.Outbox = ob
+	go ob.Run(h.Context)
+	return nil
+}
+
+// loadMailConfig loads the transport settings of the job. Without SMTP_HOST and
+// SMTP_PORT, OAuth2 senders use the SMTP server of the provider they signed in with.
+func loadMailConfig(job *outbox.Job, creds *outbox.Credentials) (*services.MailConfig, error) {
+	var host string
+	var port int
+	if job.AuthMethod == auth.AuthMethodOAuth2 && creds != nil {
+		if provider, err := sessionProvider(creds.Provider); err == nil {
+			host, port = provider.SMTPHost, provider.SMTPPort
+		}
+	}
+	if job.Transport != "" {
+		transport, err := services.ParseTransport(job.Transport)
+		if err != nil {
+			return nil, err
+		}
+		return services.LoadTransportConfig(transport, host, port)
+	}
+	return services.LoadMailConfig(host, port)
+}
+
+// deliverEmail is the outbox.DeliverFunc sending an invoice email with its PDF attached.
+// Every attempt is recorded in the email log, whatever its outcome.
+func (h *Handler) deliverEmail(ctx context.Context, job *outbox.Job, creds *outbox.Credentials) error {
+	entry := &emaillog.Entry{
+		Timestamp:  time.Now().UTC(),
+		InvoiceID:  job.InvoiceID,
+		JobID:      job.ID,
+		Attempt:    job.Attempts,
+		From:       job.From,
+		To:         job.Message.To,
+		Cc:         job.Message.Cc,
+		Bcc:        job.Message.Bcc,
+		Subject:    job.Message.Subject,
+		TemplateID: job.Message.TemplateID,
+		Reminder:   job.ReminderLevel,
+	}
+	err := h.sendJob(ctx, job, creds, entry)
+	entry.Status = emaillog.StatusSent
+	if err != nil {
+		entry.Status = emaillog.StatusFailed
+		entry.Error = err.Error()
+	}
+	if logErr := h.EmailLog.Append(entry); logErr != nil {
+		slog.Error("failed to write email log", "invoice", job.InvoiceID, "job", job.ID, "error", logErr)
+	}
+	return err
+}
+
+// sendJob performs a delivery attempt, filling in the attachment, message and server details of entry
+func (h *Handler) sendJob(ctx context.Context, job *outbox.Job, creds *outbox.Credentials, entry *emaillog.Entry) error {
+	mailConfig, err := loadMailConfig(job, creds)
+	if err != nil {
+		return outbox.Permanent(err)
+	}
+
+	var credential string
+	switch job.AuthMethod {
+	case auth.AuthMethodPlain:
+		credential = strings.TrimSpace(os.Getenv("SMTP_PASSWORD"))
+		if credential == "" {
+			return outbox.Permanent(fmt.Errorf("SMTP_PASSWORD is not set"))
+		}
+	case auth.AuthMethodLocal:
+		// the sendmail and file transports need no credential
+	case auth.AuthMethodOAuth2:
+		if creds == nil {
+			return outbox.Permanent(fmt.Errorf("job has no OAuth2 credentials"))
+		}
+		credential, err = h.refreshJobToken(ctx, job, creds)
+		if err != nil {
+			return err
+		}
+	default:
+		return outbox.Permanent(fmt.Errorf("unsupported auth method '%s'", job.AuthMethod))
+	}
+
+	inv, err := invoice.LoadInvoice(h.StorageDir.Invoices, job.InvoiceID)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return outbox.Permanent(fmt.Errorf("invoice '%s' no longer exists", job.InvoiceID))
+		}
+		return err
+	}
+	if job.IsReminder() && inv.Status != invoice.StatusSent {
+		return outbox.Permanent(fmt.Errorf("reminder cancelled, invoice '%s' is %s", inv.ID, inv.Status))
+	}
+
+	// generate pdf attachment, reusing the one of a download when cached
+	layout, err := h.pageLayout(inv, nil)
+	if err != nil {
+		return outbox.Permanent(err)
+	}
+	out := pdfOutput{Layout: layout}
+	if out.Signing, err = h.pdfSigning(inv); err != nil {
+		if errors.Is(err, errNoSigningKey) {
+			return outbox.Permanent(err)
+		}
+		return err
+	}
+	key, err := h.pdfKey(inv, out)
+	if err != nil {
+		return outbox.Permanent(err)
+	}
+	pdfData, err := h.renderPDF(ctx, inv, out, key, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to generate pdf attachment: %w", err)
+	}
+
+	message := &services.Message{
+		FromName: job.FromName,
+		To:       job.Message.To,
+		Cc:       job.Message.Cc,
+		Bcc:      job.Message.Bcc,
+		ReplyTo:  job.Message.ReplyTo,
+		Subject:  job.Message.Subject,
+		Body:     job.Message.Body,
+		HTMLBody: job.Message.HTMLBody,
+		Attachments: []services.Attachment{
+			{Filename: fmt.Sprintf("%s.pdf", inv.ID), ContentType: services.AttachmentTypePDF, Data: pdfData},
+		},
+	}
+	// only embed the logo when the HTML body references it
+	if logo := h.providerLogo(inv); logo != nil && strings.Contains(message.HTMLBody, "cid:"+logo.ContentID) {
+		message.Inline = append(message.Inline, *logo)
+	}
+
+	for _, a := range message.Attachments {
+		entry.Attachments = append(entry.Attachments, emaillog.NewAttachmentInfo(a.Filename, string(a.ContentType), a.Data))
+	}
+
+	mailer, err := services.NewMailer(mailConfig, job.From, job.Login, credential, job.AuthMethod)
+	if err != nil {
+		return outbox.Permanent(err)
+	}
+	receipt, err := mailer.SendMessage(message)
+	entry.MessageID = message.MessageID
+	if err != nil {
+		var reply *textproto.Error
+		if errors.As(err, &reply) {
+			entry.SMTPResponse = reply.Error()
+			// 5xx replies are permanent, e.g. a rejected recipient
+			if reply.Code >= 500 {
+				return outbox.Permanent(err)
+			}
+		}
+		var sendmailErr *services.SendmailError
+		if errors.As(err, &sendmailErr) {
+			entry.SMTPResponse = sendmailErr.Output
+			if !sendmailErr.Temporary() {
+				return outbox.Permanent(err)
+			}
+		}
+		return err
+	}
+	entry.SMTPResponse = receipt.Response
+	return nil
+}
+
+// refreshJobToken returns a valid access token for the job, refreshing and
+// re-sealing its credentials when the stored token has expired
+func (h *Handler) refreshJobToken(ctx context.Context, job *outbox.Job, creds *outbox.Credentials) (string, error) {
+	storedToken := &oauth2.Token{
+		AccessToken:  creds.AccessToken,
+		RefreshToken: creds.RefreshToken,
+		Expiry:       creds.Expiry,
+	}
+	provider, err := sessionProvider(creds.Provider)
+	if err != nil {
+		return "", outbox.Permanent(err)
+	}
+	validToken, err := provider.TokenSource(ctx, storedToken).Token()
+	if err != nil {
+		// a revoked or invalid refresh token will not recover by itself
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) {
+			return "", outbox.Permanent(fmt.Errorf("failed to refresh oauth token, sign in and retry: %w", err))
+		}
+		return "", fmt.Errorf("failed to refresh oauth token: %w", err)
+	}
+	if validToken.AccessToken != storedToken.AccessToken {
+		err := h.Outbox.UpdateCredentials(job, &outbox.Credentials{
+			AccessToken:  validToken.AccessToken,
+			RefreshToken: validToken.RefreshToken,
+			Expiry:       validToken.Expiry,
+			Provider:     creds.Provider,
+		})
+		if err != nil {
+			slog.Warn("failed to store refreshed token", "job", job.ID, "error", err)
+		}
+	}
+	return validToken.AccessToken, nil
+}
+
+// markInvoiceSent updates the invoice status once its email was delivered
+func (h *Handler) markInvoiceSent(job *outbox.Job) {
+	if job.IsReminder() {
+		return
+	}
+	logger := slog.With("job", job.ID, "invoice", job.InvoiceID)
+	inv, err := invoice.LoadInvoice(h.StorageDir.Invoices, job.InvoiceID)
+	if err != nil {
+		logger.Error("failed to load invoice to update sent status", "error", err)
+		return
+	}
+	inv.Status = invoice.StatusSent // <-- mark as sent
+	if err := h.saveInvoice(inv); err != nil {
+		logger.Error("failed to save invoice to update sent status", "error", err)
+		return
+	}
+	logger.Info("invoice sent status updated")
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"errors"
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/query"
+	"go-invoice/internal/services"
+	"go-invoice/internal/storage"
+	"log/slog"
+	"net/url"
+	"os"
+)
+
+// invoiceClient loads the stored profile of the invoice's client.
+// It returns nil when the invoice has no client or the profile cannot be read.
+func (h *Handler) invoiceClient(inv *invoice.Invoice) *storage.ClientData {
+	if inv.Client.Id == "" {
+		return nil
+	}
+	client, err := storage.LoadClientData(h.StorageDir.Clients, inv.Client.Id)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("failed to load client", "client", inv.Client.Id, "error", err)
+		}
+		return nil
+	}
+	return client
+}
+
+// pageLayout resolves the paper of the invoice's PDF. Each level overrides the
+// one before: services.DefaultPageSettings, provider, client, then the query
+// parameters of the request, which may be nil.
+func (h *Handler) pageLayout(inv *invoice.Invoice, values url.Values) (services.PageLayout, error) {
+	settings := services.DefaultPageSettings
+	if provider := h.invoiceProvider(inv); provider != nil {
+		settings = settings.Override(provider.PDF)
+	}
+	if client := h.invoiceClient(inv); client != nil {
+		settings = settings.Override(client.PDF)
+	}
+	settings, err := query.ApplyPageQuery(settings, values)
+	if err != nil {
+		return services.PageLayout{}, err
+	}
+	return settings.Layout()
+}
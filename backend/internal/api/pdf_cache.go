@@ -0,0 +1,115 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"go-invoice/internal/facturx"
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/pdfcache"
+	"go-invoice/internal/render"
+	"go-invoice/internal/services"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// OpenPDFCache opens the cache of rendered PDFs, sized by PDF_CACHE_SIZE.
+// A size of 0 leaves the cache disabled and every request renders.
+func (h *Handler) OpenPDFCache() error {
+	maxSize, err := pdfcache.LoadMaxSize()
+	if err != nil {
+		return err
+	}
+	if maxSize == 0 {
+		slog.Info("pdf cache disabled")
+		return nil
+	}
+	cache, err := pdfcache.New(h.StorageDir.PDFCache, maxSize)
+	if err != nil {
+		return err
+	}
+	h.PDFCache = cache
+	return nil
+}
+
+// invoiceWritten is the resourceHook of invoice writes. It keeps the search
+// index in sync and drops the cached PDFs of the old version.
+func (h *Handler) invoiceWritten(id string, resource ResourceData) {
+	h.indexInvoice(id, resource)
+	if h.PDFCache != nil {
+		if err := h.PDFCache.Invalidate(id); err != nil {
+			slog.Warn("failed to invalidate cached pdfs", "invoice", id, "error", err)
+		}
+	}
+}
+
+// pdfOutput describes a PDF of an invoice: how it is rendered and post-processed
+type pdfOutput struct {
+	Renderer string // renderer name, empty for the default renderer
+	Format   string // pdfFormatPlain or pdfFormatFacturX
+	Layout   services.PageLayout
+	Signing  *pdfSigning // nil for unsigned PDFs
+}
+
+// pdfKey returns the cache key of the invoice PDF, empty when the cache is disabled
+func (h *Handler) pdfKey(inv *invoice.Invoice, out pdfOutput) (string, error) {
+	if h.PDFCache == nil {
+		return "", nil
+	}
+	output := out.Format
+	if out.Signing != nil {
+		output += "+" + out.Signing.variant()
+	}
+	return pdfcache.Key(inv, cmp.Or(out.Renderer, h.PDF.Default), h.Version, output, out.Layout)
+}
+
+// renderPDF returns the cached PDF under key or renders and caches it
+func (h *Handler) renderPDF(ctx context.Context, inv *invoice.Invoice, out pdfOutput, key string, timeout time.Duration) ([]byte, error) {
+	if key != "" {
+		if pdf, ok := h.PDFCache.Get(inv.ID, key); ok {
+			slog.Debug("pdf served from cache", "invoice", inv.ID)
+			return pdf, nil
+		}
+	}
+	r, err := h.PDF.Get(out.Renderer)
+	if err != nil {
+		return nil, err
+	}
+	pdf, err := r.Render(ctx, inv, render.Options{Page: out.Layout, Timeout: timeout})
+	if err != nil {
+		return nil, err
+	}
+	if out.Format == pdfFormatFacturX {
+		if pdf, err = facturx.Embed(pdf, inv, render.Producer, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	if out.Signing != nil {
+		if pdf, err = out.Signing.sign(pdf); err != nil {
+			return nil, fmt.Errorf("failed to sign pdf: %w", err)
+		}
+	}
+	if key != "" {
+		if err := h.PDFCache.Put(inv.ID, key, pdf); err != nil {
+			slog.Warn("failed to cache pdf", "invoice", inv.ID, "error", err)
+		}
+	}
+	return pdf, nil
+}
+
+// etagMatches reports whether an If-None-Match header lists the entity tag
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// pdfETag quotes a cache key as an entity tag
+func pdfETag(key string) string {
+	return fmt.Sprintf("%q", key)
+}
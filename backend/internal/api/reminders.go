@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-invoice/internal/auth"
+	"go-invoice/internal/emailtemplate"
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/outbox"
+	"go-invoice/internal/reminder"
+	"go-invoice/internal/types"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// reminderInterval is how often the background runner checks for due reminders
+const reminderInterval = time.Hour
+
+// StartReminders sets up the payment reminder schedule and checks for due
+// reminders in the background until h.Context is cancelled. Reminders are
+// delivered through the outbox, so StartOutbox must be called first.
+func (h *Handler) StartReminders() error {
+	schedulePath := filepath.Join(h.StorageDir.Config, "reminders.json")
+	if err := reminder.Setup(schedulePath, h.StorageDir.EmailTemplates); err != nil {
+		return err
+	}
+
+	engine := reminder.NewEngine(schedulePath)
+	engine.Invoices = h.loadAllInvoices
+	engine.Invoice = func(id string) (*invoice.Invoice, error) {
+		return invoice.LoadInvoice(h.StorageDir.Invoices, id)
+	}
+	engine.OptedOut = h.clientOptedOut
+	engine.Queue = h.queueReminder
+	engine.SaveInvoice = h.saveInvoice
+	h.Reminders = engine
+	go engine.Run(h.Context, reminderInterval)
+	return nil
+}
+
+func (h *Handler) loadAllInvoices() ([]*invoice.Invoice, error) {
+	invoices, err := getAllInvoices(h.StorageDir.Invoices, "*.json")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	result := make([]*invoice.Invoice, len(invoices))
+	for i := range invoices {
+		result[i] = &invoices[i]
+	}
+	return result, nil
+}
+
+// clientOptedOut reports whether the invoice's client opted out of reminders.
+// The stored client profile is checked so an opt-out applies to existing invoices.
+func (h *Handler) clientOptedOut(inv *invoice.Invoice) bool {
+	client := h.invoiceClient(inv)
+	return client != nil && client.RemindersOptOut
+}
+
+// queueReminder renders the level's template for the invoice and queues it in the outbox
+func (h *Handler) queueReminder(ctx context.Context, inv *invoice.Invoice, level reminder.Level, sender *reminder.Sender) (string, error) {
+	identity := h.senderIdentity(inv)
+	snd, err := h.resolveSender(inv.Provider.Id, identity, func() (string, *outbox.Credentials, error) {
+		if sender == nil || sender.Credentials == "" {
+			return "", nil, fmt.Errorf("no sender for reminders, save the reminder schedule while signed in")
+		}
+		creds, err := h.Outbox.OpenCredentials(sender.Credentials)
+		if err != nil {
+			return "", nil, fmt.Errorf("%v, save the reminder schedule again while signed in", err)
+		}
+		return sender.From, creds, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	job := &outbox.Job{
+		InvoiceID:     inv.ID,
+		Kind:          outbox.KindReminder,
+		ReminderLevel: level.ID,
+	}
+	snd.apply(job)
+
+	vars := emailtemplate.NewVars(inv)
+	if logo := h.providerLogo(inv); logo != nil {
+		vars["PROVIDER_LOGO"] = "cid:" + logo.ContentID
+	}
+	rendered, err := h.renderInvoiceEmail(inv, level.TemplateID, vars)
+	if err != nil {
+		return "", err
+	}
+	job.Message = types.EmailMessage{
+		Subject:    rendered.Subject,
+		Body:       rendered.Body,
+		HTMLBody:   rendered.HTMLBody,
+		TemplateID: rendered.TemplateID,
+	}
+	applyEmailDefaults(&job.Message, inv)
+	if len(job.Message.To) == 0 {
+		return "", fmt.Errorf("no recipients, set the invoice email target or client email")
+	}
+	applySenderIdentity(&job.Message, identity)
+	if err := finalizeRecipients(&job.Message, inv, job.From); err != nil {
+		return "", err
+	}
+
+	if err := h.Outbox.Enqueue(job, snd.Creds); err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+// handleGetReminderSchedule returns the payment reminder schedule
+// GET /api/v1/reminders/schedule
+func (h *Handler) handleGetReminderSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := h.Reminders.Schedule()
+	if err != nil {
+		writeRespErr(w, fmt.Sprintf("failed to read reminder schedule: %v", err), http.StatusInternalServerError)
+		slog.Error("failed to read reminder schedule", "url", r.RequestURI, "error", err)
+		return
+	}
+	writeRespOk(w, "reminder schedule", schedule.Public())
+}
+
+// handleUpdateReminderSchedule replaces the payment reminder schedule.
+// With OAuth2, the signed in user becomes the sender of all reminders.
+// PUT /api/v1/reminders/schedule
+func (h *Handler) handleUpdateReminderSchedule(w http.ResponseWriter, r *http.Request) {
+	logger := slog.With("url", r.RequestURI, "method", r.Method)
+
+	var schedule reminder.Schedule
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		writeRespErr(w, fmt.Sprintf("invalid reminder schedule: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	current, err := h.Reminders.Schedule()
+	if err != nil {
+		writeRespErr(w, fmt.Sprintf("failed to read reminder schedule: %v", err), http.StatusInternalServerError)
+		logger.Error("failed to read reminder schedule", "error", err)
+		return
+	}
+	schedule.Sender = current.Sender // never taken from the request
+
+	if h.EmailAuthMethod == auth.AuthMethodOAuth2 {
+		from, creds, err := sessionSender(r)
+		if err == nil {
+			sealed, err := h.Outbox.SealCredentials(creds)
+			if err != nil {
+				writeRespErr(w, "failed to store reminder sender", http.StatusInternalServerError)
+				logger.Error("failed to seal reminder sender credentials", "error", err)
+				return
+			}
+			schedule.Sender = &reminder.Sender{From: from, AuthMethod: auth.AuthMethodOAuth2, Credentials: sealed}
+		} else if !errors.Is(err, errNotLoggedIn) {
+			logger.Warn("failed to read session for reminder sender", "error", err)
+		}
+		if schedule.Enabled && schedule.Sender == nil {
+			writeRespErr(w, "sign in to the mailer before enabling reminders, they are sent from your account", http.StatusBadRequest)
+			return
+		}
+	}
+
+	for _, l := range schedule.Levels {
+		if _, err := os.Stat(filepath.Join(h.StorageDir.EmailTemplates, l.TemplateID+".json")); err != nil {
+			writeRespErr(w, fmt.Sprintf("email template '%s' of level '%s' not found", l.TemplateID, l.ID), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := h.Reminders.SetSchedule(&schedule); err != nil {
+		writeRespErr(w, fmt.Sprintf("invalid reminder schedule: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	writeRespOk(w, "reminder schedule updated", schedule.Public())
+	logger.Info("reminder schedule updated", "enabled", schedule.Enabled, "levels", len(schedule.Levels))
+}
+
+// handleRunReminders queues the reminders due today without waiting for the
+// background runner. With dry_run=true it only lists them.
+// POST /api/v1/reminders/run?dry_run=true
+func (h *Handler) handleRunReminders(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	results, err := h.Reminders.RunOnce(r.Context(), types.Today(), dryRun)
+	if errors.Is(err, reminder.ErrDisabled) {
+		writeRespErr(w, "payment reminders are disabled, enable the schedule or use dry_run=true", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeRespErr(w, fmt.Sprintf("failed to run reminders: %v", err), http.StatusInternalServerError)
+		slog.Error("failed to run reminders", "url", r.RequestURI, "error", err)
+		return
+	}
+	msg := fmt.Sprintf("%d reminders queued", len(results))
+	if dryRun {
+		msg = fmt.Sprintf("%d reminders due", len(results))
+	}
+	writeRespOk(w, msg, results)
+}
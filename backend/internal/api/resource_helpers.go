@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"go-invoice/internal/invoice"
+	"go-invoice/internal/query"
+	"go-invoice/internal/storage"
 	"go-invoice/internal/types"
 	"log/slog"
 	"net/http"
@@ -16,17 +18,35 @@ import (
 type resourceType string
 
 const (
-	InvoiceType  resourceType = "invoice"
-	ClientType   resourceType = "client"
-	ProviderType resourceType = "provider"
+	InvoiceType       resourceType = "invoice"
+	ClientType        resourceType = "client"
+	ProviderType      resourceType = "provider"
+	EmailTemplateType resourceType = "email template"
 )
 
-// ResourceData is an interface that all resource types (Client, Provider) must implement
+// ResourceData is an interface that all resource types (Client, Provider, EmailTemplate) must implement
 type ResourceData interface {
 	SetID(id string)
 	HasRequiredFields() bool
 }
 
+// resourceValidator is implemented by resources with fields to check beyond the required ones
+type resourceValidator interface {
+	Validate() error
+}
+
+// validateResource checks a resource implementing resourceValidator
+func validateResource(resource ResourceData) error {
+	if v, ok := resource.(resourceValidator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// resourceHook is called after a resource has been successfully written or deleted.
+// resource is nil for deletions.
+type resourceHook func(id string, resource ResourceData)
+
 // getResourceByID handles GET request for a single resource by ID
 func getResourceByID(
 	w http.ResponseWriter,
@@ -66,6 +86,7 @@ func updateResourceByID(
 	storageDir string,
 	resourceType resourceType,
 	newResource func() ResourceData,
+	onWrite resourceHook,
 ) {
 	logger := slog.With("url", r.RequestURI, "method", r.Method)
 	id := r.PathValue("id")
@@ -95,12 +116,19 @@ func updateResourceByID(
 		logger.Error("invalid resource data", "error", err)
 		return
 	}
+	if err := validateResource(resource); err != nil {
+		writeRespErr(w, fmt.Sprintf("invalid %s data for '%s': %v", resourceType, id, err), http.StatusBadRequest)
+		return
+	}
 
 	if err := writeJSON(filePath, resource, 2); err != nil {
 		writeRespErr(w, fmt.Sprintf("failed to update %s '%s'", resourceType, id), http.StatusInternalServerError)
 		logger.Error("failed to update resource", "error", err)
 		return
 	}
+	if onWrite != nil {
+		onWrite(id, resource)
+	}
 
 	writeRespOk(w, fmt.Sprintf("updated %s '%s'", resourceType, id), resource)
 }
@@ -111,6 +139,7 @@ func deleteResourceByID(
 	r *http.Request,
 	storageDir string,
 	resourceType resourceType,
+	onDelete resourceHook,
 ) {
 	logger := slog.With("url", r.RequestURI, "method", r.Method)
 	id := r.PathValue("id")
@@ -132,6 +161,9 @@ func deleteResourceByID(
 		}
 		return
 	}
+	if onDelete != nil {
+		onDelete(id, nil)
+	}
 
 	writeRespOk(w, fmt.Sprintf("deleted %s '%s'", resourceType, id), nil)
 }
@@ -167,6 +199,7 @@ func createResource(
 	storageDir string,
 	resourceType resourceType,
 	newResource func() ResourceData,
+	onWrite resourceHook,
 ) {
 	logger := slog.With("url", r.RequestURI, "method", r.Method)
 	if r.Body == nil || r.ContentLength == 0 {
@@ -187,6 +220,10 @@ func createResource(
 		logger.Error("incomplete resource data")
 		return
 	}
+	if err := validateResource(resource); err != nil {
+		writeRespErr(w, fmt.Sprintf("invalid %s data: %v", resourceType, err), http.StatusBadRequest)
+		return
+	}
 
 	// Extract ID from the resource, or generate from name
 	var tempData map[string]interface{}
@@ -195,7 +232,7 @@ func createResource(
 
 	var id string
 	switch resourceType {
-	case ProviderType, ClientType:
+	case ProviderType, ClientType, EmailTemplateType:
 		existingID, ok := tempData["id"].(string)
 		if !ok || existingID == "" {
 			// if ID is not provided, generate from name
@@ -211,16 +248,13 @@ func createResource(
 		}
 	case InvoiceType:
 		// id generation: INV-YYMMDDXX
-		dateStr := types.Today().Format("060102")
-		pattern := fmt.Sprintf("INV-%s*.json", dateStr)
-		jsonFiles, err := filepath.Glob(filepath.Join(storageDir, pattern))
-		if err != nil && !errors.Is(err, os.ErrNotExist) {
+		var err error
+		id, err = invoice.NextInvoiceID(storageDir, types.Today())
+		if err != nil {
 			writeRespErr(w, "failed to generate invoice ID", http.StatusInternalServerError)
 			logger.Error("failed to generate invoice ID", "error", err)
 			return
 		}
-		suffix := invoice.FindMaxSuffixFromFilename(jsonFiles) + 1
-		id = fmt.Sprintf("INV-%s%02d", dateStr, suffix)
 
 		// apply default email template if not set
 		inv, ok := resource.(*invoice.Invoice)
@@ -230,7 +264,7 @@ func createResource(
 			return
 		}
 		if inv.EmailTemplateID == "" {
-			inv.EmailTemplateID = "default"
+			inv.EmailTemplateID = storage.DefaultEmailTemplateID
 		}
 	default:
 		writeRespErr(w, "invalid resource type, this is likely an internal error", http.StatusInternalServerError)
@@ -259,10 +293,29 @@ func createResource(
 		logger.Error("failed to create resource", "error", err)
 		return
 	}
+	if onWrite != nil {
+		onWrite(id, resource)
+	}
 
 	writeRespWithStatus(w, fmt.Sprintf("created %s '%s'", resourceType, id), resource, http.StatusCreated)
 }
 
+// loadInvoice loads an invoice for an action handler. It writes the error response
+// and returns false if the invoice cannot be loaded.
+func (h *Handler) loadInvoice(w http.ResponseWriter, r *http.Request, id string) (*invoice.Invoice, bool) {
+	inv, err := invoice.LoadInvoice(h.StorageDir.Invoices, id)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			writeRespErr(w, fmt.Sprintf("invoice not found for '%s'", id), http.StatusNotFound)
+		} else {
+			writeRespErr(w, fmt.Sprintf("failed to read invoice '%s'", id), http.StatusInternalServerError)
+		}
+		slog.Error("failed to load invoice", "url", r.RequestURI, "invoice", id, "error", err)
+		return nil, false
+	}
+	return inv, true
+}
+
 // countResources counts the number of resource files in the given storage directory
 func countResources(storageDir string) (int, error) {
 	jsonFiles, err := filepath.Glob(filepath.Join(storageDir, "*.json"))
@@ -271,3 +324,68 @@ func countResources(storageDir string) (int, error) {
 	}
 	return len(jsonFiles), nil
 }
+
+// PaginatedProfiles represents a paginated response of client or provider profiles
+type PaginatedProfiles[T any] struct {
+	Items      []T `json:"items"`
+	Page       int `json:"page"`
+	PageSize   int `json:"page_size"`
+	TotalCount int `json:"total_count"`
+	TotalPages int `json:"total_pages"`
+}
+
+type profileResource interface {
+	identifiable
+	query.Profile
+}
+
+// listProfiles handles GET request for a filtered, sorted and paginated list of profiles.
+// party selects the side of an invoice that refers to this profile type, and is used
+// by the has_outstanding filter.
+func listProfiles[T profileResource](
+	w http.ResponseWriter,
+	r *http.Request,
+	storageDir string,
+	invoiceDir string,
+	resourceType resourceType,
+	party func(invoice.Invoice) invoice.Party,
+) {
+	logger := slog.With("url", r.RequestURI, "method", r.Method)
+	queryParams := query.ParseProfileQuery(r.URL.Query())
+
+	profiles, err := getAllProfiles[T](storageDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		writeRespErr(w, fmt.Sprintf("failed to list %s informations", resourceType), http.StatusInternalServerError)
+		logger.Error("failed to list resource informations", "error", err)
+		return
+	}
+
+	var outstanding map[string]int
+	if queryParams.HasOutstanding != nil {
+		invoices, err := getAllInvoices(invoiceDir, "*.json")
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			writeRespErr(w, "failed to list invoice informations", http.StatusInternalServerError)
+			logger.Error("failed to list invoices for outstanding filter", "error", err)
+			return
+		}
+		outstanding = query.CountOutstanding(invoices, party)
+	}
+
+	profiles = query.FilterProfiles(profiles, queryParams, outstanding)
+	query.SortProfiles(profiles, queryParams.Sort)
+
+	p := query.Paginate(len(profiles), queryParams.Page, queryParams.PageSize)
+	items := profiles[p.Start:p.End]
+	if items == nil {
+		items = []T{}
+	}
+
+	result := PaginatedProfiles[T]{
+		Items:      items,
+		Page:       p.Page,
+		PageSize:   queryParams.PageSize,
+		TotalCount: len(profiles),
+		TotalPages: p.TotalPages,
+	}
+	writeRespOk(w, fmt.Sprintf("list of %ss", resourceType), result)
+}
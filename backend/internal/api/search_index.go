@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/search"
+	"go-invoice/internal/types"
+	"log/slog"
+	"path/filepath"
+	"strings"
+)
+
+// BuildSearchIndex indexes all stored invoices for full-text search, skipping
+// files that cannot be read. The index lives in memory and is kept in sync on
+// every invoice write.
+func (h *Handler) BuildSearchIndex() error {
+	index := search.NewIndex()
+
+	jsonFiles, err := filepath.Glob(filepath.Join(h.StorageDir.Invoices, "*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list invoices: %w", err)
+	}
+	skipped := 0
+	for _, file := range jsonFiles {
+		var inv invoice.Invoice
+		if err := readJSON(file, &inv); err != nil {
+			// one corrupt file must not keep the server from starting
+			slog.Warn("invoice not indexed, failed to read it", "file", file, "error", err)
+			skipped++
+			continue
+		}
+		id := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		index.Put(id, &inv)
+	}
+
+	h.SearchIndex = index
+	slog.Info("search index built", "invoices", index.Len(), "skipped", skipped)
+	return nil
+}
+
+// indexInvoice keeps the search index in sync with invoice writes, see invoiceWritten
+func (h *Handler) indexInvoice(id string, resource ResourceData) {
+	if h.SearchIndex == nil {
+		return
+	}
+	inv, ok := resource.(*invoice.Invoice)
+	if !ok || inv == nil {
+		h.SearchIndex.Remove(id)
+		return
+	}
+	h.SearchIndex.Put(id, inv)
+}
+
+// saveInvoice persists an invoice, updates the search index and drops its cached PDFs
+func (h *Handler) saveInvoice(inv *invoice.Invoice) error {
+	if err := invoice.SaveInvoice(h.StorageDir.Invoices, inv); err != nil {
+		return err
+	}
+	h.invoiceWritten(inv.ID, inv)
+	return nil
+}
+
+// createInvoice stores a new invoice under the next free ID of date, see invoice.CreateInvoice
+func (h *Handler) createInvoice(inv *invoice.Invoice, date types.Date) error {
+	if err := invoice.CreateInvoice(h.StorageDir.Invoices, inv, date); err != nil {
+		return err
+	}
+	h.invoiceWritten(inv.ID, inv)
+	return nil
+}
@@ -0,0 +1,258 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-invoice/internal/auth"
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/outbox"
+	"go-invoice/internal/services"
+	"go-invoice/internal/storage"
+	"go-invoice/internal/types"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// errSendingDisabled is returned by resolveSender when no transport or credentials are configured
+	errSendingDisabled = errors.New("email sending is not configured")
+	// errAccountNotSignedIn is returned when no credentials are stored for the account of a sender identity
+	errAccountNotSignedIn = errors.New("sender account is not signed in")
+)
+
+// sender is who an invoice email is sent from and how it is delivered
+type sender struct {
+	From       string
+	Login      string // SMTP login, differs from From when sending from an alias
+	FromName   string
+	Transport  services.Transport // empty uses MAIL_TRANSPORT
+	AuthMethod auth.AuthMethod
+	Creds      *outbox.Credentials // OAuth2 only
+}
+
+// oauth2Sender returns the OAuth2 account to send with when the identity names none,
+// e.g. the signed in user for emails sent from the browser
+type oauth2Sender func() (string, *outbox.Credentials, error)
+
+// resolveSender combines the sender identity of the provider, which may be nil, with the
+// server settings. Without an identity the sender is SMTP_FROM or the OAuth2 account
+// returned by fallback.
+func (h *Handler) resolveSender(providerID string, identity *storage.SenderIdentity, fallback oauth2Sender) (*sender, error) {
+	s := &sender{AuthMethod: h.EmailAuthMethod}
+	if identity != nil && identity.Transport != "" {
+		transport, err := services.ParseTransport(identity.Transport)
+		if err != nil {
+			return nil, err
+		}
+		s.Transport = transport
+		switch {
+		case transport != services.TransportSMTP:
+			s.AuthMethod = auth.AuthMethodLocal
+		case s.AuthMethod == auth.AuthMethodLocal || s.AuthMethod == auth.AuthMethodNone:
+			// the server default delivers locally, SMTP needs the password
+			s.AuthMethod = auth.AuthMethodPlain
+		}
+	}
+
+	switch s.AuthMethod {
+	case auth.AuthMethodNone:
+		return nil, errSendingDisabled
+	case auth.AuthMethodPlain:
+		// the password is read from the environment on delivery and never queued
+		if strings.TrimSpace(os.Getenv("SMTP_PASSWORD")) == "" {
+			return nil, fmt.Errorf("incomplete SMTP configuration, SMTP_PASSWORD is not set")
+		}
+		s.Login = strings.TrimSpace(os.Getenv("SMTP_FROM"))
+		s.From = s.Login
+	case auth.AuthMethodLocal:
+		s.From = strings.TrimSpace(os.Getenv("SMTP_FROM"))
+	case auth.AuthMethodOAuth2:
+		// the worker refreshes the token when it expires before delivery
+		var err error
+		if identity != nil && identity.Account != "" {
+			s.From, s.Creds, err = h.accountSender(providerID, identity.Account)
+		} else {
+			s.From, s.Creds, err = fallback()
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.Login = s.From
+	default:
+		return nil, fmt.Errorf("unsupported auth method '%s'", s.AuthMethod)
+	}
+
+	// the identity's address only changes the From header and envelope, the login stays the account's
+	if identity != nil {
+		if identity.FromEmail != "" {
+			s.From = identity.FromEmail
+		}
+		s.FromName = identity.FromName
+	}
+	if s.From == "" {
+		return nil, fmt.Errorf("incomplete mail configuration, SMTP_FROM is not set")
+	}
+	return s, nil
+}
+
+// apply sets the sender of the job
+func (s *sender) apply(job *outbox.Job) {
+	job.From = s.From
+	job.Login = s.Login
+	job.FromName = s.FromName
+	job.Transport = string(s.Transport)
+	job.AuthMethod = s.AuthMethod
+}
+
+// senderAccount is the OAuth2 account a provider sends from, stored when the
+// account owner saves the provider
+type senderAccount struct {
+	Account     string `json:"account"`
+	Credentials string `json:"credentials"` // sealed, see outbox.SealCredentials
+}
+
+// senderAccountPath returns the file of a provider's sender account credentials
+func (h *Handler) senderAccountPath(providerID string) string {
+	return filepath.Join(h.StorageDir.SenderAccounts, filepath.Base(providerID)+".json")
+}
+
+// accountSender returns the credentials stored for the sender account of a provider.
+// This lets a provider send from a shared mailbox whoever sends the invoice.
+func (h *Handler) accountSender(providerID, account string) (string, *outbox.Credentials, error) {
+	var stored senderAccount
+	err := readJSON(h.senderAccountPath(providerID), &stored)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", nil, err
+	}
+	if err != nil || !strings.EqualFold(stored.Account, account) {
+		return "", nil, fmt.Errorf("%w, sign in as '%s' and save provider '%s'", errAccountNotSignedIn, account, providerID)
+	}
+	creds, err := h.Outbox.OpenCredentials(stored.Credentials)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w, %v, sign in as '%s' and save provider '%s' again", errAccountNotSignedIn, err, account, providerID)
+	}
+	return stored.Account, creds, nil
+}
+
+// senderAccountHook checks the sender account of a provider saved by the request.
+// When the signed in user is the account, the returned hook stores its credentials
+// once the provider is written. Others can save the provider while the credentials
+// stored for the account are kept. It writes the error response and returns false
+// when the account has no credentials and is not the signed in user.
+func (h *Handler) senderAccountHook(w http.ResponseWriter, r *http.Request, providerID string) (resourceHook, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeRespErr(w, "failed to read request body", http.StatusBadRequest)
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var provider storage.ProviderData
+	if err := json.Unmarshal(body, &provider); err != nil {
+		return nil, true // reported when the provider is decoded
+	}
+	var account string
+	if provider.Sender != nil {
+		account = strings.TrimSpace(provider.Sender.Account)
+	}
+	if account == "" {
+		return h.deleteSenderAccount, true
+	}
+	if h.EmailAuthMethod != auth.AuthMethodOAuth2 {
+		return nil, true
+	}
+
+	from, creds, err := sessionSender(r)
+	if err == nil && strings.EqualFold(from, account) {
+		sealed, err := h.Outbox.SealCredentials(creds)
+		if err != nil {
+			writeRespErr(w, "failed to store sender account", http.StatusInternalServerError)
+			slog.Error("failed to seal sender account credentials", "error", err)
+			return nil, false
+		}
+		return func(id string, _ ResourceData) {
+			if err := writeJSON(h.senderAccountPath(id), &senderAccount{Account: from, Credentials: sealed}, 2); err != nil {
+				slog.Error("failed to store sender account", "provider", id, "error", err)
+			}
+		}, true
+	}
+	if err != nil && !errors.Is(err, errNotLoggedIn) {
+		slog.Warn("failed to read session for sender account", "error", err)
+	}
+	if providerID != "" {
+		if _, _, err := h.accountSender(providerID, account); err == nil {
+			return nil, true
+		}
+	}
+	writeRespErr(w, fmt.Sprintf("sign in as '%s' to send from its account, its credentials are stored when it saves the provider", account), http.StatusBadRequest)
+	return nil, false
+}
+
+// deleteSenderAccount removes the stored sender account credentials of a provider
+func (h *Handler) deleteSenderAccount(id string, _ ResourceData) {
+	if err := os.Remove(h.senderAccountPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("failed to delete sender account", "provider", id, "error", err)
+	}
+}
+
+// invoiceProvider loads the stored profile of the invoice's provider.
+// It returns nil when the invoice has no provider or the profile cannot be read.
+func (h *Handler) invoiceProvider(inv *invoice.Invoice) *storage.ProviderData {
+	if inv.Provider.Id == "" {
+		return nil
+	}
+	provider, err := storage.LoadProviderData(h.StorageDir.Providers, inv.Provider.Id)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("failed to load provider", "provider", inv.Provider.Id, "error", err)
+		}
+		return nil
+	}
+	return provider
+}
+
+// senderIdentity returns the sender identity of the invoice's provider, nil when it has none
+func (h *Handler) senderIdentity(inv *invoice.Invoice) *storage.SenderIdentity {
+	if provider := h.invoiceProvider(inv); provider != nil {
+		return provider.Sender
+	}
+	return nil
+}
+
+// applySenderIdentity fills in the identity's reply-to when the message has none
+// and appends its signature
+func applySenderIdentity(msg *types.EmailMessage, identity *storage.SenderIdentity) {
+	if identity == nil {
+		return
+	}
+	if msg.ReplyTo == "" {
+		msg.ReplyTo = identity.ReplyTo
+	}
+	appendSignature(msg, identity.Signature)
+}
+
+// appendSignature adds a signature below the plain text and HTML bodies,
+// unless the body already contains it, e.g. from the template
+func appendSignature(msg *types.EmailMessage, signature string) {
+	signature = strings.TrimSpace(strings.ReplaceAll(signature, "\r\n", "\n"))
+	if signature == "" || strings.Contains(msg.Body, signature) {
+		return
+	}
+	msg.Body = strings.TrimRight(msg.Body, "\n") + "\n\n-- \n" + signature
+	if msg.HTMLBody == "" {
+		return
+	}
+	block := `<p class="signature">-- <br>` + strings.ReplaceAll(html.EscapeString(signature), "\n", "<br>") + "</p>"
+	if i := strings.LastIndex(strings.ToLower(msg.HTMLBody), "</body>"); i >= 0 {
+		msg.HTMLBody = msg.HTMLBody[:i] + block + msg.HTMLBody[i:]
+	} else {
+		msg.HTMLBody += block
+	}
+}
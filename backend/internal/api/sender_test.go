@@ -0,0 +1,107 @@
+package api
+
+import (
+	"errors"
+	"go-invoice/internal/auth"
+	"go-invoice/internal/outbox"
+	"go-invoice/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResolveSender(t *testing.T) {
+	t.Setenv("SMTP_FROM", "me@example.com")
+	t.Setenv("SMTP_PASSWORD", "secret")
+	account := func() (string, *outbox.Credentials, error) {
+		return "owner@example.com", &outbox.Credentials{AccessToken: "token"}, nil
+	}
+	alias := &storage.SenderIdentity{FromName: "Billing", FromEmail: "billing@example.com"}
+	tests := []struct {
+		name      string
+		method    auth.AuthMethod
+		identity  *storage.SenderIdentity
+		wantFrom  string
+		wantLogin string
+	}{
+		{"plain", auth.AuthMethodPlain, nil, "me@example.com", "me@example.com"},
+		{"plain alias", auth.AuthMethodPlain, alias, "billing@example.com", "me@example.com"},
+		{"oauth2", auth.AuthMethodOAuth2, nil, "owner@example.com", "owner@example.com"},
+		{"oauth2 alias", auth.AuthMethodOAuth2, alias, "billing@example.com", "owner@example.com"},
+		{"local alias", auth.AuthMethodLocal, alias, "billing@example.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{EmailAuthMethod: tt.method}
+			s, err := h.resolveSender("acme", tt.identity, account)
+			if err != nil {
+				t.Fatalf("resolveSender() error = %v", err)
+			}
+			var job outbox.Job
+			s.apply(&job)
+			if job.From != tt.wantFrom || job.Login != tt.wantLogin {
+				t.Errorf("job from %q with login %q, want from %q with login %q", job.From, job.Login, tt.wantFrom, tt.wantLogin)
+			}
+			if tt.identity != nil && job.FromName != tt.identity.FromName {
+				t.Errorf("job from name = %q, want %q", job.FromName, tt.identity.FromName)
+			}
+		})
+	}
+}
+
+func TestResolveSender_Account(t *testing.T) {
+	storageDir, err := storage.NewStorageDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ob, err := outbox.New(storageDir.Outbox, []byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{StorageDir: *storageDir, Outbox: ob, EmailAuthMethod: auth.AuthMethodOAuth2}
+	identity := &storage.SenderIdentity{FromEmail: "billing@example.com", Account: "shared@example.com"}
+	signedIn := func() (string, *outbox.Credentials, error) {
+		return "someone@example.com", &outbox.Credentials{AccessToken: "someone"}, nil
+	}
+
+	// the signed in user is never used for the account
+	if _, err := h.resolveSender("acme", identity, signedIn); !errors.Is(err, errAccountNotSignedIn) {
+		t.Fatalf("resolveSender() without stored credentials error = %v, want errAccountNotSignedIn", err)
+	}
+	saveProvider := func() bool {
+		body := `{"id":"acme","name":"Acme","sender":{"account":"shared@example.com"}}`
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/providers/acme", strings.NewReader(body))
+		_, ok := h.senderAccountHook(httptest.NewRecorder(), req, "acme")
+		return ok
+	}
+	if saveProvider() {
+		t.Error("saving a provider for an account without credentials should fail when signed out")
+	}
+
+	sealed, err := ob.SealCredentials(&outbox.Credentials{AccessToken: "shared"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJSON(h.senderAccountPath("acme"), &senderAccount{Account: "shared@example.com", Credentials: sealed}, 2); err != nil {
+		t.Fatal(err)
+	}
+	s, err := h.resolveSender("acme", identity, signedIn)
+	if err != nil {
+		t.Fatalf("resolveSender() error = %v", err)
+	}
+	if s.From != "billing@example.com" || s.Login != "shared@example.com" || s.Creds.AccessToken != "shared" {
+		t.Errorf("sender from %q with login %q and token %q, want the alias sent with the stored account", s.From, s.Login, s.Creds.AccessToken)
+	}
+	if !saveProvider() {
+		t.Error("saving a provider should keep the credentials stored for its account")
+	}
+	if _, err := h.resolveSender("other", identity, signedIn); !errors.Is(err, errAccountNotSignedIn) {
+		t.Errorf("resolveSender() of another provider error = %v, want errAccountNotSignedIn", err)
+	}
+
+	h.deleteSenderAccount("acme", nil)
+	if _, err := h.resolveSender("acme", identity, signedIn); !errors.Is(err, errAccountNotSignedIn) {
+		t.Errorf("resolveSender() after the provider is deleted error = %v, want errAccountNotSignedIn", err)
+	}
+}
@@ -1,9 +1,26 @@
 package auth
 
+import (
+	"fmt"
+	"strings"
+)
+
 type AuthMethod string
 
 const (
 	AuthMethodNone   AuthMethod = "none"
 	AuthMethodOAuth2 AuthMethod = "oauth2"
 	AuthMethodPlain  AuthMethod = "plain"
+	AuthMethodLocal  AuthMethod = "local" // no login, used by the sendmail and file transports
 )
+
+// ParseAuthMethod parses a MAIL_AUTH value: plain, oauth2 or none.
+// Empty returns an empty method, left to be inferred from the credentials.
+func ParseAuthMethod(value string) (AuthMethod, error) {
+	switch m := AuthMethod(strings.ToLower(strings.TrimSpace(value))); m {
+	case "", AuthMethodPlain, AuthMethodOAuth2, AuthMethodNone:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid mail auth method '%s', expected plain, oauth2 or none", value)
+	}
+}
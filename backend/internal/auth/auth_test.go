@@ -0,0 +1,24 @@
+package auth
+
+import "testing"
+
+func TestParseAuthMethod(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    AuthMethod
+		wantErr bool
+	}{
+		{"", "", false},
+		{"plain", AuthMethodPlain, false},
+		{" OAuth2 ", AuthMethodOAuth2, false},
+		{"none", AuthMethodNone, false},
+		{"local", "", true},
+		{"xoauth2", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAuthMethod(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseAuthMethod(%q) = %q, %v, want %q, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
+)
+
+// microsoftProvider implements goth.Provider for the Microsoft identity platform.
+// goth's microsoftonline provider reads the profile from Microsoft Graph, which
+// an access token for Outlook SMTP cannot call, so the user is read from the
+// ID token returned alongside it instead.
+type microsoftProvider struct {
+	name     string
+	provider *Provider
+}
+
+func newMicrosoftProvider(p *Provider) *microsoftProvider {
+	return &microsoftProvider{name: p.Name, provider: p}
+}
+
+func (m *microsoftProvider) Name() string        { return m.name }
+func (m *microsoftProvider) SetName(name string) { m.name = name }
+func (m *microsoftProvider) Debug(bool)          {}
+
+func (m *microsoftProvider) BeginAuth(state string) (goth.Session, error) {
+	return &microsoftSession{AuthURL: m.provider.AuthCodeURL(state)}, nil
+}
+
+func (m *microsoftProvider) UnmarshalSession(data string) (goth.Session, error) {
+	s := &microsoftSession{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
+	return s, err
+}
+
+func (m *microsoftProvider) FetchUser(session goth.Session) (goth.User, error) {
+	s := session.(*microsoftSession)
+	user := goth.User{
+		Provider:     m.name,
+		AccessToken:  s.AccessToken,
+		RefreshToken: s.RefreshToken,
+		ExpiresAt:    s.ExpiresAt,
+		IDToken:      s.IDToken,
+	}
+	if s.AccessToken == "" {
+		return user, fmt.Errorf("%s cannot get user information without accessToken", m.name)
+	}
+
+	claims, err := decodeIDToken(s.IDToken)
+	if err != nil {
+		return user, err
+	}
+	user.UserID = claims.ObjectID
+	user.Name = claims.Name
+	user.Email = claims.Email
+	if user.Email == "" {
+		// work and school accounts often have no email claim, their sign-in name is the address
+		user.Email = claims.PreferredUsername
+	}
+	if user.Email == "" {
+		return user, errors.New("microsoft ID token has no email address")
+	}
+	return user, nil
+}
+
+func (m *microsoftProvider) RefreshTokenAvailable() bool { return true }
+
+func (m *microsoftProvider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return m.provider.TokenSource(goth.ContextForClient(nil), &oauth2.Token{RefreshToken: refreshToken}).Token()
+}
+
+// microsoftSession is the goth.Session of a Microsoft login
+type microsoftSession struct {
+	AuthURL      string
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+	IDToken      string
+}
+
+func (s *microsoftSession) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+func (s *microsoftSession) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	m := provider.(*microsoftProvider)
+	token, err := m.provider.Config.Exchange(goth.ContextForClient(nil), params.Get("code"))
+	if err != nil {
+		return "", err
+	}
+	if !token.Valid() {
+		return "", errors.New("invalid token received from provider")
+	}
+	s.AccessToken = token.AccessToken
+	s.RefreshToken = token.RefreshToken
+	s.ExpiresAt = token.Expiry
+	s.IDToken, _ = token.Extra("id_token").(string)
+	return token.AccessToken, nil
+}
+
+func (s *microsoftSession) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// idTokenClaims are the ID token claims used to identify the user
+type idTokenClaims struct {
+	ObjectID          string `json:"oid"`
+	Name              string `json:"name"`
+	Email             string `json:"email"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// decodeIDToken reads the claims of an ID token. The signature is not verified:
+// the token comes straight from the token endpoint over TLS, which OpenID Connect
+// accepts in place of validation for the authorization code flow.
+func decodeIDToken(idToken string) (*idTokenClaims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("microsoft login returned no valid ID token, is the openid scope granted?")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token payload: %w", err)
+	}
+	var claims idTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("invalid ID token claims: %w", err)
+	}
+	return &claims, nil
+}
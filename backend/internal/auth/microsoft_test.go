@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func testIDToken(t *testing.T, claims map[string]string) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func TestMicrosoftFetchUser(t *testing.T) {
+	tests := []struct {
+		name      string
+		claims    map[string]string
+		wantEmail string
+		errPart   string
+	}{
+		{
+			name:      "email claim",
+			claims:    map[string]string{"oid": "1", "name": "Jane Smith", "email": "jane@contoso.com", "preferred_username": "jsmith@contoso.onmicrosoft.com"},
+			wantEmail: "jane@contoso.com",
+		},
+		{
+			name:      "work account without email claim",
+			claims:    map[string]string{"oid": "1", "name": "Jane Smith", "preferred_username": "jane@contoso.com"},
+			wantEmail: "jane@contoso.com",
+		},
+		{
+			name:    "no address",
+			claims:  map[string]string{"oid": "1"},
+			errPart: "no email address",
+		},
+	}
+
+	p := newMicrosoftProvider(&Provider{Name: ProviderMicrosoft})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := p.FetchUser(&microsoftSession{AccessToken: "token", RefreshToken: "refresh", IDToken: testIDToken(t, tt.claims)})
+			if tt.errPart != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errPart) {
+					t.Fatalf("FetchUser() error = %v, want error containing %q", err, tt.errPart)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchUser() error = %v", err)
+			}
+			if user.Email != tt.wantEmail || user.Name != "Jane Smith" || user.RefreshToken != "refresh" || user.Provider != ProviderMicrosoft {
+				t.Errorf("FetchUser() = %+v", user)
+			}
+		})
+	}
+}
+
+func TestMicrosoftFetchUser_InvalidIDToken(t *testing.T) {
+	p := newMicrosoftProvider(&Provider{Name: ProviderMicrosoft})
+	if _, err := p.FetchUser(&microsoftSession{AccessToken: "token", IDToken: "not-a-jwt"}); err == nil {
+		t.Error("FetchUser() error = nil, want an invalid ID token error")
+	}
+}
+
+func TestProviderRegistry(t *testing.T) {
+	ConfigureMicrosoftOAuth2("client", "secret", "contoso.onmicrosoft.com", "http://localhost/callback")
+	p, err := GetProvider(ProviderMicrosoft)
+	if err != nil {
+		t.Fatalf("GetProvider() error = %v", err)
+	}
+	if p.SMTPHost != "smtp.office365.com" || p.SMTPPort != 587 {
+		t.Errorf("SMTP defaults = %s:%d", p.SMTPHost, p.SMTPPort)
+	}
+	url := p.AuthCodeURL("state")
+	for _, want := range []string{"login.microsoftonline.com/contoso.onmicrosoft.com/", "SMTP.Send", "offline_access", "prompt=select_account"} {
+		if !strings.Contains(url, want) {
+			t.Errorf("AuthCodeURL() = %s, want it to contain %q", url, want)
+		}
+	}
+	if _, err := GetProvider("yahoo"); err == nil {
+		t.Error("GetProvider() of an unconfigured provider error = nil")
+	}
+}
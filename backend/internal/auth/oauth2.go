@@ -8,6 +8,8 @@ import (
 	"github.com/markbates/goth/gothic"
 	"github.com/markbates/goth/providers/google"
 	"golang.org/x/oauth2"
+	googleendpoint "golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/microsoft"
 )
 
 type SessionConfig struct {
@@ -16,12 +18,9 @@ type SessionConfig struct {
 	IsProd bool
 }
 
-var GoogleOAuthConfig *oauth2.Config
-
-func ConfigureGoogleOAuth2(clientId, clientSecret, callbackURL string, config SessionConfig) {
-	store := sessions.NewCookieStore(config.Key) // TODO: use file store or redis store for production
-	store.MaxAge(config.MaxAge)
-
+// ConfigureSession sets up the session store shared by all OAuth2 providers.
+// Sessions are kept encrypted in dir, see FileSessionStore.
+func ConfigureSession(config SessionConfig, dir string) (*FileSessionStore, error) {
 	var sameSite http.SameSite
 	if config.IsProd {
 		sameSite = http.SameSiteNoneMode
@@ -29,34 +28,72 @@ func ConfigureGoogleOAuth2(clientId, clientSecret, callbackURL string, config Se
 		sameSite = http.SameSiteLaxMode
 	}
 
-	store.Options = &sessions.Options{
+	store, err := NewFileSessionStore(dir, config.Key, &sessions.Options{
 		Path:     "/",
 		MaxAge:   config.MaxAge,
 		HttpOnly: true,
 		SameSite: sameSite,
 		Secure:   config.IsProd,
+	})
+	if err != nil {
+		return nil, err
 	}
 	gothic.Store = store
+	return store, nil
+}
 
-	GoogleOAuthConfig = &oauth2.Config{
-		ClientID:     clientId,
-		ClientSecret: clientSecret,
-		RedirectURL:  callbackURL,
-		Endpoint:     google.Endpoint,
-		Scopes: []string{
-			"profile",
-			"email",
-			"https://mail.google.com/", // SMTP access scope (required for OAuth2 SMTP)
-		},
+// ConfigureGoogleOAuth2 registers Google as the "google" provider
+func ConfigureGoogleOAuth2(clientId, clientSecret, callbackURL string) {
+	scopes := []string{
+		"profile",
+		"email",
+		"https://mail.google.com/", // SMTP access scope (required for OAuth2 SMTP)
 	}
+	RegisterProvider(&Provider{
+		Name:        ProviderGoogle,
+		DisplayName: "Google",
+		Config: &oauth2.Config{
+			ClientID:     clientId,
+			ClientSecret: clientSecret,
+			RedirectURL:  callbackURL,
+			Endpoint:     googleendpoint.Endpoint,
+			Scopes:       scopes,
+		},
+		AuthCodeOptions: []oauth2.AuthCodeOption{
+			oauth2.SetAuthURLParam("prompt", "select_account"),
+			oauth2.AccessTypeOffline,
+		},
+		SMTPHost: "smtp.gmail.com",
+		SMTPPort: 587,
+	})
+	goth.UseProviders(google.New(clientId, clientSecret, callbackURL, scopes...))
+}
 
-	goth.UseProviders(
-		google.New(
-			clientId, clientSecret, callbackURL,
-			// scopes
-			"profile",
-			"email",
-			"https://mail.google.com/", // SMTP access scope (required for OAuth2 SMTP)
-		),
-	)
+// ConfigureMicrosoftOAuth2 registers Microsoft 365 / Outlook.com as the "microsoft" provider.
+// tenant is a directory ID or domain, or "common" to allow any work, school or personal account.
+func ConfigureMicrosoftOAuth2(clientId, clientSecret, tenant, callbackURL string) {
+	p := &Provider{
+		Name:        ProviderMicrosoft,
+		DisplayName: "Microsoft",
+		Config: &oauth2.Config{
+			ClientID:     clientId,
+			ClientSecret: clientSecret,
+			RedirectURL:  callbackURL,
+			Endpoint:     microsoft.AzureADEndpoint(tenant),
+			Scopes: []string{
+				"openid",
+				"email",
+				"profile",
+				"offline_access", // refresh token
+				"https://outlook.office.com/SMTP.Send",
+			},
+		},
+		AuthCodeOptions: []oauth2.AuthCodeOption{
+			oauth2.SetAuthURLParam("prompt", "select_account"),
+		},
+		SMTPHost: "smtp.office365.com",
+		SMTPPort: 587,
+	}
+	RegisterProvider(p)
+	goth.UseProviders(newMicrosoftProvider(p))
 }
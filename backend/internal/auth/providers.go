@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	ProviderGoogle    = "google"
+	ProviderMicrosoft = "microsoft"
+)
+
+// ErrUnknownProvider is returned for a provider that is not configured
+var ErrUnknownProvider = errors.New("unknown oauth2 provider")
+
+// Provider is an OAuth2 identity provider whose access tokens can
+// authenticate SMTP with XOAUTH2
+type Provider struct {
+	Name            string                  // used in routes and sessions, e.g. "google"
+	DisplayName     string                  // shown at login, e.g. "Google"
+	Config          *oauth2.Config          // client, endpoints and scopes including SMTP access
+	AuthCodeOptions []oauth2.AuthCodeOption // extra parameters for the consent page
+	SMTPHost        string                  // default SMTP server for accounts of this provider
+	SMTPPort        int
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]*Provider)
+)
+
+// RegisterProvider makes a provider available for login, replacing one with the same name
+func RegisterProvider(p *Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[p.Name] = p
+}
+
+// GetProvider returns a registered provider
+func GetProvider(name string) (*Provider, error) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w '%s'", ErrUnknownProvider, name)
+	}
+	return p, nil
+}
+
+// Providers returns the registered providers sorted by name
+func Providers() []*Provider {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	list := make([]*Provider, 0, len(providers))
+	for _, p := range providers {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// AuthCodeURL returns the consent page URL for a login with the given state
+func (p *Provider) AuthCodeURL(state string) string {
+	return p.Config.AuthCodeURL(state, p.AuthCodeOptions...)
+}
+
+// TokenSource returns a token source refreshing token when it has expired
+func (p *Provider) TokenSource(ctx context.Context, token *oauth2.Token) oauth2.TokenSource {
+	return p.Config.TokenSource(ctx, token)
+}
@@ -0,0 +1,350 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-invoice/internal/crypto"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// ErrSessionNotFound is returned when revoking a session that does not exist
+var ErrSessionNotFound = errors.New("session not found")
+
+// FileSessionStore is a sessions.Store keeping session values on the server,
+// one file per session encrypted with AES-GCM. The cookie only carries a
+// signed random session ID, so a restart with the same key keeps everyone
+// signed in and tokens never reach the browser.
+type FileSessionStore struct {
+	Options *sessions.Options // default options of new sessions
+
+	dir    string
+	key    []byte // encrypts the stored values
+	codecs []securecookie.Codec
+	mu     sync.Mutex
+}
+
+// storedSession is the file content of a session
+type storedSession struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Values    string    `json:"values"` // gob encoded values sealed with the store key
+}
+
+// SessionInfo describes a stored session. Handle identifies the session for
+// Revoke without revealing its ID, which would allow taking the session over.
+type SessionInfo struct {
+	Handle    string
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ExpiresAt time.Time
+	UserAgent string
+	Values    map[interface{}]interface{}
+}
+
+// NewFileSessionStore creates a store in dir. secret is used to derive both the
+// cookie signing key and the encryption key, it must stay the same across restarts.
+func NewFileSessionStore(dir string, secret []byte, options *sessions.Options) (*FileSessionStore, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("session secret is empty")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	codec := securecookie.New(crypto.DeriveKey(append([]byte("session-cookie:"), secret...)), nil)
+	codec.MaxAge(options.MaxAge)
+	return &FileSessionStore{
+		Options: options,
+		dir:     dir,
+		key:     crypto.DeriveKey(append([]byte("session-values:"), secret...)),
+		codecs:  []securecookie.Codec{codec},
+	}, nil
+}
+
+// SessionHandle returns the handle of the session with the given ID
+func SessionHandle(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns a cached session for the request, see sessions.Store
+func (s *FileSessionStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session named in the request cookie. A missing, invalid or
+// expired cookie yields a new empty session rather than an error, so that
+// cookies from an older key or store do not lock the user out.
+func (s *FileSessionStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var id string
+	if err := securecookie.DecodeMulti(name, c.Value, &id, s.codecs...); err != nil {
+		return session, nil
+	}
+
+	s.mu.Lock()
+	stored, err := s.read(SessionHandle(id))
+	s.mu.Unlock()
+	if err != nil || stored.Name != name || time.Now().After(stored.ExpiresAt) {
+		return session, nil
+	}
+	values, err := s.decodeValues(stored.Values)
+	if err != nil {
+		slog.Warn("discarding unreadable session", "error", err)
+		return session, nil
+	}
+	session.ID = id
+	session.Values = values
+	session.IsNew = false
+	return session, nil
+}
+
+// Save writes the session file and the cookie. A negative MaxAge deletes both.
+func (s *FileSessionStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := os.Remove(s.path(SessionHandle(session.ID))); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to delete session: %w", err)
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	now := time.Now()
+	stored := &storedSession{Name: session.Name(), CreatedAt: now}
+	if session.ID == "" {
+		id, err := crypto.GenerateSecureString(32)
+		if err != nil {
+			return err
+		}
+		session.ID = id
+	} else if existing, err := s.read(SessionHandle(session.ID)); err == nil {
+		stored.CreatedAt = existing.CreatedAt
+	}
+	stored.UpdatedAt = now
+	stored.ExpiresAt = now.Add(time.Duration(session.Options.MaxAge) * time.Second)
+	stored.UserAgent = r.UserAgent()
+
+	values, err := s.encodeValues(session.Values)
+	if err != nil {
+		return err
+	}
+	stored.Values = values
+	if err := s.write(SessionHandle(session.ID), stored); err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return fmt.Errorf("failed to encode session cookie: %w", err)
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// List returns the sessions that have not expired, most recently used first
+func (s *FileSessionStore) List() ([]SessionInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	handles, err := s.handles()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var list []SessionInfo
+	for _, handle := range handles {
+		stored, err := s.read(handle)
+		if err != nil || now.After(stored.ExpiresAt) {
+			continue
+		}
+		values, err := s.decodeValues(stored.Values)
+		if err != nil {
+			continue
+		}
+		list = append(list, SessionInfo{
+			Handle:    handle,
+			Name:      stored.Name,
+			CreatedAt: stored.CreatedAt,
+			UpdatedAt: stored.UpdatedAt,
+			ExpiresAt: stored.ExpiresAt,
+			UserAgent: stored.UserAgent,
+			Values:    values,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UpdatedAt.After(list[j].UpdatedAt) })
+	return list, nil
+}
+
+// Revoke deletes the session with the given handle, signing it out
+func (s *FileSessionStore) Revoke(handle string) error {
+	if !validHandle(handle) {
+		return ErrSessionNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(handle)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// Cleanup deletes expired and unreadable sessions and returns how many were removed
+func (s *FileSessionStore) Cleanup(now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	handles, err := s.handles()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, handle := range handles {
+		stored, err := s.read(handle)
+		if err == nil && !now.After(stored.ExpiresAt) {
+			continue
+		}
+		if err := os.Remove(s.path(handle)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, fmt.Errorf("failed to delete expired session: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// RunCleanup calls Cleanup every interval until ctx is done
+func (s *FileSessionStore) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			removed, err := s.Cleanup(now)
+			if err != nil {
+				slog.Error("failed to clean up sessions", "error", err)
+			} else if removed > 0 {
+				slog.Info("removed expired sessions", "count", removed)
+			}
+		}
+	}
+}
+
+func (s *FileSessionStore) path(handle string) string {
+	return filepath.Join(s.dir, handle+".json")
+}
+
+// handles lists the handles of all session files
+func (s *FileSessionStore) handles() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session directory: %w", err)
+	}
+	var handles []string
+	for _, e := range entries {
+		handle, ok := strings.CutSuffix(e.Name(), ".json")
+		if ok && !e.IsDir() && validHandle(handle) {
+			handles = append(handles, handle)
+		}
+	}
+	return handles, nil
+}
+
+func (s *FileSessionStore) read(handle string) (*storedSession, error) {
+	data, err := os.ReadFile(s.path(handle))
+	if err != nil {
+		return nil, err
+	}
+	var stored storedSession
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("invalid session file: %w", err)
+	}
+	return &stored, nil
+}
+
+// write replaces the session file atomically, readable by the owner only
+func (s *FileSessionStore) write(handle string, stored *storedSession) error {
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.dir, ".session-*")
+	if err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(handle)); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	return nil
+}
+
+func (s *FileSessionStore) encodeValues(values map[interface{}]interface{}) (string, error) {
+	data, err := securecookie.GobEncoder{}.Serialize(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session values: %w", err)
+	}
+	return crypto.Seal(s.key, data)
+}
+
+func (s *FileSessionStore) decodeValues(sealed string) (map[interface{}]interface{}, error) {
+	data, err := crypto.Open(s.key, sealed)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[interface{}]interface{})
+	if err := (securecookie.GobEncoder{}).Deserialize(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to decode session values: %w", err)
+	}
+	return values, nil
+}
+
+// validHandle reports whether handle is a hex encoded SHA-256, so it is safe in a path
+func validHandle(handle string) bool {
+	if len(handle) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(handle)
+	return err == nil
+}
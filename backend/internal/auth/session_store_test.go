@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func newTestSessionStore(t *testing.T, dir string, secret string) *FileSessionStore {
+	t.Helper()
+	store, err := NewFileSessionStore(dir, []byte(secret), &sessions.Options{Path: "/", MaxAge: 3600, HttpOnly: true})
+	if err != nil {
+		t.Fatalf("NewFileSessionStore() error = %v", err)
+	}
+	return store
+}
+
+// saveSession stores values in a new session and returns its cookie
+func saveSession(t *testing.T, store *FileSessionStore, values map[interface{}]interface{}) *http.Cookie {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("User-Agent", "test-agent")
+	session, err := store.Get(r, "test")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	for k, v := range values {
+		session.Values[k] = v
+	}
+	w := httptest.NewRecorder()
+	if err := session.Save(r, w); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Save() set %d cookies, want 1", len(cookies))
+	}
+	return cookies[0]
+}
+
+func loadSession(t *testing.T, store *FileSessionStore, cookie *http.Cookie) *sessions.Session {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookie)
+	session, err := store.Get(r, "test")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	return session
+}
+
+func TestFileSessionStore_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store := newTestSessionStore(t, dir, "secret")
+	cookie := saveSession(t, store, map[interface{}]interface{}{"token": "refresh-token-value"})
+
+	if strings.Contains(cookie.Value, "refresh-token-value") {
+		t.Error("cookie contains the session values")
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("found %d session files, want 1", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	if strings.Contains(string(data), "refresh-token-value") {
+		t.Error("session file stores the values unencrypted")
+	}
+	if info, _ := os.Stat(files[0]); info.Mode().Perm() != 0600 {
+		t.Errorf("session file mode = %o, want 600", info.Mode().Perm())
+	}
+
+	// a new store with the same secret, e.g. after a restart, reads the session
+	session := loadSession(t, newTestSessionStore(t, dir, "secret"), cookie)
+	if session.IsNew || session.Values["token"] != "refresh-token-value" {
+		t.Errorf("reloaded session = %v (new %v), want the saved values", session.Values, session.IsNew)
+	}
+
+	// a different secret cannot read it and starts over without an error
+	session = loadSession(t, newTestSessionStore(t, dir, "other"), cookie)
+	if !session.IsNew || len(session.Values) != 0 {
+		t.Errorf("session with a different secret = %v, want a new empty session", session.Values)
+	}
+}
+
+func TestFileSessionStore_ListRevoke(t *testing.T) {
+	store := newTestSessionStore(t, t.TempDir(), "secret")
+	cookie := saveSession(t, store, map[interface{}]interface{}{"user": "a@example.com"})
+	saveSession(t, store, map[interface{}]interface{}{"user": "b@example.com"})
+
+	list, err := store.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("List() returned %d sessions, want 2", len(list))
+	}
+	current := loadSession(t, store, cookie)
+	handle := SessionHandle(current.ID)
+	found := false
+	for _, info := range list {
+		if strings.Contains(info.Handle, current.ID) {
+			t.Error("handle reveals the session ID")
+		}
+		if info.Handle == handle {
+			found = true
+			if info.Values["user"] != "a@example.com" || info.UserAgent != "test-agent" {
+				t.Errorf("listed session = %+v", info)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("List() does not contain the session handle %s", handle)
+	}
+
+	if err := store.Revoke(handle); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if session := loadSession(t, store, cookie); !session.IsNew {
+		t.Error("revoked session can still be loaded")
+	}
+	for _, bad := range []string{handle, "../session", ""} {
+		if err := store.Revoke(bad); err != ErrSessionNotFound {
+			t.Errorf("Revoke(%q) error = %v, want ErrSessionNotFound", bad, err)
+		}
+	}
+}
+
+func TestFileSessionStore_DeleteAndCleanup(t *testing.T) {
+	dir := t.TempDir()
+	store := newTestSessionStore(t, dir, "secret")
+	cookie := saveSession(t, store, map[interface{}]interface{}{"user": "a@example.com"})
+	saveSession(t, store, map[interface{}]interface{}{"user": "b@example.com"})
+
+	// MaxAge -1 deletes the session, as on logout
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.AddCookie(cookie)
+	session, _ := store.Get(r, "test")
+	session.Options.MaxAge = -1
+	w := httptest.NewRecorder()
+	if err := session.Save(r, w); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if list, _ := store.List(); len(list) != 1 {
+		t.Errorf("%d sessions left after logout, want 1", len(list))
+	}
+
+	if removed, err := store.Cleanup(time.Now()); err != nil || removed != 0 {
+		t.Errorf("Cleanup(now) = %d, %v, want 0", removed, err)
+	}
+	if removed, err := store.Cleanup(time.Now().Add(2 * time.Hour)); err != nil || removed != 1 {
+		t.Errorf("Cleanup(later) = %d, %v, want 1", removed, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 0 {
+		t.Errorf("%d session files left after cleanup", len(files))
+	}
+}
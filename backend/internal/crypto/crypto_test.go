@@ -0,0 +1,65 @@
+package crypto
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	key := DeriveKey([]byte("session secret"))
+	sealed, err := Seal(key, []byte("access-token"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	plaintext, err := Open(key, sealed)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if string(plaintext) != "access-token" {
+		t.Errorf("Open() = %q, want %q", plaintext, "access-token")
+	}
+
+	if _, err := Open(DeriveKey([]byte("other secret")), sealed); err == nil {
+		t.Error("Open() with a different key should fail")
+	}
+	if _, err := Open(key, "bm9wZQ=="); err == nil {
+		t.Error("Open() of a truncated value should fail")
+	}
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.key")
+
+	key, err := LoadOrCreateKey(path, 32)
+	if err != nil {
+		t.Fatalf("LoadOrCreateKey() error = %v", err)
+	}
+	if len(key) != 32 {
+		t.Errorf("len(key) = %d, want 32", len(key))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("key file not created: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("key file mode = %o, want 600", perm)
+	}
+
+	again, err := LoadOrCreateKey(path, 32)
+	if err != nil {
+		t.Fatalf("LoadOrCreateKey() reload error = %v", err)
+	}
+	if !bytes.Equal(key, again) {
+		t.Error("reloaded key differs from the created key")
+	}
+
+	if err := os.WriteFile(path, []byte("not hex"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadOrCreateKey(path, 32); err == nil {
+		t.Error("LoadOrCreateKey() of a corrupt file should fail")
+	}
+}
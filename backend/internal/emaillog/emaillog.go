@@ -0,0 +1,181 @@
+package emaillog
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is the outcome of a send attempt
+type Status string
+
+const (
+	StatusSent   Status = "sent"   // accepted by the SMTP server
+	StatusFailed Status = "failed" // the attempt failed, see Entry.Error
+)
+
+// AttachmentInfo identifies an attached file without storing its content
+type AttachmentInfo struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	SHA256      string `json:"sha256"` // hex encoded
+}
+
+// NewAttachmentInfo describes an attachment and hashes its data
+func NewAttachmentInfo(filename, contentType string, data []byte) AttachmentInfo {
+	sum := sha256.Sum256(data)
+	return AttachmentInfo{
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        len(data),
+		SHA256:      hex.EncodeToString(sum[:]),
+	}
+}
+
+// Entry records a single send attempt of an invoice email
+type Entry struct {
+	Timestamp    time.Time        `json:"timestamp"`
+	InvoiceID    string           `json:"invoice_id"`
+	JobID        string           `json:"job_id,omitempty"` // outbox job, see package outbox
+	Attempt      int              `json:"attempt"`
+	Status       Status           `json:"status"`
+	From         string           `json:"from"`
+	To           []string         `json:"to"`
+	Cc           []string         `json:"cc,omitempty"`
+	Bcc          []string         `json:"bcc,omitempty"`
+	Subject      string           `json:"subject"`
+	TemplateID   string           `json:"template_id,omitempty"`
+	Reminder     string           `json:"reminder,omitempty"` // reminder level, empty for the invoice email
+	Attachments  []AttachmentInfo `json:"attachments,omitempty"`
+	MessageID    string           `json:"message_id,omitempty"`
+	SMTPResponse string           `json:"smtp_response,omitempty"` // final server reply, also set for rejections
+	Error        string           `json:"error,omitempty"`
+}
+
+// Log is an append-only log of send attempts, stored as one JSON Lines file per invoice
+type Log struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// New opens the log stored in dir
+func New(dir string) (*Log, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create email log directory %q: %v", dir, err)
+	}
+	return &Log{dir: dir}, nil
+}
+
+// Append adds an entry to the log of its invoice
+func (l *Log) Append(entry *Entry) error {
+	if entry.InvoiceID == "" {
+		return fmt.Errorf("email log entry has no invoice ID")
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal email log entry: %v", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path(entry.InvoiceID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open email log: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write email log: %v", err)
+	}
+	return nil
+}
+
+// List returns the entries of an invoice, newest first.
+// An invoice that was never emailed has no entries.
+func (l *Log) List(invoiceID string) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries, err := l.read(l.path(invoiceID))
+	if err != nil {
+		return nil, err
+	}
+	sortNewestFirst(entries)
+	return entries, nil
+}
+
+// Search returns the entries across all invoices matching the predicate, newest first
+func (l *Log) Search(match func(*Entry) bool) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	files, err := filepath.Glob(filepath.Join(l.dir, "*.jsonl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list email logs: %v", err)
+	}
+	result := []Entry{}
+	for _, file := range files {
+		entries, err := l.read(file)
+		if err != nil {
+			return nil, err
+		}
+		for i := range entries {
+			if match == nil || match(&entries[i]) {
+				result = append(result, entries[i])
+			}
+		}
+	}
+	sortNewestFirst(result)
+	return result, nil
+}
+
+func (l *Log) path(invoiceID string) string {
+	return filepath.Join(l.dir, filepath.Base(invoiceID)+".jsonl")
+}
+
+// read decodes a log file. Lines that fail to decode, e.g. a write cut short
+// by a crash, are skipped.
+func (l *Log) read(file string) ([]Entry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Entry{}, nil
+		}
+		return nil, fmt.Errorf("failed to open email log: %v", err)
+	}
+	defer f.Close()
+
+	entries := []Entry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			slog.Warn("skipping malformed email log line", "file", file, "error", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read email log: %v", err)
+	}
+	return entries, nil
+}
+
+func sortNewestFirst(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+}
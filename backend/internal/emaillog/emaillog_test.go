@@ -0,0 +1,60 @@
+package emaillog
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLog(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(dir)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	base := time.Date(2025, 11, 2, 9, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Timestamp: base, InvoiceID: "INV-1", Attempt: 1, Status: StatusFailed, Error: "connection refused"},
+		{Timestamp: base.Add(time.Minute), InvoiceID: "INV-1", Attempt: 2, Status: StatusSent, MessageID: "abc@example.com"},
+		{Timestamp: base.Add(30 * time.Second), InvoiceID: "INV-2", Attempt: 1, Status: StatusSent},
+	}
+	for i := range entries {
+		if err := l.Append(&entries[i]); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	// a truncated line from a crash is skipped
+	f, _ := os.OpenFile(l.path("INV-1"), os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"timestamp":"2025-11-0`)
+	f.Close()
+
+	list, err := l.List("INV-1")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(list) != 2 || list[0].Attempt != 2 || list[1].Attempt != 1 {
+		t.Errorf("List() = %+v, want attempts 2, 1", list)
+	}
+
+	none, err := l.List("INV-404")
+	if err != nil || len(none) != 0 {
+		t.Errorf("List() of an invoice without log = %v, %v", none, err)
+	}
+
+	sent, err := l.Search(func(e *Entry) bool { return e.Status == StatusSent })
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(sent) != 2 || sent[0].InvoiceID != "INV-1" || sent[1].InvoiceID != "INV-2" {
+		t.Errorf("Search() = %+v, want INV-1 then INV-2", sent)
+	}
+}
+
+func TestNewAttachmentInfo(t *testing.T) {
+	info := NewAttachmentInfo("INV-1.pdf", "application/pdf", []byte("abc"))
+	if info.Size != 3 || info.SHA256 != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("NewAttachmentInfo() = %+v", info)
+	}
+}
@@ -0,0 +1,222 @@
+package emailtemplate
+
+import (
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/storage"
+	"go-invoice/internal/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestVars() Vars {
+	due, _ := time.Parse("2006-01-02", "2025-11-15")
+	return NewVars(&invoice.Invoice{
+		ID:       "INV-25110101",
+		Due:      types.NewDate(due),
+		Provider: invoice.Party{Name: "Jane Smith", Email: "jane@example.com"},
+		Client:   invoice.Party{Name: "Acme Corp"},
+		Items:    []invoice.ServiceItem{{Description: "Web development"}},
+		Pricing:  invoice.Pricing{Subtotal: 1234.5, TaxRate: 10, TaxAmount: 123.45, Total: 1357.95},
+	})
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "plain variables",
+			template: "Invoice from {{PROVIDER_NAME}} ({{INVOICE_ID}}) for {{SERVICE_TYPE}}",
+			expected: "Invoice from Jane Smith (INV-25110101) for Web development",
+		},
+		{
+			name:     "spaces inside braces",
+			template: "{{ CLIENT_NAME }}",
+			expected: "Acme Corp",
+		},
+		{
+			name:     "money helper",
+			template: "{{money TOTAL}} incl. {{money TAX \"€\"}}",
+			expected: "$1,357.95 incl. €123.45",
+		},
+		{
+			name:     "date helper with default and custom layout",
+			template: "{{date DUE_DATE}} / {{date DUE_DATE \"02/01/2006\"}}",
+			expected: "15 November 2025 / 15/11/2025",
+		},
+		{
+			name:     "percent and case helpers",
+			template: "{{percent TAX_RATE}} {{upper CLIENT_NAME}} {{lower PROVIDER_NAME}}",
+			expected: "10% ACME CORP jane smith",
+		},
+		{
+			name:     "count without decimals",
+			template: "{{ITEM_COUNT}} item",
+			expected: "1 item",
+		},
+		{
+			name:     "default helper",
+			template: "{{default CLIENT_EMAIL \"no email\"}}",
+			expected: "no email",
+		},
+		{
+			name:     "if with value",
+			template: "{{#if PROVIDER_EMAIL}}Reply to {{PROVIDER_EMAIL}}{{else}}Do not reply{{/if}}",
+			expected: "Reply to jane@example.com",
+		},
+		{
+			name:     "if without value uses else",
+			template: "{{#if CLIENT_EMAIL}}set{{else}}unset{{/if}}",
+			expected: "unset",
+		},
+		{
+			name:     "unless",
+			template: "{{#unless CLIENT_ABN}}No ABN on file{{/unless}}",
+			expected: "No ABN on file",
+		},
+		{
+			name:     "nested conditionals",
+			template: "{{#if TOTAL}}{{#if TAX}}taxed{{/if}} total{{/if}}",
+			expected: "taxed total",
+		},
+	}
+
+	vars := newTestVars()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Render(tt.template, vars)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Render() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRender_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		errPart  string
+	}{
+		{"unknown variable", "{{NOPE}}", "unknown variable"},
+		{"unknown helper", "{{shout CLIENT_NAME}}", "unknown helper"},
+		{"unclosed action", "Hello {{CLIENT_NAME", "unclosed action"},
+		{"unclosed if", "{{#if CLIENT_NAME}}hi", "not closed"},
+		{"stray else", "hi{{else}}", "unexpected"},
+		{"money on text", "{{money CLIENT_NAME}}", "not an amount"},
+	}
+
+	vars := newTestVars()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Render(tt.template, vars)
+			if err == nil || !strings.Contains(err.Error(), tt.errPart) {
+				t.Errorf("Render() error = %v, want error containing %q", err, tt.errPart)
+			}
+		})
+	}
+}
+
+func TestRender_Counts(t *testing.T) {
+	vars := newTestVars()
+	const template = "{{#if DAYS_OVERDUE}}{{DAYS_OVERDUE}} days overdue{{else}}not overdue{{/if}}"
+	tests := []struct {
+		days     int
+		expected string
+	}{
+		{7, "7 days overdue"},
+		{0, "not overdue"},
+	}
+	for _, tt := range tests {
+		vars["DAYS_OVERDUE"] = tt.days
+		result, err := Render(template, vars)
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		if result != tt.expected {
+			t.Errorf("Render() with %d days = %q, want %q", tt.days, result, tt.expected)
+		}
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	vars := newTestVars()
+	vars["CLIENT_NAME"] = "Smith & <Sons>"
+	vars["PROVIDER_LOGO"] = "cid:" + LogoContentID
+
+	result, err := RenderHTML(`<p>Dear {{CLIENT_NAME}},</p>{{#if PROVIDER_LOGO}}<img src="{{PROVIDER_LOGO}}">{{/if}}`, vars)
+	if err != nil {
+		t.Fatalf("RenderHTML() error = %v", err)
+	}
+	expected := `<p>Dear Smith &amp; &lt;Sons&gt;,</p><img src="cid:provider-logo">`
+	if result != expected {
+		t.Errorf("RenderHTML() = %q, want %q", result, expected)
+	}
+}
+
+func TestRenderEmail(t *testing.T) {
+	et := &storage.EmailTemplate{
+		Id:       "branded",
+		Subject:  "Invoice {{INVOICE_ID}}\n from {{PROVIDER_NAME}}",
+		Body:     "Total: {{money TOTAL}}",
+		HTMLBody: "<b>{{money TOTAL}}</b>",
+	}
+	rendered, err := RenderEmail(et, newTestVars())
+	if err != nil {
+		t.Fatalf("RenderEmail() error = %v", err)
+	}
+	if rendered.Subject != "Invoice INV-25110101 from Jane Smith" {
+		t.Errorf("Subject = %q", rendered.Subject)
+	}
+	if rendered.Body != "Total: $1,357.95" {
+		t.Errorf("Body = %q", rendered.Body)
+	}
+	if rendered.HTMLBody != "<b>$1,357.95</b>" {
+		t.Errorf("HTMLBody = %q", rendered.HTMLBody)
+	}
+
+	et.HTMLBody = ""
+	rendered, err = RenderEmail(et, newTestVars())
+	if err != nil {
+		t.Fatalf("RenderEmail() error = %v", err)
+	}
+	if rendered.HTMLBody != "" {
+		t.Errorf("HTMLBody = %q, want empty without an HTML template", rendered.HTMLBody)
+	}
+}
+
+func TestVariablesAreDocumented(t *testing.T) {
+	vars := newTestVars()
+	if len(vars) != len(Variables) {
+		t.Errorf("NewVars() has %d variables, Variables documents %d", len(vars), len(Variables))
+	}
+	for _, v := range Variables {
+		if _, ok := vars[v.Name]; !ok {
+			t.Errorf("documented variable %s is not set by NewVars()", v.Name)
+		}
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		expected string
+	}{
+		{0, "$0.00"},
+		{5.5, "$5.50"},
+		{999.999, "$1,000.00"},
+		{1234567.891, "$1,234,567.89"},
+		{-42, "-$42.00"},
+	}
+	for _, tt := range tests {
+		if result := FormatMoney(tt.amount, "$"); result != tt.expected {
+			t.Errorf("FormatMoney(%v) = %q, want %q", tt.amount, result, tt.expected)
+		}
+	}
+}
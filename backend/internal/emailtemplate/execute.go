@@ -0,0 +1,226 @@
+package emailtemplate
+
+import (
+	"fmt"
+	"go-invoice/internal/types"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Vars holds the values available to a template.
+// Values are strings, float64 amounts, int counts or types.Date.
+type Vars map[string]any
+
+// Execute renders the template with the given variables.
+// Referencing an unknown variable is an error.
+func (t *Template) Execute(vars Vars) (string, error) {
+	var b strings.Builder
+	if err := execute(&b, t.nodes, vars, nil); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// ExecuteHTML renders the template like Execute, but HTML-escapes every
+// substituted value. The template text itself is trusted markup.
+func (t *Template) ExecuteHTML(vars Vars) (string, error) {
+	var b strings.Builder
+	if err := execute(&b, t.nodes, vars, html.EscapeString); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Render parses and executes template text in one step
+func Render(text string, vars Vars) (string, error) {
+	t, err := Parse(text)
+	if err != nil {
+		return "", err
+	}
+	return t.Execute(vars)
+}
+
+// RenderHTML parses and executes HTML template text in one step
+func RenderHTML(text string, vars Vars) (string, error) {
+	t, err := Parse(text)
+	if err != nil {
+		return "", err
+	}
+	return t.ExecuteHTML(vars)
+}
+
+// execute writes nodes to b. escape, when not nil, is applied to substituted values
+// but not to the literal template text.
+func execute(b *strings.Builder, nodes []node, vars Vars, escape func(string) string) error {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case textNode:
+			b.WriteString(string(n))
+		case *exprNode:
+			value, ok := vars[n.args[0]]
+			if !ok {
+				return fmt.Errorf("unknown variable %q", n.args[0])
+			}
+			var out string
+			if n.helper == "" {
+				out = formatValue(value)
+			} else {
+				var err error
+				out, err = helpers[n.helper](value, unquoteArgs(n.args[1:]))
+				if err != nil {
+					return fmt.Errorf("%s %s: %w", n.helper, n.args[0], err)
+				}
+			}
+			if escape != nil {
+				out = escape(out)
+			}
+			b.WriteString(out)
+		case *ifNode:
+			value, ok := vars[n.name]
+			if !ok {
+				return fmt.Errorf("unknown variable %q", n.name)
+			}
+			branch := n.els
+			if isSet(value) != n.negate {
+				branch = n.then
+			}
+			if err := execute(b, branch, vars, escape); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isSet reports whether a value counts as true in a conditional
+func isSet(value any) bool {
+	switch v := value.(type) {
+	case string:
+		return v != ""
+	case float64:
+		return v != 0
+	case int:
+		return v != 0
+	case types.Date:
+		return !v.IsZero()
+	default:
+		return value != nil
+	}
+}
+
+// formatValue prints a value without a helper
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	case types.Date:
+		if v.IsZero() {
+			return ""
+		}
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func unquoteArgs(args []string) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		if isQuoted(arg) {
+			arg = arg[1 : len(arg)-1]
+		}
+		out[i] = arg
+	}
+	return out
+}
+
+// helper formats a value, args are the unquoted literal arguments after the variable
+type helper func(value any, args []string) (string, error)
+
+var helpers = map[string]helper{
+	"money":   helperMoney,
+	"date":    helperDate,
+	"percent": helperPercent,
+	"upper": func(value any, args []string) (string, error) {
+		return strings.ToUpper(formatValue(value)), nil
+	},
+	"lower": func(value any, args []string) (string, error) {
+		return strings.ToLower(formatValue(value)), nil
+	},
+	"default": func(value any, args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("expects a fallback value")
+		}
+		if !isSet(value) {
+			return args[0], nil
+		}
+		return formatValue(value), nil
+	},
+}
+
+// helperMoney formats an amount with thousands separators, e.g. {{money TOTAL}} -> $1,234.50.
+// An optional argument replaces the currency symbol.
+func helperMoney(value any, args []string) (string, error) {
+	amount, ok := value.(float64)
+	if !ok {
+		return "", fmt.Errorf("not an amount")
+	}
+	symbol := "$"
+	if len(args) > 0 {
+		symbol = args[0]
+	}
+	return FormatMoney(amount, symbol), nil
+}
+
+// helperDate formats a date, e.g. {{date DUE_DATE}} -> 2 January 2006.
+// An optional argument sets a Go time layout.
+func helperDate(value any, args []string) (string, error) {
+	d, ok := value.(types.Date)
+	if !ok {
+		return "", fmt.Errorf("not a date")
+	}
+	if d.IsZero() {
+		return "", nil
+	}
+	layout := DefaultDateLayout
+	if len(args) > 0 {
+		layout = args[0]
+	}
+	return d.Format(layout), nil
+}
+
+// helperPercent formats a rate as a percentage, e.g. {{percent TAX_RATE}} -> 10%
+func helperPercent(value any, args []string) (string, error) {
+	rate, ok := value.(float64)
+	if !ok {
+		return "", fmt.Errorf("not a number")
+	}
+	return strconv.FormatFloat(rate, 'f', -1, 64) + "%", nil
+}
+
+// DefaultDateLayout is used by the date helper when no layout is given
+const DefaultDateLayout = "2 January 2006"
+
+// FormatMoney formats an amount with two decimals and thousands separators
+func FormatMoney(amount float64, symbol string) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	cents := int64(math.Round(amount * 100))
+	whole := strconv.FormatInt(cents/100, 10)
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	return fmt.Sprintf("%s%s%s.%02d", sign, symbol, grouped.String(), cents%100)
+}
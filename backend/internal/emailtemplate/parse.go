@@ -0,0 +1,174 @@
+package emailtemplate
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	leftDelim  = "{{"
+	rightDelim = "}}"
+)
+
+// node is a parsed piece of a template
+type node interface{}
+
+// textNode is literal text copied to the output
+type textNode string
+
+// exprNode prints a variable, optionally through a helper,
+// e.g. {{CLIENT_NAME}} or {{money TOTAL "€"}}
+type exprNode struct {
+	helper string   // empty for plain variables
+	args   []string // variable name followed by literal arguments
+}
+
+// ifNode renders then when the variable is set, otherwise els
+type ifNode struct {
+	negate bool // {{#unless VAR}}
+	name   string
+	then   []node
+	els    []node
+}
+
+// Template is a parsed email template
+type Template struct {
+	nodes []node
+}
+
+// Parse parses template text. Placeholders are written as {{NAME}}, helpers as
+// {{helper NAME "arg"}} and conditionals as {{#if NAME}}...{{else}}...{{/if}}
+// or {{#unless NAME}}...{{/unless}}.
+func Parse(text string) (*Template, error) {
+	p := &parser{text: text}
+	nodes, end, err := p.parseNodes()
+	if err != nil {
+		return nil, err
+	}
+	if end != "" {
+		return nil, fmt.Errorf("unexpected {{%s}}", end)
+	}
+	return &Template{nodes: nodes}, nil
+}
+
+type parser struct {
+	text string
+	pos  int
+}
+
+// parseNodes parses until the end of input or a block terminator ({{else}}, {{/if}}, {{/unless}}),
+// which is returned as end
+func (p *parser) parseNodes() (nodes []node, end string, err error) {
+	for p.pos < len(p.text) {
+		start := strings.Index(p.text[p.pos:], leftDelim)
+		if start < 0 {
+			nodes = append(nodes, textNode(p.text[p.pos:]))
+			p.pos = len(p.text)
+			break
+		}
+		if start > 0 {
+			nodes = append(nodes, textNode(p.text[p.pos:p.pos+start]))
+		}
+		p.pos += start + len(leftDelim)
+
+		stop := strings.Index(p.text[p.pos:], rightDelim)
+		if stop < 0 {
+			return nil, "", fmt.Errorf("unclosed action at offset %d", p.pos-len(leftDelim))
+		}
+		action := strings.TrimSpace(p.text[p.pos : p.pos+stop])
+		p.pos += stop + len(rightDelim)
+
+		switch {
+		case action == "else" || action == "/if" || action == "/unless":
+			return nodes, action, nil
+		case strings.HasPrefix(action, "#if ") || strings.HasPrefix(action, "#unless "):
+			n, err := p.parseIf(action)
+			if err != nil {
+				return nil, "", err
+			}
+			nodes = append(nodes, n)
+		default:
+			n, err := parseExpr(action)
+			if err != nil {
+				return nil, "", err
+			}
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, "", nil
+}
+
+func (p *parser) parseIf(action string) (*ifNode, error) {
+	keyword, name, _ := strings.Cut(action, " ")
+	name = strings.TrimSpace(name)
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return nil, fmt.Errorf("{{%s}} expects a single variable", action)
+	}
+	closing := "/" + strings.TrimPrefix(keyword, "#")
+	n := &ifNode{negate: keyword == "#unless", name: name}
+
+	then, end, err := p.parseNodes()
+	if err != nil {
+		return nil, err
+	}
+	n.then = then
+	if end == "else" {
+		n.els, end, err = p.parseNodes()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if end != closing {
+		return nil, fmt.Errorf("{{%s}} is not closed by {{%s}}", action, closing)
+	}
+	return n, nil
+}
+
+// parseExpr parses a variable or helper call
+func parseExpr(action string) (*exprNode, error) {
+	fields, err := splitArgs(action)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty action {{}}")
+	}
+	if len(fields) == 1 {
+		return &exprNode{args: fields}, nil
+	}
+	if _, ok := helpers[fields[0]]; !ok {
+		return nil, fmt.Errorf("unknown helper %q in {{%s}}", fields[0], action)
+	}
+	return &exprNode{helper: fields[0], args: fields[1:]}, nil
+}
+
+// splitArgs splits an action on whitespace, keeping double quoted arguments together.
+// Quoted arguments are returned with their quotes so they can be told apart from variables.
+func splitArgs(action string) ([]string, error) {
+	var fields []string
+	for i := 0; i < len(action); {
+		switch {
+		case action[i] == ' ' || action[i] == '\t':
+			i++
+		case action[i] == '"':
+			end := strings.IndexByte(action[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in {{%s}}", action)
+			}
+			fields = append(fields, action[i:i+end+2])
+			i += end + 2
+		default:
+			end := strings.IndexAny(action[i:], " \t")
+			if end < 0 {
+				end = len(action) - i
+			}
+			fields = append(fields, action[i:i+end])
+			i += end
+		}
+	}
+	return fields, nil
+}
+
+func isQuoted(arg string) bool {
+	return len(arg) >= 2 && arg[0] == '"' && arg[len(arg)-1] == '"'
+}
@@ -0,0 +1,134 @@
+package emailtemplate
+
+import (
+	"fmt"
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/storage"
+	"go-invoice/internal/types"
+	"math"
+	"strings"
+)
+
+// Variable documents a placeholder available to email templates
+type Variable struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // text, amount, count or date
+	Description string `json:"description"`
+}
+
+// Variables is the documented set of placeholders filled in by NewVars
+var Variables = []Variable{
+	{"INVOICE_ID", "text", "invoice number, e.g. INV-25110201"},
+	{"INVOICE_DATE", "date", "invoice date"},
+	{"DUE_DATE", "date", "payment due date"},
+	{"DAYS_OVERDUE", "count", "days past the due date, 0 when not overdue"},
+	{"DAYS_UNTIL_DUE", "count", "days until the due date, 0 when due or overdue"},
+	{"STATUS", "text", "invoice status"},
+	{"SERVICE_TYPE", "text", "description of the first line item"},
+	{"ITEM_COUNT", "count", "number of line items"},
+	{"SUBTOTAL", "amount", "subtotal before tax"},
+	{"TAX", "amount", "tax amount"},
+	{"TAX_RATE", "amount", "tax rate in percent"},
+	{"TOTAL", "amount", "total amount including tax"},
+	{"PROVIDER_NAME", "text", "provider name"},
+	{"PROVIDER_EMAIL", "text", "provider email address"},
+	{"PROVIDER_PHONE", "text", "provider phone number"},
+	{"PROVIDER_ADDRESS", "text", "provider address"},
+	{"PROVIDER_ABN", "text", "provider ABN"},
+	{"PROVIDER_URL", "text", "provider website"},
+	{"PROVIDER_LOGO", "text", "image source of the provider logo for HTML bodies, empty without a logo"},
+	{"CLIENT_NAME", "text", "client name"},
+	{"CLIENT_EMAIL", "text", "client email address"},
+	{"CLIENT_PHONE", "text", "client phone number"},
+	{"CLIENT_ADDRESS", "text", "client address"},
+	{"CLIENT_ABN", "text", "client ABN"},
+	{"CLIENT_URL", "text", "client website"},
+	{"PAYMENT_METHOD", "text", "payment method"},
+	{"ACCOUNT_NAME", "text", "bank account name"},
+	{"BSB", "text", "bank state branch number"},
+	{"ACCOUNT_NUMBER", "text", "bank account number"},
+}
+
+// LogoContentID is the Content-ID of the provider logo embedded in HTML emails
+const LogoContentID = "provider-logo"
+
+// NewVars builds the template variables for an invoice
+func NewVars(inv *invoice.Invoice) Vars {
+	var serviceType string
+	if len(inv.Items) > 0 {
+		serviceType = inv.Items[0].Description
+	}
+
+	var daysOverdue, daysUntilDue int
+	if !inv.Due.IsZero() {
+		days := int(math.Round(types.Today().Sub(inv.Due.Time).Hours() / 24))
+		daysOverdue = max(days, 0)
+		daysUntilDue = max(-days, 0)
+	}
+
+	vars := Vars{
+		"INVOICE_ID":     inv.ID,
+		"INVOICE_DATE":   inv.Date,
+		"DUE_DATE":       inv.Due,
+		"DAYS_OVERDUE":   daysOverdue,
+		"DAYS_UNTIL_DUE": daysUntilDue,
+		"STATUS":         string(inv.Status),
+		"SERVICE_TYPE":   serviceType,
+		"ITEM_COUNT":     len(inv.Items),
+		"SUBTOTAL":       float64(inv.Pricing.Subtotal),
+		"TAX":            float64(inv.Pricing.TaxAmount),
+		"TAX_RATE":       float64(inv.Pricing.TaxRate),
+		"TOTAL":          float64(inv.Pricing.Total),
+		"PAYMENT_METHOD": inv.Payment.Method,
+		"ACCOUNT_NAME":   inv.Payment.AccountName,
+		"BSB":            inv.Payment.BSB,
+		"ACCOUNT_NUMBER": inv.Payment.AccountNumber,
+		"PROVIDER_LOGO":  "", // set by the caller when the provider has a logo
+	}
+	addParty(vars, "PROVIDER", inv.Provider)
+	addParty(vars, "CLIENT", inv.Client)
+	return vars
+}
+
+func addParty(vars Vars, prefix string, p invoice.Party) {
+	vars[prefix+"_NAME"] = p.Name
+	vars[prefix+"_EMAIL"] = p.Email
+	vars[prefix+"_PHONE"] = p.Phone
+	vars[prefix+"_ADDRESS"] = p.Address
+	vars[prefix+"_ABN"] = p.ABN
+	vars[prefix+"_URL"] = p.URL
+}
+
+// Rendered is an email template rendered for a specific invoice
+type Rendered struct {
+	TemplateID string `json:"template_id"`
+	Subject    string `json:"subject"`
+	Body       string `json:"body"`
+	HTMLBody   string `json:"html_body,omitempty"`
+}
+
+// RenderEmail renders the subject, body and HTML body of an email template
+func RenderEmail(et *storage.EmailTemplate, vars Vars) (*Rendered, error) {
+	subject, err := Render(et.Subject, vars)
+	if err != nil {
+		return nil, fmt.Errorf("subject: %w", err)
+	}
+	body, err := Render(et.Body, vars)
+	if err != nil {
+		return nil, fmt.Errorf("body: %w", err)
+	}
+	var htmlBody string
+	if et.HTMLBody != "" {
+		htmlBody, err = RenderHTML(et.HTMLBody, vars)
+		if err != nil {
+			return nil, fmt.Errorf("html body: %w", err)
+		}
+	}
+	return &Rendered{
+		TemplateID: et.Id,
+		// a subject must be a single line
+		Subject:  strings.Join(strings.Fields(subject), " "),
+		Body:     body,
+		HTMLBody: htmlBody,
+	}, nil
+}
@@ -0,0 +1,169 @@
+// Package export writes the PDFs of many invoices to a ZIP archive with a
+// manifest, rendering a bounded number of them at once.
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-invoice/internal/invoice"
+)
+
+const (
+	// DefaultConcurrency is the number of invoices rendered at once when
+	// EXPORT_CONCURRENCY is not set
+	DefaultConcurrency = 4
+	// ManifestName is the CSV listing every invoice of the archive
+	ManifestName = "manifest.csv"
+)
+
+// LoadConcurrency reads EXPORT_CONCURRENCY, the number of invoices an export
+// renders at once. Chrome renders are also bounded by CHROME_POOL_SIZE.
+func LoadConcurrency() (int, error) {
+	value := strings.TrimSpace(os.Getenv("EXPORT_CONCURRENCY"))
+	if value == "" {
+		return DefaultConcurrency, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid EXPORT_CONCURRENCY '%s', expected a positive number", value)
+	}
+	return n, nil
+}
+
+// RenderFunc renders the PDF of an invoice
+type RenderFunc func(ctx context.Context, inv *invoice.Invoice) ([]byte, error)
+
+// Entry is the manifest row of an invoice
+type Entry struct {
+	File      string `json:"file,omitempty"` // PDF in the archive, empty when the render failed
+	InvoiceID string `json:"invoice_id"`
+	Error     string `json:"error,omitempty"` // why the render failed
+}
+
+var manifestHeader = []string{"file", "invoice_id", "date", "due", "status", "provider", "client", "subtotal", "tax", "total", "error"}
+
+// result is a finished render
+type result struct {
+	pdf []byte
+	err error
+}
+
+// Write renders the invoices and writes them to w as a ZIP archive, in the
+// order given and followed by the manifest. At most concurrency invoices are
+// rendered or waiting to be written at once, which also bounds the memory
+// held. A failed render is listed in the manifest and does not stop the
+// export. progress, which may be nil, is called after each invoice.
+func Write(ctx context.Context, w io.Writer, invoices []invoice.Invoice, render RenderFunc, concurrency int, progress func(Entry)) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]chan result, len(invoices))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+	slots := make(chan struct{}, concurrency)
+	go func() {
+		for i := range invoices {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(i int) {
+				pdf, err := render(ctx, &invoices[i])
+				results[i] <- result{pdf, err}
+			}(i)
+		}
+	}()
+
+	archive := zip.NewWriter(w)
+	manifest := [][]string{manifestHeader}
+	now := time.Now()
+	for i := range invoices {
+		inv := &invoices[i]
+		var res result
+		select {
+		case res = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		entry := Entry{InvoiceID: inv.ID}
+		if res.err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			entry.Error = res.err.Error()
+		} else {
+			entry.File = FileName(inv.ID)
+			// PDFs are compressed already
+			f, err := archive.CreateHeader(&zip.FileHeader{Name: entry.File, Method: zip.Store, Modified: now})
+			if err != nil {
+				return err
+			}
+			if _, err := f.Write(res.pdf); err != nil {
+				return err
+			}
+		}
+		<-slots
+		manifest = append(manifest, manifestRow(inv, entry))
+		if progress != nil {
+			progress(entry)
+		}
+	}
+
+	f, err := archive.CreateHeader(&zip.FileHeader{Name: ManifestName, Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return err
+	}
+	csvWriter := csv.NewWriter(f)
+	if err := csvWriter.WriteAll(manifest); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// FileName is the name of an invoice's PDF in the archive
+func FileName(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, id) + ".pdf"
+}
+
+func manifestRow(inv *invoice.Invoice, entry Entry) []string {
+	money := func(v float32) string { return strconv.FormatFloat(float64(v), 'f', 2, 32) }
+	return []string{
+		cell(entry.File),
+		cell(inv.ID),
+		inv.Date.Format(time.DateOnly),
+		inv.Due.Format(time.DateOnly),
+		string(inv.Status),
+		cell(inv.Provider.Name),
+		cell(inv.Client.Name),
+		money(inv.Pricing.Subtotal),
+		money(inv.Pricing.TaxAmount),
+		money(inv.Pricing.Total),
+		cell(entry.Error),
+	}
+}
+
+// cell keeps spreadsheets from evaluating text as a formula
+func cell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
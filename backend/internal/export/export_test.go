@@ -0,0 +1,159 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-invoice/internal/invoice"
+)
+
+func testInvoices(n int) []invoice.Invoice {
+	invoices := make([]invoice.Invoice, n)
+	for i := range invoices {
+		invoices[i] = invoice.Invoice{
+			ID:      fmt.Sprintf("INV-%02d", i),
+			Status:  invoice.StatusSent,
+			Client:  invoice.Party{Name: "Acme Corp"},
+			Pricing: invoice.Pricing{Subtotal: 100, TaxAmount: 10, TaxRate: 10, Total: 110},
+		}
+	}
+	return invoices
+}
+
+func TestWrite(t *testing.T) {
+	invoices := testInvoices(12)
+	invoices[3].Client.Name = "=HYPERLINK(\"x\")"
+	var running, maxRunning int32
+	render := func(ctx context.Context, inv *invoice.Invoice) ([]byte, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		// finish out of order
+		time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+		if inv.ID == "INV-05" {
+			return nil, errors.New("render failed")
+		}
+		return []byte("%PDF " + inv.ID), nil
+	}
+
+	var buf bytes.Buffer
+	var progress []Entry
+	err := Write(context.Background(), &buf, invoices, render, 3, func(e Entry) { progress = append(progress, e) })
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if maxRunning > 3 {
+		t.Errorf("%d renders ran at once, want at most 3", maxRunning)
+	}
+	if len(progress) != len(invoices) || progress[5].Error == "" {
+		t.Errorf("progress = %+v, want every invoice with INV-05 failed", progress)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("reading the archive: %v", err)
+	}
+	if len(r.File) != len(invoices) { // 11 PDFs and the manifest
+		t.Fatalf("archive has %d files, want %d", len(r.File), len(invoices))
+	}
+	for i, f := range r.File[:len(r.File)-1] {
+		id := invoices[i].ID
+		if i >= 5 {
+			id = invoices[i+1].ID
+		}
+		if f.Name != id+".pdf" {
+			t.Errorf("file %d = %s, want %s.pdf in invoice order", i, f.Name, id)
+		}
+	}
+
+	manifest := r.File[len(r.File)-1]
+	if manifest.Name != ManifestName {
+		t.Fatalf("last file = %s, want the manifest", manifest.Name)
+	}
+	rc, _ := manifest.Open()
+	rows, err := csv.NewReader(rc).ReadAll()
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(invoices)+1 {
+		t.Fatalf("manifest has %d rows, want a header and %d invoices", len(rows), len(invoices))
+	}
+	if row := rows[6]; row[0] != "" || row[1] != "INV-05" || row[10] != "render failed" {
+		t.Errorf("failed invoice row = %v", row)
+	}
+	if row := rows[1]; row[0] != "INV-00.pdf" || row[7] != "100.00" || row[9] != "110.00" {
+		t.Errorf("invoice row = %v", row)
+	}
+	if rows[4][6] != "'=HYPERLINK(\"x\")" {
+		t.Errorf("client cell = %s, want a formula escaped", rows[4][6])
+	}
+}
+
+func TestWrite_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	render := func(ctx context.Context, inv *invoice.Invoice) ([]byte, error) {
+		// the client disconnects while the second invoice renders
+		if inv.ID == "INV-01" {
+			cancel()
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	err := Write(ctx, io.Discard, testInvoices(10), render, 2, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Write() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestFileName(t *testing.T) {
+	if got := FileName("INV/../1"); got != "INV_.._1.pdf" {
+		t.Errorf("FileName() = %s", got)
+	}
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker()
+	id, err := tracker.Start(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.Advance(id, Entry{InvoiceID: "INV-1", File: "INV-1.pdf"})
+	tracker.Advance(id, Entry{InvoiceID: "INV-2", Error: "render failed"})
+	p, ok := tracker.Get(id)
+	if !ok || p.Status != StatusRunning || p.Done != 2 || len(p.Failed) != 1 {
+		t.Fatalf("Get() = %+v, %v", p, ok)
+	}
+	tracker.Finish(id, nil)
+	if p, _ := tracker.Get(id); p.Status != StatusDone || p.Finished == nil {
+		t.Errorf("finished export = %+v", p)
+	}
+
+	// only the most recent finished exports are kept
+	var ids []string
+	for i := 0; i < keep+5; i++ {
+		id, _ := tracker.Start(1)
+		tracker.Finish(id, errors.New("client disconnected"))
+		ids = append(ids, id)
+	}
+	if _, ok := tracker.Get(ids[0]); ok {
+		t.Error("the oldest export was not pruned")
+	}
+	if p, ok := tracker.Get(ids[len(ids)-1]); !ok || p.Status != StatusFailed {
+		t.Errorf("latest export = %+v, %v", p, ok)
+	}
+}
@@ -0,0 +1,120 @@
+package export
+
+import (
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"go-invoice/internal/crypto"
+)
+
+// Status of an export
+type Status string
+
+const (
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed" // the archive is incomplete, e.g. the client disconnected
+)
+
+// keep is how many finished exports the tracker remembers
+const keep = 20
+
+// Progress reports how far an export is
+type Progress struct {
+	ID       string     `json:"id"`
+	Status   Status     `json:"status"`
+	Total    int        `json:"total"`            // invoices in the export
+	Done     int        `json:"done"`             // invoices written or failed
+	Failed   []Entry    `json:"failed,omitempty"` // invoices that could not be rendered
+	Error    string     `json:"error,omitempty"`  // why the export failed
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// Tracker keeps the progress of running and recently finished exports in memory
+type Tracker struct {
+	mu      sync.Mutex
+	exports map[string]*Progress
+}
+
+// NewTracker creates an empty tracker
+func NewTracker() *Tracker {
+	return &Tracker{exports: make(map[string]*Progress)}
+}
+
+// Start registers an export of total invoices and returns its ID
+func (t *Tracker) Start(total int) (string, error) {
+	suffix, err := crypto.GenerateSecureBytes(4)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	id := now.Format("20060102T150405") + "-" + hex.EncodeToString(suffix)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune()
+	t.exports[id] = &Progress{ID: id, Status: StatusRunning, Total: total, Started: now}
+	return id, nil
+}
+
+// Advance records a written or failed invoice, it is a Write progress func
+func (t *Tracker) Advance(id string, entry Entry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.exports[id]; ok {
+		p.Done++
+		if entry.Error != "" {
+			p.Failed = append(p.Failed, entry)
+		}
+	}
+}
+
+// Finish marks the export done, or failed when err is not nil
+func (t *Tracker) Finish(id string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.exports[id]
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	p.Finished = &now
+	p.Status = StatusDone
+	if err != nil {
+		p.Status = StatusFailed
+		p.Error = err.Error()
+	}
+}
+
+// Get returns a copy of the progress of an export
+func (t *Tracker) Get(id string) (Progress, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.exports[id]
+	if !ok {
+		return Progress{}, false
+	}
+	copied := *p
+	copied.Failed = append([]Entry(nil), p.Failed...)
+	return copied, true
+}
+
+// prune forgets the oldest finished exports beyond keep
+func (t *Tracker) prune() {
+	var finished []*Progress
+	for _, p := range t.exports {
+		if p.Finished != nil {
+			finished = append(finished, p)
+		}
+	}
+	if len(finished) < keep {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].Finished.Before(*finished[j].Finished) })
+	for _, p := range finished[:len(finished)-keep+1] {
+		delete(t.exports, p.ID)
+	}
+}
@@ -0,0 +1,351 @@
+// Package facturx generates Factur-X / ZUGFeRD e-invoices: the invoice as
+// EN 16931 Cross Industry Invoice XML, embedded in a PDF/A-3 document.
+package facturx
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/pdf"
+	"go-invoice/internal/types"
+)
+
+const (
+	// FileName is the name of the XML attachment the Factur-X standard requires
+	FileName = "factur-x.xml"
+	// Currency of all invoices, the app bills in Australian dollars
+	Currency = "AUD"
+	// Guideline identifies the EN 16931 (COMFORT) profile
+	Guideline = "urn:cen.eu:en16931:2017"
+	// unitCode is the UN/ECE Recommendation 20 unit of quantities, "one"
+	unitCode = "C62"
+)
+
+var countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// Problem is a mandatory EN 16931 field that is missing or invalid
+type Problem struct {
+	Field   string `json:"field"` // JSON path in the invoice, e.g. provider.country
+	Term    string `json:"term"`  // EN 16931 business term, e.g. BT-40
+	Message string `json:"message"`
+}
+
+// ValidationError lists why an invoice cannot be an e-invoice
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		messages[i] = fmt.Sprintf("%s (%s)", p.Message, p.Term)
+	}
+	return "invoice is not a valid e-invoice: " + strings.Join(messages, "; ")
+}
+
+// Validate reports the mandatory EN 16931 fields the invoice is missing.
+// The invoice model has no country or currency by default, so most invoices
+// need the provider and client country set before they pass.
+func Validate(inv *invoice.Invoice) []Problem {
+	var problems []Problem
+	add := func(field, term, format string, args ...any) {
+		problems = append(problems, Problem{Field: field, Term: term, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if strings.TrimSpace(inv.ID) == "" {
+		add("id", "BT-1", "invoice number is missing")
+	}
+	if inv.Date.IsZero() {
+		add("date", "BT-2", "invoice date is missing")
+	}
+	parties := []struct {
+		field, role        string
+		nameTerm, cntyTerm string
+		party              invoice.Party
+	}{
+		{"provider", "provider", "BT-27", "BT-40", inv.Provider},
+		{"client", "client", "BT-44", "BT-55", inv.Client},
+	}
+	for _, p := range parties {
+		if strings.TrimSpace(p.party.Name) == "" {
+			add(p.field+".name", p.nameTerm, "%s name is missing", p.role)
+		}
+		switch country := strings.TrimSpace(p.party.Country); {
+		case country == "":
+			add(p.field+".country", p.cntyTerm, "%s country is missing", p.role)
+		case !countryCode.MatchString(strings.ToUpper(country)):
+			add(p.field+".country", p.cntyTerm, "%s country '%s' is not a two letter ISO 3166-1 code", p.role, country)
+		}
+	}
+
+	if len(inv.Items) == 0 {
+		add("items", "BG-25", "invoice has no items")
+	}
+	for i, item := range inv.Items {
+		if strings.TrimSpace(item.Description) == "" {
+			add(fmt.Sprintf("items[%d].description", i), "BT-153", "item %d has no description", i+1)
+		}
+		if item.UnitPrice < 0 {
+			add(fmt.Sprintf("items[%d].unit_price", i), "BT-146", "item %d has a negative price", i+1)
+		}
+	}
+
+	rate := inv.Pricing.TaxRate
+	if rate < 0 || rate > 100 {
+		add("pricing.tax_rate", "BT-119", "tax rate %g%% is not between 0 and 100", rate)
+	}
+	if rate > 0 && strings.TrimSpace(inv.Provider.ABN) == "" && strings.TrimSpace(inv.Provider.VATID) == "" {
+		add("provider.abn", "BT-32", "provider charging tax needs an ABN or VAT identifier")
+	}
+	t := computeTotals(inv)
+	if t.grand > 0 && inv.Due.IsZero() {
+		add("due", "BT-9", "due date is missing")
+	}
+	if math.Abs(float64(inv.Pricing.Total)*100-float64(t.grand)) > 1 {
+		add("pricing.total", "BT-112", "total %.2f does not match the items, expected %s", inv.Pricing.Total, amount(t.grand))
+	}
+	return problems
+}
+
+// totals are the amounts of the invoice in cents, computed from its items
+type totals struct {
+	lines []int64 // net amount of each item
+	net   int64
+	tax   int64
+	grand int64
+}
+
+func computeTotals(inv *invoice.Invoice) totals {
+	var t totals
+	for _, item := range inv.Items {
+		line := cents(decimal(item.Quantity, 4) * decimal(item.UnitPrice, 4))
+		t.lines = append(t.lines, line)
+		t.net += line
+	}
+	t.tax = cents(float64(t.net) * float64(inv.Pricing.TaxRate) / 10000)
+	t.grand = t.net + t.tax
+	return t
+}
+
+// decimal rounds a float32 to the given decimals, float32 values like 0.1
+// are not exact and would otherwise print as 0.10000000149
+func decimal(v float32, decimals int) float64 {
+	p := math.Pow10(decimals)
+	return math.Round(float64(v)*p) / p
+}
+
+func cents(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
+
+// amount formats cents with two decimals
+func amount(c int64) string {
+	sign := ""
+	if c < 0 {
+		sign, c = "-", -c
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, c/100, c%100)
+}
+
+// number formats a value with up to four decimals
+func number(v float32) string {
+	return strconv.FormatFloat(decimal(v, 4), 'f', -1, 64)
+}
+
+// XML generates the Cross Industry Invoice of the invoice in the EN 16931
+// profile. It returns a *ValidationError when mandatory fields are missing.
+func XML(inv *invoice.Invoice) ([]byte, error) {
+	if problems := Validate(inv); len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	t := computeTotals(inv)
+	category, rate := taxCategory(inv.Pricing.TaxRate)
+
+	x := &xmlWriter{}
+	x.b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	x.open("rsm:CrossIndustryInvoice",
+		`xmlns:rsm="urn:un:unece:uncefact:data:standard:CrossIndustryInvoice:100"`,
+		`xmlns:ram="urn:un:unece:uncefact:data:standard:ReusableAggregateBusinessInformationEntity:100"`,
+		`xmlns:qdt="urn:un:unece:uncefact:data:standard:QualifiedDataType:100"`,
+		`xmlns:udt="urn:un:unece:uncefact:data:standard:UnqualifiedDataType:100"`)
+
+	x.open("rsm:ExchangedDocumentContext")
+	x.open("ram:GuidelineSpecifiedDocumentContextParameter")
+	x.elem("ram:ID", Guideline)
+	x.close()
+	x.close()
+
+	x.open("rsm:ExchangedDocument")
+	x.elem("ram:ID", inv.ID)
+	x.elem("ram:TypeCode", "380") // commercial invoice
+	x.date("ram:IssueDateTime", inv.Date)
+	x.close()
+
+	x.open("rsm:SupplyChainTradeTransaction")
+	for i, item := range inv.Items {
+		x.open("ram:IncludedSupplyChainTradeLineItem")
+		x.open("ram:AssociatedDocumentLineDocument")
+		x.elem("ram:LineID", strconv.Itoa(i+1))
+		x.close()
+		x.open("ram:SpecifiedTradeProduct")
+		x.elem("ram:Name", item.Description)
+		x.optional("ram:Description", item.DescriptionDetail)
+		x.close()
+		x.open("ram:SpecifiedLineTradeAgreement")
+		x.open("ram:NetPriceProductTradePrice")
+		x.elem("ram:ChargeAmount", number(item.UnitPrice))
+		x.close()
+		x.close()
+		x.open("ram:SpecifiedLineTradeDelivery")
+		x.elemAttr("ram:BilledQuantity", number(item.Quantity), "unitCode", unitCode)
+		x.close()
+		x.open("ram:SpecifiedLineTradeSettlement")
+		x.open("ram:ApplicableTradeTax")
+		x.elem("ram:TypeCode", "VAT")
+		x.elem("ram:CategoryCode", category)
+		x.optional("ram:RateApplicablePercent", rate)
+		x.close()
+		x.open("ram:SpecifiedTradeSettlementLineMonetarySummation")
+		x.elem("ram:LineTotalAmount", amount(t.lines[i]))
+		x.close()
+		x.close()
+		x.close()
+	}
+
+	x.open("ram:ApplicableHeaderTradeAgreement")
+	x.party("ram:SellerTradeParty", inv.Provider, category)
+	x.party("ram:BuyerTradeParty", inv.Client, category)
+	x.close()
+
+	x.open("ram:ApplicableHeaderTradeDelivery")
+	x.close()
+
+	x.open("ram:ApplicableHeaderTradeSettlement")
+	x.elem("ram:PaymentReference", inv.ID)
+	x.elem("ram:InvoiceCurrencyCode", Currency)
+	if p := inv.Payment; p.AccountNumber != "" {
+		x.open("ram:SpecifiedTradeSettlementPaymentMeans")
+		x.elem("ram:TypeCode", "30") // credit transfer
+		x.optional("ram:Information", p.Method)
+		x.open("ram:PayeePartyCreditorFinancialAccount")
+		x.optional("ram:AccountName", p.AccountName)
+		x.elem("ram:ProprietaryID", strings.TrimSpace(p.BSB+" "+p.AccountNumber))
+		x.close()
+		x.close()
+	}
+	x.open("ram:ApplicableTradeTax")
+	x.elem("ram:CalculatedAmount", amount(t.tax))
+	x.elem("ram:TypeCode", "VAT")
+	if category == categoryNotSubject {
+		x.elem("ram:ExemptionReason", "Not subject to VAT")
+	}
+	x.elem("ram:BasisAmount", amount(t.net))
+	x.elem("ram:CategoryCode", category)
+	x.optional("ram:RateApplicablePercent", rate)
+	x.close()
+	if start, end, ok := servicePeriod(inv.Items); ok {
+		x.open("ram:BillingSpecifiedPeriod")
+		x.date("ram:StartDateTime", start)
+		x.date("ram:EndDateTime", end)
+		x.close()
+	}
+	if !inv.Due.IsZero() {
+		x.open("ram:SpecifiedTradePaymentTerms")
+		x.date("ram:DueDateDateTime", inv.Due)
+		x.close()
+	}
+	x.open("ram:SpecifiedTradeSettlementHeaderMonetarySummation")
+	x.elem("ram:LineTotalAmount", amount(t.net))
+	x.elem("ram:TaxBasisTotalAmount", amount(t.net))
+	x.elemAttr("ram:TaxTotalAmount", amount(t.tax), "currencyID", Currency)
+	x.elem("ram:GrandTotalAmount", amount(t.grand))
+	x.elem("ram:DuePayableAmount", amount(t.grand))
+	x.close()
+	x.close()
+
+	x.close()
+	x.close()
+	return []byte(x.b.String()), nil
+}
+
+const (
+	categoryStandard   = "S"
+	categoryNotSubject = "O"
+)
+
+// taxCategory maps the tax rate to a VAT category and its rate. Invoices
+// without tax, e.g. to clients abroad, are outside the scope of VAT.
+func taxCategory(rate float32) (category, percent string) {
+	if rate == 0 {
+		return categoryNotSubject, ""
+	}
+	return categoryStandard, number(rate)
+}
+
+// servicePeriod returns the first and last day of the items
+func servicePeriod(items []invoice.ServiceItem) (start, end types.Date, ok bool) {
+	var dates []types.Date
+	for _, item := range items {
+		if !item.Date.IsZero() {
+			dates = append(dates, item.Date)
+		}
+	}
+	if len(dates) == 0 {
+		return start, end, false
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j].Time) })
+	return dates[0], dates[len(dates)-1], true
+}
+
+// Embed turns a PDF of the invoice into a Factur-X PDF/A-3 with the XML attached
+func Embed(pdfData []byte, inv *invoice.Invoice, producer string, now time.Time) ([]byte, error) {
+	data, err := XML(inv)
+	if err != nil {
+		return nil, err
+	}
+	return pdf.ToPDFA3(pdfData, pdf.ArchiveInfo{
+		Title:    inv.ID,
+		Author:   inv.Provider.Name,
+		Subject:  fmt.Sprintf("Invoice %s", inv.ID),
+		Producer: producer,
+		Date:     now,
+		Attachments: []pdf.Attachment{{
+			Name:        FileName,
+			Description: "Factur-X Invoice",
+			MIMEType:    "text/xml",
+			// the EN 16931 XML is a complete, legally equivalent representation
+			Relationship: "Alternative",
+			ModDate:      now,
+			Data:         data,
+		}},
+		XMP: xmpExtension,
+	})
+}
+
+// xmpExtension declares the Factur-X properties and their PDF/A extension schema
+const xmpExtension = `<rdf:Description rdf:about="" xmlns:fx="urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#">
+<fx:DocumentType>INVOICE</fx:DocumentType>
+<fx:DocumentFileName>factur-x.xml</fx:DocumentFileName>
+<fx:Version>1.0</fx:Version>
+<fx:ConformanceLevel>EN 16931</fx:ConformanceLevel>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:pdfaExtension="http://www.aiim.org/pdfa/ns/extension/" xmlns:pdfaSchema="http://www.aiim.org/pdfa/ns/schema#" xmlns:pdfaProperty="http://www.aiim.org/pdfa/ns/property#">
+<pdfaExtension:schemas><rdf:Bag><rdf:li rdf:parseType="Resource">
+<pdfaSchema:schema>Factur-X PDFA Extension Schema</pdfaSchema:schema>
+<pdfaSchema:namespaceURI>urn:factur-x:pdfa:CrossIndustryDocument:invoice:1p0#</pdfaSchema:namespaceURI>
+<pdfaSchema:prefix>fx</pdfaSchema:prefix>
+<pdfaSchema:property><rdf:Seq>
+<rdf:li rdf:parseType="Resource"><pdfaProperty:name>DocumentFileName</pdfaProperty:name><pdfaProperty:valueType>Text</pdfaProperty:valueType><pdfaProperty:category>external</pdfaProperty:category><pdfaProperty:description>The name of the embedded XML document</pdfaProperty:description></rdf:li>
+<rdf:li rdf:parseType="Resource"><pdfaProperty:name>DocumentType</pdfaProperty:name><pdfaProperty:valueType>Text</pdfaProperty:valueType><pdfaProperty:category>external</pdfaProperty:category><pdfaProperty:description>The type of the hybrid document in capital letters, e.g. INVOICE or ORDER</pdfaProperty:description></rdf:li>
+<rdf:li rdf:parseType="Resource"><pdfaProperty:name>Version</pdfaProperty:name><pdfaProperty:valueType>Text</pdfaProperty:valueType><pdfaProperty:category>external</pdfaProperty:category><pdfaProperty:description>The actual version of the standard applying to the embedded XML document</pdfaProperty:description></rdf:li>
+<rdf:li rdf:parseType="Resource"><pdfaProperty:name>ConformanceLevel</pdfaProperty:name><pdfaProperty:valueType>Text</pdfaProperty:valueType><pdfaProperty:category>external</pdfaProperty:category><pdfaProperty:description>The conformance level of the embedded XML document</pdfaProperty:description></rdf:li>
+</rdf:Seq></pdfaSchema:property>
+</rdf:li></rdf:Bag></pdfaExtension:schemas>
+</rdf:Description>
+`
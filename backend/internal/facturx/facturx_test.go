@@ -0,0 +1,175 @@
+package facturx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/pdf"
+	"go-invoice/internal/types"
+)
+
+func date(year int, month time.Month, day int) types.Date {
+	return types.NewDate(time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+}
+
+func testInvoice() *invoice.Invoice {
+	inv := &invoice.Invoice{
+		ID:     "INV-25110301",
+		Status: invoice.StatusDraft,
+		Date:   date(2025, 11, 3),
+		Due:    date(2025, 12, 3),
+		Provider: invoice.Party{
+			Id: "jane_smith", Name: "Jane Smith & Co", Address: "1 Main St\nSydney NSW 2000",
+			Email: "jane@example.com", ABN: "12 345 678 901", Country: "AU",
+		},
+		Client: invoice.Party{
+			Id: "acme_corp", Name: "Acme Corp", Address: "Hauptstr. 1\n10115 Berlin", Country: "de",
+			VATID: "DE123456789",
+		},
+		Payment: invoice.PaymentInfo{Method: "Bank transfer", AccountName: "Jane Smith", BSB: "062-000", AccountNumber: "1234 5678"},
+		Pricing: invoice.Pricing{TaxRate: 10},
+	}
+	inv.AddItem(invoice.NewServiceItem(date(2025, 10, 6), "Consulting", 7.5, 120))
+	inv.AddItem(invoice.NewServiceItemWithDetail(date(2025, 10, 1), "Hosting", "October", 1, 19.99))
+	return inv
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(inv *invoice.Invoice)
+		fields []string
+	}{
+		{"valid", func(inv *invoice.Invoice) {}, nil},
+		{"missing countries", func(inv *invoice.Invoice) {
+			inv.Provider.Country = ""
+			inv.Client.Country = "Germany"
+		}, []string{"provider.country", "client.country"}},
+		{"no items", func(inv *invoice.Invoice) {
+			inv.Items = nil
+			inv.Pricing.Update(0)
+		}, []string{"items"}},
+		{"item without description", func(inv *invoice.Invoice) { inv.Items[0].Description = " " }, []string{"items[0].description"}},
+		{"tax without tax identifier", func(inv *invoice.Invoice) { inv.Provider.ABN = "" }, []string{"provider.abn"}},
+		{"no tax without tax identifier", func(inv *invoice.Invoice) {
+			inv.Provider.ABN = ""
+			inv.Pricing.TaxRate = 0
+			inv.Recalculate()
+		}, nil},
+		{"missing due date", func(inv *invoice.Invoice) { inv.Due = types.Date{} }, []string{"due"}},
+		{"stale total", func(inv *invoice.Invoice) { inv.Pricing.Total += 5 }, []string{"pricing.total"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := testInvoice()
+			tt.modify(inv)
+			var fields []string
+			for _, p := range Validate(inv) {
+				if p.Term == "" || p.Message == "" {
+					t.Errorf("problem %+v without term or message", p)
+				}
+				fields = append(fields, p.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("Validate() fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestXML(t *testing.T) {
+	data, err := XML(testInvoice())
+	if err != nil {
+		t.Fatalf("XML() error = %v", err)
+	}
+	if err := xml.Unmarshal(data, new(struct{})); err != nil {
+		t.Fatalf("XML() is not well-formed: %v", err)
+	}
+	s := string(data)
+	for _, want := range []string{
+		"<ram:ID>urn:cen.eu:en16931:2017</ram:ID>",
+		`<udt:DateTimeString format="102">20251103</udt:DateTimeString>`,
+		"<ram:Name>Jane Smith &amp; Co</ram:Name>",
+		"<ram:Description>October</ram:Description>",
+		`<ram:BilledQuantity unitCode="C62">7.5</ram:BilledQuantity>`,
+		"<ram:ChargeAmount>19.99</ram:ChargeAmount>",
+		"<ram:LineTotalAmount>900.00</ram:LineTotalAmount>",
+		"<ram:CountryID>DE</ram:CountryID>",
+		`<ram:ID schemeID="VA">DE123456789</ram:ID>`,
+		`<ram:ID schemeID="FC">12345678901</ram:ID>`,
+		"<ram:ProprietaryID>062-000 1234 5678</ram:ProprietaryID>",
+		"<ram:CategoryCode>S</ram:CategoryCode>",
+		"<ram:RateApplicablePercent>10</ram:RateApplicablePercent>",
+		`<ram:TaxTotalAmount currencyID="AUD">92.00</ram:TaxTotalAmount>`,
+		"<ram:GrandTotalAmount>1011.99</ram:GrandTotalAmount>",
+		`<udt:DateTimeString format="102">20251001</udt:DateTimeString>`, // service period start
+		`<udt:DateTimeString format="102">20251203</udt:DateTimeString>`, // due date
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("XML() does not contain %s", want)
+		}
+	}
+	// the settlement elements are in schema order
+	order := []string{"PaymentReference", "InvoiceCurrencyCode", "SpecifiedTradeSettlementPaymentMeans",
+		"ApplicableTradeTax", "BillingSpecifiedPeriod", "SpecifiedTradePaymentTerms", "SpecifiedTradeSettlementHeaderMonetarySummation"}
+	settlement := s[strings.Index(s, "<ram:ApplicableHeaderTradeSettlement>"):]
+	last := -1
+	for _, element := range order {
+		i := strings.Index(settlement, "<ram:"+element+">")
+		if i < last {
+			t.Errorf("%s is out of order", element)
+		}
+		last = i
+	}
+}
+
+func TestXML_NoTax(t *testing.T) {
+	inv := testInvoice()
+	inv.Pricing.TaxRate = 0
+	inv.Recalculate()
+	data, err := XML(inv)
+	if err != nil {
+		t.Fatalf("XML() error = %v", err)
+	}
+	s := string(data)
+	if !strings.Contains(s, "<ram:CategoryCode>O</ram:CategoryCode>") || !strings.Contains(s, "<ram:ExemptionReason>") {
+		t.Error("XML() without tax is not outside the scope of VAT")
+	}
+	if strings.Contains(s, "RateApplicablePercent") || strings.Contains(s, `schemeID="VA"`) {
+		t.Error("XML() without tax has a rate or VAT identifier")
+	}
+}
+
+func TestXML_Invalid(t *testing.T) {
+	inv := testInvoice()
+	inv.Client.Country = ""
+	_, err := XML(inv)
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || len(invalid.Problems) != 1 {
+		t.Fatalf("XML() error = %v, want a ValidationError with one problem", err)
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	doc := pdf.New()
+	doc.AddPage(pdf.Size{Width: 595, Height: 842}).FillRect(10, 10, 100, 20)
+	original, err := doc.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Embed(original, testInvoice(), "go-invoice", time.Date(2025, 11, 3, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	for _, want := range []string{"/AFRelationship /Alternative", "(factur-x.xml)", "<pdfaid:part>3</pdfaid:part>",
+		"<fx:ConformanceLevel>EN 16931</fx:ConformanceLevel>", "/Subtype /text#2Fxml"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("Embed() output does not contain %s", want)
+		}
+	}
+}
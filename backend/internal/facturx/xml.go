@@ -0,0 +1,116 @@
+package facturx
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/types"
+)
+
+// xmlWriter writes indented elements in the order the CII schema requires
+type xmlWriter struct {
+	b     strings.Builder
+	stack []string
+}
+
+func (x *xmlWriter) indent() {
+	x.b.WriteString(strings.Repeat("  ", len(x.stack)))
+}
+
+func (x *xmlWriter) open(name string, attrs ...string) {
+	x.indent()
+	x.b.WriteString("<" + name)
+	for _, attr := range attrs {
+		x.b.WriteString(" " + attr)
+	}
+	x.b.WriteString(">\n")
+	x.stack = append(x.stack, name)
+}
+
+func (x *xmlWriter) close() {
+	name := x.stack[len(x.stack)-1]
+	x.stack = x.stack[:len(x.stack)-1]
+	x.indent()
+	x.b.WriteString("</" + name + ">\n")
+}
+
+func (x *xmlWriter) elem(name, value string) {
+	x.elemAttr(name, value, "", "")
+}
+
+// optional writes the element unless value is empty
+func (x *xmlWriter) optional(name, value string) {
+	if strings.TrimSpace(value) != "" {
+		x.elem(name, value)
+	}
+}
+
+func (x *xmlWriter) elemAttr(name, value, attr, attrValue string) {
+	x.indent()
+	x.b.WriteString("<" + name)
+	if attr != "" {
+		x.b.WriteString(" " + attr + `="`)
+		xml.EscapeText(&x.b, []byte(attrValue))
+		x.b.WriteString(`"`)
+	}
+	x.b.WriteString(">")
+	xml.EscapeText(&x.b, []byte(strings.TrimSpace(value)))
+	x.b.WriteString("</" + name + ">\n")
+}
+
+// date writes a date in format 102, YYYYMMDD
+func (x *xmlWriter) date(name string, d types.Date) {
+	x.open(name)
+	x.elemAttr("udt:DateTimeString", d.Format("20060102"), "format", "102")
+	x.close()
+}
+
+// party writes a seller or buyer. Parties outside the scope of VAT must not
+// carry VAT identifiers, so only the ABN is written for them.
+func (x *xmlWriter) party(name string, p invoice.Party, category string) {
+	x.open(name)
+	x.elem("ram:Name", p.Name)
+
+	x.open("ram:PostalTradeAddress")
+	lines := addressLines(p.Address)
+	for i, tag := range []string{"ram:LineOne", "ram:LineTwo", "ram:LineThree"} {
+		if i < len(lines) {
+			x.elem(tag, lines[i])
+		}
+	}
+	x.elem("ram:CountryID", strings.ToUpper(strings.TrimSpace(p.Country)))
+	x.close()
+
+	if p.Email != "" {
+		x.open("ram:URIUniversalCommunication")
+		x.elemAttr("ram:URIID", p.Email, "schemeID", "EM")
+		x.close()
+	}
+	if p.VATID != "" && category != categoryNotSubject {
+		x.open("ram:SpecifiedTaxRegistration")
+		x.elemAttr("ram:ID", strings.ReplaceAll(p.VATID, " ", ""), "schemeID", "VA")
+		x.close()
+	}
+	if p.ABN != "" {
+		x.open("ram:SpecifiedTaxRegistration")
+		x.elemAttr("ram:ID", strings.ReplaceAll(p.ABN, " ", ""), "schemeID", "FC")
+		x.close()
+	}
+	x.close()
+}
+
+// addressLines splits a free text address into at most three lines, the last
+// line taking the rest
+func addressLines(address string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(address, "\r\n", "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > 3 {
+		lines = append(lines[:2], strings.Join(lines[2:], ", "))
+	}
+	return lines
+}
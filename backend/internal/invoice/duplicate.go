@@ -0,0 +1,40 @@
+package invoice
+
+import "go-invoice/internal/types"
+
+// DuplicateOptions controls how an invoice is copied by Duplicate
+type DuplicateOptions struct {
+	Shift           types.Period // moves item dates and the due date, zero keeps them
+	ClearQuantities bool         // resets item quantities so only descriptions and rates are kept
+}
+
+// Duplicate returns a copy of the invoice as a new draft dated today.
+// The copy has no ID, the caller assigns one from the numbering sequence.
+func (inv *Invoice) Duplicate(today types.Date, opts DuplicateOptions) *Invoice {
+	dup := *inv
+	dup.ID = ""
+	dup.Status = StatusDraft
+	dup.Date = today
+	dup.Reminders = nil
+	dup.Items = make([]ServiceItem, len(inv.Items))
+	copy(dup.Items, inv.Items)
+
+	if !opts.Shift.IsZero() {
+		if !dup.Due.IsZero() {
+			dup.Due = dup.Due.AddPeriod(opts.Shift)
+		}
+		for i := range dup.Items {
+			if !dup.Items[i].Date.IsZero() {
+				dup.Items[i].Date = dup.Items[i].Date.AddPeriod(opts.Shift)
+			}
+		}
+	}
+
+	if opts.ClearQuantities {
+		for i := range dup.Items {
+			dup.Items[i].Quantity = 0
+		}
+		dup.Recalculate()
+	}
+	return &dup
+}
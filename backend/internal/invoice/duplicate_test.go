@@ -0,0 +1,53 @@
+package invoice
+
+import (
+	"go-invoice/internal/types"
+	"testing"
+	"time"
+)
+
+func date(s string) types.Date {
+	t, _ := time.Parse("2006-01-02", s)
+	return types.NewDate(t)
+}
+
+func TestDuplicate(t *testing.T) {
+	source := &Invoice{
+		ID:     "INV-25110101",
+		Status: StatusPaid,
+		Date:   date("2025-11-01"),
+		Due:    date("2025-12-01"),
+		Items: []ServiceItem{
+			NewServiceItem(date("2025-10-15"), "Consulting", 10, 100),
+			{Description: "Undated", Quantity: 2, UnitPrice: 50, TotalPrice: 100},
+		},
+		Pricing:   Pricing{TaxRate: 10},
+		Reminders: []ReminderRecord{{Level: "due", Date: date("2025-12-01")}},
+	}
+	source.Recalculate()
+	today := date("2025-12-02")
+
+	dup := source.Duplicate(today, DuplicateOptions{})
+	if dup.ID != "" || dup.Status != StatusDraft || dup.Reminders != nil {
+		t.Errorf("Duplicate() id = %q, status = %s, reminders = %+v, want a new draft without reminders", dup.ID, dup.Status, dup.Reminders)
+	}
+	if dup.Date.String() != "2025-12-02" || dup.Due.String() != "2025-12-01" || dup.Items[0].Date.String() != "2025-10-15" {
+		t.Errorf("Duplicate() dates = %s, due %s, item %s, want only the invoice date reset", dup.Date.String(), dup.Due.String(), dup.Items[0].Date.String())
+	}
+	dup.Items[0].Description = "changed"
+	if source.Items[0].Description != "Consulting" {
+		t.Error("Duplicate() shares its items with the source")
+	}
+	if source.Status != StatusPaid || len(source.Reminders) != 1 {
+		t.Errorf("Duplicate() changed the source: %+v", source)
+	}
+
+	shift, _ := types.ParsePeriod("1m")
+	dup = source.Duplicate(today, DuplicateOptions{Shift: shift, ClearQuantities: true})
+	if dup.Due.String() != "2026-01-01" || dup.Items[0].Date.String() != "2025-11-15" || !dup.Items[1].Date.IsZero() {
+		t.Errorf("Duplicate() shifted due %s and items %s, %s, want a month later and undated items kept", dup.Due.String(), dup.Items[0].Date.String(), dup.Items[1].Date.String())
+	}
+	if dup.Items[0].Quantity != 0 || dup.Items[0].UnitPrice != 100 || dup.Pricing.Total != 0 {
+		t.Errorf("Duplicate() with cleared quantities = %+v, pricing %+v", dup.Items[0], dup.Pricing)
+	}
+}
@@ -2,7 +2,9 @@ package invoice
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go-invoice/internal/types"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -37,6 +39,59 @@ func FindMaxSuffixFromFilename(filenames []string) int {
 	return maxSuffix
 }
 
+// NextInvoiceID returns the next free invoice ID for the given date.
+// IDs follow the format INV-YYMMDDXX, where XX is a sequence number within the day.
+func NextInvoiceID(invoiceRoot string, date types.Date) (string, error) {
+	dateStr := date.Format("060102")
+	pattern := fmt.Sprintf("INV-%s*.json", dateStr)
+	jsonFiles, err := filepath.Glob(filepath.Join(invoiceRoot, pattern))
+	if err != nil {
+		return "", fmt.Errorf("failed to list invoices for '%s': %w", dateStr, err)
+	}
+	suffix := FindMaxSuffixFromFilename(jsonFiles) + 1
+	return fmt.Sprintf("INV-%s%02d", dateStr, suffix), nil
+}
+
+// maxCreateAttempts bounds the IDs CreateInvoice tries when others are taken concurrently
+const maxCreateAttempts = 10
+
+// CreateInvoice saves a new invoice under the next free ID of the given date.
+// The file is created exclusively, so an invoice created at the same time is
+// never overwritten: its ID is skipped and the next one tried. The error wraps
+// os.ErrExist when every attempt found its ID taken.
+func CreateInvoice(invoiceRoot string, inv *Invoice, date types.Date) error {
+	for range maxCreateAttempts {
+		id, err := NextInvoiceID(invoiceRoot, date)
+		if err != nil {
+			return err
+		}
+		inv.SetID(id)
+		data, err := json.MarshalIndent(inv, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal invoice '%s': %w", id, err)
+		}
+
+		path := filepath.Join(invoiceRoot, id+".json")
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create invoice file '%s': %w", path, err)
+		}
+		_, err = file.Write(data)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(path)
+			return fmt.Errorf("failed to write invoice file '%s': %w", path, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("no free invoice ID for %s after %d attempts: %w", date.String(), maxCreateAttempts, os.ErrExist)
+}
+
 func LoadInvoice(invoiceRoot string, id string) (*Invoice, error) {
 	inv := &Invoice{}
 	filepath := filepath.Join(invoiceRoot, id+".json")
@@ -0,0 +1,61 @@
+package invoice
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestNextInvoiceID(t *testing.T) {
+	dir := t.TempDir()
+	day := date("2025-11-02")
+	if id, err := NextInvoiceID(dir, day); err != nil || id != "INV-25110201" {
+		t.Errorf("NextInvoiceID() in an empty directory = %q, %v, want INV-25110201", id, err)
+	}
+	for _, name := range []string{"INV-25110201.json", "INV-25110204.json", "INV-25110399.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if id, err := NextInvoiceID(dir, day); err != nil || id != "INV-25110205" {
+		t.Errorf("NextInvoiceID() = %q, %v, want INV-25110205 after the highest suffix of the day", id, err)
+	}
+}
+
+func TestCreateInvoice(t *testing.T) {
+	dir := t.TempDir()
+	day := date("2025-11-02")
+
+	var wg sync.WaitGroup
+	invoices := make([]*Invoice, 8)
+	errs := make([]error, len(invoices))
+	for i := range invoices {
+		invoices[i] = &Invoice{Status: StatusDraft, Date: day}
+		wg.Go(func() { errs[i] = CreateInvoice(dir, invoices[i], day) })
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i, inv := range invoices {
+		if errs[i] != nil {
+			// only a lost race of every attempt may fail, and it must not overwrite
+			if !errors.Is(errs[i], os.ErrExist) {
+				t.Errorf("CreateInvoice() error = %v", errs[i])
+			}
+			continue
+		}
+		if seen[inv.ID] {
+			t.Errorf("CreateInvoice() assigned %s twice", inv.ID)
+		}
+		seen[inv.ID] = true
+		if _, err := LoadInvoice(dir, inv.ID); err != nil {
+			t.Errorf("LoadInvoice(%s) error = %v", inv.ID, err)
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != len(seen) {
+		t.Errorf("%d invoice files for %d created invoices", len(files), len(seen))
+	}
+}
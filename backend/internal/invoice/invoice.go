@@ -10,21 +10,46 @@ type InvoiceStatus string
 const (
 	StatusDraft InvoiceStatus = "draft"
 	StatusSent  InvoiceStatus = "send"
+	StatusPaid  InvoiceStatus = "paid"
 )
 
+// DefaultPaymentTerm is the time between invoice date and due date when no due date is given
+var DefaultPaymentTerm = types.Period{Days: 30}
+
 // Invoice represents the core invoice domain model
 type Invoice struct {
-	ID              string        `json:"id"`                     // invoice number/identifier
-	Status          InvoiceStatus `json:"status"`                 // invoice status (draft, sent)
-	Date            types.Date    `json:"date"`                   // invoice date
-	Due             types.Date    `json:"due"`                    // payment due date
-	Provider        Party         `json:"provider"`               // service provider
-	Client          Party         `json:"client"`                 // client/customer
-	Items           []ServiceItem `json:"items"`                  // list of services/products
-	Pricing         Pricing       `json:"pricing"`                // pricing details
-	Payment         PaymentInfo   `json:"payment"`                // payment information
-	EmailTarget     string        `json:"email_target,omitempty"` // (optional) email target for sending the invoice
-	EmailTemplateID string        `json:"email_template_id"`      // email template ID
+	ID              string           `json:"id"`                       // invoice number/identifier
+	Status          InvoiceStatus    `json:"status"`                   // invoice status (draft, sent, paid)
+	Date            types.Date       `json:"date"`                     // invoice date
+	Due             types.Date       `json:"due"`                      // payment due date
+	Provider        Party            `json:"provider"`                 // service provider
+	Client          Party            `json:"client"`                   // client/customer
+	Items           []ServiceItem    `json:"items"`                    // list of services/products
+	Pricing         Pricing          `json:"pricing"`                  // pricing details
+	Payment         PaymentInfo      `json:"payment"`                  // payment information
+	EmailTarget     string           `json:"email_target,omitempty"`   // (optional) email target for sending the invoice
+	EmailCc         string           `json:"email_cc,omitempty"`       // (optional) comma separated CC addresses
+	EmailBcc        string           `json:"email_bcc,omitempty"`      // (optional) comma separated BCC addresses
+	EmailReplyTo    string           `json:"email_reply_to,omitempty"` // (optional) Reply-To address
+	EmailTemplateID string           `json:"email_template_id"`        // email template ID
+	Reminders       []ReminderRecord `json:"reminders,omitempty"`      // payment reminders queued so far
+}
+
+// ReminderRecord records a payment reminder queued for the invoice, see package reminder
+type ReminderRecord struct {
+	Level string     `json:"level"`            // schedule level ID, e.g. overdue_7
+	Date  types.Date `json:"date"`             // day the reminder was queued
+	JobID string     `json:"job_id,omitempty"` // outbox job delivering the reminder
+}
+
+// HasReminder reports whether a reminder of the given level was already queued
+func (inv *Invoice) HasReminder(level string) bool {
+	for _, r := range inv.Reminders {
+		if r.Level == level {
+			return true
+		}
+	}
+	return false
 }
 
 // SetEmailTarget sets the email address to send the invoice to
@@ -46,6 +71,14 @@ func (inv *Invoice) AddItem(item ServiceItem) {
 	inv.Pricing.Update(calculateSubtotal(inv.Items))
 }
 
+// Recalculate updates item totals and the pricing from quantities, unit prices and the tax rate
+func (inv *Invoice) Recalculate() {
+	for i := range inv.Items {
+		inv.Items[i].TotalPrice = inv.Items[i].Quantity * inv.Items[i].UnitPrice
+	}
+	inv.Pricing.Update(calculateSubtotal(inv.Items))
+}
+
 // Party represents either the service provider or the client/customer
 type Party struct {
 	Id      string `json:"id"`                // (optional) unique identifier
@@ -55,6 +88,8 @@ type Party struct {
 	Phone   string `json:"phone,omitempty"`   // (optional) phone number
 	ABN     string `json:"abn,omitempty"`     // (optional) Australian Business Number
 	URL     string `json:"url,omitempty"`     // (optional) website URL
+	Country string `json:"country,omitempty"` // (optional) ISO 3166-1 alpha-2 code, e.g. AU, required for e-invoices
+	VATID   string `json:"vat_id,omitempty"`  // (optional) VAT identifier with country prefix, e.g. DE123456789
 }
 
 func (p *Party) HasRequiredFields() bool {
@@ -0,0 +1,79 @@
+package outbox
+
+import (
+	"encoding/json"
+	"go-invoice/internal/auth"
+	"go-invoice/internal/types"
+	"time"
+)
+
+// Status is the delivery state of an outbox job
+type Status string
+
+const (
+	StatusQueued  Status = "queued"  // waiting for the next delivery attempt
+	StatusSending Status = "sending" // a delivery attempt is in progress
+	StatusSent    Status = "sent"    // delivered, final
+	StatusFailed  Status = "failed"  // gave up after the last attempt, final until retried
+)
+
+// Kind is what an outbox job delivers
+type Kind string
+
+const (
+	KindInvoice  Kind = "invoice"  // the invoice itself, marks it as sent once delivered
+	KindReminder Kind = "reminder" // a payment reminder for a sent invoice
+)
+
+// Job is an email waiting in the outbox, stored as <id>.json
+type Job struct {
+	ID            string             `json:"id"`
+	InvoiceID     string             `json:"invoice_id"`
+	Kind          Kind               `json:"kind,omitempty"`           // empty means KindInvoice
+	ReminderLevel string             `json:"reminder_level,omitempty"` // schedule level of a reminder job
+	Status        Status             `json:"status"`
+	Message       types.EmailMessage `json:"message"`             // rendered message, frozen at enqueue time
+	From          string             `json:"from"`                // sender address
+	Login         string             `json:"login,omitempty"`     // SMTP login, empty means From
+	FromName      string             `json:"from_name,omitempty"` // sender display name
+	Transport     string             `json:"transport,omitempty"` // overrides MAIL_TRANSPORT, see services.Transport
+	AuthMethod    auth.AuthMethod    `json:"auth_method"`         // how to authenticate with the SMTP server
+	Credentials   string             `json:"credentials,omitempty"`
+	Attempts      int                `json:"attempts"`
+	MaxAttempts   int                `json:"max_attempts"`
+	LastError     string             `json:"last_error,omitempty"`
+	NextAttemptAt time.Time          `json:"next_attempt_at"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+	SentAt        *time.Time         `json:"sent_at,omitempty"`
+}
+
+// Credentials are the OAuth2 tokens used to deliver a job. They are sealed
+// into Job.Credentials so no plaintext token is written to disk.
+type Credentials struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry"`
+	Provider     string    `json:"provider,omitempty"` // OAuth2 provider, empty means google
+}
+
+// Public returns a copy of the job that is safe to return from the API
+func (j *Job) Public() *Job {
+	c := *j
+	c.Credentials = ""
+	return &c
+}
+
+// IsReminder reports whether the job delivers a payment reminder
+func (j *Job) IsReminder() bool {
+	return j.Kind == KindReminder
+}
+
+// IsFinal reports whether the worker will no longer pick up the job
+func (j *Job) IsFinal() bool {
+	return j.Status == StatusSent || j.Status == StatusFailed
+}
+
+func (j *Job) marshal() ([]byte, error) {
+	return json.MarshalIndent(j, "", "  ")
+}
@@ -0,0 +1,424 @@
+package outbox
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-invoice/internal/crypto"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxAttempts  = 6
+	DefaultBaseDelay    = 30 * time.Second
+	DefaultMaxDelay     = time.Hour
+	DefaultPollInterval = 15 * time.Second
+)
+
+// ErrNotFound is returned when a job does not exist
+var ErrNotFound = errors.New("outbox job not found")
+
+// ErrInvalidState is returned when a job cannot be changed in its current state
+var ErrInvalidState = errors.New("invalid outbox job state")
+
+// DeliverFunc performs a single delivery attempt. creds is nil when the job has no sealed credentials.
+type DeliverFunc func(ctx context.Context, job *Job, creds *Credentials) error
+
+// permanentError marks a delivery error that retrying cannot fix
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job fails immediately instead of being retried
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+// Outbox is a durable queue of emails stored as JSON files, delivered by Run
+type Outbox struct {
+	dir  string
+	key  []byte // AES key sealing job credentials
+	mu   sync.Mutex
+	wake chan struct{}
+
+	Deliver      DeliverFunc
+	OnSent       func(job *Job) // (optional) called after a job is delivered
+	MaxAttempts  int
+	BaseDelay    time.Duration // delay before the first retry, doubled after each failure
+	MaxDelay     time.Duration // upper bound of the retry delay
+	PollInterval time.Duration // how often to look for due jobs
+}
+
+// New creates an outbox storing jobs in dir. secret is used to seal job credentials.
+func New(dir string, secret []byte, deliver DeliverFunc) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory %q: %v", dir, err)
+	}
+	return &Outbox{
+		dir:          dir,
+		key:          crypto.DeriveKey(secret),
+		wake:         make(chan struct{}, 1),
+		Deliver:      deliver,
+		MaxAttempts:  DefaultMaxAttempts,
+		BaseDelay:    DefaultBaseDelay,
+		MaxDelay:     DefaultMaxDelay,
+		PollInterval: DefaultPollInterval,
+	}, nil
+}
+
+// Enqueue stores a new job and wakes the worker. creds may be nil.
+func (o *Outbox) Enqueue(job *Job, creds *Credentials) error {
+	id, err := newJobID()
+	if err != nil {
+		return err
+	}
+	if creds != nil {
+		job.Credentials, err = o.SealCredentials(creds)
+		if err != nil {
+			return err
+		}
+	}
+	if job.Kind == "" {
+		job.Kind = KindInvoice
+	}
+	now := time.Now().UTC()
+	job.ID = id
+	job.Status = StatusQueued
+	job.Attempts = 0
+	job.MaxAttempts = o.MaxAttempts
+	job.NextAttemptAt = now
+	job.CreatedAt = now
+	job.UpdatedAt = now
+
+	o.mu.Lock()
+	err = o.save(job)
+	o.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	o.notify()
+	return nil
+}
+
+// Get returns the job stored under id
+func (o *Outbox) Get(id string) (*Job, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.load(id)
+}
+
+// List returns all jobs, newest first
+func (o *Outbox) List() ([]*Job, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.list()
+}
+
+// Retry queues a failed job for immediate delivery with a fresh set of attempts
+func (o *Outbox) Retry(id string) (*Job, error) {
+	o.mu.Lock()
+	job, err := o.load(id)
+	if err != nil {
+		o.mu.Unlock()
+		return nil, err
+	}
+	if job.Status != StatusFailed {
+		o.mu.Unlock()
+		return nil, fmt.Errorf("%w: only failed jobs can be retried, job '%s' is %s", ErrInvalidState, id, job.Status)
+	}
+	job.Status = StatusQueued
+	job.Attempts = 0
+	job.MaxAttempts = o.MaxAttempts
+	job.NextAttemptAt = time.Now().UTC()
+	job.UpdatedAt = job.NextAttemptAt
+	err = o.save(job)
+	o.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	o.notify()
+	return job, nil
+}
+
+// Delete removes a job that is not being delivered
+func (o *Outbox) Delete(id string) error {
+	id = filepath.Base(id) // the job checked is the file removed
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	job, err := o.load(id)
+	if err != nil {
+		return err
+	}
+	if job.Status == StatusSending {
+		return fmt.Errorf("%w: job '%s' is being delivered", ErrInvalidState, id)
+	}
+	return os.Remove(o.path(id))
+}
+
+// Run delivers due jobs until ctx is cancelled. Jobs left in the sending state
+// by a previous run are queued again first.
+func (o *Outbox) Run(ctx context.Context) {
+	if err := o.recover(); err != nil {
+		slog.Error("failed to recover outbox jobs", "error", err)
+	}
+
+	ticker := time.NewTicker(o.PollInterval)
+	defer ticker.Stop()
+	for {
+		o.processDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+// processDue delivers every queued job whose next attempt is due
+func (o *Outbox) processDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := o.claimNext()
+		if err != nil {
+			slog.Error("failed to read outbox", "error", err)
+			return
+		}
+		if job == nil {
+			return
+		}
+		o.attempt(ctx, job)
+	}
+}
+
+// claimNext marks the oldest due job as sending and returns it, or nil when no job is due
+func (o *Outbox) claimNext() (*Job, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	jobs, err := o.list()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	for i := len(jobs) - 1; i >= 0; i-- { // oldest first
+		job := jobs[i]
+		if job.Status != StatusQueued || job.NextAttemptAt.After(now) {
+			continue
+		}
+		job.Status = StatusSending
+		job.Attempts++
+		job.UpdatedAt = now
+		if err := o.save(job); err != nil {
+			return nil, err
+		}
+		return job, nil
+	}
+	return nil, nil
+}
+
+// attempt runs a delivery attempt and records its outcome
+func (o *Outbox) attempt(ctx context.Context, job *Job) {
+	logger := slog.With("job", job.ID, "invoice", job.InvoiceID, "attempt", job.Attempts)
+
+	creds, err := o.credentials(job)
+	if err == nil {
+		err = o.Deliver(ctx, job, creds)
+	}
+
+	o.mu.Lock()
+	now := time.Now().UTC()
+	job.UpdatedAt = now
+	var permanent *permanentError
+	switch {
+	case err == nil:
+		job.Status = StatusSent
+		job.SentAt = &now
+		job.LastError = ""
+		job.Credentials = "" // no longer needed
+		logger.Info("outbox job delivered")
+	case errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts:
+		job.Status = StatusFailed
+		job.LastError = err.Error()
+		logger.Error("outbox job failed", "error", err)
+	default:
+		job.Status = StatusQueued
+		job.LastError = err.Error()
+		job.NextAttemptAt = now.Add(o.backoff(job.Attempts))
+		logger.Warn("outbox delivery failed, retrying", "error", err, "next_attempt_at", job.NextAttemptAt)
+	}
+	saveErr := o.save(job)
+	o.mu.Unlock()
+	if saveErr != nil {
+		logger.Error("failed to save outbox job", "error", saveErr)
+		return
+	}
+
+	if job.Status == StatusSent && o.OnSent != nil {
+		o.OnSent(job)
+	}
+}
+
+// backoff returns the delay before the next attempt after the given number of attempts
+func (o *Outbox) backoff(attempts int) time.Duration {
+	delay := o.BaseDelay
+	for i := 1; i < attempts && delay < o.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, o.MaxDelay)
+}
+
+// credentials opens the sealed credentials of a job
+func (o *Outbox) credentials(job *Job) (*Credentials, error) {
+	if job.Credentials == "" {
+		return nil, nil
+	}
+	creds, err := o.OpenCredentials(job.Credentials)
+	if err != nil {
+		// the secret changed since the job was queued
+		return nil, Permanent(fmt.Errorf("%v, sign in and retry", err))
+	}
+	return creds, nil
+}
+
+// SealCredentials encrypts credentials with the outbox secret, e.g. to keep them
+// for emails queued later without a user session
+func (o *Outbox) SealCredentials(creds *Credentials) (string, error) {
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal credentials: %v", err)
+	}
+	sealed, err := crypto.Seal(o.key, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to seal credentials: %v", err)
+	}
+	return sealed, nil
+}
+
+// OpenCredentials decrypts credentials sealed by SealCredentials
+func (o *Outbox) OpenCredentials(sealed string) (*Credentials, error) {
+	data, err := crypto.Open(o.key, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open credentials: %v", err)
+	}
+	var creds Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("failed to decode credentials: %v", err)
+	}
+	return &creds, nil
+}
+
+// UpdateCredentials re-seals the credentials of a job, e.g. after refreshing an access token
+func (o *Outbox) UpdateCredentials(job *Job, creds *Credentials) error {
+	sealed, err := o.SealCredentials(creds)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	job.Credentials = sealed
+	return o.save(job)
+}
+
+// recover queues jobs interrupted while sending
+func (o *Outbox) recover() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	jobs, err := o.list()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.Status != StatusSending {
+			continue
+		}
+		slog.Warn("requeueing interrupted outbox job", "job", job.ID, "invoice", job.InvoiceID)
+		job.Status = StatusQueued
+		job.NextAttemptAt = time.Now().UTC()
+		if err := o.save(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *Outbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default: // a wake-up is already pending
+	}
+}
+
+func (o *Outbox) path(id string) string {
+	return filepath.Join(o.dir, id+".json")
+}
+
+func (o *Outbox) load(id string) (*Job, error) {
+	data, err := os.ReadFile(o.path(filepath.Base(id)))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: '%s'", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to read outbox job '%s': %v", id, err)
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to decode outbox job '%s': %v", id, err)
+	}
+	return &job, nil
+}
+
+func (o *Outbox) list() ([]*Job, error) {
+	entries, err := os.ReadDir(o.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox directory: %v", err)
+	}
+	var jobs []*Job
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		job, err := o.load(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			slog.Warn("skipping unreadable outbox job", "file", e.Name(), "error", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].ID > jobs[j].ID
+	})
+	return jobs, nil
+}
+
+// save writes the job atomically so a crash never leaves a truncated file
+func (o *Outbox) save(job *Job) error {
+	data, err := job.marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox job: %v", err)
+	}
+	tmp := o.path(job.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write outbox job: %v", err)
+	}
+	if err := os.Rename(tmp, o.path(job.ID)); err != nil {
+		return fmt.Errorf("failed to write outbox job: %v", err)
+	}
+	return nil
+}
+
+// newJobID returns a sortable unique job ID, e.g. 20251102T150405.000-1a2b3c4d
+func newJobID() (string, error) {
+	suffix, err := crypto.GenerateSecureBytes(4)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102T150405.000"), hex.EncodeToString(suffix)), nil
+}
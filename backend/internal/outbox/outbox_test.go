@@ -0,0 +1,188 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"go-invoice/internal/auth"
+	"go-invoice/internal/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestOutbox(t *testing.T, deliver DeliverFunc) *Outbox {
+	t.Helper()
+	o, err := New(t.TempDir(), []byte("secret"), deliver)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return o
+}
+
+func enqueue(t *testing.T, o *Outbox, creds *Credentials) *Job {
+	t.Helper()
+	job := &Job{
+		InvoiceID:  "INV-25110101",
+		Message:    types.NewEmailMessage([]string{"client@example.com"}, "Invoice", "Hello"),
+		From:       "me@example.com",
+		AuthMethod: auth.AuthMethodOAuth2,
+	}
+	if err := o.Enqueue(job, creds); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	return job
+}
+
+func TestOutbox_Delivered(t *testing.T) {
+	var got *Credentials
+	o := newTestOutbox(t, func(ctx context.Context, job *Job, creds *Credentials) error {
+		got = creds
+		return nil
+	})
+	var sent []string
+	o.OnSent = func(job *Job) { sent = append(sent, job.InvoiceID) }
+
+	job := enqueue(t, o, &Credentials{AccessToken: "token-123"})
+
+	// the token is never written in plaintext
+	data, err := os.ReadFile(o.path(job.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "token-123") {
+		t.Error("job file contains the plaintext access token")
+	}
+
+	o.processDue(context.Background())
+
+	job, err = o.Get(job.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if job.Status != StatusSent || job.SentAt == nil || job.Attempts != 1 {
+		t.Errorf("job = %+v, want sent after 1 attempt", job)
+	}
+	if job.Credentials != "" {
+		t.Error("credentials should be dropped once sent")
+	}
+	if got == nil || got.AccessToken != "token-123" {
+		t.Errorf("Deliver() got credentials %+v", got)
+	}
+	if len(sent) != 1 || sent[0] != "INV-25110101" {
+		t.Errorf("OnSent() calls = %v", sent)
+	}
+}
+
+func TestOutbox_RetriesWithBackoff(t *testing.T) {
+	o := newTestOutbox(t, func(ctx context.Context, job *Job, creds *Credentials) error {
+		return errors.New("connection refused")
+	})
+	o.MaxAttempts = 3
+	job := enqueue(t, o, nil)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		o.processDue(context.Background())
+		job, _ = o.Get(job.ID)
+		if job.Attempts != attempt {
+			t.Fatalf("attempts = %d, want %d", job.Attempts, attempt)
+		}
+		if attempt < 3 {
+			if job.Status != StatusQueued {
+				t.Fatalf("status after attempt %d = %s, want queued", attempt, job.Status)
+			}
+			// not due yet, nothing happens
+			o.processDue(context.Background())
+			if again, _ := o.Get(job.ID); again.Attempts != attempt {
+				t.Fatalf("job retried before its backoff elapsed")
+			}
+			// make it due
+			job.NextAttemptAt = time.Now().Add(-time.Second)
+			o.save(job)
+		}
+	}
+	if job.Status != StatusFailed || job.LastError != "connection refused" {
+		t.Errorf("job = %+v, want failed with last error", job)
+	}
+
+	// a failed job can be retried with fresh attempts
+	job, err := o.Retry(job.ID)
+	if err != nil {
+		t.Fatalf("Retry() error = %v", err)
+	}
+	if job.Status != StatusQueued || job.Attempts != 0 {
+		t.Errorf("job after Retry() = %+v", job)
+	}
+}
+
+func TestOutbox_PermanentError(t *testing.T) {
+	o := newTestOutbox(t, func(ctx context.Context, job *Job, creds *Credentials) error {
+		return Permanent(errors.New("550 mailbox unavailable"))
+	})
+	job := enqueue(t, o, nil)
+	o.processDue(context.Background())
+	job, _ = o.Get(job.ID)
+	if job.Status != StatusFailed || job.Attempts != 1 {
+		t.Errorf("job = %+v, want failed after 1 attempt", job)
+	}
+}
+
+func TestOutbox_Backoff(t *testing.T) {
+	o := &Outbox{BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute}
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{5, 5 * time.Minute},
+		{50, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if result := o.backoff(tt.attempts); result != tt.expected {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, result, tt.expected)
+		}
+	}
+}
+
+func TestOutbox_RecoverAndStateErrors(t *testing.T) {
+	o := newTestOutbox(t, nil)
+	job := enqueue(t, o, nil)
+
+	// simulate a crash during delivery
+	job.Status = StatusSending
+	o.save(job)
+
+	if err := o.Delete(job.ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Delete() of a sending job error = %v, want ErrInvalidState", err)
+	}
+	if _, err := o.Retry(job.ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Retry() of a sending job error = %v, want ErrInvalidState", err)
+	}
+
+	if err := o.recover(); err != nil {
+		t.Fatalf("recover() error = %v", err)
+	}
+	job, _ = o.Get(job.ID)
+	if job.Status != StatusQueued {
+		t.Errorf("status after recover() = %s, want queued", job.Status)
+	}
+
+	// an ID escaping the outbox directory names the job, not another file
+	other := filepath.Join(filepath.Dir(o.dir), "other")
+	os.MkdirAll(other, 0755)
+	victim := filepath.Join(other, job.ID+".json")
+	os.WriteFile(victim, []byte("{}"), 0644)
+	if err := o.Delete("../other/" + job.ID); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if _, err := os.Stat(victim); err != nil {
+		t.Errorf("Delete() removed a file outside the outbox: %v", err)
+	}
+	if _, err := o.Get(job.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,298 @@
+// Package pdf writes simple PDF documents: pages of text, lines and filled
+// rectangles set in embedded TrueType fonts. It has no layout engine, callers
+// place everything at absolute positions.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Size is a page size in points
+type Size struct {
+	Width  float64
+	Height float64
+}
+
+// Color is an RGB color
+type Color struct {
+	R, G, B uint8
+}
+
+// Document is a PDF document under construction
+type Document struct {
+	Title        string
+	Author       string
+	Subject      string
+	Producer     string
+	CreationDate time.Time // omitted when zero
+
+	pages []*Page
+	fonts []*Font
+}
+
+// New creates an empty document
+func New() *Document {
+	return &Document{}
+}
+
+// AddPage appends a page of the given size
+func (d *Document) AddPage(size Size) *Page {
+	p := &Page{doc: d, size: size}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Pages returns the pages added so far
+func (d *Document) Pages() []*Page {
+	return d.pages
+}
+
+// fontName returns the resource name of f, adding it to the document on first use
+func (d *Document) fontName(f *Font) string {
+	for i, font := range d.fonts {
+		if font == f {
+			return fmt.Sprintf("F%d", i+1)
+		}
+	}
+	d.fonts = append(d.fonts, f)
+	return fmt.Sprintf("F%d", len(d.fonts))
+}
+
+// Page is a page of a Document. Positions are in points from the top left
+// corner of the page, text is placed by its baseline.
+type Page struct {
+	doc     *Document
+	size    Size
+	content bytes.Buffer
+}
+
+// Size returns the page size
+func (p *Page) Size() Size {
+	return p.size
+}
+
+// SetFillColor sets the color of text and filled shapes
+func (p *Page) SetFillColor(c Color) {
+	fmt.Fprintf(&p.content, "%s %s %s rg\n", colorComponent(c.R), colorComponent(c.G), colorComponent(c.B))
+}
+
+// SetStrokeColor sets the color of lines
+func (p *Page) SetStrokeColor(c Color) {
+	fmt.Fprintf(&p.content, "%s %s %s RG\n", colorComponent(c.R), colorComponent(c.G), colorComponent(c.B))
+}
+
+// FillRect fills a rectangle with the fill color
+func (p *Page) FillRect(x, y, width, height float64) {
+	fmt.Fprintf(&p.content, "%s %s %s %s re f\n", num(x), num(p.size.Height-y-height), num(width), num(height))
+}
+
+// Line draws a line of the given width in the stroke color
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n", num(width), num(x1), num(p.size.Height-y1), num(x2), num(p.size.Height-y2))
+}
+
+// Text writes s with its baseline starting at x, y in the fill color
+func (p *Page) Text(x, y float64, f *Font, size float64, s string) {
+	fmt.Fprintf(&p.content, "BT /%s %s Tf %s %s Td %s Tj ET\n",
+		p.doc.fontName(f), num(size), num(x), num(p.size.Height-y), literalString(encodeWinAnsi(s)))
+}
+
+// Bytes returns the encoded document
+func (d *Document) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTo writes the document with a classic cross-reference table, so that
+// it can be extended by incremental updates
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	// object numbers: catalog, page tree, info, then three per font and two per page
+	const catalogObj, pagesObj, infoObj = 1, 2, 3
+	fontObj := func(i int) int { return 4 + 3*i }
+	pageObj := func(i int) int { return 4 + 3*len(d.fonts) + 2*i }
+	// fonts are registered while pages are drawn, so count them before numbering pages
+	size := pageObj(len(d.pages))
+
+	out := &objectWriter{offsets: make([]int, size)}
+	out.buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	out.object(catalogObj, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj))
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", pageObj(i))
+	}
+	out.object(pagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	out.object(infoObj, d.info())
+
+	var fontRefs strings.Builder
+	for i, f := range d.fonts {
+		n := fontObj(i)
+		fmt.Fprintf(&fontRefs, " /F%d %d 0 R", i+1, n)
+		if err := out.font(n, f); err != nil {
+			return 0, err
+		}
+	}
+
+	for i, p := range d.pages {
+		n := pageObj(i)
+		out.object(n, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /Font <<%s >> >> /Contents %d 0 R >>",
+			pagesObj, num(p.size.Width), num(p.size.Height), fontRefs.String(), n+1))
+		if err := out.stream(n+1, "", p.content.Bytes()); err != nil {
+			return 0, err
+		}
+	}
+
+	// the ID only depends on the content, so equal documents are byte for byte equal
+	id := md5.Sum(out.buf.Bytes())
+	xref := out.buf.Len()
+	fmt.Fprintf(&out.buf, "xref\n0 %d\n0000000000 65535 f \n", size)
+	for _, offset := range out.offsets[1:] {
+		fmt.Fprintf(&out.buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out.buf, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R /ID [<%x> <%x>] >>\nstartxref\n%d\n%%%%EOF\n",
+		size, catalogObj, infoObj, id, id, xref)
+
+	n, err := w.Write(out.buf.Bytes())
+	return int64(n), err
+}
+
+// info returns the document information dictionary
+func (d *Document) info() string {
+	var b strings.Builder
+	b.WriteString("<<")
+	for _, entry := range []struct{ key, value string }{
+		{"Title", d.Title},
+		{"Author", d.Author},
+		{"Subject", d.Subject},
+		{"Producer", d.Producer},
+	} {
+		if entry.value != "" {
+			fmt.Fprintf(&b, " /%s %s", entry.key, TextString(entry.value))
+		}
+	}
+	if !d.CreationDate.IsZero() {
+		fmt.Fprintf(&b, " /CreationDate %s", literalString([]byte(Date(d.CreationDate))))
+	}
+	b.WriteString(" >>")
+	return b.String()
+}
+
+// objectWriter writes numbered objects and records their offsets
+type objectWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func (w *objectWriter) object(n int, body string) {
+	w.offsets[n] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n%s\nendobj\n", n, body)
+}
+
+// stream writes a Flate compressed stream with extra dictionary entries
+func (w *objectWriter) stream(n int, entries string, data []byte) error {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(data); err != nil {
+		return fmt.Errorf("failed to compress stream: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress stream: %w", err)
+	}
+	w.offsets[n] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n<< /Length %d /Filter /FlateDecode%s >>\nstream\n", n, compressed.Len(), prefixSpace(entries))
+	w.buf.Write(compressed.Bytes())
+	w.buf.WriteString("\nendstream\nendobj\n")
+	return nil
+}
+
+// font writes a font as objects n to n+2: the font, its descriptor and the font file
+func (w *objectWriter) font(n int, f *Font) error {
+	widths := make([]string, 0, 224)
+	for code := 32; code < 256; code++ {
+		widths = append(widths, strconv.Itoa(f.widths[code]))
+	}
+	w.object(n, fmt.Sprintf("<< /Type /Font /Subtype /TrueType /BaseFont /%s /FirstChar 32 /LastChar 255 /Widths [%s] /Encoding /WinAnsiEncoding /FontDescriptor %d 0 R >>",
+		f.name, strings.Join(widths, " "), n+1))
+	w.object(n+1, fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags %d /FontBBox [%d %d %d %d] /ItalicAngle %s /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		f.name, f.flags, f.bbox[0], f.bbox[1], f.bbox[2], f.bbox[3], num(f.italicAngle), f.ascent, f.descent, f.capHeight, n+2))
+	return w.stream(n+2, fmt.Sprintf("/Length1 %d", len(f.data)), f.data)
+}
+
+func prefixSpace(s string) string {
+	if s == "" {
+		return ""
+	}
+	return " " + s
+}
+
+// Date formats t as a PDF date string, e.g. D:20251103120000Z
+func Date(t time.Time) string {
+	return t.UTC().Format("D:20060102150405Z")
+}
+
+// TextString encodes s as a PDF text string, in UTF-16 when it is not plain ASCII
+func TextString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return literalString([]byte(s))
+	}
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// literalString encodes bytes as a PDF literal string
+func literalString(s []byte) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range s {
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// num formats a number with at most two decimals
+func num(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
+
+func colorComponent(c uint8) string {
+	return strconv.FormatFloat(float64(c)/255, 'f', 3, 64)
+}
@@ -0,0 +1,198 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func testFont(t *testing.T) *Font {
+	t.Helper()
+	f, err := ParseFont(goregular.TTF)
+	if err != nil {
+		t.Fatalf("ParseFont() error = %v", err)
+	}
+	return f
+}
+
+func TestParseFont(t *testing.T) {
+	f := testFont(t)
+	if f.Name() != "GoRegular" {
+		t.Errorf("Name() = %q, want GoRegular", f.Name())
+	}
+	if w := f.Width("M", 1000); w <= f.Width("i", 1000) || w > 1000 {
+		t.Errorf("Width(M) = %v, want wider than i and at most an em", w)
+	}
+	if got, want := f.Width("ab", 12), f.Width("a", 12)+f.Width("b", 12); got != want {
+		t.Errorf("Width(ab) = %v, want %v", got, want)
+	}
+	if f.Ascent(10) <= 0 || f.Descent(10) <= 0 {
+		t.Errorf("Ascent() = %v, Descent() = %v, want both positive", f.Ascent(10), f.Descent(10))
+	}
+}
+
+func TestEncodeWinAnsi(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []byte
+	}{
+		{"abc", []byte("abc")},
+		{"Café", []byte{'C', 'a', 'f', 0xe9}},
+		{"5 €", []byte{'5', ' ', 0x80}},
+		{"a\tb\nc", []byte("a b c")},
+		{"✓ 日", []byte("? ?")},
+	}
+	for _, tt := range tests {
+		if got := encodeWinAnsi(tt.in); !bytes.Equal(got, tt.want) {
+			t.Errorf("encodeWinAnsi(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFont_Wrap(t *testing.T) {
+	f := testFont(t)
+	width := f.Width("hello world", 10)
+
+	lines := f.Wrap("hello world hello world\nbye", 10, width)
+	want := []string{"hello world", "hello world", "bye"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("Wrap() = %q, want %q", lines, want)
+	}
+
+	// a word longer than the line is split
+	lines = f.Wrap("hellohellohellohello", 10, width)
+	if len(lines) < 2 || strings.Join(lines, "") != "hellohellohellohello" {
+		t.Errorf("Wrap() of a long word = %q", lines)
+	}
+	for _, line := range lines {
+		if f.Width(line, 10) > width {
+			t.Errorf("line %q wider than %v", line, width)
+		}
+	}
+
+	// a single character wider than the line still makes progress
+	if lines := f.Wrap("éé", 10, 1); strings.Join(lines, "") != "éé" {
+		t.Errorf("Wrap() into a narrow line = %q", lines)
+	}
+}
+
+func TestTextString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Invoice (1)", `(Invoice \(1\))`},
+		{`a\b`, `(a\\b)`},
+		{"Café", "<FEFF00430061006600E9>"},
+	}
+	for _, tt := range tests {
+		if got := TextString(tt.in); got != tt.want {
+			t.Errorf("TextString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDocument_WriteTo(t *testing.T) {
+	f := testFont(t)
+	doc := New()
+	doc.Title = "INV-1"
+	doc.Producer = "go-invoice"
+	doc.CreationDate = time.Date(2025, 11, 3, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		p := doc.AddPage(Size{Width: 600, Height: 800})
+		p.SetFillColor(Color{R: 255})
+		p.FillRect(10, 20, 100, 50)
+		p.SetStrokeColor(Color{})
+		p.Line(10, 100, 200, 100, 0.5)
+		p.Text(10, 50, f, 12, fmt.Sprintf("Page (%d) €", i+1))
+	}
+
+	data, err := doc.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error = %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-1.7\n")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatalf("missing PDF header or trailer")
+	}
+	for _, want := range []string{
+		"/Count 2",
+		"/Title (INV-1)",
+		"/CreationDate (D:20251103120000Z)",
+		"/BaseFont /GoRegular",
+		"/MediaBox [0 0 600 800]",
+	} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("document does not contain %q", want)
+		}
+	}
+	checkXref(t, data)
+
+	// content is flipped to PDF coordinates
+	content := inflateStream(t, data, 8)
+	for _, want := range []string{
+		"1.000 0.000 0.000 rg",
+		"10 730 100 50 re f",
+		"0.5 w 10 700 m 200 700 l S",
+		"BT /F1 12 Tf 10 750 Td (Page \\(1\\) \x80) Tj ET",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("page content does not contain %q:\n%s", want, content)
+		}
+	}
+
+	again, _ := doc.Bytes()
+	if !bytes.Equal(data, again) {
+		t.Error("writing the same document twice gave different bytes")
+	}
+}
+
+// checkXref verifies that every cross-reference entry points at its object
+func checkXref(t *testing.T, data []byte) {
+	t.Helper()
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	start, _ := strconv.Atoi(string(m[1]))
+	lines := strings.Split(string(data[start:]), "\n")
+	if lines[0] != "xref" {
+		t.Fatalf("startxref points at %q, want xref", lines[0])
+	}
+	var first, count int
+	fmt.Sscanf(lines[1], "%d %d", &first, &count)
+	for i := 1; i < count; i++ {
+		offset, _ := strconv.Atoi(lines[2+i][:10])
+		if want := fmt.Sprintf("%d 0 obj", first+i); !bytes.HasPrefix(data[offset:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q", first+i, data[offset:offset+10])
+		}
+	}
+}
+
+// inflateStream returns the decompressed stream of object n
+func inflateStream(t *testing.T, data []byte, n int) string {
+	t.Helper()
+	start := bytes.Index(data, []byte(fmt.Sprintf("\n%d 0 obj\n", n)))
+	if start < 0 {
+		t.Fatalf("object %d not found", n)
+	}
+	body := data[start:]
+	body = body[bytes.Index(body, []byte("stream\n"))+len("stream\n"):]
+	r, err := zlib.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("object %d is not a Flate stream: %v", n, err)
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to inflate object %d: %v", n, err)
+	}
+	return string(content)
+}
@@ -0,0 +1,213 @@
+package pdf
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// Font is a TrueType font embedded whole into the documents using it. Text is
+// written in WinAnsiEncoding, so characters outside Windows-1252 print as '?'.
+type Font struct {
+	name        string // PostScript name
+	data        []byte
+	widths      [256]int // advance widths in 1/1000 em by character code
+	bbox        [4]int
+	italicAngle float64
+	ascent      int
+	descent     int
+	capHeight   int
+	flags       int
+}
+
+// font descriptor flags, see PDF 32000-1:2008 table 123
+const (
+	flagFixedPitch  = 1 << 0
+	flagNonsymbolic = 1 << 5
+	flagItalic      = 1 << 6
+)
+
+// ParseFont reads a TrueType font
+func ParseFont(data []byte) (*Font, error) {
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %w", err)
+	}
+	var buf sfnt.Buffer
+	unitsPerEm := float64(f.UnitsPerEm())
+	ppem := fixed.I(int(f.UnitsPerEm()))
+	scale := func(v fixed.Int26_6) int {
+		return int(math.Round(float64(v) / 64 * 1000 / unitsPerEm))
+	}
+
+	name, err := f.Name(&buf, sfnt.NameIDPostScript)
+	if err != nil {
+		return nil, fmt.Errorf("font has no PostScript name: %w", err)
+	}
+	out := &Font{
+		name:  strings.Map(func(r rune) rune { return pdfNameRune(r) }, name),
+		data:  data,
+		flags: flagNonsymbolic,
+	}
+
+	for code := 32; code < 256; code++ {
+		r := winAnsiRunes[code]
+		if r == 0 {
+			continue
+		}
+		glyph, err := f.GlyphIndex(&buf, r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up glyph for %q: %w", r, err)
+		}
+		advance, err := f.GlyphAdvance(&buf, glyph, ppem, font.HintingNone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read width of %q: %w", r, err)
+		}
+		out.widths[code] = scale(advance)
+	}
+
+	bounds, err := f.Bounds(&buf, ppem, font.HintingNone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font bounds: %w", err)
+	}
+	// sfnt measures y downwards, PDF upwards
+	out.bbox = [4]int{scale(bounds.Min.X), -scale(bounds.Max.Y), scale(bounds.Max.X), -scale(bounds.Min.Y)}
+
+	metrics, err := f.Metrics(&buf, ppem, font.HintingNone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font metrics: %w", err)
+	}
+	out.ascent = scale(metrics.Ascent)
+	out.descent = -scale(metrics.Descent)
+	out.capHeight = scale(metrics.CapHeight)
+	if out.capHeight == 0 {
+		out.capHeight = out.ascent
+	}
+
+	if post := f.PostTable(); post != nil {
+		out.italicAngle = post.ItalicAngle
+		if post.IsFixedPitch {
+			out.flags |= flagFixedPitch
+		}
+		if post.ItalicAngle != 0 {
+			out.flags |= flagItalic
+		}
+	}
+	return out, nil
+}
+
+// Name returns the PostScript name of the font
+func (f *Font) Name() string {
+	return f.name
+}
+
+// Width returns the width of s in points when set in the given size
+func (f *Font) Width(s string, size float64) float64 {
+	total := 0
+	for _, c := range encodeWinAnsi(s) {
+		total += f.widths[c]
+	}
+	return float64(total) * size / 1000
+}
+
+// Ascent returns the height above the baseline in points for the given size
+func (f *Font) Ascent(size float64) float64 {
+	return float64(f.ascent) * size / 1000
+}
+
+// Descent returns the depth below the baseline in points for the given size, as a positive number
+func (f *Font) Descent(size float64) float64 {
+	return float64(-f.descent) * size / 1000
+}
+
+// Wrap breaks s into lines no wider than width, splitting at spaces and at
+// line breaks in s. Words longer than width are split between characters.
+func (f *Font) Wrap(s string, size, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if f.Width(candidate, size) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			// break words that do not fit on a line of their own
+			for f.Width(word, size) > width {
+				_, first := utf8.DecodeRuneInString(word)
+				n := len(word)
+				for n > first && f.Width(word[:n], size) > width {
+					_, last := utf8.DecodeLastRuneInString(word[:n])
+					n -= last
+				}
+				lines = append(lines, word[:n])
+				word = word[n:]
+			}
+			line = word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// pdfNameRune keeps the characters allowed unescaped in a PDF name
+func pdfNameRune(r rune) rune {
+	if r <= ' ' || r > '~' || strings.ContainsRune("()<>[]{}/%#", r) {
+		return -1
+	}
+	return r
+}
+
+// encodeWinAnsi converts s to WinAnsiEncoding, replacing unknown characters with '?'
+func encodeWinAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			out = append(out, ' ')
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			out = append(out, byte(r))
+		default:
+			if c, ok := winAnsiCodes[r]; ok {
+				out = append(out, c)
+			} else {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
+
+// winAnsiRunes maps the codes of WinAnsiEncoding to Unicode, 0 where undefined
+var winAnsiRunes = func() [256]rune {
+	var runes [256]rune
+	for c := 0x20; c < 0x7f; c++ {
+		runes[c] = rune(c)
+	}
+	for c := 0xa0; c <= 0xff; c++ {
+		runes[c] = rune(c)
+	}
+	for r, c := range winAnsiCodes {
+		runes[c] = r
+	}
+	return runes
+}()
+
+// winAnsiCodes maps the Windows-1252 characters of 0x80 to 0x9f to their codes
+var winAnsiCodes = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
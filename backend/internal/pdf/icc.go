@@ -0,0 +1,92 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// sRGBProfile builds a version 2 ICC profile of the sRGB color space, the
+// output intent of PDF/A documents. Colorants are adapted to the D50 white of
+// the profile connection space.
+func sRGBProfile() []byte {
+	xyz := func(x, y, z float64) []byte {
+		var b bytes.Buffer
+		b.WriteString("XYZ \x00\x00\x00\x00")
+		for _, v := range []float64{x, y, z} {
+			binary.Write(&b, binary.BigEndian, int32(math.Round(v*65536)))
+		}
+		return b.Bytes()
+	}
+	text := func(s string) []byte {
+		return append([]byte("text\x00\x00\x00\x00"+s), 0)
+	}
+	desc := func(s string) []byte {
+		var b bytes.Buffer
+		b.WriteString("desc\x00\x00\x00\x00")
+		binary.Write(&b, binary.BigEndian, uint32(len(s)+1))
+		b.WriteString(s)
+		b.WriteByte(0)
+		b.Write(make([]byte, 4+4+2+1+67)) // no Unicode or ScriptCode description
+		return b.Bytes()
+	}
+	curve := func() []byte {
+		const n = 1024
+		var b bytes.Buffer
+		b.WriteString("curv\x00\x00\x00\x00")
+		binary.Write(&b, binary.BigEndian, uint32(n))
+		for i := 0; i < n; i++ {
+			v := float64(i) / (n - 1)
+			if v <= 0.04045 {
+				v /= 12.92
+			} else {
+				v = math.Pow((v+0.055)/1.055, 2.4)
+			}
+			binary.Write(&b, binary.BigEndian, uint16(math.Round(v*65535)))
+		}
+		return b.Bytes()
+	}()
+
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", desc("sRGB IEC61966-2.1")},
+		{"cprt", text("No copyright, use freely")},
+		{"wtpt", xyz(0.9642, 1.0, 0.8249)},
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", curve},
+		{"gTRC", curve},
+		{"bTRC", curve},
+	}
+
+	// tag data follows the header and tag table, each aligned to four bytes
+	var table, data bytes.Buffer
+	offset := 128 + 4 + 12*len(tags)
+	binary.Write(&table, binary.BigEndian, uint32(len(tags)))
+	for _, tag := range tags {
+		table.WriteString(tag.sig)
+		binary.Write(&table, binary.BigEndian, uint32(offset+data.Len()))
+		binary.Write(&table, binary.BigEndian, uint32(len(tag.data)))
+		data.Write(tag.data)
+		for data.Len()%4 != 0 {
+			data.WriteByte(0)
+		}
+	}
+
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[0:], uint32(offset+data.Len()))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000) // version 2.1
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	for i, v := range []uint16{2025, 1, 1, 0, 0, 0} {
+		binary.BigEndian.PutUint16(header[24+2*i:], v)
+	}
+	copy(header[36:], "acsp")
+	copy(header[68:], xyz(0.9642, 1.0, 0.8249)[8:]) // D50 illuminant
+
+	return append(append(header, table.Bytes()...), data.Bytes()...)
+}
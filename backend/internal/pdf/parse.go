@@ -0,0 +1,402 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrUnsupported is returned for PDFs this package cannot update, e.g.
+// encrypted files or files with cross-reference streams
+var ErrUnsupported = errors.New("unsupported pdf")
+
+// ref is an indirect reference to an object
+type ref struct {
+	num, gen int
+}
+
+func (r ref) String() string {
+	return fmt.Sprintf("%d %d R", r.num, r.gen)
+}
+
+// dictEntry is an entry of a dictionary with its value as written in the file
+type dictEntry struct {
+	key   string // without the slash
+	value string
+}
+
+// dict is a parsed dictionary. Values stay raw, only what an update changes is interpreted.
+type dict []dictEntry
+
+// get returns the raw value of key
+func (d dict) get(key string) (string, bool) {
+	for _, e := range d {
+		if e.key == key {
+			return e.value, true
+		}
+	}
+	return "", false
+}
+
+// set replaces or appends the value of key
+func (d dict) set(key, value string) dict {
+	for i, e := range d {
+		if e.key == key {
+			d[i].value = value
+			return d
+		}
+	}
+	return append(d, dictEntry{key, value})
+}
+
+// ref returns the value of key as an indirect reference
+func (d dict) ref(key string) (ref, bool) {
+	value, ok := d.get(key)
+	if !ok {
+		return ref{}, false
+	}
+	return parseRef(value)
+}
+
+// parseRef reads an indirect reference, e.g. 12 0 R
+func parseRef(value string) (ref, bool) {
+	var r ref
+	var kw string
+	if n, _ := fmt.Sscanf(value, "%d %d %s", &r.num, &r.gen, &kw); n != 3 || kw != "R" {
+		return ref{}, false
+	}
+	return r, true
+}
+
+func (d dict) String() string {
+	var b bytes.Buffer
+	b.WriteString("<<")
+	for _, e := range d {
+		fmt.Fprintf(&b, " /%s %s", e.key, e.value)
+	}
+	b.WriteString(" >>")
+	return b.String()
+}
+
+// file is an existing PDF read for an incremental update
+type file struct {
+	data      []byte
+	offsets   map[int]int // object number to offset, newest revision first
+	trailer   dict
+	startxref int
+	size      int // number of objects, the next free object number
+}
+
+// readFile parses the cross-reference tables and the trailer of a PDF
+func readFile(data []byte) (*file, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, fmt.Errorf("not a pdf")
+	}
+	i := bytes.LastIndex(data, []byte("startxref"))
+	if i < 0 {
+		return nil, fmt.Errorf("invalid pdf: missing startxref")
+	}
+	fields := bytes.Fields(data[i+len("startxref"):])
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("invalid pdf: bad startxref")
+	}
+	start, err := strconv.Atoi(string(fields[0]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pdf: bad startxref")
+	}
+
+	f := &file{data: data, offsets: make(map[int]int), startxref: start}
+	seen := make(map[int]bool)
+	for offset := start; ; {
+		if seen[offset] {
+			return nil, fmt.Errorf("invalid pdf: cross-reference loop")
+		}
+		seen[offset] = true
+		trailer, err := f.readXref(offset)
+		if err != nil {
+			return nil, err
+		}
+		if f.trailer == nil {
+			f.trailer = trailer
+		}
+		prev, ok := trailer.get("Prev")
+		if !ok {
+			break
+		}
+		if offset, err = strconv.Atoi(prev); err != nil {
+			return nil, fmt.Errorf("invalid pdf: bad /Prev")
+		}
+	}
+
+	if _, encrypted := f.trailer.get("Encrypt"); encrypted {
+		return nil, fmt.Errorf("%w: encrypted", ErrUnsupported)
+	}
+	size, _ := f.trailer.get("Size")
+	if f.size, err = strconv.Atoi(size); err != nil {
+		return nil, fmt.Errorf("invalid pdf: bad trailer /Size")
+	}
+	return f, nil
+}
+
+// readXref reads a classic cross-reference section and its trailer.
+// Entries already known from a newer section are kept.
+func (f *file) readXref(offset int) (dict, error) {
+	if offset < 0 || offset >= len(f.data) {
+		return nil, fmt.Errorf("invalid pdf: cross-reference offset out of range")
+	}
+	s := &scanner{data: f.data, pos: offset}
+	if kw := s.keyword(); kw != "xref" {
+		// PDF 1.5 files may store it in a compressed stream object instead
+		return nil, fmt.Errorf("%w: cross-reference streams", ErrUnsupported)
+	}
+	for {
+		s.skipSpace()
+		if bytes.HasPrefix(f.data[s.pos:], []byte("trailer")) {
+			s.pos += len("trailer")
+			return s.dict()
+		}
+		first, err1 := strconv.Atoi(s.keyword())
+		count, err2 := strconv.Atoi(s.keyword())
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid pdf: bad cross-reference subsection")
+		}
+		for n := first; n < first+count; n++ {
+			entryOffset, err1 := strconv.Atoi(s.keyword())
+			_, err2 := strconv.Atoi(s.keyword())
+			kind := s.keyword()
+			if err1 != nil || err2 != nil || (kind != "n" && kind != "f") {
+				return nil, fmt.Errorf("invalid pdf: bad cross-reference entry for object %d", n)
+			}
+			if _, known := f.offsets[n]; !known {
+				if kind == "n" {
+					f.offsets[n] = entryOffset
+				} else {
+					f.offsets[n] = -1
+				}
+			}
+		}
+	}
+}
+
+// object reads the dictionary of an indirect object
+func (f *file) object(r ref) (dict, error) {
+	value, err := f.raw(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseDict(value)
+}
+
+// raw reads the value of an indirect object as written, e.g. an array
+func (f *file) raw(r ref) (string, error) {
+	offset, ok := f.offsets[r.num]
+	if !ok || offset < 0 || offset >= len(f.data) {
+		return "", fmt.Errorf("invalid pdf: object %d not found", r.num)
+	}
+	s := &scanner{data: f.data, pos: offset}
+	num, _ := strconv.Atoi(s.keyword())
+	s.keyword() // generation
+	if num != r.num || s.keyword() != "obj" {
+		return "", fmt.Errorf("invalid pdf: object %d not at its offset", r.num)
+	}
+	return s.value()
+}
+
+// resolve returns value, or the value of the object it refers to
+func (f *file) resolve(value string) (string, error) {
+	if r, ok := parseRef(value); ok {
+		return f.raw(r)
+	}
+	return value, nil
+}
+
+// parseArray reads an array value
+func parseArray(value string) ([]string, error) {
+	s := &scanner{data: []byte(value)}
+	return s.array()
+}
+
+// parseDict reads a dictionary value
+func parseDict(value string) (dict, error) {
+	s := &scanner{data: []byte(value)}
+	return s.dict()
+}
+
+// scanner reads the few PDF syntax elements an update needs
+type scanner struct {
+	data []byte
+	pos  int
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+// skipSpace skips white space and comments
+func (s *scanner) skipSpace() {
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		switch {
+		case isSpace(c):
+			s.pos++
+		case c == '%':
+			for s.pos < len(s.data) && s.data[s.pos] != '\n' && s.data[s.pos] != '\r' {
+				s.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// keyword reads a run of regular characters, e.g. a number or obj
+func (s *scanner) keyword() string {
+	s.skipSpace()
+	start := s.pos
+	for s.pos < len(s.data) && !isSpace(s.data[s.pos]) && !isDelimiter(s.data[s.pos]) {
+		s.pos++
+	}
+	return string(s.data[start:s.pos])
+}
+
+// dict reads a dictionary, keeping each value as written
+func (s *scanner) dict() (dict, error) {
+	s.skipSpace()
+	if !bytes.HasPrefix(s.data[s.pos:], []byte("<<")) {
+		return nil, fmt.Errorf("invalid pdf: expected a dictionary at offset %d", s.pos)
+	}
+	s.pos += 2
+	var d dict
+	for {
+		s.skipSpace()
+		if s.pos >= len(s.data) {
+			return nil, fmt.Errorf("invalid pdf: unterminated dictionary")
+		}
+		if bytes.HasPrefix(s.data[s.pos:], []byte(">>")) {
+			s.pos += 2
+			return d, nil
+		}
+		if s.data[s.pos] != '/' {
+			return nil, fmt.Errorf("invalid pdf: expected a name at offset %d", s.pos)
+		}
+		s.pos++
+		key := s.keyword()
+		value, err := s.value()
+		if err != nil {
+			return nil, err
+		}
+		d = append(d, dictEntry{key, value})
+	}
+}
+
+// array reads an array, keeping each element as written
+func (s *scanner) array() ([]string, error) {
+	s.skipSpace()
+	if s.pos >= len(s.data) || s.data[s.pos] != '[' {
+		return nil, fmt.Errorf("invalid pdf: expected an array at offset %d", s.pos)
+	}
+	s.pos++
+	var items []string
+	for {
+		s.skipSpace()
+		if s.pos >= len(s.data) {
+			return nil, fmt.Errorf("invalid pdf: unterminated array")
+		}
+		if s.data[s.pos] == ']' {
+			s.pos++
+			return items, nil
+		}
+		item, err := s.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+// value reads one value as written, taking a reference as a whole
+func (s *scanner) value() (string, error) {
+	s.skipSpace()
+	start := s.pos
+	if err := s.skipValue(); err != nil {
+		return "", err
+	}
+	// a reference is three tokens
+	if isNumber(s.data[start:s.pos]) {
+		save := s.pos
+		gen, kw := s.keyword(), s.keyword()
+		if !isNumber([]byte(gen)) || kw != "R" {
+			s.pos = save
+		}
+	}
+	return string(bytes.TrimSpace(s.data[start:s.pos])), nil
+}
+
+func isNumber(b []byte) bool {
+	_, err := strconv.ParseFloat(string(b), 64)
+	return len(b) > 0 && err == nil
+}
+
+// skipValue moves past one object: a dictionary, array, string, name or keyword
+func (s *scanner) skipValue() error {
+	s.skipSpace()
+	if s.pos >= len(s.data) {
+		return fmt.Errorf("invalid pdf: unexpected end of file")
+	}
+	switch c := s.data[s.pos]; {
+	case bytes.HasPrefix(s.data[s.pos:], []byte("<<")):
+		_, err := s.dict()
+		return err
+	case c == '<':
+		end := bytes.IndexByte(s.data[s.pos:], '>')
+		if end < 0 {
+			return fmt.Errorf("invalid pdf: unterminated hex string")
+		}
+		s.pos += end + 1
+	case c == '(':
+		depth := 0
+		for ; s.pos < len(s.data); s.pos++ {
+			switch s.data[s.pos] {
+			case '\\':
+				s.pos++
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					s.pos++
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("invalid pdf: unterminated string")
+	case c == '[':
+		s.pos++
+		for {
+			s.skipSpace()
+			if s.pos >= len(s.data) {
+				return fmt.Errorf("invalid pdf: unterminated array")
+			}
+			if s.data[s.pos] == ']' {
+				s.pos++
+				return nil
+			}
+			if err := s.skipValue(); err != nil {
+				return err
+			}
+		}
+	case c == '/':
+		s.pos++
+		s.keyword()
+	default:
+		if s.keyword() == "" {
+			return fmt.Errorf("invalid pdf: unexpected %q at offset %d", c, s.pos)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package pdf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Attachment is a file embedded in a PDF/A-3 document as an associated file
+type Attachment struct {
+	Name         string // file name, e.g. factur-x.xml
+	Description  string
+	MIMEType     string // e.g. text/xml
+	Relationship string // how it relates to the document: Data, Source, Alternative or Supplement
+	ModDate      time.Time
+	Data         []byte
+}
+
+// ArchiveInfo describes a document converted to PDF/A-3
+type ArchiveInfo struct {
+	Title       string
+	Author      string
+	Subject     string
+	Producer    string
+	Date        time.Time // creation and modification date in the metadata
+	Attachments []Attachment
+	XMP         string // extra rdf:Description elements, e.g. the schemas of an attachment
+}
+
+// ToPDFA3 turns a PDF into a PDF/A-3b document. The original bytes are kept
+// and an incremental update adds the XMP metadata, an sRGB output intent and
+// the attachments, and replaces the document information. Conformance also
+// depends on the original: its fonts must be embedded, which both this
+// package and Chrome do.
+func ToPDFA3(data []byte, info ArchiveInfo) ([]byte, error) {
+	f, err := readFile(data)
+	if err != nil {
+		return nil, err
+	}
+	u, err := newUpdate(f)
+	if err != nil {
+		return nil, err
+	}
+
+	// output intent
+	iccObj := u.newObj()
+	if err := u.stream(iccObj, "/N 3", sRGBProfile()); err != nil {
+		return nil, err
+	}
+	intentObj := u.newObj()
+	u.object(intentObj, fmt.Sprintf("<< /Type /OutputIntent /S /GTS_PDFA1 /OutputConditionIdentifier (sRGB IEC61966-2.1) /Info (sRGB IEC61966-2.1) /DestOutputProfile %d 0 R >>", iccObj))
+
+	// attachments, named in sorted order as the name tree requires
+	attachments := append([]Attachment(nil), info.Attachments...)
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].Name < attachments[j].Name })
+	var names, afs []string
+	for _, a := range attachments {
+		fileObj := u.newObj()
+		entries := fmt.Sprintf("/Type /EmbeddedFile /Subtype %s /Params << /Size %d /ModDate %s >>",
+			name(a.MIMEType), len(a.Data), literalString([]byte(Date(a.ModDate))))
+		if err := u.stream(fileObj, entries, a.Data); err != nil {
+			return nil, err
+		}
+		specObj := u.newObj()
+		u.object(specObj, fmt.Sprintf("<< /Type /Filespec /F %s /UF %s /Desc %s /AFRelationship /%s /EF << /F %d 0 R /UF %d 0 R >> >>",
+			TextString(a.Name), TextString(a.Name), TextString(a.Description), a.Relationship, fileObj, fileObj))
+		names = append(names, fmt.Sprintf("%s %d 0 R", TextString(a.Name), specObj))
+		afs = append(afs, fmt.Sprintf("%d 0 R", specObj))
+	}
+	if len(attachments) > 0 {
+		embedded := fmt.Sprintf("<< /Names [%s] >>", strings.Join(names, " "))
+		if r, ok := u.catalog.ref("Names"); ok && r.gen == 0 {
+			// the name dictionary is shared, update it in place
+			tree, err := f.object(r)
+			if err != nil {
+				return nil, err
+			}
+			u.object(r.num, tree.set("EmbeddedFiles", embedded).String())
+		} else {
+			tree := dict{}
+			if value, ok := u.catalog.get("Names"); ok && strings.HasPrefix(value, "<<") {
+				s := &scanner{data: []byte(value)}
+				if tree, err = s.dict(); err != nil {
+					return nil, err
+				}
+			}
+			u.catalog = u.catalog.set("Names", tree.set("EmbeddedFiles", embedded).String())
+		}
+		u.catalog = u.catalog.set("AF", fmt.Sprintf("[%s]", strings.Join(afs, " ")))
+	}
+
+	// metadata, stored uncompressed so it can be found without a PDF parser
+	metadataObj := u.newObj()
+	u.plainStream(metadataObj, "/Type /Metadata /Subtype /XML", []byte(info.xmp()))
+
+	infoObj := u.newObj()
+	u.object(infoObj, info.info())
+	u.trailer = u.trailer.set("Info", fmt.Sprintf("%d 0 R", infoObj))
+
+	u.catalog = u.catalog.set("Metadata", fmt.Sprintf("%d 0 R", metadataObj))
+	u.catalog = u.catalog.set("OutputIntents", fmt.Sprintf("[%d 0 R]", intentObj))
+	return u.finish(), nil
+}
+
+// name encodes s as a PDF name, e.g. text/xml as /text#2Fxml
+func name(s string) string {
+	var b strings.Builder
+	b.WriteByte('/')
+	for _, c := range []byte(s) {
+		if c < '!' || c > '~' || isDelimiter(c) || c == '#' {
+			fmt.Fprintf(&b, "#%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// info returns the document information dictionary, matching the XMP metadata
+func (a ArchiveInfo) info() string {
+	var b strings.Builder
+	b.WriteString("<<")
+	for _, entry := range []struct{ key, value string }{
+		{"Title", a.Title},
+		{"Author", a.Author},
+		{"Subject", a.Subject},
+		{"Producer", a.Producer},
+	} {
+		if entry.value != "" {
+			fmt.Fprintf(&b, " /%s %s", entry.key, TextString(entry.value))
+		}
+	}
+	date := literalString([]byte(Date(a.Date)))
+	fmt.Fprintf(&b, " /CreationDate %s /ModDate %s >>", date, date)
+	return b.String()
+}
+
+// xmp returns the XMP metadata packet declaring PDF/A-3b conformance
+func (a ArchiveInfo) xmp() string {
+	esc := func(s string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+	date := a.Date.UTC().Format("2006-01-02T15:04:05Z")
+
+	var b strings.Builder
+	b.WriteString("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("<rdf:Description rdf:about=\"\" xmlns:pdfaid=\"http://www.aiim.org/pdfa/ns/id/\">\n")
+	b.WriteString("<pdfaid:part>3</pdfaid:part>\n<pdfaid:conformance>B</pdfaid:conformance>\n</rdf:Description>\n")
+	b.WriteString("<rdf:Description rdf:about=\"\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\">\n<dc:format>application/pdf</dc:format>\n")
+	if a.Title != "" {
+		fmt.Fprintf(&b, "<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n", esc(a.Title))
+	}
+	if a.Author != "" {
+		fmt.Fprintf(&b, "<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n", esc(a.Author))
+	}
+	if a.Subject != "" {
+		fmt.Fprintf(&b, "<dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:description>\n", esc(a.Subject))
+	}
+	b.WriteString("</rdf:Description>\n")
+	b.WriteString("<rdf:Description rdf:about=\"\" xmlns:pdf=\"http://ns.adobe.com/pdf/1.3/\" xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\">\n")
+	if a.Producer != "" {
+		fmt.Fprintf(&b, "<pdf:Producer>%s</pdf:Producer>\n", esc(a.Producer))
+	}
+	fmt.Fprintf(&b, "<xmp:CreateDate>%s</xmp:CreateDate>\n<xmp:ModifyDate>%s</xmp:ModifyDate>\n</rdf:Description>\n", date, date)
+	b.WriteString(a.XMP)
+	b.WriteString("</rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"w\"?>")
+	return b.String()
+}
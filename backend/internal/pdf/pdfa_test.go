@@ -0,0 +1,179 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildPDF writes objects 1..n with a classic cross-reference table, object 1 is the catalog
+func buildPDF(trailer string, objects ...string) []byte {
+	out := &objectWriter{offsets: make([]int, len(objects)+1)}
+	out.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	for i, body := range objects {
+		out.object(i+1, body)
+	}
+	xref := out.buf.Len()
+	fmt.Fprintf(&out.buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range out.offsets[1:] {
+		fmt.Fprintf(&out.buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out.buf, "trailer\n<< /Size %d /Root 1 0 R%s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, xref)
+	return out.buf.Bytes()
+}
+
+func TestToPDFA3(t *testing.T) {
+	original := testDocument(t)
+	before, err := readFile(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2025, 11, 3, 12, 0, 0, 0, time.UTC)
+	xmlData := []byte("<Invoice>INV-1</Invoice>")
+
+	data, err := ToPDFA3(original, ArchiveInfo{
+		Title:    "INV-1",
+		Author:   "Jane Smith & Co",
+		Producer: "go-invoice",
+		Date:     date,
+		Attachments: []Attachment{{
+			Name: "factur-x.xml", Description: "Factur-X Invoice", MIMEType: "text/xml",
+			Relationship: "Data", ModDate: date, Data: xmlData,
+		}},
+		XMP: "<rdf:Description rdf:about=\"\" xmlns:fx=\"urn:factur-x\"><fx:Version>1.0</fx:Version></rdf:Description>\n",
+	})
+	if err != nil {
+		t.Fatalf("ToPDFA3() error = %v", err)
+	}
+	if !bytes.HasPrefix(data, original) {
+		t.Fatal("the update does not keep the original bytes")
+	}
+
+	// the updated file parses, with the catalog replaced by its new revision
+	f, err := readFile(data)
+	if err != nil {
+		t.Fatalf("reading the updated pdf: %v", err)
+	}
+	root, _ := f.trailer.ref("Root")
+	catalog, err := f.object(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"Pages", "Metadata", "OutputIntents", "AF", "Names"} {
+		if _, ok := catalog.get(key); !ok {
+			t.Errorf("catalog misses /%s: %s", key, catalog)
+		}
+	}
+	infoRef, _ := f.trailer.ref("Info")
+	info, err := f.object(infoRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := info.get("ModDate"); v != "(D:20251103120000Z)" {
+		t.Errorf("info /ModDate = %s", v)
+	}
+	if prev, _ := f.trailer.get("Prev"); prev != fmt.Sprint(before.startxref) {
+		t.Errorf("trailer /Prev = %s, want %d", prev, before.startxref)
+	}
+	id, _ := before.trailer.get("ID")
+	if newID, _ := f.trailer.get("ID"); !strings.HasPrefix(newID, id[:strings.Index(id, ">")+1]) {
+		t.Errorf("trailer /ID = %s does not keep the original %s", newID, id)
+	}
+	for n, offset := range f.offsets {
+		if offset > 0 && !bytes.HasPrefix(data[offset:], []byte(fmt.Sprintf("%d 0 obj", n))) {
+			t.Errorf("xref entry %d points at %q", n, data[offset:offset+10])
+		}
+	}
+
+	for _, want := range []string{
+		"<pdfaid:part>3</pdfaid:part>",
+		"<pdfaid:conformance>B</pdfaid:conformance>",
+		"<dc:creator><rdf:Seq><rdf:li>Jane Smith &amp; Co</rdf:li></rdf:Seq></dc:creator>",
+		"<xmp:ModifyDate>2025-11-03T12:00:00Z</xmp:ModifyDate>",
+		"<fx:Version>1.0</fx:Version>",
+		"/Type /EmbeddedFile /Subtype /text#2Fxml",
+		"/AFRelationship /Data",
+		"/S /GTS_PDFA1",
+	} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("pdf does not contain %q", want)
+		}
+	}
+
+	// new objects: profile, output intent, file, file specification, metadata, info
+	if icc := inflateStream(t, data, before.size); icc[36:40] != "acsp" {
+		t.Error("output intent profile is not an ICC profile")
+	}
+	if got := inflateStream(t, data, before.size+2); got != string(xmlData) {
+		t.Errorf("embedded file = %q", got)
+	}
+}
+
+func TestToPDFA3_Names(t *testing.T) {
+	attach := ArchiveInfo{
+		Date:        time.Now(),
+		Attachments: []Attachment{{Name: "a.xml", MIMEType: "text/xml", Relationship: "Data"}},
+	}
+	pages := "<< /Type /Pages /Kids [] /Count 0 >>"
+
+	// an inline name dictionary keeps its other trees
+	data, err := ToPDFA3(buildPDF("", "<</Type/Catalog/Pages 2 0 R/Names<</Dests<</Names[(a)[2 0 R]]>>>>/PageMode/UseNone>>", pages), attach)
+	if err != nil {
+		t.Fatalf("ToPDFA3() error = %v", err)
+	}
+	f, _ := readFile(data)
+	catalog, _ := f.object(ref{num: 1})
+	names, _ := catalog.get("Names")
+	if !strings.Contains(names, "/Dests <</Names[(a)[2 0 R]]>>") || !strings.Contains(names, "/EmbeddedFiles") {
+		t.Errorf("catalog /Names = %s", names)
+	}
+	if mode, _ := catalog.get("PageMode"); mode != "/UseNone" {
+		t.Errorf("catalog /PageMode = %q", mode)
+	}
+
+	// a shared name dictionary is updated in place
+	data, err = ToPDFA3(buildPDF("", "<< /Type /Catalog /Pages 2 0 R /Names 3 0 R >>", pages, "<< /Dests 4 0 R >>", "<< >>"), attach)
+	if err != nil {
+		t.Fatalf("ToPDFA3() error = %v", err)
+	}
+	f, _ = readFile(data)
+	catalog, _ = f.object(ref{num: 1})
+	tree, _ := f.object(ref{num: 3})
+	if v, _ := catalog.get("Names"); v != "3 0 R" {
+		t.Errorf("catalog /Names = %s, want the shared dictionary", v)
+	}
+	dests, _ := tree.get("Dests")
+	if _, ok := tree.get("EmbeddedFiles"); !ok || dests != "4 0 R" {
+		t.Errorf("name dictionary = %s", tree)
+	}
+}
+
+func TestToPDFA3_Unsupported(t *testing.T) {
+	if _, err := ToPDFA3([]byte("hello"), ArchiveInfo{}); err == nil {
+		t.Error("ToPDFA3() of a non-pdf did not fail")
+	}
+	original := buildPDF("", "<< /Type /Catalog >>")
+	xrefStream := bytes.Replace(original, []byte("\nxref\n"), []byte("\n9 0 obj\n"), 1)
+	if _, err := ToPDFA3(xrefStream, ArchiveInfo{}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("ToPDFA3() with a cross-reference stream error = %v, want ErrUnsupported", err)
+	}
+	encrypted := buildPDF(" /Encrypt 2 0 R", "<< /Type /Catalog >>", "<< >>")
+	if _, err := ToPDFA3(encrypted, ArchiveInfo{}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("ToPDFA3() of an encrypted pdf error = %v, want ErrUnsupported", err)
+	}
+}
+
+func testDocument(t *testing.T) []byte {
+	t.Helper()
+	doc := New()
+	doc.Title = "INV-1"
+	doc.AddPage(Size{Width: 600, Height: 800}).Text(10, 50, testFont(t), 12, "Invoice")
+	data, err := doc.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,452 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// SignatureSize is the space reserved for the encoded signature, enough for
+// a CMS signature with a certificate chain of a few certificates
+const SignatureSize = 16384
+
+// byteRangePlaceholder is replaced by the actual byte range once the update is written
+const byteRangePlaceholder = "[0 0000000000 0000000000 0000000000]"
+
+// signature appearance, in points
+const (
+	appearanceWidth    = 220
+	appearanceMargin   = 36 // from the bottom right corner of the page
+	appearanceFontSize = 8
+	appearanceLeading  = 10
+	appearancePadding  = 6
+)
+
+// SignatureInfo describes a signature added by Sign
+type SignatureInfo struct {
+	Name     string // signer, usually the certificate's common name
+	Reason   string
+	Location string
+	Date     time.Time
+	Visible  bool  // draw a signature block at the bottom right of the last page
+	Font     *Font // font of the signature block, required when Visible
+}
+
+// Sign adds a PAdES signature field to a PDF in an incremental update. sign
+// receives the bytes covered by the signature, the whole document except the
+// signature value, and returns the detached CMS signature.
+func Sign(data []byte, info SignatureInfo, sign func(signed []byte) ([]byte, error)) ([]byte, error) {
+	if info.Visible && info.Font == nil {
+		return nil, fmt.Errorf("a visible signature needs a font")
+	}
+	f, err := readFile(data)
+	if err != nil {
+		return nil, err
+	}
+	u, err := newUpdate(f)
+	if err != nil {
+		return nil, err
+	}
+	pageRef, page, mediaBox, err := f.lastPage(u.catalog)
+	if err != nil {
+		return nil, err
+	}
+
+	sigObj := u.newObj()
+	var b strings.Builder
+	fmt.Fprintf(&b, "<< /Type /Sig /Filter /Adobe.PPKLite /SubFilter /ETSI.CAdES.detached /ByteRange %s /Contents <%s> /M %s",
+		byteRangePlaceholder, strings.Repeat("0", 2*SignatureSize), literalString([]byte(Date(info.Date))))
+	for _, entry := range []struct{ key, value string }{
+		{"Name", info.Name},
+		{"Reason", info.Reason},
+		{"Location", info.Location},
+	} {
+		if entry.value != "" {
+			fmt.Fprintf(&b, " /%s %s", entry.key, TextString(entry.value))
+		}
+	}
+	b.WriteString(" >>")
+	u.object(sigObj, b.String())
+
+	widgetObj := u.newObj()
+	rect := "[0 0 0 0]"
+	appearance := ""
+	if info.Visible {
+		lines := info.lines(appearanceWidth - 2*appearancePadding)
+		height := float64(2*appearancePadding + appearanceLeading*len(lines))
+		x := mediaBox[2] - appearanceMargin - appearanceWidth
+		y := mediaBox[1] + appearanceMargin
+		rect = fmt.Sprintf("[%s %s %s %s]", num(x), num(y), num(x+appearanceWidth), num(y+height))
+		apObj, err := u.appearance(info.Font, lines, height)
+		if err != nil {
+			return nil, err
+		}
+		appearance = fmt.Sprintf(" /AP << /N %d 0 R >>", apObj)
+	}
+	// print and locked; the object number keeps the field name unique
+	u.object(widgetObj, fmt.Sprintf("<< /Type /Annot /Subtype /Widget /FT /Sig /T (Signature%d) /V %d 0 R /P %s /Rect %s /F 132%s >>",
+		widgetObj, sigObj, pageRef, rect, appearance))
+	widget := fmt.Sprintf("%d 0 R", widgetObj)
+
+	// the widget is an annotation of the page and a field of the form
+	if page, err = u.appendItem(page, "Annots", widget); err != nil {
+		return nil, err
+	}
+	if err := u.replace(pageRef, page.String()); err != nil {
+		return nil, err
+	}
+	form := dict{}
+	value, hasForm := u.catalog.get("AcroForm")
+	formRef, formIsRef := parseRef(value)
+	if hasForm {
+		if value, err = f.resolve(value); err != nil {
+			return nil, err
+		}
+		if form, err = parseDict(value); err != nil {
+			return nil, err
+		}
+	}
+	if form, err = u.appendItem(form, "Fields", widget); err != nil {
+		return nil, err
+	}
+	form = form.set("SigFlags", "3") // signatures exist, append only
+	if formIsRef {
+		if err := u.replace(formRef, form.String()); err != nil {
+			return nil, err
+		}
+	} else {
+		u.catalog = u.catalog.set("AcroForm", form.String())
+	}
+
+	out := u.finish()
+
+	// fill in the byte range, then sign everything but the signature value
+	start := u.out.offsets[sigObj]
+	i := bytes.Index(out[start:], []byte(byteRangePlaceholder))
+	j := bytes.Index(out[start:], []byte("/Contents <"))
+	if i < 0 || j < 0 {
+		return nil, fmt.Errorf("signature placeholder not found")
+	}
+	contents := start + j + len("/Contents ")
+	end := contents + 2*SignatureSize + 2
+	byteRange := fmt.Sprintf("[0 %d %d %d]", contents, end, len(out)-end)
+	copy(out[start+i:], byteRange+strings.Repeat(" ", len(byteRangePlaceholder)-len(byteRange)))
+
+	signed := make([]byte, 0, len(out)-(end-contents))
+	signed = append(signed, out[:contents]...)
+	signed = append(signed, out[end:]...)
+	signature, err := sign(signed)
+	if err != nil {
+		return nil, err
+	}
+	if len(signature) > SignatureSize {
+		return nil, fmt.Errorf("signature of %d bytes exceeds the %d bytes reserved", len(signature), SignatureSize)
+	}
+	hex.Encode(out[contents+1:], signature)
+	return out, nil
+}
+
+// lines returns the text of the signature block, wrapped to width
+func (info SignatureInfo) lines(width float64) []string {
+	text := []string{
+		"Digitally signed by " + info.Name,
+		"Date: " + info.Date.UTC().Format("2006-01-02 15:04:05 MST"),
+	}
+	if info.Reason != "" {
+		text = append(text, "Reason: "+info.Reason)
+	}
+	if info.Location != "" {
+		text = append(text, "Location: "+info.Location)
+	}
+	var lines []string
+	for _, s := range text {
+		lines = append(lines, info.Font.Wrap(s, appearanceFontSize, width)...)
+	}
+	return lines
+}
+
+// appearance writes the form XObject drawing a visible signature and returns its number
+func (u *update) appearance(f *Font, lines []string, height float64) (int, error) {
+	fontObj, err := u.font(f)
+	if err != nil {
+		return 0, err
+	}
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "q 0.5 w 0.4 0.4 0.4 RG 0.25 0.25 %s %s re S Q\n", num(appearanceWidth-0.5), num(height-0.5))
+	content.WriteString("BT 0.1 0.1 0.1 rg\n")
+	y := height - appearancePadding - f.Ascent(appearanceFontSize)
+	for _, line := range lines {
+		fmt.Fprintf(&content, "/F1 %d Tf 1 0 0 1 %d %s Tm %s Tj\n",
+			appearanceFontSize, appearancePadding, num(y), literalString(encodeWinAnsi(line)))
+		y -= appearanceLeading
+	}
+	content.WriteString("ET\n")
+
+	n := u.newObj()
+	entries := fmt.Sprintf("/Type /XObject /Subtype /Form /BBox [0 0 %d %s] /Resources << /Font << /F1 %d 0 R >> >>",
+		appearanceWidth, num(height), fontObj)
+	return n, u.stream(n, entries, content.Bytes())
+}
+
+// appendItem adds item to the array under key of d. An array stored in an
+// object of its own is replaced, otherwise the returned dictionary holds it.
+func (u *update) appendItem(d dict, key, item string) (dict, error) {
+	value, ok := d.get(key)
+	if !ok {
+		return d.set(key, "["+item+"]"), nil
+	}
+	r, isRef := parseRef(value)
+	if isRef {
+		var err error
+		if value, err = u.f.raw(r); err != nil {
+			return nil, err
+		}
+	}
+	items, err := parseArray(value)
+	if err != nil {
+		return nil, err
+	}
+	array := "[" + strings.Join(append(items, item), " ") + "]"
+	if isRef {
+		return d, u.replace(r, array)
+	}
+	return d.set(key, array), nil
+}
+
+// lastPage finds the last page of the document and its media box, which the
+// page may inherit from the page tree
+func (f *file) lastPage(catalog dict) (ref, dict, [4]float64, error) {
+	var mediaBox string
+	r, ok := catalog.ref("Pages")
+	for depth := 0; ok && depth < 32; depth++ {
+		node, err := f.object(r)
+		if err != nil {
+			return ref{}, nil, [4]float64{}, err
+		}
+		if value, ok := node.get("MediaBox"); ok {
+			mediaBox = value
+		}
+		kids, isTree := node.get("Kids")
+		if !isTree {
+			box, err := f.rectangle(mediaBox)
+			return r, node, box, err
+		}
+		if kids, err = f.resolve(kids); err != nil {
+			return ref{}, nil, [4]float64{}, err
+		}
+		items, err := parseArray(kids)
+		if err != nil {
+			return ref{}, nil, [4]float64{}, err
+		}
+		if len(items) == 0 {
+			break
+		}
+		r, ok = parseRef(items[len(items)-1])
+	}
+	return ref{}, nil, [4]float64{}, fmt.Errorf("invalid pdf: no page found")
+}
+
+// rectangle reads a rectangle such as a media box
+func (f *file) rectangle(value string) ([4]float64, error) {
+	var box [4]float64
+	value, err := f.resolve(value)
+	if err != nil {
+		return box, err
+	}
+	items, err := parseArray(value)
+	if err != nil || len(items) != 4 {
+		return box, fmt.Errorf("invalid pdf: bad rectangle %q", value)
+	}
+	for i, item := range items {
+		if box[i], err = strconv.ParseFloat(item, 64); err != nil {
+			return box, fmt.Errorf("invalid pdf: bad rectangle %q", value)
+		}
+	}
+	return box, nil
+}
+
+// Signature is a signature found in a PDF
+type Signature struct {
+	ByteRange [4]int // offset and length of the two signed parts
+	Contents  []byte // the signature, usually a CMS SignedData structure
+	Name      string
+	Reason    string
+	Location  string
+	Date      time.Time // the signing time claimed by the signer, zero when missing
+}
+
+// Signed returns the bytes of data covered by the signature
+func (s Signature) Signed(data []byte) []byte {
+	signed := make([]byte, 0, s.ByteRange[1]+s.ByteRange[3])
+	signed = append(signed, data[s.ByteRange[0]:s.ByteRange[0]+s.ByteRange[1]]...)
+	return append(signed, data[s.ByteRange[2]:s.ByteRange[2]+s.ByteRange[3]]...)
+}
+
+// CoversDocument tells whether the signature covers all of data, that is
+// nothing was appended to the document after signing
+func (s Signature) CoversDocument(data []byte) bool {
+	return s.ByteRange[0] == 0 && s.ByteRange[2]+s.ByteRange[3] == len(data)
+}
+
+// Signatures finds the signatures of a PDF by their byte ranges. It does not
+// need to parse the document, so it also reads files Sign cannot update.
+func Signatures(data []byte) ([]Signature, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, fmt.Errorf("not a pdf")
+	}
+	var signatures []Signature
+	seen := make(map[[4]int]bool)
+	key := []byte("/ByteRange")
+	for pos := 0; ; {
+		i := bytes.Index(data[pos:], key)
+		if i < 0 {
+			return signatures, nil
+		}
+		pos += i + len(key)
+
+		s := &scanner{data: data, pos: pos}
+		items, err := s.array()
+		if err != nil || len(items) != 4 {
+			continue
+		}
+		var sig Signature
+		valid := true
+		for k, item := range items {
+			n, err := strconv.Atoi(item)
+			if err != nil || n < 0 {
+				valid = false
+			}
+			sig.ByteRange[k] = n
+		}
+		// each value is checked on its own, a huge length would overflow a sum
+		r := sig.ByteRange
+		if !valid || r[0] != 0 || r[1] >= r[2] || r[1] > len(data) || r[2] > len(data) || r[3] > len(data)-r[2] || seen[r] {
+			continue
+		}
+		seen[r] = true
+
+		// the gap between the two parts is the hex string of the signature
+		gap := bytes.TrimSpace(data[r[1]:r[2]])
+		if len(gap) < 2 || gap[0] != '<' || gap[len(gap)-1] != '>' {
+			continue
+		}
+		if sig.Contents, err = hex.DecodeString(string(gap[1 : len(gap)-1])); err != nil {
+			continue
+		}
+		sig.readDict(data, pos)
+		signatures = append(signatures, sig)
+	}
+}
+
+// readDict reads the details of a signature from its dictionary, which
+// contains the byte range at pos. Details are optional, so errors are ignored.
+func (sig *Signature) readDict(data []byte, pos int) {
+	start := bytes.LastIndex(data[:pos], []byte("obj"))
+	if start < 0 {
+		return
+	}
+	s := &scanner{data: data, pos: start + len("obj")}
+	d, err := s.dict()
+	if err != nil || s.pos < pos {
+		return
+	}
+	if value, ok := d.get("Name"); ok {
+		sig.Name = decodeText(value)
+	}
+	if value, ok := d.get("Reason"); ok {
+		sig.Reason = decodeText(value)
+	}
+	if value, ok := d.get("Location"); ok {
+		sig.Location = decodeText(value)
+	}
+	if value, ok := d.get("M"); ok {
+		sig.Date, _ = parseDate(decodeText(value))
+	}
+}
+
+// decodeText decodes a literal or hex text string, in UTF-16 or PDFDocEncoding
+func decodeText(value string) string {
+	var raw []byte
+	switch {
+	case strings.HasPrefix(value, "("):
+		raw = unescapeLiteral(value)
+	case strings.HasPrefix(value, "<"):
+		digits := strings.Join(strings.Fields(strings.Trim(value, "<>")), "")
+		if len(digits)%2 == 1 {
+			digits += "0"
+		}
+		raw, _ = hex.DecodeString(digits)
+	default:
+		return ""
+	}
+	if len(raw) >= 2 && raw[0] == 0xfe && raw[1] == 0xff {
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	// PDFDocEncoding matches Latin-1 for the printable characters
+	runes := make([]rune, len(raw))
+	for i, c := range raw {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// unescapeLiteral decodes the escapes of a literal string
+func unescapeLiteral(value string) []byte {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "("), ")")
+	var b []byte
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '\\' || i+1 == len(value) {
+			b = append(b, c)
+			continue
+		}
+		i++
+		switch c = value[i]; c {
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case '\r', '\n':
+			// a line continuation
+		default:
+			if c >= '0' && c <= '7' {
+				n := 0
+				for k := 0; k < 3 && i < len(value) && value[i] >= '0' && value[i] <= '7'; k++ {
+					n = n*8 + int(value[i]-'0')
+					i++
+				}
+				i--
+				b = append(b, byte(n))
+			} else {
+				b = append(b, c)
+			}
+		}
+	}
+	return b
+}
+
+// parseDate reads a PDF date such as D:20251103120000Z or D:20251103120000+10'00'
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimPrefix(s, "D:")
+	s = strings.ReplaceAll(strings.TrimSuffix(s, "'"), "'", "")
+	for _, layout := range []string{"20060102150405Z0700", "20060102150405Z07", "20060102150405", "200601021504", "20060102"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid pdf date %q", s)
+}
@@ -0,0 +1,218 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	original := testDocument(t)
+	date := time.Date(2025, 11, 3, 12, 0, 0, 0, time.UTC)
+	var signed []byte
+	data, err := Sign(original, SignatureInfo{
+		Name: "Jane Smith", Reason: "Invoice issued", Location: "Zürich", Date: date,
+		Visible: true, Font: testFont(t),
+	}, func(b []byte) ([]byte, error) {
+		signed = append([]byte(nil), b...)
+		return []byte("signature"), nil
+	})
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if !bytes.HasPrefix(data, original) {
+		t.Fatal("the update does not keep the original bytes")
+	}
+	checkUpdate(t, data)
+
+	sigs, err := Signatures(data)
+	if err != nil || len(sigs) != 1 {
+		t.Fatalf("Signatures() = %v, %v", sigs, err)
+	}
+	sig := sigs[0]
+	if !bytes.HasPrefix(sig.Contents, []byte("signature\x00")) {
+		t.Errorf("signature contents = %q", sig.Contents[:12])
+	}
+	if !bytes.Equal(sig.Signed(data), signed) {
+		t.Error("the byte range does not cover what was signed")
+	}
+	if !sig.CoversDocument(data) {
+		t.Errorf("byte range %v does not cover the document of %d bytes", sig.ByteRange, len(data))
+	}
+	if sig.Name != "Jane Smith" || sig.Reason != "Invoice issued" || sig.Location != "Zürich" || !sig.Date.Equal(date) {
+		t.Errorf("signature details = %+v", sig)
+	}
+
+	// the widget is an annotation of the page and a field of the form
+	f, _ := readFile(data)
+	catalog, _ := f.object(ref{num: 1})
+	form, _ := catalog.get("AcroForm")
+	if !strings.Contains(form, "/SigFlags 3") || !strings.Contains(form, "/Fields [") {
+		t.Errorf("catalog /AcroForm = %s", form)
+	}
+	pageRef, page, box, err := f.lastPage(catalog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if box != [4]float64{0, 0, 600, 800} {
+		t.Errorf("media box = %v", box)
+	}
+	annots, _ := page.get("Annots")
+	widget, _ := parseRef(strings.Trim(annots, "[]"))
+	w, err := f.object(widget)
+	if err != nil {
+		t.Fatalf("reading the widget %s: %v", annots, err)
+	}
+	if p, _ := w.ref("P"); p != pageRef {
+		t.Errorf("widget /P = %v, want %v", p, pageRef)
+	}
+	if rect, _ := w.get("Rect"); !strings.HasPrefix(rect, "[344 36 564 ") {
+		t.Errorf("widget /Rect = %s, want the bottom right corner", rect)
+	}
+	ap, _ := w.get("AP")
+	apRef, _ := parseDict(ap)
+	n, _ := apRef.ref("N")
+	if content := inflateStream(t, data, n.num); !strings.Contains(content, "(Digitally signed by Jane Smith) Tj") ||
+		!strings.Contains(content, "(Location: Z\xfcrich) Tj") {
+		t.Errorf("appearance = %q", content)
+	}
+}
+
+func TestSign_Twice(t *testing.T) {
+	sign := func(b []byte) ([]byte, error) { return []byte{1, 2, 3}, nil }
+	first, err := Sign(testDocument(t), SignatureInfo{Name: "A", Date: time.Now()}, sign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Sign(first, SignatureInfo{Name: "B", Date: time.Now()}, sign)
+	if err != nil {
+		t.Fatalf("signing a signed pdf: %v", err)
+	}
+	checkUpdate(t, second)
+	sigs, _ := Signatures(second)
+	if len(sigs) != 2 || sigs[0].Name != "A" || sigs[1].Name != "B" {
+		t.Fatalf("Signatures() = %+v", sigs)
+	}
+	if sigs[0].CoversDocument(second) || !sigs[1].CoversDocument(second) {
+		t.Error("only the last signature covers the whole document")
+	}
+	if !bytes.Equal(sigs[0].Signed(second), sigs[0].Signed(first)) {
+		t.Error("the first signature no longer covers the same bytes")
+	}
+
+	f, _ := readFile(second)
+	catalog, _ := f.object(ref{num: 1})
+	form, _ := catalog.get("AcroForm")
+	fieldsValue, _ := mustDict(t, form).get("Fields")
+	if fields, _ := parseArray(fieldsValue); len(fields) != 2 {
+		t.Errorf("form fields = %s, want both signatures", fieldsValue)
+	}
+}
+
+func TestSign_SharedArrays(t *testing.T) {
+	// a page tree with an inherited media box, annotations and form fields in objects of their own
+	data := buildPDF("",
+		"<< /Type /Catalog /Pages 2 0 R /AcroForm 5 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 300 400] >>",
+		"<< /Type /Page /Parent 2 0 R /Annots 4 0 R >>",
+		"[6 0 R]",
+		"<< /Fields 7 0 R /DA (/Helv 0 Tf 0 g) >>",
+		"<< /Type /Annot /Subtype /Link /Rect [0 0 1 1] >>",
+		"[]",
+	)
+	signed, err := Sign(data, SignatureInfo{Date: time.Now()}, func(b []byte) ([]byte, error) { return nil, nil })
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	checkUpdate(t, signed)
+	f, _ := readFile(signed)
+	annots, _ := f.raw(ref{num: 4})
+	fields, _ := f.raw(ref{num: 7})
+	form, _ := f.object(ref{num: 5})
+	if !strings.HasPrefix(annots, "[6 0 R ") || strings.Count(fields, " R") != 1 {
+		t.Errorf("annots = %s, fields = %s", annots, fields)
+	}
+	if da, _ := form.get("DA"); da != "(/Helv 0 Tf 0 g)" {
+		t.Errorf("form = %s, want its other entries kept", form)
+	}
+	if _, err := Sign(data, SignatureInfo{Visible: true}, nil); err == nil {
+		t.Error("Sign() of a visible signature without a font did not fail")
+	}
+	missing := buildPDF("", "<< /Type /Catalog /Pages 2 0 R >>", "<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	if _, err := Sign(missing, SignatureInfo{}, nil); err == nil {
+		t.Error("Sign() with a missing page did not fail")
+	}
+}
+
+func TestSign_TooLarge(t *testing.T) {
+	_, err := Sign(testDocument(t), SignatureInfo{Date: time.Now()}, func(b []byte) ([]byte, error) {
+		return make([]byte, SignatureSize+1), nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("Sign() error = %v, want the signature too large", err)
+	}
+	fail := errors.New("no key")
+	if _, err := Sign(testDocument(t), SignatureInfo{}, func(b []byte) ([]byte, error) { return nil, fail }); !errors.Is(err, fail) {
+		t.Errorf("Sign() error = %v, want the signer's error", err)
+	}
+}
+
+func TestSignatures_HugeByteRange(t *testing.T) {
+	prefix := "%PDF-1.7\n1 0 obj\n<< /Type /Sig /ByteRange "
+	gap := "<3082>"
+	start := len(prefix) + len("[0 0000 0000 9223372036854775800] /Contents ")
+	data := []byte(fmt.Sprintf("%s[0 %04d %04d 9223372036854775800] /Contents %s >>\nendobj\n%%%%EOF\n",
+		prefix, start, start+len(gap), gap))
+	sigs, err := Signatures(data)
+	if err != nil {
+		t.Fatalf("Signatures() error = %v", err)
+	}
+	if len(sigs) != 0 {
+		t.Errorf("Signatures() = %+v, want the byte range past the end rejected", sigs)
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	for _, tt := range []struct{ value, want string }{
+		{`(Invoice \(final\))`, "Invoice (final)"},
+		{`(line\nbreak \101\102)`, "line\nbreak AB"},
+		{`<FEFF005A00FC>`, "Zü"},
+		{`(Z\374rich)`, "Zürich"},
+		{`/Name`, ""},
+	} {
+		if got := decodeText(tt.value); got != tt.want {
+			t.Errorf("decodeText(%s) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+	for _, s := range []string{"D:20251103120000Z", "D:20251103220000+10'00'", "D:20251103120000"} {
+		if got, err := parseDate(s); err != nil || !got.Equal(time.Date(2025, 11, 3, 12, 0, 0, 0, time.UTC)) {
+			t.Errorf("parseDate(%s) = %v, %v", s, got, err)
+		}
+	}
+}
+
+// checkUpdate checks the cross-reference entries of an updated pdf
+func checkUpdate(t *testing.T, data []byte) {
+	t.Helper()
+	f, err := readFile(data)
+	if err != nil {
+		t.Fatalf("reading the updated pdf: %v", err)
+	}
+	for n, offset := range f.offsets {
+		if offset > 0 && !bytes.HasPrefix(data[offset:], []byte(fmt.Sprintf("%d 0 obj", n))) {
+			t.Errorf("xref entry %d points at %q", n, data[offset:offset+10])
+		}
+	}
+}
+
+func mustDict(t *testing.T, value string) dict {
+	t.Helper()
+	d, err := parseDict(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
@@ -0,0 +1,148 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// update appends an incremental update to a PDF: new and replaced objects,
+// a cross-reference section for them and a trailer pointing to the original's.
+// The original bytes are kept, so earlier signatures stay valid.
+type update struct {
+	f       *file
+	out     *objectWriter
+	next    int   // next free object number
+	written []int // objects of the update
+	trailer dict  // trailer entries set by the update, e.g. Info
+	root    ref   // the catalog
+	catalog dict  // the catalog, written by finish
+}
+
+// newUpdate starts an update of a PDF read by readFile
+func newUpdate(f *file) (*update, error) {
+	root, ok := f.trailer.ref("Root")
+	if !ok {
+		return nil, fmt.Errorf("invalid pdf: trailer without /Root")
+	}
+	if root.gen != 0 {
+		return nil, fmt.Errorf("%w: catalog generation %d", ErrUnsupported, root.gen)
+	}
+	catalog, err := f.object(root)
+	if err != nil {
+		return nil, err
+	}
+	u := &update{
+		f:       f,
+		out:     &objectWriter{offsets: make([]int, f.size)},
+		next:    f.size,
+		root:    root,
+		catalog: catalog,
+	}
+	u.out.buf.Write(f.data)
+	if !bytes.HasSuffix(f.data, []byte("\n")) {
+		u.out.buf.WriteByte('\n')
+	}
+	return u, nil
+}
+
+// newObj allocates an object number
+func (u *update) newObj() int {
+	u.next++
+	u.out.offsets = append(u.out.offsets, 0)
+	return u.next - 1
+}
+
+// object writes a new object or replaces an existing one of generation 0
+func (u *update) object(n int, body string) {
+	u.out.object(n, body)
+	u.written = append(u.written, n)
+}
+
+// stream writes a compressed stream object
+func (u *update) stream(n int, entries string, data []byte) error {
+	if err := u.out.stream(n, entries, data); err != nil {
+		return err
+	}
+	u.written = append(u.written, n)
+	return nil
+}
+
+// plainStream writes an uncompressed stream object
+func (u *update) plainStream(n int, entries string, data []byte) {
+	u.out.offsets[n] = u.out.buf.Len()
+	fmt.Fprintf(&u.out.buf, "%d 0 obj\n<< %s /Length %d >>\nstream\n%s\nendstream\nendobj\n", n, entries, len(data), data)
+	u.written = append(u.written, n)
+}
+
+// font embeds a font and returns the number of its font object
+func (u *update) font(f *Font) (int, error) {
+	n := u.newObj()
+	u.newObj()
+	u.newObj()
+	if err := u.out.font(n, f); err != nil {
+		return 0, err
+	}
+	u.written = append(u.written, n, n+1, n+2)
+	return n, nil
+}
+
+// replace writes the new revision of an existing object, which must be of generation 0
+func (u *update) replace(r ref, body string) error {
+	if r.gen != 0 {
+		return fmt.Errorf("%w: object %d has generation %d", ErrUnsupported, r.num, r.gen)
+	}
+	u.object(r.num, body)
+	return nil
+}
+
+// finish writes the catalog, the cross-reference section and the trailer
+// and returns the updated document
+func (u *update) finish() []byte {
+	u.object(u.root.num, u.catalog.String())
+
+	// keep the first half of the ID, it identifies the original document
+	id := md5.Sum(u.out.buf.Bytes())
+	firstID := fmt.Sprintf("<%x>", id)
+	if value, ok := u.f.trailer.get("ID"); ok {
+		s := &scanner{data: []byte(value), pos: 1}
+		start := s.pos
+		if err := s.skipValue(); err == nil {
+			firstID = strings.TrimSpace(value[start:s.pos])
+		}
+	}
+
+	buf := &u.out.buf
+	xref := buf.Len()
+	buf.WriteString("xref\n")
+	sort.Ints(u.written)
+	for i := 0; i < len(u.written); {
+		j := i
+		for j+1 < len(u.written) && u.written[j+1] == u.written[j]+1 {
+			j++
+		}
+		fmt.Fprintf(buf, "%d %d\n", u.written[i], j-i+1)
+		for _, n := range u.written[i : j+1] {
+			fmt.Fprintf(buf, "%010d 00000 n \n", u.out.offsets[n])
+		}
+		i = j + 1
+	}
+	trailer := dict{}
+	for _, e := range u.f.trailer {
+		switch e.key {
+		case "Size", "Prev", "ID", "XRefStm":
+		default:
+			trailer = append(trailer, e)
+		}
+	}
+	for _, e := range u.trailer {
+		trailer = trailer.set(e.key, e.value)
+	}
+	trailer = trailer.set("Size", fmt.Sprint(u.next))
+	trailer = trailer.set("Prev", fmt.Sprint(u.f.startxref))
+	trailer = trailer.set("ID", fmt.Sprintf("[%s <%x>]", firstID, id))
+	fmt.Fprintf(buf, "trailer\n%s\nstartxref\n%d\n%%%%EOF\n", trailer, xref)
+	return buf.Bytes()
+}
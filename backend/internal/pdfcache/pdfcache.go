@@ -0,0 +1,228 @@
+// Package pdfcache keeps rendered invoice PDFs on disk, addressed by a hash of
+// everything that goes into the render.
+package pdfcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/services"
+)
+
+// DefaultMaxSizeMB is the cache size when PDF_CACHE_SIZE is not set
+const DefaultMaxSizeMB = 200
+
+// LoadMaxSize reads PDF_CACHE_SIZE, the cache size in megabytes. 0 disables the cache.
+func LoadMaxSize() (int64, error) {
+	value := strings.TrimSpace(os.Getenv("PDF_CACHE_SIZE"))
+	if value == "" {
+		return DefaultMaxSizeMB << 20, nil
+	}
+	mb, err := strconv.Atoi(value)
+	if err != nil || mb < 0 {
+		return 0, fmt.Errorf("invalid PDF_CACHE_SIZE '%s', expected megabytes or 0 to disable", value)
+	}
+	return int64(mb) << 20, nil
+}
+
+// Key hashes the inputs of a render: the invoice as stored, the renderer with
+// the build that renders it, the output, i.e. the format and the signature
+// applied after rendering, and the page. Any change gives a different key.
+func Key(inv *invoice.Invoice, renderer, version, output string, page services.PageLayout) (string, error) {
+	data, err := json.Marshal(struct {
+		Invoice  *invoice.Invoice    `json:"invoice"`
+		Renderer string              `json:"renderer"`
+		Version  string              `json:"version"`
+		Output   string              `json:"output"`
+		Page     services.PageLayout `json:"page"`
+	}{inv, renderer, version, output, page})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash invoice '%s': %w", inv.ID, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Cache stores PDFs as <dir>/<invoice ID>/<key>.pdf. When the PDFs grow past
+// the maximum size, the least recently used are removed.
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	mu   sync.Mutex
+	size int64
+}
+
+// New opens the cache stored in dir, limited to maxSize bytes
+func New(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create pdf cache directory %q: %v", dir, err)
+	}
+	c := &Cache{dir: dir, maxSize: maxSize}
+	files, err := c.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		c.size += f.size
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evict()
+	return c, nil
+}
+
+// Get returns the cached PDF of the invoice with the given key
+func (c *Cache) Get(invoiceID, key string) ([]byte, bool) {
+	path := c.path(invoiceID, key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("failed to read cached pdf", "invoice", invoiceID, "error", err)
+		}
+		return nil, false
+	}
+	// the modification time orders eviction
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return data, true
+}
+
+// Put stores the PDF of the invoice under the key and evicts old PDFs when
+// the cache is full
+func (c *Cache) Put(invoiceID, key string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.path(invoiceID, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create pdf cache directory: %w", err)
+	}
+	var previous int64
+	if info, err := os.Stat(path); err == nil {
+		previous = info.Size()
+	}
+	// write to a temporary file first so readers never see a partial PDF
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to cache pdf of invoice '%s': %w", invoiceID, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to cache pdf of invoice '%s': %w", invoiceID, err)
+	}
+	c.size += int64(len(data)) - previous
+	c.evict()
+	return nil
+}
+
+// Invalidate removes the cached PDFs of an invoice, e.g. after it was edited
+func (c *Cache) Invalidate(invoiceID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dir := filepath.Join(c.dir, filepath.Base(invoiceID))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to invalidate pdfs of invoice '%s': %w", invoiceID, err)
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil {
+			c.size -= info.Size()
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to invalidate pdfs of invoice '%s': %w", invoiceID, err)
+	}
+	return nil
+}
+
+// Size returns the total size of the cached PDFs in bytes
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+func (c *Cache) path(invoiceID, key string) string {
+	return filepath.Join(c.dir, filepath.Base(invoiceID), filepath.Base(key)+".pdf")
+}
+
+type file struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// files lists the cached PDFs
+func (c *Cache) files() ([]file, error) {
+	var files []file
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".pdf" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, file{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cached pdfs: %w", err)
+	}
+	return files, nil
+}
+
+// evict removes the least recently used PDFs until the cache fits its maximum
+// size. The caller must hold c.mu.
+func (c *Cache) evict() {
+	if c.size <= c.maxSize {
+		return
+	}
+	files, err := c.files()
+	if err != nil {
+		slog.Warn("failed to evict cached pdfs", "error", err)
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	// recount, the size may have drifted from files changed outside the cache
+	c.size = 0
+	for _, f := range files {
+		c.size += f.size
+	}
+	for _, f := range files {
+		if c.size <= c.maxSize {
+			break
+		}
+		if err := os.Remove(f.path); err != nil {
+			slog.Warn("failed to evict cached pdf", "path", f.path, "error", err)
+			continue
+		}
+		c.size -= f.size
+		// drop the invoice directory once it is empty
+		if dir := filepath.Dir(f.path); dir != c.dir {
+			os.Remove(dir)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package pdfcache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/services"
+)
+
+func TestKey(t *testing.T) {
+	inv := &invoice.Invoice{ID: "INV-25110301", Client: invoice.Party{Name: "Acme Corp"}}
+	a4 := services.PageLayout{PaperSize: services.PaperSizeA4}
+
+	key, err := Key(inv, "native", "1.0.0", "", a4)
+	if err != nil {
+		t.Fatalf("Key() error = %v", err)
+	}
+	if again, _ := Key(inv, "native", "1.0.0", "", a4); again != key {
+		t.Error("Key() is not stable")
+	}
+
+	edited := *inv
+	edited.Client.Name = "Acme Pty Ltd"
+	changes := map[string]func() (string, error){
+		"invoice":   func() (string, error) { return Key(&edited, "native", "1.0.0", "", a4) },
+		"renderer":  func() (string, error) { return Key(inv, "chrome", "1.0.0", "", a4) },
+		"version":   func() (string, error) { return Key(inv, "native", "1.0.1", "", a4) },
+		"format":    func() (string, error) { return Key(inv, "native", "1.0.0", "facturx", a4) },
+		"signature": func() (string, error) { return Key(inv, "native", "1.0.0", "signed:3f2a", a4) },
+		"page": func() (string, error) {
+			return Key(inv, "native", "1.0.0", "", services.PageLayout{PaperSize: services.PaperSizeA4, Landscape: true})
+		},
+	}
+	for name, key2 := range changes {
+		if other, _ := key2(); other == key {
+			t.Errorf("changing the %s does not change the key", name)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	c, err := New(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("INV-1", "abc"); ok {
+		t.Error("Get() found a PDF in an empty cache")
+	}
+
+	pdf := []byte("%PDF-1.7 one")
+	if err := c.Put("INV-1", "abc", pdf); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if got, ok := c.Get("INV-1", "abc"); !ok || !bytes.Equal(got, pdf) {
+		t.Errorf("Get() = %q, %v", got, ok)
+	}
+	if _, ok := c.Get("INV-1", "def"); ok {
+		t.Error("Get() found a PDF under another key")
+	}
+	c.Put("INV-1", "def", pdf)
+	c.Put("INV-2", "abc", pdf)
+	if c.Size() != int64(3*len(pdf)) {
+		t.Errorf("Size() = %d, want %d", c.Size(), 3*len(pdf))
+	}
+
+	if err := c.Invalidate("INV-1"); err != nil {
+		t.Fatalf("Invalidate() error = %v", err)
+	}
+	if _, ok := c.Get("INV-1", "abc"); ok {
+		t.Error("Get() found a PDF of an invalidated invoice")
+	}
+	if _, ok := c.Get("INV-2", "abc"); !ok {
+		t.Error("Invalidate() removed the PDF of another invoice")
+	}
+	if c.Size() != int64(len(pdf)) {
+		t.Errorf("Size() after Invalidate() = %d, want %d", c.Size(), len(pdf))
+	}
+	if err := c.Invalidate("INV-3"); err != nil {
+		t.Errorf("Invalidate() of an uncached invoice error = %v", err)
+	}
+
+	// IDs cannot escape the cache directory
+	c.Put("../INV-4", "../../key", pdf)
+	if _, ok := c.Get("INV-4", "key"); !ok {
+		t.Error("Put() did not confine the path to the cache directory")
+	}
+}
+
+func TestCache_Evict(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := bytes.Repeat([]byte("x"), 100)
+	c.Put("INV-1", "a", pdf)
+	c.Put("INV-2", "b", pdf)
+	// age the first two so the order does not depend on the clock resolution
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, "INV-1", "a.pdf"), old, old)
+	os.Chtimes(filepath.Join(dir, "INV-2", "b.pdf"), old.Add(time.Minute), old.Add(time.Minute))
+
+	// reading INV-1 makes INV-2 the least recently used
+	c.Get("INV-1", "a")
+	c.Put("INV-3", "c", pdf)
+
+	if _, ok := c.Get("INV-2", "b"); ok {
+		t.Error("the least recently used PDF was not evicted")
+	}
+	for _, id := range []string{"INV-1", "INV-3"} {
+		if _, ok := c.Get(id, map[string]string{"INV-1": "a", "INV-3": "c"}[id]); !ok {
+			t.Errorf("%s was evicted", id)
+		}
+	}
+	if c.Size() != 200 {
+		t.Errorf("Size() = %d, want 200", c.Size())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "INV-2")); !os.IsNotExist(err) {
+		t.Error("the empty invoice directory was not removed")
+	}
+
+	// a reopened cache counts the stored PDFs and shrinks to a smaller maximum
+	c, err = New(dir, 150)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Size() != 100 {
+		t.Errorf("Size() of the reopened cache = %d, want 100", c.Size())
+	}
+}
+
+func TestLoadMaxSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"", DefaultMaxSizeMB << 20, false},
+		{"0", 0, false},
+		{"50", 50 << 20, false},
+		{"-1", 0, true},
+		{"1GB", 0, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("PDF_CACHE_SIZE", tt.value)
+		got, err := LoadMaxSize()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("LoadMaxSize() with %q = %d, %v, want %d", tt.value, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package pdfsign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+var (
+	oidAttributeContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttributeSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidAttributeSigningCertV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidRSAEncryption          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidSHA256WithRSA          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSHA384WithRSA          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}
+	oidSHA512WithRSA          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidRSAPSS                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}
+	oidECPublicKey            = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidECDSAWithSHA256        = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidECDSAWithSHA384        = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidECDSAWithSHA512        = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+)
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo contentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue // IssuerAndSerialNumber, or [0] SubjectKeyIdentifier
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// essCertIDv2 identifies the signing certificate, its hash algorithm defaults to SHA-256
+type essCertIDv2 struct {
+	CertHash []byte
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+// Sign returns a detached CMS signature of content as PAdES expects it:
+// SHA-256 digests and the signed attributes content type, message digest
+// and signing certificate. The signing time is in the PDF instead.
+func (k *Key) Sign(content []byte) ([]byte, error) {
+	cert := k.Certificate()
+	digest := sha256.Sum256(content)
+	certHash := sha256.Sum256(cert.Raw)
+	attrs, err := encodeAttributes(
+		pendingAttribute{oidAttributeContentType, oidData},
+		pendingAttribute{oidAttributeMessageDigest, digest[:]},
+		pendingAttribute{oidAttributeSigningCertV2, signingCertificateV2{Certs: []essCertIDv2{{CertHash: certHash[:]}}}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// the signature covers the attributes encoded as a SET
+	attrsDigest := sha256.Sum256(attrs)
+	signature, err := k.Signer.Sign(rand.Reader, attrsDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+	var sigAlg pkix.AlgorithmIdentifier
+	switch k.Signer.(type) {
+	case *rsa.PrivateKey:
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue}
+	case *ecdsa.PrivateKey:
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", k.Signer)
+	}
+
+	sid, err := asn1.Marshal(issuerAndSerial{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, Serial: cert.SerialNumber})
+	if err != nil {
+		return nil, err
+	}
+	var certs []byte
+	for _, c := range k.Chain {
+		certs = append(certs, c.Raw...)
+	}
+	sha256Alg := pkix.AlgorithmIdentifier{Algorithm: oidSHA256}
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256Alg},
+		EncapContentInfo: contentInfo{ContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    sha256Alg,
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrs[setHeaderLen(attrs):]},
+			SignatureAlgorithm: sigAlg,
+			Signature:          signature,
+		}},
+	}
+	inner, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: inner},
+	})
+}
+
+// pendingAttribute is an attribute with a single value, marshalled by encodeAttributes
+type pendingAttribute struct {
+	oid   asn1.ObjectIdentifier
+	value any
+}
+
+// encodeAttributes encodes attributes as a DER SET, which sorts its elements
+func encodeAttributes(attrs ...pendingAttribute) ([]byte, error) {
+	encoded := make([][]byte, len(attrs))
+	for i, a := range attrs {
+		value, err := asn1.Marshal(a.value)
+		if err != nil {
+			return nil, err
+		}
+		if encoded[i], err = asn1.Marshal(attribute{
+			Type:   a.oid,
+			Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: value},
+		}); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(encoded, func(i, j int) bool { return bytes.Compare(encoded[i], encoded[j]) < 0 })
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(encoded, nil)})
+}
+
+// setHeaderLen is the length of the tag and length bytes of a DER element
+func setHeaderLen(der []byte) int {
+	if der[1] < 0x80 {
+		return 2
+	}
+	return 2 + int(der[1]&0x7f)
+}
+
+// parsedSignature is a CMS signature read for verification
+type parsedSignature struct {
+	certs       []*x509.Certificate
+	signer      *x509.Certificate
+	hash        crypto.Hash
+	signedAttrs []byte // the signed attributes encoded as a SET, nil when absent
+	digest      []byte // message digest attribute
+	signingTime time.Time
+	sigAlg      asn1.ObjectIdentifier
+	signature   []byte
+}
+
+// parseSignature reads a detached CMS SignedData with one signer. The
+// signature of a PDF is padded with zeros, so trailing data is ignored.
+func parseSignature(der []byte) (*parsedSignature, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, fmt.Errorf("invalid CMS signature: %w", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("unsupported signature: content type %v", ci.ContentType)
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("invalid CMS signed data: %w", err)
+	}
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("unsupported signature: %d signers", len(sd.SignerInfos))
+	}
+	si := sd.SignerInfos[0]
+	p := &parsedSignature{sigAlg: si.SignatureAlgorithm.Algorithm, signature: si.Signature}
+	var ok bool
+	if p.hash, ok = digestHash(si.DigestAlgorithm.Algorithm); !ok {
+		return nil, fmt.Errorf("unsupported digest algorithm %v", si.DigestAlgorithm.Algorithm)
+	}
+	if len(sd.Certificates.Bytes) > 0 {
+		certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in signature: %w", err)
+		}
+		p.certs = certs
+	}
+
+	// the signer is identified by issuer and serial number or by subject key identifier
+	for _, cert := range p.certs {
+		if si.SID.Class == asn1.ClassContextSpecific && si.SID.Tag == 0 {
+			if bytes.Equal(cert.SubjectKeyId, si.SID.Bytes) {
+				p.signer = cert
+			}
+			continue
+		}
+		var ias issuerAndSerial
+		if _, err := asn1.Unmarshal(si.SID.FullBytes, &ias); err != nil {
+			return nil, fmt.Errorf("invalid signer identifier: %w", err)
+		}
+		if bytes.Equal(cert.RawIssuer, ias.Issuer.FullBytes) && cert.SerialNumber.Cmp(ias.Serial) == 0 {
+			p.signer = cert
+		}
+	}
+	if p.signer == nil {
+		return nil, fmt.Errorf("the signing certificate is not included in the signature")
+	}
+
+	if len(si.SignedAttrs.FullBytes) > 0 {
+		// the attributes are signed with their universal SET tag instead of [0]
+		p.signedAttrs = append([]byte{0x31}, si.SignedAttrs.FullBytes[1:]...)
+		var attrs []attribute
+		if _, err := asn1.UnmarshalWithParams(p.signedAttrs, &attrs, "set"); err != nil {
+			return nil, fmt.Errorf("invalid signed attributes: %w", err)
+		}
+		for _, a := range attrs {
+			switch {
+			case a.Type.Equal(oidAttributeMessageDigest):
+				if _, err := asn1.Unmarshal(a.Values.Bytes, &p.digest); err != nil {
+					return nil, fmt.Errorf("invalid message digest: %w", err)
+				}
+			case a.Type.Equal(oidAttributeSigningTime):
+				asn1.Unmarshal(a.Values.Bytes, &p.signingTime)
+			}
+		}
+		if p.digest == nil {
+			return nil, fmt.Errorf("the signed attributes have no message digest")
+		}
+	}
+	return p, nil
+}
+
+// verify checks that the signature covers content
+func (p *parsedSignature) verify(content []byte) error {
+	h := p.hash.New()
+	h.Write(content)
+	digest := h.Sum(nil)
+	signed := content
+	if p.signedAttrs != nil {
+		if !bytes.Equal(digest, p.digest) {
+			return fmt.Errorf("the document was modified after signing")
+		}
+		signed = p.signedAttrs
+	}
+	alg, err := p.algorithm()
+	if err != nil {
+		return err
+	}
+	if err := p.signer.CheckSignature(alg, signed, p.signature); err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	return nil
+}
+
+// algorithm maps the signature and digest algorithms to an x509 signature algorithm
+func (p *parsedSignature) algorithm() (x509.SignatureAlgorithm, error) {
+	rsa := map[crypto.Hash]x509.SignatureAlgorithm{crypto.SHA1: x509.SHA1WithRSA, crypto.SHA256: x509.SHA256WithRSA, crypto.SHA384: x509.SHA384WithRSA, crypto.SHA512: x509.SHA512WithRSA}
+	pss := map[crypto.Hash]x509.SignatureAlgorithm{crypto.SHA256: x509.SHA256WithRSAPSS, crypto.SHA384: x509.SHA384WithRSAPSS, crypto.SHA512: x509.SHA512WithRSAPSS}
+	ec := map[crypto.Hash]x509.SignatureAlgorithm{crypto.SHA1: x509.ECDSAWithSHA1, crypto.SHA256: x509.ECDSAWithSHA256, crypto.SHA384: x509.ECDSAWithSHA384, crypto.SHA512: x509.ECDSAWithSHA512}
+	var alg x509.SignatureAlgorithm
+	switch oid := p.sigAlg; {
+	case oid.Equal(oidRSAEncryption), oid.Equal(oidSHA256WithRSA), oid.Equal(oidSHA384WithRSA), oid.Equal(oidSHA512WithRSA):
+		alg = rsa[p.hash]
+	case oid.Equal(oidRSAPSS):
+		alg = pss[p.hash]
+	case oid.Equal(oidECPublicKey), oid.Equal(oidECDSAWithSHA256), oid.Equal(oidECDSAWithSHA384), oid.Equal(oidECDSAWithSHA512):
+		alg = ec[p.hash]
+	}
+	if alg == x509.UnknownSignatureAlgorithm {
+		return alg, fmt.Errorf("unsupported signature algorithm %v with %v", p.sigAlg, p.hash)
+	}
+	return alg, nil
+}
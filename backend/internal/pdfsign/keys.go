@@ -0,0 +1,205 @@
+// Package pdfsign signs invoice PDFs with a provider's certificate and
+// verifies the signatures of uploaded PDFs. Signatures are PAdES baseline
+// (B-B) signatures: a detached CMS SignedData over the document's byte range.
+package pdfsign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrPassword is returned when a key cannot be decrypted with the password given
+var ErrPassword = errors.New("wrong password or corrupted key")
+
+// Key is a signing key with its certificate chain
+type Key struct {
+	Signer crypto.Signer
+	Chain  []*x509.Certificate // the signing certificate first, then its issuers
+}
+
+// KeyInfo describes the certificate of a key
+type KeyInfo struct {
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	Serial      string    `json:"serial"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	Fingerprint string    `json:"fingerprint"` // SHA-256 of the certificate
+	SelfSigned  bool      `json:"self_signed"`
+	Chain       int       `json:"chain"` // number of certificates
+}
+
+// ParseKey reads a private key and its certificates from a PKCS#12 file or
+// from PEM blocks. password decrypts a PKCS#12 file or an encrypted PKCS#8
+// key and is ignored otherwise.
+func ParseKey(data []byte, password string) (*Key, error) {
+	var (
+		signer crypto.Signer
+		certs  []*x509.Certificate
+		err    error
+	)
+	if bytes.Contains(data, []byte("-----BEGIN ")) {
+		signer, certs, err = parsePEM(data, password)
+	} else {
+		signer, certs, err = parsePKCS12(data, password)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newKey(signer, certs)
+}
+
+// newKey checks the key and orders its chain from the signing certificate up
+func newKey(signer crypto.Signer, certs []*x509.Certificate) (*Key, error) {
+	if signer == nil {
+		return nil, fmt.Errorf("no private key found")
+	}
+	switch signer.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey:
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, expected an RSA or ECDSA key", signer)
+	}
+	var leaf *x509.Certificate
+	var rest []*x509.Certificate
+	for _, cert := range certs {
+		if leaf == nil && publicKeyEqual(cert.PublicKey, signer.Public()) {
+			leaf = cert
+		} else {
+			rest = append(rest, cert)
+		}
+	}
+	if leaf == nil {
+		return nil, fmt.Errorf("no certificate matches the private key")
+	}
+	if leaf.KeyUsage != 0 && leaf.KeyUsage&(x509.KeyUsageDigitalSignature|x509.KeyUsageContentCommitment) == 0 {
+		return nil, fmt.Errorf("certificate '%s' is not for digital signatures", leaf.Subject.CommonName)
+	}
+
+	chain := []*x509.Certificate{leaf}
+	for cert := leaf; ; {
+		issuer := -1
+		for i, c := range rest {
+			if bytes.Equal(c.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(c) == nil {
+				issuer = i
+				break
+			}
+		}
+		if issuer < 0 {
+			break
+		}
+		cert = rest[issuer]
+		chain = append(chain, cert)
+		rest = append(rest[:issuer], rest[issuer+1:]...)
+	}
+	// certificates outside the chain are unrelated, e.g. other CAs of the bundle
+	return &Key{Signer: signer, Chain: chain}, nil
+}
+
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
+
+// parsePEM reads a private key and certificates from PEM blocks
+func parsePEM(data []byte, password string) (crypto.Signer, []*x509.Certificate, error) {
+	var signer crypto.Signer
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if _, encrypted := block.Headers["Proc-Type"]; encrypted {
+			return nil, nil, fmt.Errorf("legacy encrypted PEM keys are not supported, convert the key to PKCS#8 or PKCS#12")
+		}
+		var key any
+		var err error
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse certificate: %w", err)
+			}
+			certs = append(certs, cert)
+			continue
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		case "ENCRYPTED PRIVATE KEY":
+			key, err = decryptPKCS8(block.Bytes, password)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		if signer != nil {
+			return nil, nil, fmt.Errorf("more than one private key found")
+		}
+		var ok bool
+		if signer, ok = key.(crypto.Signer); !ok {
+			return nil, nil, fmt.Errorf("unsupported key type %T", key)
+		}
+	}
+	return signer, certs, nil
+}
+
+// PEM encodes the key as an unencrypted PKCS#8 block followed by the chain
+func (k *Key) PEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.Signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	var b bytes.Buffer
+	pem.Encode(&b, &pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	for _, cert := range k.Chain {
+		pem.Encode(&b, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return b.Bytes(), nil
+}
+
+// Certificate returns the signing certificate
+func (k *Key) Certificate() *x509.Certificate {
+	return k.Chain[0]
+}
+
+// Info describes the signing certificate
+func (k *Key) Info() KeyInfo {
+	cert := k.Certificate()
+	sum := sha256.Sum256(cert.Raw)
+	return KeyInfo{
+		Subject:     cert.Subject.String(),
+		Issuer:      cert.Issuer.String(),
+		Serial:      cert.SerialNumber.Text(16),
+		NotBefore:   cert.NotBefore,
+		NotAfter:    cert.NotAfter,
+		Fingerprint: hex.EncodeToString(sum[:]),
+		SelfSigned:  bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil,
+		Chain:       len(k.Chain),
+	}
+}
+
+// Name is the signer's name shown in the signature, the certificate's common name
+func (k *Key) Name() string {
+	cert := k.Certificate()
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	if len(cert.Subject.Organization) > 0 {
+		return cert.Subject.Organization[0]
+	}
+	return cert.Subject.String()
+}
@@ -0,0 +1,405 @@
+package pdfsign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"unicode/utf16"
+)
+
+var (
+	oidData                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidEncryptedData        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidKeyBag               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidShroudedKeyBag       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509Certificate      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidPBES2                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidPBEWithSHA3KeyDESCBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidHMACWithSHA1         = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256       = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384       = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512       = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC           = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidSHA1                 = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+// errLegacyCipher explains how to re-export a file using a cipher this package does not implement
+var errLegacyCipher = errors.New("the key is encrypted with a legacy cipher (RC2 or RC4), re-export it with 'openssl pkcs12 -export -keypbe AES-256-CBC -certpbe AES-256-CBC' or upload PEM files")
+
+// hashes by digest algorithm identifier
+var digestHashes = []struct {
+	oid  asn1.ObjectIdentifier
+	hash crypto.Hash
+}{
+	{oidSHA1, crypto.SHA1},
+	{oidSHA256, crypto.SHA256},
+	{oidSHA384, crypto.SHA384},
+	{oidSHA512, crypto.SHA512},
+}
+
+func digestHash(oid asn1.ObjectIdentifier) (crypto.Hash, bool) {
+	for _, h := range digestHashes {
+		if h.oid.Equal(oid) {
+			return h.hash, true
+		}
+	}
+	return 0, false
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type pfx struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue `asn1:"tag:0,explicit"`
+	Attributes asn1.RawValue `asn1:"optional"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Data      []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+// parsePKCS12 reads the private key and certificates of a PKCS#12 (.p12, .pfx) file
+func parsePKCS12(data []byte, password string) (crypto.Signer, []*x509.Certificate, error) {
+	var p pfx
+	if rest, err := asn1.Unmarshal(data, &p); err != nil || len(rest) > 0 {
+		return nil, nil, fmt.Errorf("not a PKCS#12 or PEM file")
+	}
+	if p.Version != 3 {
+		return nil, nil, fmt.Errorf("unsupported PKCS#12 version %d", p.Version)
+	}
+	if !p.AuthSafe.ContentType.Equal(oidData) {
+		return nil, nil, fmt.Errorf("unsupported PKCS#12 file: public-key integrity mode")
+	}
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(p.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, nil, fmt.Errorf("invalid PKCS#12 file: %w", err)
+	}
+	if p.MacData.Mac.Algorithm.Algorithm != nil {
+		if err := p.MacData.verify(authSafe, password); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var contents []contentInfo
+	if _, err := asn1.Unmarshal(authSafe, &contents); err != nil {
+		return nil, nil, fmt.Errorf("invalid PKCS#12 file: %w", err)
+	}
+	var signer crypto.Signer
+	var certs []*x509.Certificate
+	for _, ci := range contents {
+		var safe []byte
+		switch {
+		case ci.ContentType.Equal(oidData):
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &safe); err != nil {
+				return nil, nil, fmt.Errorf("invalid PKCS#12 file: %w", err)
+			}
+		case ci.ContentType.Equal(oidEncryptedData):
+			var ed encryptedData
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+				return nil, nil, fmt.Errorf("invalid PKCS#12 file: %w", err)
+			}
+			info := ed.EncryptedContentInfo
+			var err error
+			if safe, err = decrypt(info.ContentEncryptionAlgorithm, info.EncryptedContent, password); err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("unsupported PKCS#12 content %v", ci.ContentType)
+		}
+
+		var bags []safeBag
+		if _, err := asn1.Unmarshal(safe, &bags); err != nil {
+			return nil, nil, fmt.Errorf("invalid PKCS#12 file: %w", err)
+		}
+		for _, bag := range bags {
+			var key any
+			var err error
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				var cb certBag
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil {
+					return nil, nil, fmt.Errorf("invalid PKCS#12 certificate: %w", err)
+				}
+				if !cb.ID.Equal(oidX509Certificate) {
+					continue
+				}
+				cert, err := x509.ParseCertificate(cb.Data)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to parse certificate: %w", err)
+				}
+				certs = append(certs, cert)
+				continue
+			case bag.ID.Equal(oidKeyBag):
+				key, err = x509.ParsePKCS8PrivateKey(bag.Value.Bytes)
+			case bag.ID.Equal(oidShroudedKeyBag):
+				key, err = decryptPKCS8(bag.Value.Bytes, password)
+			default:
+				continue
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse private key: %w", err)
+			}
+			if signer != nil {
+				return nil, nil, fmt.Errorf("more than one private key found")
+			}
+			var ok bool
+			if signer, ok = key.(crypto.Signer); !ok {
+				return nil, nil, fmt.Errorf("unsupported key type %T", key)
+			}
+		}
+	}
+	return signer, certs, nil
+}
+
+// verify checks the integrity of the file, which also tells whether the password is right
+func (m macData) verify(content []byte, password string) error {
+	h, ok := digestHash(m.Mac.Algorithm.Algorithm)
+	if !ok {
+		return fmt.Errorf("unsupported PKCS#12 MAC algorithm %v", m.Mac.Algorithm.Algorithm)
+	}
+	key := pkcs12KDF(h.New, bmpPassword(password), m.MacSalt, m.Iterations, 3, h.Size())
+	mac := hmac.New(h.New, key)
+	mac.Write(content)
+	if !hmac.Equal(mac.Sum(nil), m.Mac.Digest) {
+		return ErrPassword
+	}
+	return nil
+}
+
+// decryptPKCS8 decrypts an EncryptedPrivateKeyInfo and parses the key
+func decryptPKCS8(der []byte, password string) (any, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("invalid encrypted key: %w", err)
+	}
+	plain, err := decrypt(info.Algorithm, info.Data, password)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(plain)
+	if err != nil {
+		// a wrong password may still produce valid padding
+		return nil, ErrPassword
+	}
+	return key, nil
+}
+
+// decrypt decrypts data with a password based encryption scheme
+func decrypt(alg pkix.AlgorithmIdentifier, data []byte, password string) ([]byte, error) {
+	var block cipher.Block
+	var iv []byte
+	switch {
+	case alg.Algorithm.Equal(oidPBES2):
+		var params pbes2Params
+		if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("invalid PBES2 parameters: %w", err)
+		}
+		if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+			return nil, fmt.Errorf("unsupported key derivation %v", params.KeyDerivationFunc.Algorithm)
+		}
+		var kdf pbkdf2Params
+		if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+			return nil, fmt.Errorf("invalid PBKDF2 parameters: %w", err)
+		}
+		var prf func() hash.Hash
+		switch oid := kdf.PRF.Algorithm; {
+		case oid == nil, oid.Equal(oidHMACWithSHA1):
+			prf = sha1.New
+		case oid.Equal(oidHMACWithSHA256):
+			prf = sha256.New
+		case oid.Equal(oidHMACWithSHA384):
+			prf = sha512.New384
+		case oid.Equal(oidHMACWithSHA512):
+			prf = sha512.New
+		default:
+			return nil, fmt.Errorf("unsupported PBKDF2 function %v", oid)
+		}
+
+		var keyLen int
+		var newCipher func([]byte) (cipher.Block, error)
+		switch scheme := params.EncryptionScheme.Algorithm; {
+		case scheme.Equal(oidAES128CBC):
+			keyLen, newCipher = 16, aes.NewCipher
+		case scheme.Equal(oidAES192CBC):
+			keyLen, newCipher = 24, aes.NewCipher
+		case scheme.Equal(oidAES256CBC):
+			keyLen, newCipher = 32, aes.NewCipher
+		case scheme.Equal(oidDESEDE3CBC):
+			keyLen, newCipher = 24, des.NewTripleDESCipher
+		default:
+			return nil, errLegacyCipher
+		}
+		if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+			return nil, fmt.Errorf("invalid cipher parameters: %w", err)
+		}
+		key, err := pbkdf2.Key(prf, password, kdf.Salt, kdf.Iterations, keyLen)
+		if err != nil {
+			return nil, err
+		}
+		if block, err = newCipher(key); err != nil {
+			return nil, err
+		}
+
+	case alg.Algorithm.Equal(oidPBEWithSHA3KeyDESCBC):
+		var params pbeParams
+		if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("invalid PBE parameters: %w", err)
+		}
+		pw := bmpPassword(password)
+		key := pkcs12KDF(sha1.New, pw, params.Salt, params.Iterations, 1, 24)
+		iv = pkcs12KDF(sha1.New, pw, params.Salt, params.Iterations, 2, 8)
+		var err error
+		if block, err = des.NewTripleDESCipher(key); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, errLegacyCipher
+	}
+
+	if len(iv) != block.BlockSize() || len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("invalid encrypted data")
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+	// PKCS#7 padding
+	n := int(plain[len(plain)-1])
+	if n == 0 || n > block.BlockSize() || !bytes.Equal(plain[len(plain)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, ErrPassword
+	}
+	return plain[:len(plain)-n], nil
+}
+
+// bmpPassword encodes a password as PKCS#12 expects it: UTF-16 big endian with a terminating zero
+func bmpPassword(password string) []byte {
+	units := utf16.Encode([]rune(password))
+	b := make([]byte, 0, 2*len(units)+2)
+	for _, u := range units {
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return append(b, 0, 0)
+}
+
+// pkcs12KDF derives size bytes of key material, see RFC 7292 appendix B.2.
+// id is 1 for a key, 2 for an IV and 3 for a MAC key.
+func pkcs12KDF(newHash func() hash.Hash, password, salt []byte, iterations int, id byte, size int) []byte {
+	h := newHash()
+	v := h.BlockSize()
+	fill := func(s []byte) []byte {
+		if len(s) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(s)+v-1)/v))
+		for i := range out {
+			out[i] = s[i%len(s)]
+		}
+		return out
+	}
+	d := bytes.Repeat([]byte{id}, v)
+	input := append(fill(salt), fill(password)...)
+
+	var out []byte
+	one := big.NewInt(1)
+	modulus := new(big.Int).Lsh(one, uint(8*v))
+	for len(out) < size {
+		h.Reset()
+		h.Write(d)
+		h.Write(input)
+		a := h.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			h.Reset()
+			h.Write(a)
+			a = h.Sum(nil)
+		}
+		out = append(out, a...)
+
+		// each block of the input becomes (block + b + 1) mod 2^(8v)
+		b := new(big.Int).SetBytes(fill(a))
+		b.Add(b, one)
+		for j := 0; j < len(input); j += v {
+			block := new(big.Int).SetBytes(input[j : j+v])
+			block.Add(block, b).Mod(block, modulus)
+			sum := block.Bytes()
+			clear(input[j : j+v])
+			copy(input[j+v-len(sum):j+v], sum)
+		}
+	}
+	return out[:size]
+}
@@ -0,0 +1,42 @@
+package pdfsign
+
+import (
+	"sync"
+	"time"
+
+	"go-invoice/internal/pdf"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Options of a PDF signature
+type Options struct {
+	Reason   string // e.g. "Invoice issued by Acme Corp"
+	Location string
+	Visible  bool // draw a signature block at the bottom right of the last page
+	Date     time.Time
+}
+
+// appearanceFont is the font of visible signatures
+var appearanceFont = sync.OnceValues(func() (*pdf.Font, error) {
+	return pdf.ParseFont(goregular.TTF)
+})
+
+// SignPDF signs a PDF with the key in an incremental update
+func (k *Key) SignPDF(data []byte, opts Options) ([]byte, error) {
+	info := pdf.SignatureInfo{
+		Name:     k.Name(),
+		Reason:   opts.Reason,
+		Location: opts.Location,
+		Date:     opts.Date,
+		Visible:  opts.Visible,
+	}
+	if opts.Visible {
+		font, err := appearanceFont()
+		if err != nil {
+			return nil, err
+		}
+		info.Font = font
+	}
+	return pdf.Sign(data, info, k.Sign)
+}
@@ -0,0 +1,128 @@
+package pdfsign
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go-invoice/internal/crypto"
+)
+
+// ErrNoKey is returned when a provider has no signing key
+var ErrNoKey = errors.New("no signing key")
+
+// KeyStore keeps the signing key of each provider in a file of its own,
+// sealed with a key derived from the server secret
+type KeyStore struct {
+	dir string
+	key []byte // AES key sealing the signing keys
+	mu  sync.Mutex
+}
+
+// NewKeyStore opens the key store in dir
+func NewKeyStore(dir string, secret []byte) (*KeyStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create signing key directory %q: %v", dir, err)
+	}
+	// only the server may read the keys, even if the storage root was created earlier
+	if err := os.Chmod(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to protect signing key directory %q: %v", dir, err)
+	}
+	return &KeyStore{dir: dir, key: crypto.DeriveKey(secret)}, nil
+}
+
+// Save stores the signing key of a provider, replacing any previous one
+func (s *KeyStore) Save(providerID string, key *Key) error {
+	path, err := s.path(providerID)
+	if err != nil {
+		return err
+	}
+	plain, err := key.PEM()
+	if err != nil {
+		return err
+	}
+	sealed, err := crypto.Seal(s.key, plain)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(sealed), 0600); err != nil {
+		return fmt.Errorf("failed to write signing key: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write signing key: %v", err)
+	}
+	return nil
+}
+
+// Load returns the signing key of a provider, or ErrNoKey
+func (s *KeyStore) Load(providerID string) (*Key, error) {
+	path, err := s.path(providerID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	sealed, err := os.ReadFile(path)
+	s.mu.Unlock()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %v", err)
+	}
+	plain, err := crypto.Open(s.key, string(sealed))
+	if err != nil {
+		// the server secret changed since the key was stored
+		return nil, fmt.Errorf("failed to open signing key of '%s': %v", providerID, err)
+	}
+	return ParseKey(plain, "")
+}
+
+// Delete removes the signing key of a provider, or returns ErrNoKey
+func (s *KeyStore) Delete(providerID string) error {
+	path, err := s.path(providerID)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNoKey
+	}
+	return err
+}
+
+// Certificates returns the signing certificates of all stored keys
+func (s *KeyStore) Certificates() ([]*x509.Certificate, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".key")
+		if !ok {
+			continue
+		}
+		key, err := s.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, key.Certificate())
+	}
+	return certs, nil
+}
+
+func (s *KeyStore) path(providerID string) (string, error) {
+	if providerID == "" || providerID != filepath.Base(providerID) || strings.HasPrefix(providerID, ".") {
+		return "", fmt.Errorf("invalid provider ID '%s'", providerID)
+	}
+	return filepath.Join(s.dir, providerID+".key"), nil
+}
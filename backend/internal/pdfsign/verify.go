@@ -0,0 +1,114 @@
+package pdfsign
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"time"
+
+	"go-invoice/internal/pdf"
+)
+
+// Result is the verification of one signature of a PDF
+type Result struct {
+	Signer             string     `json:"signer,omitempty"` // subject of the signing certificate
+	Issuer             string     `json:"issuer,omitempty"`
+	Fingerprint        string     `json:"fingerprint,omitempty"` // SHA-256 of the signing certificate
+	Name               string     `json:"name,omitempty"`        // signer name written in the PDF
+	Reason             string     `json:"reason,omitempty"`
+	Location           string     `json:"location,omitempty"`
+	SigningTime        *time.Time `json:"signing_time,omitempty"`          // claimed by the signer, not a trusted timestamp
+	Intact             bool       `json:"intact"`                          // the signed bytes are unchanged and the signature matches the certificate
+	CoversDocument     bool       `json:"covers_document"`                 // nothing was appended to the document after signing
+	Trusted            bool       `json:"trusted"`                         // the certificate chains to a trusted root now
+	ValidAtClaimedTime *bool      `json:"valid_at_claimed_time,omitempty"` // chained at the claimed signing time, informational as it is not timestamped
+	Error              string     `json:"error,omitempty"`                 // why the signature is not intact
+	TrustError         string     `json:"trust_error,omitempty"`           // why the certificate is not trusted
+}
+
+// Report is the verification of all signatures of a PDF
+type Report struct {
+	Valid      bool     `json:"valid"` // signed, every signature intact and trusted, and the last one covers the whole document
+	Signatures []Result `json:"signatures"`
+}
+
+// Verify checks the signatures of a PDF. Certificates are trusted when they
+// chain to roots, or to the system roots when roots is nil, at the current
+// time: the signing time claimed by the signer has no trusted timestamp, and
+// would let a backdated signature pass with an expired certificate.
+func Verify(data []byte, roots *x509.CertPool) (*Report, error) {
+	sigs, err := pdf.Signatures(data)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Valid: len(sigs) > 0, Signatures: []Result{}}
+	for i, sig := range sigs {
+		result := verifySignature(data, sig, roots)
+		if !result.Intact || !result.Trusted || (i == len(sigs)-1 && !result.CoversDocument) {
+			report.Valid = false
+		}
+		report.Signatures = append(report.Signatures, result)
+	}
+	return report, nil
+}
+
+func verifySignature(data []byte, sig pdf.Signature, roots *x509.CertPool) Result {
+	result := Result{
+		Name:           sig.Name,
+		Reason:         sig.Reason,
+		Location:       sig.Location,
+		CoversDocument: sig.CoversDocument(data),
+	}
+	p, err := parseSignature(sig.Contents)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	cert := p.signer
+	sum := sha256.Sum256(cert.Raw)
+	result.Signer = cert.Subject.String()
+	result.Issuer = cert.Issuer.String()
+	result.Fingerprint = hex.EncodeToString(sum[:])
+
+	signingTime := sig.Date
+	if signingTime.IsZero() {
+		signingTime = p.signingTime
+	}
+	if !signingTime.IsZero() {
+		result.SigningTime = &signingTime
+	}
+
+	if err := p.verify(sig.Signed(data)); err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Intact = true
+	}
+
+	if err := p.verifyChain(roots, time.Now()); err != nil {
+		result.TrustError = err.Error()
+	} else {
+		result.Trusted = true
+	}
+	if !signingTime.IsZero() {
+		valid := p.verifyChain(roots, signingTime) == nil
+		result.ValidAtClaimedTime = &valid
+	}
+	return result
+}
+
+// verifyChain checks that the signing certificate chains to roots at the given time
+func (p *parsedSignature) verifyChain(roots *x509.CertPool, at time.Time) error {
+	intermediates := x509.NewCertPool()
+	for _, c := range p.certs {
+		if c != p.signer {
+			intermediates.AddCert(c)
+		}
+	}
+	_, err := p.signer.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
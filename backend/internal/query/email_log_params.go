@@ -0,0 +1,81 @@
+package query
+
+import (
+	"go-invoice/internal/emaillog"
+	"go-invoice/internal/types"
+	"net/url"
+)
+
+// EmailLogQueryParams holds all possible query parameters for searching the email log
+type EmailLogQueryParams struct {
+	Search    string // matches against subject, sender, recipients, message ID and invoice ID
+	InvoiceID string
+	Status    string
+	Recipient string // matches any To, Cc or Bcc address
+	DateFrom  types.Date
+	DateTo    types.Date
+	// Pagination
+	Page     int
+	PageSize int
+}
+
+// ParseEmailLogQuery extracts and validates query parameters from URL
+func ParseEmailLogQuery(values url.Values) *EmailLogQueryParams {
+	page, pageSize := parsePagination(values)
+
+	return &EmailLogQueryParams{
+		Search:    values.Get("q"),
+		InvoiceID: values.Get("invoice_id"),
+		Status:    values.Get("status"),
+		Recipient: values.Get("recipient"),
+		DateFrom:  parseTimeParam(values.Get("from")),
+		DateTo:    parseTimeParam(values.Get("to")),
+		Page:      page,
+		PageSize:  pageSize,
+	}
+}
+
+// MatchEmailLogEntry checks if an entry matches all active filters
+func MatchEmailLogEntry(entry *emaillog.Entry, params *EmailLogQueryParams) bool {
+	if params.InvoiceID != "" && entry.InvoiceID != params.InvoiceID {
+		return false
+	}
+	if params.Status != "" && string(entry.Status) != params.Status {
+		return false
+	}
+	if params.Recipient != "" &&
+		!anyContainsFold(entry.To, params.Recipient) &&
+		!anyContainsFold(entry.Cc, params.Recipient) &&
+		!anyContainsFold(entry.Bcc, params.Recipient) {
+		return false
+	}
+
+	// dates are compared by calendar day in UTC
+	day := types.NewDate(entry.Timestamp.UTC())
+	if !params.DateFrom.IsZero() && day.Before(params.DateFrom.Time) {
+		return false
+	}
+	if !params.DateTo.IsZero() && day.After(params.DateTo.Time) {
+		return false
+	}
+
+	if params.Search != "" &&
+		!containsFold(entry.Subject, params.Search) &&
+		!containsFold(entry.From, params.Search) &&
+		!containsFold(entry.MessageID, params.Search) &&
+		!containsFold(entry.InvoiceID, params.Search) &&
+		!anyContainsFold(entry.To, params.Search) &&
+		!anyContainsFold(entry.Cc, params.Search) {
+		return false
+	}
+	return true
+}
+
+func anyContainsFold(values []string, substr string) bool {
+	for _, v := range values {
+		if containsFold(v, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package query
+
+import (
+	"go-invoice/internal/emaillog"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestMatchEmailLogEntry(t *testing.T) {
+	entry := &emaillog.Entry{
+		Timestamp: time.Date(2025, 11, 2, 23, 30, 0, 0, time.UTC),
+		InvoiceID: "INV-25110201",
+		Status:    emaillog.StatusSent,
+		From:      "jane@example.com",
+		To:        []string{"billing@acme.com", "ap@acme.com"},
+		Cc:        []string{"accounts@example.com"},
+		Bcc:       []string{"archive@example.com"},
+		Subject:   "Invoice from Jane Smith",
+		MessageID: "1a2b3c@example.com",
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected bool
+	}{
+		{"no filters", "", true},
+		{"invoice id", "invoice_id=INV-25110201", true},
+		{"other invoice id", "invoice_id=INV-25110202", false},
+		{"status", "status=sent", true},
+		{"other status", "status=failed", false},
+		{"recipient", "recipient=AP@acme", true},
+		{"unknown recipient", "recipient=ceo@acme.com", false},
+		{"cc recipient", "recipient=accounts@", true},
+		{"bcc recipient", "recipient=archive@", true},
+		{"search ignores bcc", "q=archive", false},
+		{"search subject", "q=jane+smith", true},
+		{"search message id", "q=1a2b3c", true},
+		{"search recipient", "q=billing", true},
+		{"search no match", "q=refund", false},
+		{"date range includes day", "from=2025-11-02&to=2025-11-02", true},
+		{"date range after", "from=2025-11-03", false},
+		{"date range before", "to=2025-11-01", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			params := ParseEmailLogQuery(values)
+			if result := MatchEmailLogEntry(entry, params); result != tt.expected {
+				t.Errorf("MatchEmailLogEntry(%q) = %v, want %v", tt.query, result, tt.expected)
+			}
+		})
+	}
+}
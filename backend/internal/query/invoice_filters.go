@@ -2,9 +2,14 @@ package query
 
 import (
 	"go-invoice/internal/invoice"
+	"go-invoice/internal/types"
 	"strings"
 )
 
+// today returns the reference date for derived filters such as overdue.
+// It is a variable so tests can pin the date.
+var today = types.Today
+
 // FilterInvoices applies all active filters to the invoice list
 func FilterInvoices(invoices []invoice.Invoice, params *InvoiceQueryParams) []invoice.Invoice {
 	if !params.HasFilters() {
@@ -48,9 +53,37 @@ func matchesFilters(inv invoice.Invoice, params *InvoiceQueryParams) bool {
 	if !params.DateTo.IsZero() && inv.Date.After(params.DateTo.Time) {
 		return false
 	}
+	if params.MinTotal != nil && inv.Pricing.Total < *params.MinTotal {
+		return false
+	}
+	if params.MaxTotal != nil && inv.Pricing.Total > *params.MaxTotal {
+		return false
+	}
+	if params.Overdue != nil && IsOverdue(inv, today()) != *params.Overdue {
+		return false
+	}
+	if !params.DueWithin.IsZero() && !isDueWithin(inv, today(), params.DueWithin) {
+		return false
+	}
 	return true
 }
 
+// IsOverdue reports whether a sent invoice is past its due date
+func IsOverdue(inv invoice.Invoice, today types.Date) bool {
+	return inv.Status == invoice.StatusSent &&
+		!inv.Due.IsZero() &&
+		inv.Due.Before(today.Time)
+}
+
+// isDueWithin reports whether the invoice is due between today and today + period, inclusive
+func isDueWithin(inv invoice.Invoice, today types.Date, period types.Period) bool {
+	if inv.Due.IsZero() {
+		return false
+	}
+	limit := today.AddPeriod(period)
+	return !inv.Due.Before(today.Time) && !inv.Due.After(limit.Time)
+}
+
 // matchesClientID checks if invoice's client name matches the ID
 func matchesClientID(inv invoice.Invoice, clientID string) bool {
 	// client ID is derived from client name (lowercase with underscores)
@@ -0,0 +1,142 @@
+package query
+
+import (
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/types"
+	"testing"
+	"time"
+)
+
+func date(s string) types.Date {
+	t, _ := time.Parse("2006-01-02", s)
+	return types.NewDate(t)
+}
+
+func newTestInvoices() []invoice.Invoice {
+	return []invoice.Invoice{
+		{
+			ID: "INV-25100101", Status: invoice.StatusSent,
+			Date: date("2025-10-01"), Due: date("2025-10-15"),
+			Client:  invoice.Party{Name: "Globex"},
+			Pricing: invoice.Pricing{Total: 500},
+		},
+		{
+			ID: "INV-25102001", Status: invoice.StatusSent,
+			Date: date("2025-10-20"), Due: date("2025-11-05"),
+			Client:  invoice.Party{Name: "Acme Corp"},
+			Pricing: invoice.Pricing{Total: 1500},
+		},
+		{
+			ID: "INV-25102501", Status: invoice.StatusDraft,
+			Date: date("2025-10-25"), Due: date("2025-10-30"),
+			Client:  invoice.Party{Name: "Initech"},
+			Pricing: invoice.Pricing{Total: 250},
+		},
+	}
+}
+
+func invoiceIDs(invoices []invoice.Invoice) []string {
+	ids := make([]string, len(invoices))
+	for i, inv := range invoices {
+		ids[i] = inv.ID
+	}
+	return ids
+}
+
+func TestFilterInvoices_Derived(t *testing.T) {
+	today = func() types.Date { return date("2025-11-01") }
+	defer func() { today = types.Today }()
+
+	yes, no := true, false
+	minTotal, maxTotal := float32(300), float32(1000)
+
+	tests := []struct {
+		name     string
+		params   *InvoiceQueryParams
+		expected []string
+	}{
+		{
+			name:     "min_total",
+			params:   &InvoiceQueryParams{MinTotal: &minTotal},
+			expected: []string{"INV-25100101", "INV-25102001"},
+		},
+		{
+			name:     "min_total and max_total",
+			params:   &InvoiceQueryParams{MinTotal: &minTotal, MaxTotal: &maxTotal},
+			expected: []string{"INV-25100101"},
+		},
+		{
+			name:     "overdue ignores drafts",
+			params:   &InvoiceQueryParams{Overdue: &yes},
+			expected: []string{"INV-25100101"},
+		},
+		{
+			name:     "not overdue",
+			params:   &InvoiceQueryParams{Overdue: &no},
+			expected: []string{"INV-25102001", "INV-25102501"},
+		},
+		{
+			name:     "due within 7 days",
+			params:   &InvoiceQueryParams{DueWithin: types.Period{Days: 7}},
+			expected: []string{"INV-25102001"},
+		},
+		{
+			name:     "due within 1 day",
+			params:   &InvoiceQueryParams{DueWithin: types.Period{Days: 1}},
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := invoiceIDs(FilterInvoices(newTestInvoices(), tt.params))
+			if !equalIDs(result, tt.expected) {
+				t.Errorf("FilterInvoices() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSortInvoices(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []SortKey
+		expected []string
+	}{
+		{
+			name:     "default is date descending",
+			keys:     nil,
+			expected: []string{"INV-25102501", "INV-25102001", "INV-25100101"},
+		},
+		{
+			name:     "total ascending",
+			keys:     []SortKey{{Field: InvoiceSortTotal}},
+			expected: []string{"INV-25102501", "INV-25100101", "INV-25102001"},
+		},
+		{
+			name:     "due descending",
+			keys:     []SortKey{{Field: InvoiceSortDue, Desc: true}},
+			expected: []string{"INV-25102001", "INV-25102501", "INV-25100101"},
+		},
+		{
+			name:     "client ascending",
+			keys:     []SortKey{{Field: InvoiceSortClient}},
+			expected: []string{"INV-25102001", "INV-25100101", "INV-25102501"},
+		},
+		{
+			name:     "status then total descending",
+			keys:     []SortKey{{Field: InvoiceSortStatus}, {Field: InvoiceSortTotal, Desc: true}},
+			expected: []string{"INV-25102501", "INV-25102001", "INV-25100101"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoices := newTestInvoices()
+			SortInvoices(invoices, tt.keys)
+			if result := invoiceIDs(invoices); !equalIDs(result, tt.expected) {
+				t.Errorf("SortInvoices() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
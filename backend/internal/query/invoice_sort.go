@@ -0,0 +1,74 @@
+package query
+
+import (
+	"go-invoice/internal/invoice"
+	"sort"
+	"strings"
+)
+
+// Invoice sort fields
+const (
+	InvoiceSortDate   = "date"
+	InvoiceSortDue    = "due"
+	InvoiceSortTotal  = "total"
+	InvoiceSortClient = "client"
+	InvoiceSortStatus = "status"
+	InvoiceSortID     = "id"
+)
+
+var invoiceSortFields = []string{
+	InvoiceSortDate,
+	InvoiceSortDue,
+	InvoiceSortTotal,
+	InvoiceSortClient,
+	InvoiceSortStatus,
+	InvoiceSortID,
+}
+
+// DefaultInvoiceSort orders invoices by date descending (newest first)
+var DefaultInvoiceSort = []SortKey{{Field: InvoiceSortDate, Desc: true}}
+
+// SortInvoices orders invoices by the given sort keys, falling back to
+// DefaultInvoiceSort when no keys are given. Ties are broken by ID descending.
+func SortInvoices(invoices []invoice.Invoice, keys []SortKey) {
+	if len(keys) == 0 {
+		keys = DefaultInvoiceSort
+	}
+	sort.SliceStable(invoices, func(i, j int) bool {
+		for _, key := range keys {
+			c := compareInvoices(invoices[i], invoices[j], key.Field)
+			if c != 0 {
+				if key.Desc {
+					return c > 0
+				}
+				return c < 0
+			}
+		}
+		return invoices[i].ID > invoices[j].ID
+	})
+}
+
+// compareInvoices compares a single field of two invoices
+func compareInvoices(a, b invoice.Invoice, field string) int {
+	switch field {
+	case InvoiceSortDate:
+		return a.Date.Compare(b.Date.Time)
+	case InvoiceSortDue:
+		return a.Due.Compare(b.Due.Time)
+	case InvoiceSortTotal:
+		switch {
+		case a.Pricing.Total < b.Pricing.Total:
+			return -1
+		case a.Pricing.Total > b.Pricing.Total:
+			return 1
+		}
+		return 0
+	case InvoiceSortClient:
+		return compareStrings(a.Client.Name, b.Client.Name)
+	case InvoiceSortStatus:
+		return compareStrings(string(a.Status), string(b.Status))
+	case InvoiceSortID:
+		return strings.Compare(a.ID, b.ID)
+	}
+	return 0
+}
@@ -0,0 +1,58 @@
+package query
+
+import (
+	"fmt"
+	"go-invoice/internal/services"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ApplyPageQuery overrides the page settings with the paper_size, orientation,
+// margin (all sides) and margin_top, margin_right, margin_bottom and margin_left
+// query parameters. Margins are in millimetres. Unlike the list filters,
+// invalid values are an error rather than ignored.
+func ApplyPageQuery(settings services.PageSettings, values url.Values) (services.PageSettings, error) {
+	if v := values.Get("paper_size"); v != "" {
+		settings.PaperSize = strings.ToLower(v)
+	}
+	if v := values.Get("orientation"); v != "" {
+		settings.Orientation = strings.ToLower(v)
+	}
+
+	var margins services.Margins
+	if settings.Margins != nil {
+		margins = *settings.Margins
+	}
+	changed := false
+	sides := []struct {
+		param string
+		value []*float64
+	}{
+		{"margin", []*float64{&margins.Top, &margins.Right, &margins.Bottom, &margins.Left}},
+		{"margin_top", []*float64{&margins.Top}},
+		{"margin_right", []*float64{&margins.Right}},
+		{"margin_bottom", []*float64{&margins.Bottom}},
+		{"margin_left", []*float64{&margins.Left}},
+	}
+	for _, side := range sides {
+		v := values.Get(side.param)
+		if v == "" {
+			continue
+		}
+		mm, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(mm) || math.IsInf(mm, 0) {
+			return settings, fmt.Errorf("invalid %s '%s', expected millimetres", side.param, v)
+		}
+		for _, m := range side.value {
+			*m = mm
+		}
+		changed = true
+	}
+	if changed {
+		settings.Margins = &margins
+	}
+
+	return settings, settings.Validate()
+}
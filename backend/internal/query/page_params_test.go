@@ -0,0 +1,58 @@
+package query
+
+import (
+	"go-invoice/internal/services"
+	"net/url"
+	"testing"
+)
+
+func TestApplyPageQuery(t *testing.T) {
+	base := services.PageSettings{
+		PaperSize: "a4",
+		Margins:   &services.Margins{Top: 10, Right: 10, Bottom: 10, Left: 10},
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		want    services.PageSettings
+		wantErr bool
+	}{
+		{"no parameters", "", base, false},
+		{"paper and orientation", "paper_size=Letter&orientation=LANDSCAPE",
+			services.PageSettings{PaperSize: "letter", Orientation: "landscape", Margins: base.Margins}, false},
+		{"all margins", "margin=15",
+			services.PageSettings{PaperSize: "a4", Margins: &services.Margins{Top: 15, Right: 15, Bottom: 15, Left: 15}}, false},
+		{"one side over all margins", "margin=15&margin_left=25.5",
+			services.PageSettings{PaperSize: "a4", Margins: &services.Margins{Top: 15, Right: 15, Bottom: 15, Left: 25.5}}, false},
+		{"one side over the base", "margin_bottom=0",
+			services.PageSettings{PaperSize: "a4", Margins: &services.Margins{Top: 10, Right: 10, Bottom: 0, Left: 10}}, false},
+		{"unknown paper", "paper_size=tabloid", services.PageSettings{}, true},
+		{"unknown orientation", "orientation=sideways", services.PageSettings{}, true},
+		{"margin not a number", "margin=wide", services.PageSettings{}, true},
+		{"margin NaN", "margin=NaN", services.PageSettings{}, true},
+		{"margin infinite", "margin_left=-Inf", services.PageSettings{}, true},
+		{"margin too large", "margin_top=80", services.PageSettings{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			got, err := ApplyPageQuery(base, values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyPageQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.PaperSize != tt.want.PaperSize || got.Orientation != tt.want.Orientation || *got.Margins != *tt.want.Margins {
+				t.Errorf("ApplyPageQuery() = %+v %+v, want %+v %+v", got, got.Margins, tt.want, tt.want.Margins)
+			}
+		})
+	}
+
+	// the base margins are not modified
+	values, _ := url.ParseQuery("margin=20")
+	if _, err := ApplyPageQuery(base, values); err != nil || base.Margins.Top != 10 {
+		t.Errorf("ApplyPageQuery() changed the base margins to %+v", base.Margins)
+	}
+}
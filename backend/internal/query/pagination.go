@@ -0,0 +1,39 @@
+package query
+
+// Pagination holds the clamped page position and the slice bounds for a result set
+type Pagination struct {
+	Page       int
+	TotalPages int
+	Start      int
+	End        int
+}
+
+// Paginate calculates the page bounds for totalCount items.
+// The page is clamped to the last available page.
+func Paginate(totalCount, page, pageSize int) Pagination {
+	totalPages := (totalCount + pageSize - 1) / pageSize
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	// Ensure page is within bounds
+	if page > totalPages {
+		page = totalPages
+	}
+
+	start := (page - 1) * pageSize
+	end := start + pageSize
+	if start > totalCount {
+		start = totalCount
+	}
+	if end > totalCount {
+		end = totalCount
+	}
+
+	return Pagination{
+		Page:       page,
+		TotalPages: totalPages,
+		Start:      start,
+		End:        end,
+	}
+}
@@ -90,27 +90,8 @@ func TestPaginateInvoices(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			invoices := createInvoices(tt.totalItems)
-			totalCount := len(invoices)
-
-			// Calculate pagination (same logic as handler)
-			totalPages := (totalCount + tt.pageSize - 1) / tt.pageSize
-			if totalPages < 1 {
-				totalPages = 1
-			}
-
-			page := tt.page
-			if page > totalPages {
-				page = totalPages
-			}
-
-			start := (page - 1) * tt.pageSize
-			end := start + tt.pageSize
-			if start > totalCount {
-				start = totalCount
-			}
-			if end > totalCount {
-				end = totalCount
-			}
+			p := Paginate(len(invoices), tt.page, tt.pageSize)
+			totalPages, start, end := p.TotalPages, p.Start, p.End
 
 			paginatedItems := invoices[start:end]
 
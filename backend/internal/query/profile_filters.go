@@ -0,0 +1,96 @@
+package query
+
+import (
+	"go-invoice/internal/invoice"
+	"sort"
+	"strings"
+)
+
+// Profile is implemented by stored client and provider records
+type Profile interface {
+	GetParty() invoice.Party
+}
+
+// FilterProfiles applies all active filters to the profile list.
+// outstanding maps a profile ID to its number of outstanding invoices and
+// is only consulted when the has_outstanding filter is set.
+func FilterProfiles[T Profile](profiles []T, params *ProfileQueryParams, outstanding map[string]int) []T {
+	if !params.HasFilters() {
+		return profiles
+	}
+
+	filtered := make([]T, 0, len(profiles))
+	for _, p := range profiles {
+		if matchesProfileFilters(p.GetParty(), params, outstanding) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// matchesProfileFilters checks if a profile matches all active filters
+func matchesProfileFilters(party invoice.Party, params *ProfileQueryParams, outstanding map[string]int) bool {
+	if params.Search != "" &&
+		!containsFold(party.Name, params.Search) &&
+		!containsFold(party.Email, params.Search) {
+		return false
+	}
+	if params.Name != "" && !containsFold(party.Name, params.Name) {
+		return false
+	}
+	if params.Email != "" && !containsFold(party.Email, params.Email) {
+		return false
+	}
+	if params.HasOutstanding != nil && (outstanding[party.Id] > 0) != *params.HasOutstanding {
+		return false
+	}
+	return true
+}
+
+// SortProfiles orders profiles by the given sort keys, ties are broken by ID
+func SortProfiles[T Profile](profiles []T, keys []SortKey) {
+	sort.SliceStable(profiles, func(i, j int) bool {
+		a, b := profiles[i].GetParty(), profiles[j].GetParty()
+		for _, key := range keys {
+			var c int
+			switch key.Field {
+			case ProfileSortID:
+				c = compareStrings(a.Id, b.Id)
+			case ProfileSortName:
+				c = compareStrings(a.Name, b.Name)
+			case ProfileSortEmail:
+				c = compareStrings(a.Email, b.Email)
+			}
+			if c != 0 {
+				if key.Desc {
+					return c > 0
+				}
+				return c < 0
+			}
+		}
+		return a.Id < b.Id
+	})
+}
+
+// CountOutstanding counts outstanding (sent) invoices per party ID.
+// party selects which side of the invoice to count, e.g. the client.
+func CountOutstanding(invoices []invoice.Invoice, party func(invoice.Invoice) invoice.Party) map[string]int {
+	counts := make(map[string]int)
+	for _, inv := range invoices {
+		if inv.Status != invoice.StatusSent {
+			continue
+		}
+		p := party(inv)
+		id := p.Id
+		if id == "" {
+			// fall back to the ID derived from name for older invoices
+			id = normalizeID(p.Name)
+		}
+		counts[id]++
+	}
+	return counts
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
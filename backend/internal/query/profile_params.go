@@ -0,0 +1,67 @@
+package query
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// Profile sort fields
+const (
+	ProfileSortID    = "id"
+	ProfileSortName  = "name"
+	ProfileSortEmail = "email"
+)
+
+var profileSortFields = []string{ProfileSortID, ProfileSortName, ProfileSortEmail}
+
+// ProfileQueryParams holds all possible query parameters for client and provider listings
+type ProfileQueryParams struct {
+	Search         string // matches against name or email
+	Name           string
+	Email          string
+	HasOutstanding *bool // nil when the filter is not set
+	Sort           []SortKey
+	// Pagination
+	Page     int
+	PageSize int
+}
+
+// ParseProfileQuery extracts and validates query parameters from URL
+func ParseProfileQuery(values url.Values) *ProfileQueryParams {
+	page, pageSize := parsePagination(values)
+
+	sortKeys := parseSortParam(values.Get("sort"), profileSortFields)
+	if len(sortKeys) == 0 {
+		sortKeys = []SortKey{{Field: ProfileSortName}}
+	}
+
+	return &ProfileQueryParams{
+		Search:         values.Get("q"),
+		Name:           values.Get("name"),
+		Email:          values.Get("email"),
+		HasOutstanding: parseBoolParam(values.Get("has_outstanding")),
+		Sort:           sortKeys,
+		Page:           page,
+		PageSize:       pageSize,
+	}
+}
+
+// HasFilters returns true if any filter is active
+func (q *ProfileQueryParams) HasFilters() bool {
+	return q.Search != "" ||
+		q.Name != "" ||
+		q.Email != "" ||
+		q.HasOutstanding != nil
+}
+
+// parseBoolParam returns nil if the value is empty or not a valid boolean
+func parseBoolParam(value string) *bool {
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil
+	}
+	return &parsed
+}
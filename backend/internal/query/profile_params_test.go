@@ -0,0 +1,185 @@
+package query
+
+import (
+	"go-invoice/internal/invoice"
+	"net/url"
+	"testing"
+)
+
+type testProfile struct {
+	invoice.Party
+}
+
+func (p *testProfile) GetParty() invoice.Party {
+	return p.Party
+}
+
+func newTestProfiles() []*testProfile {
+	return []*testProfile{
+		{invoice.Party{Id: "acme", Name: "Acme Corp", Email: "billing@acme.test"}},
+		{invoice.Party{Id: "globex", Name: "Globex", Email: "accounts@globex.test"}},
+		{invoice.Party{Id: "initech", Name: "Initech", Email: "finance@initech.test"}},
+	}
+}
+
+func profileIDs(profiles []*testProfile) []string {
+	ids := make([]string, len(profiles))
+	for i, p := range profiles {
+		ids[i] = p.Id
+	}
+	return ids
+}
+
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseProfileQuery(t *testing.T) {
+	values, _ := url.ParseQuery("q=acme&has_outstanding=true&sort=email:desc,name&page=2&page_size=5")
+	result := ParseProfileQuery(values)
+
+	if result.Search != "acme" {
+		t.Errorf("Search = %s, want acme", result.Search)
+	}
+	if result.HasOutstanding == nil || !*result.HasOutstanding {
+		t.Errorf("HasOutstanding = %v, want true", result.HasOutstanding)
+	}
+	if len(result.Sort) != 2 ||
+		result.Sort[0] != (SortKey{Field: ProfileSortEmail, Desc: true}) ||
+		result.Sort[1] != (SortKey{Field: ProfileSortName}) {
+		t.Errorf("Sort = %v, want [email:desc name:asc]", result.Sort)
+	}
+	if result.Page != 2 || result.PageSize != 5 {
+		t.Errorf("Page, PageSize = %d, %d, want 2, 5", result.Page, result.PageSize)
+	}
+}
+
+func TestParseProfileQuery_Defaults(t *testing.T) {
+	result := ParseProfileQuery(url.Values{})
+
+	if result.HasFilters() {
+		t.Errorf("HasFilters() = true, want false")
+	}
+	if len(result.Sort) != 1 || result.Sort[0] != (SortKey{Field: ProfileSortName}) {
+		t.Errorf("Sort = %v, want [name:asc]", result.Sort)
+	}
+	if result.HasOutstanding != nil {
+		t.Errorf("HasOutstanding = %v, want nil", *result.HasOutstanding)
+	}
+}
+
+func TestFilterProfiles(t *testing.T) {
+	yes, no := true, false
+	outstanding := map[string]int{"globex": 2}
+
+	tests := []struct {
+		name     string
+		params   *ProfileQueryParams
+		expected []string
+	}{
+		{
+			name:     "no filters",
+			params:   &ProfileQueryParams{},
+			expected: []string{"acme", "globex", "initech"},
+		},
+		{
+			name:     "search matches name case-insensitively",
+			params:   &ProfileQueryParams{Search: "ACME"},
+			expected: []string{"acme"},
+		},
+		{
+			name:     "search matches email",
+			params:   &ProfileQueryParams{Search: "finance@"},
+			expected: []string{"initech"},
+		},
+		{
+			name:     "email filter",
+			params:   &ProfileQueryParams{Email: ".test"},
+			expected: []string{"acme", "globex", "initech"},
+		},
+		{
+			name:     "has outstanding",
+			params:   &ProfileQueryParams{HasOutstanding: &yes},
+			expected: []string{"globex"},
+		},
+		{
+			name:     "has no outstanding",
+			params:   &ProfileQueryParams{HasOutstanding: &no},
+			expected: []string{"acme", "initech"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := profileIDs(FilterProfiles(newTestProfiles(), tt.params, outstanding))
+			if !equalIDs(result, tt.expected) {
+				t.Errorf("FilterProfiles() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSortProfiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []SortKey
+		expected []string
+	}{
+		{
+			name:     "name ascending",
+			keys:     []SortKey{{Field: ProfileSortName}},
+			expected: []string{"acme", "globex", "initech"},
+		},
+		{
+			name:     "name descending",
+			keys:     []SortKey{{Field: ProfileSortName, Desc: true}},
+			expected: []string{"initech", "globex", "acme"},
+		},
+		{
+			name:     "email ascending",
+			keys:     []SortKey{{Field: ProfileSortEmail}},
+			expected: []string{"globex", "acme", "initech"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profiles := newTestProfiles()
+			SortProfiles(profiles, tt.keys)
+			if result := profileIDs(profiles); !equalIDs(result, tt.expected) {
+				t.Errorf("SortProfiles() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCountOutstanding(t *testing.T) {
+	invoices := []invoice.Invoice{
+		{Status: invoice.StatusSent, Client: invoice.Party{Id: "acme"}},
+		{Status: invoice.StatusSent, Client: invoice.Party{Id: "acme"}},
+		{Status: invoice.StatusDraft, Client: invoice.Party{Id: "globex"}},
+		{Status: invoice.StatusSent, Client: invoice.Party{Name: "Initech Pty"}},
+	}
+
+	counts := CountOutstanding(invoices, func(inv invoice.Invoice) invoice.Party {
+		return inv.Client
+	})
+
+	if counts["acme"] != 2 {
+		t.Errorf("counts[acme] = %d, want 2", counts["acme"])
+	}
+	if counts["globex"] != 0 {
+		t.Errorf("counts[globex] = %d, want 0", counts["globex"])
+	}
+	if counts["initech_pty"] != 1 {
+		t.Errorf("counts[initech_pty] = %d, want 1", counts["initech_pty"])
+	}
+}
@@ -15,6 +15,7 @@ const (
 
 // InvoiceQueryParams holds all possible query parameters for invoice filtering
 type InvoiceQueryParams struct {
+	Search      string // full-text search query, see package search
 	ClientID    string
 	ProviderID  string
 	Status      string
@@ -22,6 +23,11 @@ type InvoiceQueryParams struct {
 	DueDateTo   types.Date
 	DateFrom    types.Date
 	DateTo      types.Date
+	MinTotal    *float32     // nil when the filter is not set
+	MaxTotal    *float32     // nil when the filter is not set
+	Overdue     *bool        // sent invoices past their due date, nil when not set
+	DueWithin   types.Period // due between today and today + period, zero when not set
+	Sort        []SortKey    // empty means the default order
 	// Pagination
 	Page     int
 	PageSize int
@@ -29,23 +35,10 @@ type InvoiceQueryParams struct {
 
 // ParseInvoiceQuery extracts and validates query parameters from URL
 func ParseInvoiceQuery(values url.Values) *InvoiceQueryParams {
-	page := parseIntParam(values.Get("page"), DefaultPage)
-	pageSize := parseIntParam(values.Get("page_size"), DefaultPageSize)
-
-	// Validate page
-	if page < 1 {
-		page = DefaultPage
-	}
-
-	// Validate page_size
-	if pageSize < 1 {
-		pageSize = DefaultPageSize
-	}
-	if pageSize > MaxPageSize {
-		pageSize = MaxPageSize
-	}
+	page, pageSize := parsePagination(values)
 
 	return &InvoiceQueryParams{
+		Search:      values.Get("q"),
 		ClientID:    values.Get("client_id"),
 		ProviderID:  values.Get("provider_id"),
 		Status:      values.Get("status"),
@@ -53,6 +46,11 @@ func ParseInvoiceQuery(values url.Values) *InvoiceQueryParams {
 		DueDateTo:   parseTimeParam(values.Get("due_to")),
 		DateFrom:    parseTimeParam(values.Get("from")),
 		DateTo:      parseTimeParam(values.Get("to")),
+		MinTotal:    parseFloatParam(values.Get("min_total")),
+		MaxTotal:    parseFloatParam(values.Get("max_total")),
+		Overdue:     parseBoolParam(values.Get("overdue")),
+		DueWithin:   parsePeriodParam(values.Get("due_within")),
+		Sort:        parseSortParam(values.Get("sort"), invoiceSortFields),
 		Page:        page,
 		PageSize:    pageSize,
 	}
@@ -60,13 +58,38 @@ func ParseInvoiceQuery(values url.Values) *InvoiceQueryParams {
 
 // HasFilters returns true if any filter is active
 func (q *InvoiceQueryParams) HasFilters() bool {
-	return (q.ClientID != "" ||
+	return (q.Search != "" ||
+		q.ClientID != "" ||
 		q.ProviderID != "" ||
 		q.Status != "" ||
 		!q.DueDateFrom.IsZero() ||
 		!q.DueDateTo.IsZero() ||
 		!q.DateFrom.IsZero() ||
-		!q.DateTo.IsZero())
+		!q.DateTo.IsZero() ||
+		q.MinTotal != nil ||
+		q.MaxTotal != nil ||
+		q.Overdue != nil ||
+		!q.DueWithin.IsZero())
+}
+
+// parsePagination extracts and validates the page and page_size parameters
+func parsePagination(values url.Values) (page, pageSize int) {
+	page = parseIntParam(values.Get("page"), DefaultPage)
+	pageSize = parseIntParam(values.Get("page_size"), DefaultPageSize)
+
+	// Validate page
+	if page < 1 {
+		page = DefaultPage
+	}
+
+	// Validate page_size
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+	return page, pageSize
 }
 
 func parseTimeParam(value string) types.Date {
@@ -78,6 +101,31 @@ func parseTimeParam(value string) types.Date {
 	return types.NewDate(t)
 }
 
+// parseFloatParam returns nil if the value is empty or not a valid number
+func parseFloatParam(value string) *float32 {
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		return nil
+	}
+	f := float32(parsed)
+	return &f
+}
+
+// parsePeriodParam returns a zero period if the value is empty or invalid
+func parsePeriodParam(value string) types.Period {
+	if value == "" {
+		return types.Period{}
+	}
+	period, err := types.ParsePeriod(value)
+	if err != nil {
+		return types.Period{}
+	}
+	return period
+}
+
 func parseIntParam(value string, defaultValue int) int {
 	if value == "" {
 		return defaultValue
@@ -147,3 +147,87 @@ func TestHasFilters(t *testing.T) {
 		})
 	}
 }
+
+func TestParseInvoiceQuery_Sort(t *testing.T) {
+	tests := []struct {
+		name        string
+		queryString string
+		expected    []SortKey
+	}{
+		{
+			name:        "no sort",
+			queryString: "",
+			expected:    nil,
+		},
+		{
+			name:        "single key defaults to ascending",
+			queryString: "sort=total",
+			expected:    []SortKey{{Field: InvoiceSortTotal}},
+		},
+		{
+			name:        "multiple keys with directions",
+			queryString: "sort=client:asc,due:desc",
+			expected:    []SortKey{{Field: InvoiceSortClient}, {Field: InvoiceSortDue, Desc: true}},
+		},
+		{
+			name:        "unknown fields and directions are ignored",
+			queryString: "sort=amount,status:sideways,date:DESC",
+			expected:    []SortKey{{Field: InvoiceSortDate, Desc: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.queryString)
+			result := ParseInvoiceQuery(values)
+
+			if len(result.Sort) != len(tt.expected) {
+				t.Fatalf("Sort = %v, want %v", result.Sort, tt.expected)
+			}
+			for i := range result.Sort {
+				if result.Sort[i] != tt.expected[i] {
+					t.Errorf("Sort = %v, want %v", result.Sort, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestParseInvoiceQuery_DerivedFilters(t *testing.T) {
+	values, _ := url.ParseQuery("min_total=100.5&max_total=2000&overdue=true&due_within=7d")
+	result := ParseInvoiceQuery(values)
+
+	if result.MinTotal == nil || *result.MinTotal != 100.5 {
+		t.Errorf("MinTotal = %v, want 100.5", result.MinTotal)
+	}
+	if result.MaxTotal == nil || *result.MaxTotal != 2000 {
+		t.Errorf("MaxTotal = %v, want 2000", result.MaxTotal)
+	}
+	if result.Overdue == nil || !*result.Overdue {
+		t.Errorf("Overdue = %v, want true", result.Overdue)
+	}
+	if result.DueWithin.Days != 7 {
+		t.Errorf("DueWithin = %+v, want 7 days", result.DueWithin)
+	}
+	if !result.HasFilters() {
+		t.Errorf("HasFilters() = false, want true")
+	}
+}
+
+func TestParseInvoiceQuery_InvalidDerivedFilters(t *testing.T) {
+	values, _ := url.ParseQuery("min_total=abc&overdue=maybe&due_within=7x")
+	result := ParseInvoiceQuery(values)
+
+	if result.MinTotal != nil {
+		t.Errorf("MinTotal = %v, want nil", *result.MinTotal)
+	}
+	if result.Overdue != nil {
+		t.Errorf("Overdue = %v, want nil", *result.Overdue)
+	}
+	if !result.DueWithin.IsZero() {
+		t.Errorf("DueWithin = %+v, want zero", result.DueWithin)
+	}
+	if result.HasFilters() {
+		t.Errorf("HasFilters() = true, want false")
+	}
+}
@@ -0,0 +1,52 @@
+package query
+
+import "strings"
+
+// SortKey is a single field to order results by
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// parseSortParam parses a comma separated list of sort keys.
+// Each key is a field name optionally suffixed with ":asc" or ":desc",
+// e.g. "date:desc,total". Unknown fields and directions are ignored.
+func parseSortParam(value string, allowed []string) []SortKey {
+	if value == "" {
+		return nil
+	}
+
+	var keys []SortKey
+	for _, part := range strings.Split(value, ",") {
+		field, dir, _ := strings.Cut(strings.TrimSpace(part), ":")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !isAllowedField(field, allowed) {
+			continue
+		}
+
+		key := SortKey{Field: field}
+		switch strings.ToLower(strings.TrimSpace(dir)) {
+		case "", "asc":
+		case "desc":
+			key.Desc = true
+		default:
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func isAllowedField(field string, allowed []string) bool {
+	for _, f := range allowed {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// compareStrings compares two strings case-insensitively
+func compareStrings(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
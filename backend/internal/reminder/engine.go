@@ -0,0 +1,157 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/types"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrDisabled is returned by RunOnce when the schedule is disabled
+var ErrDisabled = errors.New("payment reminders are disabled")
+
+// Result describes the reminder due for an invoice in a run
+type Result struct {
+	InvoiceID  string `json:"invoice_id"`
+	Level      string `json:"level"`
+	TemplateID string `json:"template_id"`
+	JobID      string `json:"job_id,omitempty"`  // outbox job, empty in a dry run
+	Skipped    string `json:"skipped,omitempty"` // why no reminder was queued
+	Error      string `json:"error,omitempty"`
+}
+
+// Engine finds invoices due for a reminder and queues them. The storage and
+// delivery are provided by the caller.
+type Engine struct {
+	path string
+	mu   sync.Mutex // serializes runs and schedule updates
+
+	Invoices    func() ([]*invoice.Invoice, error)                                                           // all stored invoices
+	Invoice     func(id string) (*invoice.Invoice, error)                                                    // the stored invoice
+	OptedOut    func(inv *invoice.Invoice) bool                                                              // whether the invoice's client opted out
+	Queue       func(ctx context.Context, inv *invoice.Invoice, level Level, sender *Sender) (string, error) // queues a reminder, returns the job ID
+	SaveInvoice func(inv *invoice.Invoice) error
+}
+
+// NewEngine creates an engine for the schedule stored at path
+func NewEngine(path string) *Engine {
+	return &Engine{path: path}
+}
+
+// Schedule returns the stored schedule
+func (e *Engine) Schedule() (*Schedule, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return LoadSchedule(e.path)
+}
+
+// SetSchedule validates and stores the schedule
+func (e *Engine) SetSchedule(s *Schedule) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return s.Save(e.path)
+}
+
+// RunOnce queues the reminders due today. A dry run only reports them.
+func (e *Engine) RunOnce(ctx context.Context, today types.Date, dryRun bool) ([]Result, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	schedule, err := LoadSchedule(e.path)
+	if err != nil {
+		return nil, err
+	}
+	if !schedule.Enabled && !dryRun {
+		return nil, ErrDisabled
+	}
+	invoices, err := e.Invoices()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []Result{}
+	for _, inv := range invoices {
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+		level := schedule.DueLevel(inv, today)
+		if level == nil {
+			continue
+		}
+		result := Result{InvoiceID: inv.ID, Level: level.ID, TemplateID: level.TemplateID}
+		if e.OptedOut(inv) {
+			result.Skipped = "client opted out of reminders"
+			results = append(results, result)
+			continue
+		}
+		if dryRun {
+			results = append(results, result)
+			continue
+		}
+
+		// the invoice may have changed since it was listed, e.g. been paid
+		fresh, err := e.Invoice(inv.ID)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			slog.Error("failed to reload invoice for payment reminder", "invoice", inv.ID, "error", err)
+			continue
+		}
+		if due := schedule.DueLevel(fresh, today); due == nil || due.ID != level.ID {
+			continue
+		}
+
+		jobID, err := e.Queue(ctx, fresh, *level, schedule.Sender)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			slog.Error("failed to queue payment reminder", "invoice", inv.ID, "level", level.ID, "error", err)
+			continue
+		}
+		result.JobID = jobID
+
+		// record the reminder so it is never queued twice
+		if err := e.record(inv.ID, invoice.ReminderRecord{Level: level.ID, Date: today, JobID: jobID}); err != nil {
+			result.Error = err.Error()
+			slog.Error("payment reminder queued but not recorded", "invoice", inv.ID, "level", level.ID, "job", jobID, "error", err)
+		}
+		results = append(results, result)
+		slog.Info("payment reminder queued", "invoice", inv.ID, "level", level.ID, "job", jobID)
+	}
+	return results, nil
+}
+
+// record appends a queued reminder to the stored invoice. The invoice is read
+// again so edits made while the reminder was queued are kept; should it have
+// been paid meanwhile, the outbox cancels the reminder before delivery.
+func (e *Engine) record(id string, rec invoice.ReminderRecord) error {
+	inv, err := e.Invoice(id)
+	if err != nil {
+		return err
+	}
+	inv.Reminders = append(inv.Reminders, rec)
+	return e.SaveInvoice(inv)
+}
+
+// Run checks for due reminders every interval until ctx is cancelled
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, err := e.RunOnce(ctx, types.Today(), false)
+		if err != nil && !errors.Is(err, ErrDisabled) {
+			slog.Error("payment reminder run failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
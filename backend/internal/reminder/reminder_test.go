@@ -0,0 +1,217 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"go-invoice/internal/emailtemplate"
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/types"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func date(s string) types.Date {
+	t, _ := time.Parse("2006-01-02", s)
+	return types.NewDate(t)
+}
+
+func TestDueLevel(t *testing.T) {
+	schedule := DefaultSchedule()
+	tests := []struct {
+		name      string
+		status    invoice.InvoiceStatus
+		today     string
+		reminders []string
+		expected  string // empty means no reminder
+	}{
+		{"too early", invoice.StatusSent, "2025-11-11", nil, ""},
+		{"3 days before due", invoice.StatusSent, "2025-11-12", nil, "before_due"},
+		{"between levels", invoice.StatusSent, "2025-11-13", nil, "before_due"},
+		{"before due already sent", invoice.StatusSent, "2025-11-13", []string{"before_due"}, ""},
+		{"on due date", invoice.StatusSent, "2025-11-15", []string{"before_due"}, "due"},
+		{"7 days overdue", invoice.StatusSent, "2025-11-22", []string{"before_due", "due"}, "overdue_7"},
+		{"found late sends only the latest level", invoice.StatusSent, "2026-01-10", nil, "overdue_30"},
+		{"never after a later level", invoice.StatusSent, "2025-11-22", []string{"overdue_30"}, ""},
+		{"draft", invoice.StatusDraft, "2025-11-22", nil, ""},
+		{"paid", invoice.StatusPaid, "2025-11-22", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := &invoice.Invoice{ID: "INV-1", Status: tt.status, Due: date("2025-11-15")}
+			for _, level := range tt.reminders {
+				inv.Reminders = append(inv.Reminders, invoice.ReminderRecord{Level: level})
+			}
+			level := schedule.DueLevel(inv, date(tt.today))
+			var result string
+			if level != nil {
+				result = level.ID
+			}
+			if result != tt.expected {
+				t.Errorf("DueLevel() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		levels []Level
+		valid  bool
+	}{
+		{"default", DefaultSchedule().Levels, true},
+		{"bad id", []Level{{ID: "Due Soon", TemplateID: "t"}}, false},
+		{"duplicate id", []Level{{ID: "a", OffsetDays: 1, TemplateID: "t"}, {ID: "a", OffsetDays: 2, TemplateID: "t"}}, false},
+		{"duplicate offset", []Level{{ID: "a", OffsetDays: 1, TemplateID: "t"}, {ID: "b", OffsetDays: 1, TemplateID: "t"}}, false},
+		{"missing template", []Level{{ID: "a"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Schedule{Enabled: true, Levels: tt.levels}
+			if err := s.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Validate() error = %v, want valid = %v", err, tt.valid)
+			}
+		})
+	}
+
+	s := &Schedule{Levels: []Level{{ID: "late", OffsetDays: 7, TemplateID: "t"}, {ID: "early", OffsetDays: -3, TemplateID: "t"}}}
+	if err := s.Validate(); err != nil || s.Levels[0].ID != "early" {
+		t.Errorf("Validate() should order levels by offset, got %+v", s.Levels)
+	}
+}
+
+func TestEngine_RunOnce(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "reminders.json")
+	engine := NewEngine(path)
+
+	invoices := []*invoice.Invoice{
+		{ID: "INV-1", Status: invoice.StatusSent, Due: date("2025-11-15"), Client: invoice.Party{Id: "acme"}},
+		{ID: "INV-2", Status: invoice.StatusSent, Due: date("2025-11-15"), Client: invoice.Party{Id: "optout"}},
+		{ID: "INV-3", Status: invoice.StatusPaid, Due: date("2025-11-15"), Client: invoice.Party{Id: "acme"}},
+		{ID: "INV-4", Status: invoice.StatusSent, Due: date("2025-12-31"), Client: invoice.Party{Id: "acme"}},
+	}
+	// the engine works on copies, like invoices read from disk
+	stored := make(map[string]*invoice.Invoice)
+	for _, inv := range invoices {
+		stored[inv.ID] = inv
+	}
+	load := func(id string) (*invoice.Invoice, error) {
+		inv := *stored[id]
+		return &inv, nil
+	}
+	var queued []string
+	saved := make(map[string]int)
+	engine.Invoices = func() ([]*invoice.Invoice, error) {
+		var all []*invoice.Invoice
+		for _, inv := range invoices {
+			c, _ := load(inv.ID)
+			all = append(all, c)
+		}
+		return all, nil
+	}
+	engine.Invoice = load
+	engine.OptedOut = func(inv *invoice.Invoice) bool { return inv.Client.Id == "optout" }
+	var onQueue func(inv *invoice.Invoice)
+	engine.Queue = func(ctx context.Context, inv *invoice.Invoice, level Level, sender *Sender) (string, error) {
+		queued = append(queued, inv.ID+":"+level.ID)
+		if onQueue != nil {
+			onQueue(inv)
+		}
+		return "job-" + inv.ID, nil
+	}
+	engine.SaveInvoice = func(inv *invoice.Invoice) error {
+		saved[inv.ID]++
+		stored[inv.ID] = inv
+		return nil
+	}
+	today := date("2025-11-22")
+
+	if _, err := engine.RunOnce(context.Background(), today, false); !errors.Is(err, ErrDisabled) {
+		t.Fatalf("RunOnce() on the disabled default schedule error = %v, want ErrDisabled", err)
+	}
+
+	// a dry run reports without queueing
+	results, err := engine.RunOnce(context.Background(), today, true)
+	if err != nil {
+		t.Fatalf("RunOnce() dry run error = %v", err)
+	}
+	if len(results) != 2 || len(queued) != 0 {
+		t.Fatalf("dry run results = %+v, queued = %v", results, queued)
+	}
+
+	schedule := DefaultSchedule()
+	schedule.Enabled = true
+	if err := engine.SetSchedule(schedule); err != nil {
+		t.Fatalf("SetSchedule() error = %v", err)
+	}
+	results, err = engine.RunOnce(context.Background(), today, false)
+	if err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if len(queued) != 1 || queued[0] != "INV-1:overdue_7" {
+		t.Errorf("queued = %v, want only INV-1:overdue_7", queued)
+	}
+	if len(results) != 2 || results[1].Skipped == "" {
+		t.Errorf("results = %+v, want the opted out client reported as skipped", results)
+	}
+	if rec := stored["INV-1"].Reminders; len(rec) != 1 || rec[0].Level != "overdue_7" || rec[0].JobID != "job-INV-1" || saved["INV-1"] != 1 {
+		t.Errorf("reminder not recorded on the invoice: %+v", rec)
+	}
+
+	// a second run on the same day sends nothing new
+	if _, err := engine.RunOnce(context.Background(), today, false); err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if len(queued) != 1 {
+		t.Errorf("queued = %v after a second run, want no duplicates", queued)
+	}
+
+	// edits made while a reminder is queued are kept when it is recorded
+	stored["INV-4"].Due = date("2025-11-01")
+	onQueue = func(inv *invoice.Invoice) {
+		edited := *stored[inv.ID]
+		edited.Status = invoice.StatusPaid
+		stored[inv.ID] = &edited
+	}
+	if _, err := engine.RunOnce(context.Background(), today, false); err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if inv := stored["INV-4"]; inv.Status != invoice.StatusPaid || len(inv.Reminders) != 1 {
+		t.Errorf("invoice paid during the run = %s with reminders %+v, want paid with the reminder recorded", inv.Status, inv.Reminders)
+	}
+
+	// invoices paid after they were listed are not reminded
+	onQueue = nil
+	stored["INV-1"].Due = date("2025-10-01")
+	list, _ := engine.Invoices()
+	engine.Invoices = func() ([]*invoice.Invoice, error) { return list, nil }
+	stored["INV-1"].Status = invoice.StatusPaid
+	queued = nil
+	if _, err := engine.RunOnce(context.Background(), today, false); err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if len(queued) != 0 {
+		t.Errorf("queued = %v, want nothing for an invoice paid since it was listed", queued)
+	}
+}
+
+func TestDefaultTemplatesRender(t *testing.T) {
+	inv := &invoice.Invoice{ID: "INV-1", Status: invoice.StatusSent, Due: date("2025-11-15")}
+	vars := emailtemplate.NewVars(inv)
+	for _, et := range DefaultTemplates() {
+		if _, err := emailtemplate.RenderEmail(et, vars); err != nil {
+			t.Errorf("template %s: %v", et.Id, err)
+		}
+	}
+	for _, l := range DefaultSchedule().Levels {
+		found := false
+		for _, et := range DefaultTemplates() {
+			found = found || et.Id == l.TemplateID
+		}
+		if !found {
+			t.Errorf("level %s uses template %s, which is not a default template", l.ID, l.TemplateID)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package reminder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-invoice/internal/auth"
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/types"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// Level is a step of the reminder schedule
+type Level struct {
+	ID         string `json:"id"`          // unique level name, recorded on the invoice
+	OffsetDays int    `json:"offset_days"` // days relative to the due date, negative is before it
+	TemplateID string `json:"template_id"` // email template used for this level
+}
+
+// Sender is who reminders are sent as. The background runner has no user
+// session, so OAuth2 credentials are captured when the schedule is saved.
+type Sender struct {
+	From        string          `json:"from"`
+	AuthMethod  auth.AuthMethod `json:"auth_method"`
+	Credentials string          `json:"credentials,omitempty"` // sealed, see outbox.SealCredentials
+}
+
+// Schedule configures which reminders are sent for sent, unpaid invoices
+type Schedule struct {
+	Enabled bool    `json:"enabled"`
+	Levels  []Level `json:"levels"` // ordered by OffsetDays
+	Sender  *Sender `json:"sender,omitempty"`
+}
+
+// DefaultSchedule reminds 3 days before the due date, on the due date,
+// and 7 and 30 days after it. It is disabled until turned on.
+func DefaultSchedule() *Schedule {
+	return &Schedule{
+		Enabled: false,
+		Levels: []Level{
+			{ID: "before_due", OffsetDays: -3, TemplateID: "reminder_before_due"},
+			{ID: "due", OffsetDays: 0, TemplateID: "reminder_due"},
+			{ID: "overdue_7", OffsetDays: 7, TemplateID: "reminder_overdue_7"},
+			{ID: "overdue_30", OffsetDays: 30, TemplateID: "reminder_overdue_30"},
+		},
+	}
+}
+
+var levelIDPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// Validate checks the levels and orders them by offset
+func (s *Schedule) Validate() error {
+	ids := make(map[string]bool)
+	offsets := make(map[int]bool)
+	for _, l := range s.Levels {
+		if !levelIDPattern.MatchString(l.ID) {
+			return fmt.Errorf("invalid level id '%s', use lowercase letters, digits, '_' and '-'", l.ID)
+		}
+		if ids[l.ID] {
+			return fmt.Errorf("duplicate level id '%s'", l.ID)
+		}
+		if offsets[l.OffsetDays] {
+			return fmt.Errorf("duplicate offset %d days in level '%s'", l.OffsetDays, l.ID)
+		}
+		if l.TemplateID == "" {
+			return fmt.Errorf("level '%s' has no template_id", l.ID)
+		}
+		ids[l.ID] = true
+		offsets[l.OffsetDays] = true
+	}
+	if s.Enabled && len(s.Levels) == 0 {
+		return fmt.Errorf("an enabled schedule needs at least one level")
+	}
+	sort.Slice(s.Levels, func(i, j int) bool {
+		return s.Levels[i].OffsetDays < s.Levels[j].OffsetDays
+	})
+	return nil
+}
+
+// Public returns a copy of the schedule that is safe to return from the API
+func (s *Schedule) Public() *Schedule {
+	c := *s
+	if s.Sender != nil {
+		sender := *s.Sender
+		sender.Credentials = ""
+		c.Sender = &sender
+	}
+	return &c
+}
+
+// DueLevel returns the level to send for the invoice today, or nil when no
+// reminder is due. Only the latest level that has come due is considered,
+// so an invoice found late gets one reminder instead of every missed level,
+// and a level is never sent after a later one.
+func (s *Schedule) DueLevel(inv *invoice.Invoice, today types.Date) *Level {
+	if inv.Status != invoice.StatusSent || inv.Due.IsZero() {
+		return nil
+	}
+	latest := -1
+	for i, l := range s.Levels {
+		if !inv.Due.AddDate(0, 0, l.OffsetDays).After(today.Time) {
+			latest = i
+		}
+	}
+	if latest < 0 {
+		return nil
+	}
+	for _, l := range s.Levels[latest:] {
+		if inv.HasReminder(l.ID) {
+			return nil
+		}
+	}
+	level := s.Levels[latest]
+	return &level
+}
+
+// UsesTemplate reports whether any level sends the email template
+func (s *Schedule) UsesTemplate(templateID string) bool {
+	for _, l := range s.Levels {
+		if l.TemplateID == templateID {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadSchedule reads the schedule stored at path. A missing file yields the default schedule.
+func LoadSchedule(path string) (*Schedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return DefaultSchedule(), nil
+		}
+		return nil, fmt.Errorf("failed to read reminder schedule: %v", err)
+	}
+	var s Schedule
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to decode reminder schedule: %v", err)
+	}
+	return &s, nil
+}
+
+// Save writes the schedule to path
+func (s *Schedule) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal reminder schedule: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write reminder schedule: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package reminder
+
+import (
+	"errors"
+	"fmt"
+	"go-invoice/internal/storage"
+	"os"
+	"path/filepath"
+)
+
+const paymentDetails = "Amount due: {{money TOTAL}}\nAccount name: {{ACCOUNT_NAME}}\nBSB: {{BSB}}\nAccount number: {{ACCOUNT_NUMBER}}\n\nIf you have already paid, please disregard this email.\n\nKind regards,\n{{PROVIDER_NAME}}"
+
+// DefaultTemplates returns the email templates used by DefaultSchedule
+func DefaultTemplates() []*storage.EmailTemplate {
+	return []*storage.EmailTemplate{
+		{
+			Id:      "reminder_before_due",
+			Name:    "Reminder: Due Soon",
+			Subject: "Reminder: invoice {{INVOICE_ID}} is due on {{date DUE_DATE}}",
+			Body:    "Hi {{CLIENT_NAME}},\n\nThis is a friendly reminder that invoice {{INVOICE_ID}} is due in {{DAYS_UNTIL_DUE}} days, on {{date DUE_DATE}}.\n\n" + paymentDetails,
+		},
+		{
+			Id:      "reminder_due",
+			Name:    "Reminder: Due Today",
+			Subject: "Invoice {{INVOICE_ID}} is due today",
+			Body:    "Hi {{CLIENT_NAME}},\n\nInvoice {{INVOICE_ID}} is due for payment today.\n\n" + paymentDetails,
+		},
+		{
+			Id:      "reminder_overdue_7",
+			Name:    "Reminder: Overdue",
+			Subject: "Overdue: invoice {{INVOICE_ID}}",
+			Body:    "Hi {{CLIENT_NAME}},\n\nOur records show that invoice {{INVOICE_ID}} was due on {{date DUE_DATE}} and is now {{DAYS_OVERDUE}} days overdue.\n\n" + paymentDetails,
+		},
+		{
+			Id:      "reminder_overdue_30",
+			Name:    "Reminder: Final Notice",
+			Subject: "Final reminder: invoice {{INVOICE_ID}} is {{DAYS_OVERDUE}} days overdue",
+			Body:    "Hi {{CLIENT_NAME}},\n\nInvoice {{INVOICE_ID}} was due on {{date DUE_DATE}} and remains unpaid after {{DAYS_OVERDUE}} days. Please arrange payment as soon as possible or contact us if there is an issue with the invoice.\n\n" + paymentDetails,
+		},
+	}
+}
+
+// Setup writes the default schedule and its templates on first run.
+// Once the schedule exists, deleted templates are not recreated.
+func Setup(schedulePath, templateDir string) error {
+	if _, err := os.Stat(schedulePath); err == nil {
+		return nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to check reminder schedule: %v", err)
+	}
+
+	for _, et := range DefaultTemplates() {
+		path := filepath.Join(templateDir, et.Id+".json")
+		if _, err := os.Stat(path); err == nil {
+			continue // keep a user template with the same ID
+		}
+		if err := et.SaveToFile(path); err != nil {
+			return fmt.Errorf("failed to create reminder template '%s': %v", et.Id, err)
+		}
+	}
+	return DefaultSchedule().Save(schedulePath)
+}
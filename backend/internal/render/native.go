@@ -0,0 +1,466 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-invoice/internal/emailtemplate"
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/pdf"
+	"go-invoice/internal/services"
+	"go-invoice/internal/types"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Producer is written into the metadata of native PDFs
+const Producer = "go-invoice"
+
+// colors of the frontend invoice display
+var (
+	colorForeground = pdf.Color{R: 9, G: 9, B: 11}
+	colorMuted      = pdf.Color{R: 113, G: 113, B: 122}
+	colorMutedBg    = pdf.Color{R: 244, G: 244, B: 245}
+	colorBorder     = pdf.Color{R: 228, G: 228, B: 231}
+	colorPrimary    = pdf.Color{R: 24, G: 24, B: 27}
+	colorPrimaryFg  = pdf.Color{R: 250, G: 250, B: 250}
+)
+
+// dimensions of the layout in points for a content width of baseWidth,
+// scaled to the content width of the page otherwise
+const (
+	baseWidth    = 515
+	gap          = 24
+	footerHeight = 20 // reserved at the bottom of every page for the page number
+	pointsPerMM  = 72 / 25.4
+)
+
+// NativeRenderer lays out an invoice like the frontend invoice display:
+// header, bill to, items, totals, payment information and notes. The item
+// table continues on new pages with its header repeated.
+type NativeRenderer struct {
+	regular *pdf.Font
+	bold    *pdf.Font
+	now     func() time.Time
+}
+
+// NewNativeRenderer creates a renderer using the Go fonts
+func NewNativeRenderer() (*NativeRenderer, error) {
+	regular, err := pdf.ParseFont(goregular.TTF)
+	if err != nil {
+		return nil, err
+	}
+	bold, err := pdf.ParseFont(gobold.TTF)
+	if err != nil {
+		return nil, err
+	}
+	return &NativeRenderer{regular: regular, bold: bold, now: time.Now}, nil
+}
+
+func (r *NativeRenderer) Render(ctx context.Context, inv *invoice.Invoice, opts Options) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	page := pageLayout(opts.Page)
+	paper := page.Size()
+
+	doc := pdf.New()
+	doc.Title = inv.ID
+	doc.Author = inv.Provider.Name
+	doc.Subject = fmt.Sprintf("Invoice %s", inv.ID)
+	doc.Producer = Producer
+	doc.CreationDate = r.now()
+
+	l := &layout{
+		doc:  doc,
+		size: pdf.Size{Width: paper.Width * 72, Height: paper.Height * 72},
+		margins: services.Margins{
+			Top:    page.Margins.Top * pointsPerMM,
+			Right:  page.Margins.Right * pointsPerMM,
+			Bottom: page.Margins.Bottom * pointsPerMM,
+			Left:   page.Margins.Left * pointsPerMM,
+		},
+		regular: r.regular,
+		bold:    r.bold,
+	}
+	// landscape pages get wider tables rather than larger text
+	l.scale = (min(l.size.Width, l.size.Height) - l.margins.Left - l.margins.Right) / baseWidth
+	l.newPage()
+	l.header(inv)
+	l.billTo(inv.Client)
+	l.items(inv.Items)
+	l.totals(inv.Pricing)
+	l.payment(inv.Payment)
+	l.notes()
+	l.pageNumbers()
+	return doc.Bytes()
+}
+
+// layout places the invoice sections top to bottom, y is the top of the free space
+type layout struct {
+	doc     *pdf.Document
+	size    pdf.Size
+	margins services.Margins // in points
+	scale   float64
+	page    *pdf.Page
+	y       float64
+	regular *pdf.Font
+	bold    *pdf.Font
+}
+
+// pt scales a length of the layout to the page
+func (l *layout) pt(v float64) float64 {
+	return v * l.scale
+}
+
+func (l *layout) left() float64   { return l.margins.Left }
+func (l *layout) right() float64  { return l.size.Width - l.margins.Right }
+func (l *layout) width() float64  { return l.right() - l.left() }
+func (l *layout) bottom() float64 { return l.size.Height - l.margins.Bottom - l.pt(footerHeight) }
+
+// lineHeight is the height of a line of text in the given unscaled size
+func (l *layout) lineHeight(size float64) float64 {
+	return l.pt(size * 1.5)
+}
+
+func (l *layout) newPage() {
+	l.page = l.doc.AddPage(l.size)
+	l.y = l.margins.Top
+}
+
+// fits starts a new page unless height fits below y, reporting whether it did
+func (l *layout) fits(height float64) bool {
+	if l.y+height <= l.bottom() {
+		return true
+	}
+	l.newPage()
+	return false
+}
+
+// text writes s in the unscaled size with the top of its line at y
+func (l *layout) text(x, y float64, f *pdf.Font, size float64, c pdf.Color, s string) {
+	l.page.SetFillColor(c)
+	// center the glyphs vertically in the line
+	baseline := y + (l.lineHeight(size)+f.Ascent(l.pt(size))-f.Descent(l.pt(size)))/2
+	l.page.Text(x, baseline, f, l.pt(size), s)
+}
+
+// textRight writes s ending at x
+func (l *layout) textRight(x, y float64, f *pdf.Font, size float64, c pdf.Color, s string) {
+	l.text(x-f.Width(s, l.pt(size)), y, f, size, c, s)
+}
+
+// labelled writes "label value" with the label muted and the value emphasized
+func (l *layout) labelled(x, y float64, size float64, label, value string) {
+	l.text(x, y, l.regular, size, colorMuted, label)
+	l.text(x+l.regular.Width(label+" ", l.pt(size)), y, l.bold, size, colorForeground, value)
+}
+
+func (l *layout) rule(y float64) {
+	l.page.SetStrokeColor(colorBorder)
+	l.page.Line(l.left(), y, l.right(), y, l.pt(0.75))
+}
+
+// header writes the invoice title and dates on the left and the provider on the right
+func (l *layout) header(inv *invoice.Invoice) {
+	top := l.y
+	y := top
+	l.text(l.left(), y, l.bold, 26, colorForeground, "INVOICE")
+	y += l.lineHeight(26)
+	for _, row := range [][2]string{
+		{"Invoice Number:", inv.ID},
+		{"Date:", formatDate(inv.Date)},
+		{"Due Date:", formatDate(inv.Due)},
+	} {
+		l.labelled(l.left(), y, 10, row[0], row[1])
+		y += l.lineHeight(10)
+	}
+	leftBottom := y
+
+	y = top
+	columnWidth := l.width() / 2
+	for _, line := range l.bold.Wrap(inv.Provider.Name, l.pt(16), columnWidth) {
+		l.textRight(l.right(), y, l.bold, 16, colorForeground, line)
+		y += l.lineHeight(16)
+	}
+	for _, line := range partyDetails(inv.Provider) {
+		for _, wrapped := range l.regular.Wrap(line, l.pt(10), columnWidth) {
+			l.textRight(l.right(), y, l.regular, 10, colorMuted, wrapped)
+			y += l.lineHeight(10)
+		}
+	}
+	l.y = max(leftBottom, y) + l.pt(gap)
+}
+
+// billTo writes the client in a shaded box
+func (l *layout) billTo(client invoice.Party) {
+	padding := l.pt(10)
+	innerWidth := l.width() - 2*padding
+	name := l.bold.Wrap(client.Name, l.pt(11), innerWidth)
+	var details []string
+	for _, line := range partyDetails(client) {
+		details = append(details, l.regular.Wrap(line, l.pt(10), innerWidth)...)
+	}
+	boxHeight := 2*padding + float64(len(name))*l.lineHeight(11) + float64(len(details))*l.lineHeight(10)
+	l.fits(l.lineHeight(12) + l.pt(6) + boxHeight)
+
+	l.text(l.left(), l.y, l.bold, 12, colorForeground, "Bill To:")
+	l.y += l.lineHeight(12) + l.pt(6)
+	l.page.SetFillColor(colorMutedBg)
+	l.page.FillRect(l.left(), l.y, l.width(), boxHeight)
+	y := l.y + padding
+	for _, line := range name {
+		l.text(l.left()+padding, y, l.bold, 11, colorForeground, line)
+		y += l.lineHeight(11)
+	}
+	for _, line := range details {
+		l.text(l.left()+padding, y, l.regular, 10, colorMuted, line)
+		y += l.lineHeight(10)
+	}
+	l.y += boxHeight + l.pt(gap)
+}
+
+// column of the item table, align is "left", "center" or "right"
+type column struct {
+	title string
+	width float64 // unscaled, 0 takes the remaining width
+	align string
+}
+
+var itemColumns = []column{
+	{"Date", 75, "left"},
+	{"Description", 0, "left"},
+	{"Qty", 45, "center"},
+	{"Unit Price", 80, "right"},
+	{"Amount", 80, "right"},
+}
+
+// columnBounds returns the left and right edge of each column
+func (l *layout) columnBounds() [][2]float64 {
+	fixed := 0.0
+	for _, c := range itemColumns {
+		fixed += l.pt(c.width)
+	}
+	bounds := make([][2]float64, len(itemColumns))
+	x := l.left()
+	for i, c := range itemColumns {
+		w := l.pt(c.width)
+		if c.width == 0 {
+			w = l.width() - fixed
+		}
+		bounds[i] = [2]float64{x, x + w}
+		x += w
+	}
+	return bounds
+}
+
+// cell writes s aligned within a column with padding
+func (l *layout) cell(bounds [2]float64, align string, y float64, f *pdf.Font, size float64, c pdf.Color, s string) {
+	padding := l.pt(8)
+	switch align {
+	case "right":
+		l.textRight(bounds[1]-padding, y, f, size, c, s)
+	case "center":
+		l.text((bounds[0]+bounds[1]-f.Width(s, l.pt(size)))/2, y, f, size, c, s)
+	default:
+		l.text(bounds[0]+padding, y, f, size, c, s)
+	}
+}
+
+func (l *layout) tableHeader(bounds [][2]float64) {
+	height := l.pt(26)
+	l.page.SetFillColor(colorPrimary)
+	l.page.FillRect(l.left(), l.y, l.width(), height)
+	y := l.y + (height-l.lineHeight(10))/2
+	for i, c := range itemColumns {
+		l.cell(bounds[i], c.align, y, l.bold, 10, colorPrimaryFg, c.title)
+	}
+	l.y += height
+}
+
+// items writes the item table, repeating its header on every page it spans
+func (l *layout) items(items []invoice.ServiceItem) {
+	bounds := l.columnBounds()
+	padding := l.pt(8)
+	descWidth := bounds[1][1] - bounds[1][0] - 2*padding
+
+	l.fits(l.pt(26) + l.pt(40))
+	l.tableHeader(bounds)
+	for i, item := range items {
+		description := l.bold.Wrap(item.Description, l.pt(10), descWidth)
+		var detail []string
+		if item.DescriptionDetail != "" {
+			detail = l.regular.Wrap(item.DescriptionDetail, l.pt(9), descWidth)
+		}
+		height := 2*padding + float64(len(description))*l.lineHeight(10) + float64(len(detail))*l.lineHeight(9)
+		if !l.fits(height) {
+			l.tableHeader(bounds)
+		}
+
+		y := l.y + padding
+		l.cell(bounds[0], "left", y, l.regular, 10, colorMuted, formatDate(item.Date))
+		l.cell(bounds[2], "center", y, l.regular, 10, colorMuted, formatNumber(item.Quantity))
+		l.cell(bounds[3], "right", y, l.regular, 10, colorMuted, formatMoney(item.UnitPrice))
+		l.cell(bounds[4], "right", y, l.bold, 10, colorForeground, formatMoney(item.TotalPrice))
+		for _, line := range description {
+			l.cell(bounds[1], "left", y, l.bold, 10, colorForeground, line)
+			y += l.lineHeight(10)
+		}
+		for _, line := range detail {
+			l.cell(bounds[1], "left", y, l.regular, 9, colorMuted, line)
+			y += l.lineHeight(9)
+		}
+		l.y += height
+		if i < len(items)-1 {
+			l.rule(l.y)
+		}
+	}
+	l.page.SetStrokeColor(colorBorder)
+	l.page.Line(l.left(), l.y, l.right(), l.y, l.pt(1.5))
+	l.y += l.pt(gap)
+}
+
+// totals writes subtotal, tax and total right aligned
+func (l *layout) totals(pricing invoice.Pricing) {
+	width := l.pt(220)
+	rowHeight := l.pt(24)
+	totalHeight := l.pt(30)
+	l.fits(2*rowHeight + l.pt(6) + totalHeight)
+
+	x := l.right() - width
+	for _, row := range [][2]string{
+		{"Subtotal:", formatMoney(pricing.Subtotal)},
+		{fmt.Sprintf("GST (%s%%):", formatNumber(pricing.TaxRate)), formatMoney(pricing.TaxAmount)},
+	} {
+		y := l.y + (rowHeight-l.lineHeight(10))/2
+		l.text(x, y, l.regular, 10, colorMuted, row[0])
+		l.textRight(l.right(), y, l.bold, 10, colorForeground, row[1])
+		l.y += rowHeight
+		l.page.SetStrokeColor(colorBorder)
+		l.page.Line(x, l.y, l.right(), l.y, l.pt(0.75))
+	}
+	l.y += l.pt(6)
+	l.page.SetFillColor(colorPrimary)
+	l.page.FillRect(x, l.y, width, totalHeight)
+	y := l.y + (totalHeight-l.lineHeight(12))/2
+	l.text(x+l.pt(12), y, l.bold, 12, colorPrimaryFg, "Total:")
+	l.textRight(l.right()-l.pt(12), y, l.bold, 12, colorPrimaryFg, formatMoney(pricing.Total))
+	l.y += totalHeight + l.pt(gap)
+}
+
+// payment writes the bank details in two columns
+func (l *layout) payment(payment invoice.PaymentInfo) {
+	l.fits(l.pt(16) + l.lineHeight(12) + l.pt(8) + 2*l.lineHeight(10))
+	l.rule(l.y)
+	l.y += l.pt(16)
+	l.text(l.left(), l.y, l.bold, 12, colorForeground, "Payment Information")
+	l.y += l.lineHeight(12) + l.pt(8)
+
+	columns := [][][2]string{
+		{{"Payment Method:", payment.Method}, {"Account Name:", payment.AccountName}},
+		{{"BSB:", formatBSB(payment.BSB)}, {"Account Number:", payment.AccountNumber}},
+	}
+	for i, rows := range columns {
+		x := l.left() + float64(i)*l.width()/2
+		for j, row := range rows {
+			y := l.y + float64(j)*l.lineHeight(10)
+			l.text(x, y, l.bold, 10, colorForeground, row[0])
+			l.text(x+l.bold.Width(row[0]+" ", l.pt(10)), y, l.regular, 10, colorMuted, row[1])
+		}
+	}
+	l.y += 2*l.lineHeight(10) + l.pt(gap)
+}
+
+const paymentNote = "Payment is due within 30 days. Please include the invoice number with your payment. Thank you for your business!"
+
+func (l *layout) notes() {
+	lines := l.regular.Wrap(paymentNote, l.pt(9), l.width())
+	l.fits(l.pt(16) + float64(len(lines))*l.lineHeight(9))
+	l.rule(l.y)
+	l.y += l.pt(16)
+	for _, line := range lines {
+		l.text(l.left(), l.y, l.regular, 9, colorMuted, line)
+		l.y += l.lineHeight(9)
+	}
+}
+
+// pageNumbers numbers the pages at the bottom right when there is more than one
+func (l *layout) pageNumbers() {
+	pages := l.doc.Pages()
+	if len(pages) < 2 {
+		return
+	}
+	for i, page := range pages {
+		l.page = page
+		y := l.bottom() + l.pt(footerHeight) - l.lineHeight(8)
+		l.textRight(l.right(), y, l.regular, 8, colorMuted, fmt.Sprintf("Page %d of %d", i+1, len(pages)))
+	}
+}
+
+// partyDetails returns the address lines and contact details of a party
+func partyDetails(p invoice.Party) []string {
+	var lines []string
+	if p.Address != "" {
+		lines = append(lines, strings.Split(strings.ReplaceAll(p.Address, "\r\n", "\n"), "\n")...)
+	}
+	if p.ABN != "" {
+		lines = append(lines, "ABN: "+formatABN(p.ABN))
+	}
+	if p.Phone != "" {
+		lines = append(lines, "Phone: "+p.Phone)
+	}
+	if p.Email != "" {
+		lines = append(lines, "Email: "+p.Email)
+	}
+	if p.URL != "" {
+		lines = append(lines, "Website: "+p.URL)
+	}
+	return lines
+}
+
+// formatDate formats like the frontend formatDateShort, e.g. Nov 3, 2025
+func formatDate(d types.Date) string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format("Jan 2, 2006")
+}
+
+func formatMoney(amount float32) string {
+	return emailtemplate.FormatMoney(float64(amount), "$")
+}
+
+func formatNumber(v float32) string {
+	return strconv.FormatFloat(float64(v), 'f', -1, 32)
+}
+
+// formatABN groups an 11 digit ABN like the frontend, e.g. 51 824 753 556
+func formatABN(abn string) string {
+	digits := onlyDigits(abn)
+	if len(digits) != 11 {
+		return abn
+	}
+	return fmt.Sprintf("%s %s %s %s", digits[:2], digits[2:5], digits[5:8], digits[8:])
+}
+
+// formatBSB formats a 6 digit BSB like the frontend, e.g. 123-456
+func formatBSB(bsb string) string {
+	digits := onlyDigits(bsb)
+	if len(digits) != 6 {
+		return bsb
+	}
+	return digits[:3] + "-" + digits[3:]
+}
+
+func onlyDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,135 @@
+// Package render turns invoices into PDF documents, either with Chrome
+// printing the frontend print route or natively in Go.
+package render
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/services"
+)
+
+// Renderer names, as used by PDF_RENDERER and the renderer query parameter
+const (
+	Chrome = "chrome" // the frontend print route, the way the invoice looks in the app
+	Native = "native" // pure Go layout, no browser needed
+	Auto   = "auto"   // Chrome when available, native otherwise
+)
+
+// DefaultTimeout limits a render when Options.Timeout is not set
+const DefaultTimeout = 30 * time.Second
+
+// ErrUnknownRenderer is returned for a renderer name that is not configured
+var ErrUnknownRenderer = errors.New("unknown pdf renderer")
+
+// Options control a render
+type Options struct {
+	Page    services.PageLayout // a zero layout renders on services.DefaultPageSettings
+	Timeout time.Duration
+}
+
+// Renderer renders an invoice to a PDF document
+type Renderer interface {
+	Render(ctx context.Context, inv *invoice.Invoice, opts Options) ([]byte, error)
+}
+
+// Renderers are the renderers of a deployment with the one used by default
+type Renderers struct {
+	Default   string
+	renderers map[string]Renderer
+}
+
+// NewRenderers creates a set of renderers, defaultName must be one of them
+func NewRenderers(defaultName string, renderers map[string]Renderer) (*Renderers, error) {
+	if _, ok := renderers[defaultName]; !ok {
+		return nil, fmt.Errorf("%w '%s'", ErrUnknownRenderer, defaultName)
+	}
+	return &Renderers{Default: defaultName, renderers: renderers}, nil
+}
+
+// Load creates the Chrome and native renderers and picks the default from
+// PDF_RENDERER: chrome, native or auto (the default). Auto uses Chrome when
+// CHROME_REMOTE_URL is set or a Chrome binary is found, native otherwise.
+func Load(pool *services.ChromePool, baseURL string) (*Renderers, error) {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("PDF_RENDERER")))
+	defaultName, err := Select(mode, services.ChromeAvailable())
+	if err != nil {
+		return nil, err
+	}
+	native, err := NewNativeRenderer()
+	if err != nil {
+		return nil, err
+	}
+	return NewRenderers(defaultName, map[string]Renderer{
+		Chrome: &ChromeRenderer{Pool: pool, BaseURL: baseURL},
+		Native: native,
+	})
+}
+
+// Select resolves a PDF_RENDERER mode to a renderer name
+func Select(mode string, chromeAvailable bool) (string, error) {
+	switch mode {
+	case Chrome, Native:
+		return mode, nil
+	case Auto, "":
+		if chromeAvailable {
+			return Chrome, nil
+		}
+		return Native, nil
+	default:
+		return "", fmt.Errorf("invalid PDF_RENDERER '%s', expected chrome, native or auto", mode)
+	}
+}
+
+// Get returns the renderer with the given name, or the default for an empty name
+func (r *Renderers) Get(name string) (Renderer, error) {
+	if name == "" {
+		name = r.Default
+	}
+	renderer, ok := r.renderers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w '%s', expected one of %s", ErrUnknownRenderer, name, strings.Join(r.Names(), ", "))
+	}
+	return renderer, nil
+}
+
+// Names returns the names of the renderers, sorted
+func (r *Renderers) Names() []string {
+	names := make([]string, 0, len(r.renderers))
+	for name := range r.renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pageLayout returns the layout of the default page settings for a zero layout
+func pageLayout(layout services.PageLayout) services.PageLayout {
+	if layout.PaperSize.Width > 0 && layout.PaperSize.Height > 0 {
+		return layout
+	}
+	layout, _ = services.DefaultPageSettings.Layout()
+	return layout
+}
+
+// ChromeRenderer prints the frontend print route of the invoice with a pooled
+// browser. The route loads the invoice itself, so only its ID is used.
+type ChromeRenderer struct {
+	Pool    *services.ChromePool
+	BaseURL string // where Chrome reaches the app, see api.Handler.LocalBaseURL
+}
+
+func (r *ChromeRenderer) Render(ctx context.Context, inv *invoice.Invoice, opts Options) ([]byte, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	url := fmt.Sprintf("%s/invoices/%s/print", r.BaseURL, inv.ID)
+	return r.Pool.GeneratePDF(ctx, url, timeout, pageLayout(opts.Page), inv.ID)
+}
@@ -0,0 +1,229 @@
+package render
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-invoice/internal/invoice"
+	"go-invoice/internal/services"
+	"go-invoice/internal/types"
+)
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		mode    string
+		chrome  bool
+		want    string
+		wantErr bool
+	}{
+		{"", true, Chrome, false},
+		{"", false, Native, false},
+		{"auto", false, Native, false},
+		{"chrome", false, Chrome, false},
+		{"native", true, Native, false},
+		{"wkhtmltopdf", true, "", true},
+	}
+	for _, tt := range tests {
+		got, err := Select(tt.mode, tt.chrome)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Select(%q, %v) = %q, %v, want %q", tt.mode, tt.chrome, got, err, tt.want)
+		}
+	}
+}
+
+// stubRenderer records the invoice it rendered
+type stubRenderer struct{ rendered string }
+
+func (s *stubRenderer) Render(ctx context.Context, inv *invoice.Invoice, opts Options) ([]byte, error) {
+	s.rendered = inv.ID
+	return []byte("%PDF"), nil
+}
+
+func TestRenderers_Get(t *testing.T) {
+	chrome, native := &stubRenderer{}, &stubRenderer{}
+	if _, err := NewRenderers("typst", map[string]Renderer{Native: native}); !errors.Is(err, ErrUnknownRenderer) {
+		t.Errorf("NewRenderers() with a missing default error = %v, want ErrUnknownRenderer", err)
+	}
+	renderers, err := NewRenderers(Native, map[string]Renderer{Chrome: chrome, Native: native})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r, err := renderers.Get(""); err != nil || r != native {
+		t.Errorf("Get(\"\") = %v, %v, want the default", r, err)
+	}
+	if r, err := renderers.Get(Chrome); err != nil || r != chrome {
+		t.Errorf("Get(chrome) = %v, %v", r, err)
+	}
+	if _, err := renderers.Get("typst"); !errors.Is(err, ErrUnknownRenderer) {
+		t.Errorf("Get(typst) error = %v, want ErrUnknownRenderer", err)
+	}
+	if names := strings.Join(renderers.Names(), ","); names != "chrome,native" {
+		t.Errorf("Names() = %s", names)
+	}
+}
+
+func testInvoice(items int) *invoice.Invoice {
+	date := types.NewDate(time.Date(2025, 11, 3, 0, 0, 0, 0, time.UTC))
+	inv := &invoice.Invoice{
+		ID:     "INV-25110301",
+		Status: invoice.StatusDraft,
+		Date:   date,
+		Due:    date.AddDate(0, 0, 30),
+		Provider: invoice.Party{
+			Id: "jane", Name: "Jane Smith Consulting", Address: "1 George St\nSydney NSW 2000",
+			ABN: "51824753556", Email: "jane@example.com",
+		},
+		Client:  invoice.Party{Id: "acme", Name: "Acme Corp", Address: "2 Pitt St\nSydney NSW 2000"},
+		Payment: invoice.PaymentInfo{Method: "Bank Transfer", AccountName: "Jane Smith", BSB: "123456", AccountNumber: "12345678"},
+		Pricing: invoice.Pricing{TaxRate: 10},
+	}
+	for i := 0; i < items; i++ {
+		inv.AddItem(invoice.NewServiceItemWithDetail(date, fmt.Sprintf("Consulting (week %d)", i+1),
+			"Architecture review, pairing sessions and a written report on the migration plan", 7.5, 150))
+	}
+	return inv
+}
+
+func TestNativeRenderer_Render(t *testing.T) {
+	r, err := NewNativeRenderer()
+	if err != nil {
+		t.Fatalf("NewNativeRenderer() error = %v", err)
+	}
+	r.now = func() time.Time { return time.Date(2025, 11, 3, 9, 0, 0, 0, time.UTC) }
+
+	data, err := r.Render(context.Background(), testInvoice(2), Options{Page: services.PageLayout{PaperSize: services.PaperSizeA4}})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		t.Fatal("output is not a PDF")
+	}
+	if n := pageCount(data); n != 1 {
+		t.Errorf("rendered %d pages, want 1", n)
+	}
+	text := strings.Join(pageContents(t, data), "\n")
+	for _, want := range []string{
+		"INVOICE", "INV-25110301", "Nov 3, 2025", "Dec 3, 2025",
+		"Jane Smith Consulting", "Sydney NSW 2000", "ABN: 51 824 753 556",
+		"Bill To:", "Acme Corp",
+		"Consulting \\(week 2\\)", "$1,125.00",
+		"Subtotal:", "$2,250.00", "GST \\(10%\\):", "$225.00", "$2,475.00",
+		"123-456", "12345678",
+	} {
+		if !strings.Contains(text, "("+want+")") {
+			t.Errorf("PDF does not show %q", want)
+		}
+	}
+	if !bytes.Contains(data, []byte("/Title (INV-25110301)")) || !bytes.Contains(data, []byte("/CreationDate (D:20251103090000Z)")) {
+		t.Error("PDF metadata missing the title or creation date")
+	}
+
+	again, _ := r.Render(context.Background(), testInvoice(2), Options{Page: services.PageLayout{PaperSize: services.PaperSizeA4}})
+	if !bytes.Equal(data, again) {
+		t.Error("rendering the same invoice twice gave different PDFs")
+	}
+}
+
+func TestNativeRenderer_RenderPages(t *testing.T) {
+	r, err := NewNativeRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := r.Render(context.Background(), testInvoice(40), Options{Page: services.PageLayout{PaperSize: services.PaperSizeLetter}})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	n := pageCount(data)
+	if n < 2 {
+		t.Fatalf("rendered %d pages for 40 items, want the table to continue on new pages", n)
+	}
+	pages := pageContents(t, data)
+	for i, page := range pages {
+		if strings.Contains(page, "(Consulting") && !strings.Contains(page, "(Unit Price)") {
+			t.Errorf("page %d continues the item table without its header", i+1)
+		}
+	}
+	if !strings.Contains(pages[n-1], fmt.Sprintf("(Page %d of %d)", n, n)) {
+		t.Error("pages are not numbered")
+	}
+	if !bytes.Contains(data, []byte("/MediaBox [0 0 612 792]")) {
+		t.Error("page is not US Letter")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.Render(ctx, testInvoice(1), Options{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Render() with a cancelled context error = %v", err)
+	}
+}
+
+func TestNativeRenderer_RenderLayout(t *testing.T) {
+	r, err := NewNativeRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layout, err := services.PageSettings{
+		PaperSize:   "a4",
+		Orientation: services.OrientationLandscape,
+		Margins:     &services.Margins{Top: 20, Right: 20, Bottom: 20, Left: 30},
+	}.Layout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := r.Render(context.Background(), testInvoice(2), Options{Page: layout})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	m := regexp.MustCompile(`/MediaBox \[0 0 ([\d.]+) ([\d.]+)\]`).FindSubmatch(data)
+	if m == nil {
+		t.Fatal("missing MediaBox")
+	}
+	width, _ := strconv.ParseFloat(string(m[1]), 64)
+	height, _ := strconv.ParseFloat(string(m[2]), 64)
+	if width < 841 || width > 843 || height < 594 || height > 596 {
+		t.Errorf("MediaBox %vx%v, want A4 landscape", width, height)
+	}
+
+	// no text starts left of the 30mm margin or above the 20mm margin
+	left, top := 30*72/25.4, height-20*72/25.4
+	for _, pos := range regexp.MustCompile(`Tf ([\d.]+) ([\d.]+) Td`).FindAllStringSubmatch(pageContents(t, data)[0], -1) {
+		x, _ := strconv.ParseFloat(pos[1], 64)
+		y, _ := strconv.ParseFloat(pos[2], 64)
+		if x < left || y > top {
+			t.Errorf("text at %v,%v is inside the margins", x, y)
+		}
+	}
+}
+
+func pageCount(data []byte) int {
+	return len(regexp.MustCompile(`/Type /Page\b[^s]`).FindAll(data, -1))
+}
+
+// pageContents inflates the content streams of the pages, the only streams without extra entries
+func pageContents(t *testing.T, data []byte) []string {
+	t.Helper()
+	var pages []string
+	for _, m := range regexp.MustCompile(`<< /Length \d+ /Filter /FlateDecode >>\nstream\n`).FindAllIndex(data, -1) {
+		r, err := zlib.NewReader(bytes.NewReader(data[m[1]:]))
+		if err != nil {
+			t.Fatalf("invalid content stream: %v", err)
+		}
+		content, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("invalid content stream: %v", err)
+		}
+		pages = append(pages, string(content))
+	}
+	return pages
+}
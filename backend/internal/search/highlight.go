@@ -0,0 +1,153 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	snippetContext = 40 // runes of context kept on each side of the first match
+	maxHighlights  = 5  // maximum number of highlights returned per document
+)
+
+// span is a half-open range of rune offsets
+type span struct {
+	start, end int
+}
+
+// highlight builds snippets for every field value matching at least one term
+func (doc *document) highlight(terms []string) []Highlight {
+	var highlights []Highlight
+	for _, v := range doc.values {
+		runes := []rune(v.text)
+		var spans []span
+		if identifierFields[v.field] {
+			spans = identifierSpans(runes, terms)
+		} else {
+			spans = tokenSpans(runes, terms)
+		}
+		if len(spans) == 0 {
+			continue
+		}
+		highlights = append(highlights, Highlight{
+			Field:   v.field,
+			Snippet: snippet(runes, spans),
+		})
+		if len(highlights) == maxHighlights {
+			break
+		}
+	}
+	return highlights
+}
+
+// tokenSpans finds words in text that equal or start with any of the terms
+func tokenSpans(runes []rune, terms []string) []span {
+	var spans []span
+	start := -1
+	for i := 0; i <= len(runes); i++ {
+		inWord := i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]))
+		if inWord && start < 0 {
+			start = i
+		}
+		if !inWord && start >= 0 {
+			word := strings.ToLower(string(runes[start:i]))
+			for _, term := range terms {
+				if strings.HasPrefix(word, term) {
+					spans = append(spans, span{start, i})
+					break
+				}
+			}
+			start = -1
+		}
+	}
+	return spans
+}
+
+// identifierSpans finds fragments of an identifier matching any of the terms,
+// ignoring separators such as spaces and dashes
+func identifierSpans(runes []rune, terms []string) []span {
+	// map each rune of the compact form back to its position in the original text
+	var compacted []rune
+	var positions []int
+	for i, r := range runes {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			compacted = append(compacted, unicode.ToLower(r))
+			positions = append(positions, i)
+		}
+	}
+	text := string(compacted)
+
+	var spans []span
+	for _, term := range terms {
+		offset := 0
+		for {
+			idx := strings.Index(text[offset:], term)
+			if idx < 0 {
+				break
+			}
+			// convert byte offsets of the compact string to rune offsets
+			startRune := len([]rune(text[:offset+idx]))
+			endRune := startRune + len([]rune(term))
+			spans = append(spans, span{positions[startRune], positions[endRune-1] + 1})
+			offset += idx + len(term)
+		}
+	}
+	return mergeSpans(spans)
+}
+
+// mergeSpans sorts spans and joins overlapping ones
+func mergeSpans(spans []span) []span {
+	if len(spans) < 2 {
+		return spans
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].start < spans[j].start
+	})
+	merged := []span{spans[0]}
+	for _, s := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if s.start <= last.end {
+			if s.end > last.end {
+				last.end = s.end
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// snippet cuts a window around the first match and wraps every match in <mark> tags
+func snippet(runes []rune, spans []span) string {
+	from := spans[0].start - snippetContext
+	if from < 0 {
+		from = 0
+	}
+	to := spans[0].end + snippetContext
+	if to > len(runes) {
+		to = len(runes)
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, s := range spans {
+		if s.start < from || s.end > to {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:s.start])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(runes[s.start:s.end])))
+		b.WriteString("</mark>")
+		pos = s.end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:to])))
+	if to < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
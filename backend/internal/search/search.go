@@ -0,0 +1,257 @@
+package search
+
+import (
+	"go-invoice/internal/invoice"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Field identifies a searchable part of an invoice
+type Field string
+
+const (
+	FieldID                Field = "id"
+	FieldClientName        Field = "client_name"
+	FieldClientEmail       Field = "client_email"
+	FieldClientABN         Field = "client_abn"
+	FieldProviderName      Field = "provider_name"
+	FieldProviderABN       Field = "provider_abn"
+	FieldDescription       Field = "description"
+	FieldDescriptionDetail Field = "description_detail"
+)
+
+// fieldWeights ranks matches by where they were found. Identifier matches weigh
+// the most since they are the most specific.
+var fieldWeights = map[Field]float64{
+	FieldID:                5,
+	FieldClientABN:         4,
+	FieldProviderABN:       4,
+	FieldClientName:        3,
+	FieldClientEmail:       3,
+	FieldProviderName:      2,
+	FieldDescription:       2,
+	FieldDescriptionDetail: 1,
+}
+
+// identifierFields are matched by substring on their compact form
+// (lowercase, without spaces or separators) rather than by token.
+var identifierFields = map[Field]bool{
+	FieldID:          true,
+	FieldClientABN:   true,
+	FieldProviderABN: true,
+}
+
+// prefixPenalty scales the score of a token that only matched by prefix
+const prefixPenalty = 0.5
+
+// Result is a single ranked search hit
+type Result struct {
+	ID         string      `json:"id"`
+	Score      float64     `json:"score"`
+	Highlights []Highlight `json:"highlights"`
+}
+
+// Highlight is a snippet of a matched field with matches wrapped in <mark> tags.
+// The snippet is HTML escaped.
+type Highlight struct {
+	Field   Field  `json:"field"`
+	Snippet string `json:"snippet"`
+}
+
+// fieldValue is one indexed value of a document, e.g. the description of a single item
+type fieldValue struct {
+	field Field
+	text  string
+}
+
+type document struct {
+	values []fieldValue
+}
+
+// Index is an in-memory inverted index over invoices.
+// It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]*document
+	postings map[string]map[string]map[Field]int // term -> document ID -> field -> term frequency
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[string]*document),
+		postings: make(map[string]map[string]map[Field]int),
+	}
+}
+
+// Put adds or replaces the invoice stored under id
+func (idx *Index) Put(id string, inv *invoice.Invoice) {
+	doc := newDocument(id, inv)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+	idx.docs[id] = doc
+	for _, v := range doc.values {
+		if identifierFields[v.field] {
+			continue
+		}
+		for _, term := range tokenize(v.text) {
+			docs, ok := idx.postings[term]
+			if !ok {
+				docs = make(map[string]map[Field]int)
+				idx.postings[term] = docs
+			}
+			fields, ok := docs[id]
+			if !ok {
+				fields = make(map[Field]int)
+				docs[id] = fields
+			}
+			fields[v.field]++
+		}
+	}
+}
+
+// Remove deletes the invoice stored under id, if any
+func (idx *Index) Remove(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+func (idx *Index) remove(id string) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for _, v := range doc.values {
+		for _, term := range tokenize(v.text) {
+			if docs, ok := idx.postings[term]; ok {
+				delete(docs, id)
+				if len(docs) == 0 {
+					delete(idx.postings, term)
+				}
+			}
+		}
+	}
+	delete(idx.docs, id)
+}
+
+// Len returns the number of indexed invoices
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+// Search returns all invoices matching every term of the query, ordered by score.
+// Terms match whole words, word prefixes, or fragments of identifiers such as
+// the invoice ID or an ABN.
+func (idx *Index) Search(q string) []Result {
+	terms := tokenize(q)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var scores map[string]float64
+	for _, term := range terms {
+		termScores := idx.scoreTerm(term)
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		// all terms must match
+		for id, score := range scores {
+			if s, ok := termScores[id]; ok {
+				scores[id] = score + s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, Result{
+			ID:         id,
+			Score:      score,
+			Highlights: idx.docs[id].highlight(terms),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID > results[j].ID // newer invoice IDs first
+	})
+	return results
+}
+
+// scoreTerm scores every document matching a single query term
+func (idx *Index) scoreTerm(term string) map[string]float64 {
+	scores := make(map[string]float64)
+	for indexed, docs := range idx.postings {
+		var factor float64
+		switch {
+		case indexed == term:
+			factor = 1
+		case strings.HasPrefix(indexed, term):
+			factor = prefixPenalty
+		default:
+			continue
+		}
+		for id, fields := range docs {
+			for field, tf := range fields {
+				scores[id] += factor * fieldWeights[field] * float64(tf)
+			}
+		}
+	}
+
+	for id, doc := range idx.docs {
+		for _, v := range doc.values {
+			if identifierFields[v.field] && strings.Contains(compact(v.text), term) {
+				scores[id] += fieldWeights[v.field]
+			}
+		}
+	}
+	return scores
+}
+
+func newDocument(id string, inv *invoice.Invoice) *document {
+	doc := &document{}
+	add := func(field Field, text string) {
+		if text != "" {
+			doc.values = append(doc.values, fieldValue{field: field, text: text})
+		}
+	}
+	add(FieldID, id)
+	add(FieldClientName, inv.Client.Name)
+	add(FieldClientEmail, inv.Client.Email)
+	add(FieldClientABN, inv.Client.ABN)
+	add(FieldProviderName, inv.Provider.Name)
+	add(FieldProviderABN, inv.Provider.ABN)
+	for _, item := range inv.Items {
+		add(FieldDescription, item.Description)
+		add(FieldDescriptionDetail, item.DescriptionDetail)
+	}
+	return doc
+}
+
+// tokenize splits text into lowercase words of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// compact lowercases text and strips everything but letters and digits,
+// so "INV-25110201" becomes "inv25110201" and "12 345 678 901" becomes "12345678901"
+func compact(text string) string {
+	return strings.Join(tokenize(text), "")
+}
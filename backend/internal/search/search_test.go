@@ -0,0 +1,128 @@
+package search
+
+import (
+	"go-invoice/internal/invoice"
+	"testing"
+)
+
+func newTestIndex() *Index {
+	idx := NewIndex()
+	idx.Put("INV-25110201", &invoice.Invoice{
+		Client:   invoice.Party{Name: "Acme Corp", Email: "billing@acme.test", ABN: "12 345 678 901"},
+		Provider: invoice.Party{Name: "Jane Smith"},
+		Items: []invoice.ServiceItem{
+			{Description: "Website redesign", DescriptionDetail: "Landing page and checkout flow"},
+		},
+	})
+	idx.Put("INV-25110301", &invoice.Invoice{
+		Client:   invoice.Party{Name: "Globex", Email: "accounts@globex.test"},
+		Provider: invoice.Party{Name: "Jane Smith"},
+		Items: []invoice.ServiceItem{
+			{Description: "Consulting", DescriptionDetail: "Website audit"},
+		},
+	})
+	return idx
+}
+
+func resultIDs(results []Result) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{
+			name:     "description ranks above detail",
+			query:    "website",
+			expected: []string{"INV-25110201", "INV-25110301"},
+		},
+		{
+			name:     "word prefix",
+			query:    "consult",
+			expected: []string{"INV-25110301"},
+		},
+		{
+			name:     "client email",
+			query:    "accounts@globex.test",
+			expected: []string{"INV-25110301"},
+		},
+		{
+			name:     "ABN fragment ignores spaces",
+			query:    "345678",
+			expected: []string{"INV-25110201"},
+		},
+		{
+			name:     "ID fragment",
+			query:    "1103",
+			expected: []string{"INV-25110301"},
+		},
+		{
+			name:     "all terms must match",
+			query:    "website globex",
+			expected: []string{"INV-25110301"},
+		},
+		{
+			name:     "no match",
+			query:    "nothing",
+			expected: []string{},
+		},
+	}
+
+	idx := newTestIndex()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := resultIDs(idx.Search(tt.query))
+			if len(result) != len(tt.expected) {
+				t.Fatalf("Search(%q) = %v, want %v", tt.query, result, tt.expected)
+			}
+			for i := range result {
+				if result[i] != tt.expected[i] {
+					t.Errorf("Search(%q) = %v, want %v", tt.query, result, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestSearch_Highlights(t *testing.T) {
+	idx := newTestIndex()
+
+	results := idx.Search("checkout")
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	highlights := results[0].Highlights
+	if len(highlights) != 1 {
+		t.Fatalf("got %d highlights, want 1", len(highlights))
+	}
+	if highlights[0].Field != FieldDescriptionDetail {
+		t.Errorf("Field = %s, want %s", highlights[0].Field, FieldDescriptionDetail)
+	}
+	if want := "Landing page and <mark>checkout</mark> flow"; highlights[0].Snippet != want {
+		t.Errorf("Snippet = %q, want %q", highlights[0].Snippet, want)
+	}
+
+	results = idx.Search("345678")
+	if want := "12 <mark>345 678</mark> 901"; results[0].Highlights[0].Snippet != want {
+		t.Errorf("Snippet = %q, want %q", results[0].Highlights[0].Snippet, want)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	idx := newTestIndex()
+	idx.Remove("INV-25110201")
+
+	if idx.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", idx.Len())
+	}
+	if results := idx.Search("acme"); len(results) != 0 {
+		t.Errorf("Search(acme) = %v, want no results", resultIDs(results))
+	}
+}
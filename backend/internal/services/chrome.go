@@ -204,7 +204,7 @@ func extractHost(urlStr string) string {
 }
 
 // GeneratePDF navigates to the specified URL and generates a PDF of the page
-func (s *ChromeService) GeneratePDF(url string, timeout time.Duration, paperSize PaperSize, title string) ([]byte, error) {
+func (s *ChromeService) GeneratePDF(url string, timeout time.Duration, layout PageLayout, title string) ([]byte, error) {
 	tabCtx, cancel := chromedp.NewContext(s.browserCtx)
 	defer cancel()
 
@@ -250,10 +250,16 @@ func (s *ChromeService) GeneratePDF(url string, timeout time.Duration, paperSize
 			// no error node, so #pdf-render-complete must be present.
 			// pdf generation
 			var printErr error
+			margins := layout.MarginsInches()
 			pdfBuffer, _, printErr = page.PrintToPDF().
 				WithPrintBackground(true).
-				WithPaperHeight(paperSize.Height).
-				WithPaperWidth(paperSize.Width).
+				WithPaperHeight(layout.PaperSize.Height).
+				WithPaperWidth(layout.PaperSize.Width).
+				WithLandscape(layout.Landscape).
+				WithMarginTop(margins.Top).
+				WithMarginRight(margins.Right).
+				WithMarginBottom(margins.Bottom).
+				WithMarginLeft(margins.Left).
 				Do(ctx)
 			return printErr
 		}),
@@ -281,3 +287,9 @@ func getChromePath() string {
 	}
 	return chromePath
 }
+
+// ChromeAvailable reports whether PDFs can be printed with Chrome: a remote
+// Chrome is configured or a local Chrome binary is found
+func ChromeAvailable() bool {
+	return os.Getenv("CHROME_REMOTE_URL") != "" || getChromePath() != ""
+}
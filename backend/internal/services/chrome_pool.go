@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	DefaultChromePoolSize       = 2
+	DefaultChromeMaxRenders     = 50
+	DefaultChromeHealthInterval = time.Minute
+	chromeHealthTimeout         = 5 * time.Second
+)
+
+// ErrPoolClosed is returned when rendering after the pool was shut down
+var ErrPoolClosed = errors.New("chrome pool is closed")
+
+// ChromePoolConfig limits the browsers of a ChromePool
+type ChromePoolConfig struct {
+	Size           int           // browsers kept, and so the number of concurrent renders
+	MaxRenders     int           // a browser is replaced after this many renders
+	HealthInterval time.Duration // how often idle browsers are checked
+}
+
+// LoadChromePoolConfig reads the pool settings from the environment:
+//
+//	CHROME_POOL_SIZE         concurrent browsers, default 2
+//	CHROME_MAX_RENDERS       renders before a browser is recycled, default 50
+//	CHROME_HEALTH_INTERVAL   e.g. 1m
+func LoadChromePoolConfig() (ChromePoolConfig, error) {
+	cfg := ChromePoolConfig{}
+	var err error
+	if cfg.Size, err = intEnv("CHROME_POOL_SIZE", DefaultChromePoolSize); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxRenders, err = intEnv("CHROME_MAX_RENDERS", DefaultChromeMaxRenders); err != nil {
+		return cfg, err
+	}
+	if cfg.HealthInterval, err = durationEnv("CHROME_HEALTH_INTERVAL", DefaultChromeHealthInterval); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// pdfBrowser is a browser of the pool, implemented by ChromeService
+type pdfBrowser interface {
+	GeneratePDF(url string, timeout time.Duration, layout PageLayout, title string) ([]byte, error)
+	Healthy() error
+	Close()
+}
+
+// pooledBrowser is a browser with its render count
+type pooledBrowser struct {
+	browser pdfBrowser
+	renders int
+}
+
+// ChromePool shares long-lived Chrome browsers between requests. Browsers are
+// started on first use, checked while idle, restarted when they crash and
+// recycled after MaxRenders renders. At most Size renders run at once.
+type ChromePool struct {
+	cfg        ChromePoolConfig
+	newBrowser func() (pdfBrowser, error)
+	tokens     chan struct{} // one per render slot
+	mu         sync.Mutex
+	idle       []*pooledBrowser // most recently used last
+	done       chan struct{}
+	closeOnce  sync.Once
+	wg         sync.WaitGroup
+}
+
+// NewChromePool creates a pool of browsers started by NewChromeService and
+// starts its health checks. No browser is launched until the first render.
+func NewChromePool(cfg ChromePoolConfig) *ChromePool {
+	return newChromePool(cfg, func() (pdfBrowser, error) { return NewChromeService() })
+}
+
+func newChromePool(cfg ChromePoolConfig, newBrowser func() (pdfBrowser, error)) *ChromePool {
+	if cfg.Size <= 0 {
+		cfg.Size = DefaultChromePoolSize
+	}
+	if cfg.MaxRenders <= 0 {
+		cfg.MaxRenders = DefaultChromeMaxRenders
+	}
+	if cfg.HealthInterval <= 0 {
+		cfg.HealthInterval = DefaultChromeHealthInterval
+	}
+	p := &ChromePool{
+		cfg:        cfg,
+		newBrowser: newBrowser,
+		tokens:     make(chan struct{}, cfg.Size),
+		done:       make(chan struct{}),
+	}
+	for i := 0; i < cfg.Size; i++ {
+		p.tokens <- struct{}{}
+	}
+	p.wg.Add(1)
+	go p.runHealthChecks()
+	return p
+}
+
+// GeneratePDF renders url like ChromeService.GeneratePDF on a pooled browser,
+// waiting for a free slot until ctx is done. When the browser turns out to
+// have crashed, the render is retried once on a new browser.
+func (p *ChromePool) GeneratePDF(ctx context.Context, url string, timeout time.Duration, layout PageLayout, title string) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		pb, err := p.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		pdf, err := pb.browser.GeneratePDF(url, timeout, layout, title)
+		pb.renders++
+		if err == nil {
+			p.release(pb)
+			return pdf, nil
+		}
+		if healthErr := pb.browser.Healthy(); healthErr != nil {
+			slog.Warn("chrome browser crashed, restarting", "error", healthErr)
+			p.discard(pb)
+			if attempt == 1 {
+				continue
+			}
+			return nil, err
+		}
+		p.release(pb)
+		return nil, err
+	}
+}
+
+// acquire takes a render slot and an idle browser, starting one when none is idle
+func (p *ChromePool) acquire(ctx context.Context) (*pooledBrowser, error) {
+	// checked first, as select picks randomly when a slot is free as well
+	select {
+	case <-p.done:
+		return nil, ErrPoolClosed
+	default:
+	}
+	select {
+	case <-p.done:
+		return nil, ErrPoolClosed
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a chrome browser: %w", ctx.Err())
+	case <-p.tokens:
+	}
+
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		pb := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return pb, nil
+	}
+	p.mu.Unlock()
+
+	browser, err := p.newBrowser()
+	if err != nil {
+		p.tokens <- struct{}{}
+		return nil, fmt.Errorf("failed to start chrome: %w", err)
+	}
+	return &pooledBrowser{browser: browser}, nil
+}
+
+// release returns a browser to the pool, recycling it when it has rendered enough
+func (p *ChromePool) release(pb *pooledBrowser) {
+	if pb.renders >= p.cfg.MaxRenders {
+		slog.Info("recycling chrome browser", "renders", pb.renders)
+		p.discard(pb)
+		return
+	}
+	p.mu.Lock()
+	p.idle = append(p.idle, pb)
+	p.mu.Unlock()
+	p.tokens <- struct{}{}
+}
+
+// discard closes a browser and frees its slot for a new one
+func (p *ChromePool) discard(pb *pooledBrowser) {
+	pb.browser.Close()
+	p.tokens <- struct{}{}
+}
+
+// runHealthChecks checks the idle browsers every HealthInterval until the pool is closed
+func (p *ChromePool) runHealthChecks() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.cfg.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.checkIdle()
+		}
+	}
+}
+
+// checkIdle closes idle browsers that fail their health check. Browsers are
+// taken out of the pool while checked, so renders start new ones meanwhile.
+func (p *ChromePool) checkIdle() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	var healthy []*pooledBrowser
+	for _, pb := range idle {
+		if err := pb.browser.Healthy(); err != nil {
+			slog.Warn("chrome browser failed health check, restarting on next render", "error", err)
+			pb.browser.Close()
+			continue
+		}
+		healthy = append(healthy, pb)
+	}
+
+	p.mu.Lock()
+	p.idle = append(healthy, p.idle...)
+	// renders may have started browsers while these were checked, keep at most Size
+	for len(p.idle) > p.cfg.Size {
+		p.idle[0].browser.Close()
+		p.idle = p.idle[1:]
+	}
+	p.mu.Unlock()
+}
+
+// Close stops new renders, waits for running renders until ctx is done and closes all browsers
+func (p *ChromePool) Close(ctx context.Context) error {
+	p.closeOnce.Do(func() { close(p.done) })
+	p.wg.Wait()
+	for i := 0; i < p.cfg.Size; i++ {
+		select {
+		case <-p.tokens:
+		case <-ctx.Done():
+			return fmt.Errorf("closing chrome pool: %d renders still running: %w", p.cfg.Size-i, ctx.Err())
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, pb := range p.idle {
+		pb.browser.Close()
+	}
+	p.idle = nil
+	return nil
+}
+
+// Healthy checks that the browser still responds
+func (s *ChromeService) Healthy() error {
+	if err := s.browserCtx.Err(); err != nil {
+		return fmt.Errorf("browser is gone: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(s.browserCtx, chromeHealthTimeout)
+	defer cancel()
+	var result int
+	if err := chromedp.Run(ctx, chromedp.Evaluate(`1`, &result)); err != nil {
+		return fmt.Errorf("browser does not respond: %w", err)
+	}
+	return nil
+}
+
+// intEnv parses a positive integer from the environment, returning fallback when unset
+func intEnv(key string, fallback int) (int, error) {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s '%s', expected a positive number", key, value)
+	}
+	return n, nil
+}
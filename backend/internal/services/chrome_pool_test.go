@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeBrowser is a pdfBrowser counting its renders
+type fakeBrowser struct {
+	id      int
+	crashed atomic.Bool
+	closed  atomic.Bool
+	delay   time.Duration
+	active  *atomic.Int32 // renders running across all browsers
+	peak    *atomic.Int32
+}
+
+func (b *fakeBrowser) GeneratePDF(url string, timeout time.Duration, layout PageLayout, title string) ([]byte, error) {
+	if b.crashed.Load() {
+		return nil, errors.New("websocket closed")
+	}
+	if b.active != nil {
+		n := b.active.Add(1)
+		defer b.active.Add(-1)
+		for {
+			peak := b.peak.Load()
+			if n <= peak || b.peak.CompareAndSwap(peak, n) {
+				break
+			}
+		}
+	}
+	time.Sleep(b.delay)
+	return []byte("%PDF-" + title), nil
+}
+
+func (b *fakeBrowser) Healthy() error {
+	if b.crashed.Load() {
+		return errors.New("browser is gone")
+	}
+	return nil
+}
+
+func (b *fakeBrowser) Close() { b.closed.Store(true) }
+
+// fakeBrowsers starts fakeBrowsers and remembers them in order
+type fakeBrowsers struct {
+	mu       sync.Mutex
+	browsers []*fakeBrowser
+	delay    time.Duration
+	active   atomic.Int32
+	peak     atomic.Int32
+}
+
+func (f *fakeBrowsers) start() (pdfBrowser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b := &fakeBrowser{id: len(f.browsers), delay: f.delay, active: &f.active, peak: &f.peak}
+	f.browsers = append(f.browsers, b)
+	return b, nil
+}
+
+func (f *fakeBrowsers) started() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.browsers)
+}
+
+func TestChromePool_ReusesBrowsers(t *testing.T) {
+	f := &fakeBrowsers{}
+	pool := newChromePool(ChromePoolConfig{Size: 2, MaxRenders: 100, HealthInterval: time.Hour}, f.start)
+	defer pool.Close(context.Background())
+
+	if f.started() != 0 {
+		t.Fatalf("started %d browsers before the first render, want lazy start", f.started())
+	}
+	for i := 0; i < 5; i++ {
+		pdf, err := pool.GeneratePDF(context.Background(), "http://x/print", time.Second, PageLayout{PaperSize: PaperSizeA4}, "INV-1")
+		if err != nil || string(pdf) != "%PDF-INV-1" {
+			t.Fatalf("GeneratePDF() = %q, %v", pdf, err)
+		}
+	}
+	if f.started() != 1 {
+		t.Errorf("started %d browsers for sequential renders, want 1", f.started())
+	}
+}
+
+func TestChromePool_LimitsConcurrency(t *testing.T) {
+	f := &fakeBrowsers{delay: 20 * time.Millisecond}
+	pool := newChromePool(ChromePoolConfig{Size: 2, MaxRenders: 100, HealthInterval: time.Hour}, f.start)
+	defer pool.Close(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := pool.GeneratePDF(context.Background(), "http://x/print", time.Second, PageLayout{PaperSize: PaperSizeA4}, "INV-1"); err != nil {
+				t.Errorf("GeneratePDF() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if peak := f.peak.Load(); peak > 2 {
+		t.Errorf("%d renders ran at once, want at most 2", peak)
+	}
+	if f.started() > 2 {
+		t.Errorf("started %d browsers, want at most 2", f.started())
+	}
+
+	// a full pool makes callers wait until their context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a, _ := pool.acquire(context.Background())
+	b, _ := pool.acquire(context.Background())
+	if _, err := pool.GeneratePDF(ctx, "http://x/print", time.Second, PageLayout{PaperSize: PaperSizeA4}, "INV-1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GeneratePDF() on a busy pool error = %v, want context.Canceled", err)
+	}
+	pool.release(a)
+	pool.release(b)
+}
+
+func TestChromePool_RecyclesAndRestarts(t *testing.T) {
+	f := &fakeBrowsers{}
+	pool := newChromePool(ChromePoolConfig{Size: 1, MaxRenders: 3, HealthInterval: time.Hour}, f.start)
+	defer pool.Close(context.Background())
+
+	render := func() error {
+		_, err := pool.GeneratePDF(context.Background(), "http://x/print", time.Second, PageLayout{PaperSize: PaperSizeA4}, "INV-1")
+		return err
+	}
+	for i := 0; i < 3; i++ {
+		if err := render(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f.started() != 1 || !f.browsers[0].closed.Load() {
+		t.Fatalf("browser not recycled after MaxRenders")
+	}
+
+	// a crashed browser is replaced and the render retried
+	if err := render(); err != nil {
+		t.Fatal(err)
+	}
+	f.browsers[1].crashed.Store(true)
+	if err := render(); err != nil {
+		t.Fatalf("render after a crash error = %v, want a retry on a new browser", err)
+	}
+	if f.started() != 3 || !f.browsers[1].closed.Load() {
+		t.Errorf("crashed browser not replaced, started %d", f.started())
+	}
+
+	// the health check closes idle crashed browsers
+	f.browsers[2].crashed.Store(true)
+	pool.checkIdle()
+	if !f.browsers[2].closed.Load() {
+		t.Error("health check kept a crashed browser")
+	}
+	if err := render(); err != nil || f.started() != 4 {
+		t.Errorf("render after health check = %v, started %d, want a new browser", err, f.started())
+	}
+}
+
+func TestChromePool_Close(t *testing.T) {
+	f := &fakeBrowsers{delay: 50 * time.Millisecond}
+	pool := newChromePool(ChromePoolConfig{Size: 2, MaxRenders: 100, HealthInterval: time.Hour}, f.start)
+
+	done := make(chan error)
+	go func() {
+		_, err := pool.GeneratePDF(context.Background(), "http://x/print", time.Second, PageLayout{PaperSize: PaperSizeA4}, "INV-1")
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	if err := pool.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("running render error = %v, want it to finish", err)
+	}
+	if !f.browsers[0].closed.Load() {
+		t.Error("Close() did not close the browser")
+	}
+	if _, err := pool.GeneratePDF(context.Background(), "http://x/print", time.Second, PageLayout{PaperSize: PaperSizeA4}, "INV-1"); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("GeneratePDF() after Close() error = %v, want ErrPoolClosed", err)
+	}
+}
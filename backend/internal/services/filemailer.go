@@ -0,0 +1,65 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileMailer writes each message as an .eml file instead of sending it,
+// for staging servers and tests without a mail server
+type FileMailer struct {
+	from string
+	dir  string
+}
+
+// NewFileMailer creates a new FileMailer writing to dir, which is created if needed
+func NewFileMailer(from, dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create mail drop directory: %w", err)
+	}
+	return &FileMailer{from: from, dir: dir}, nil
+}
+
+// SendMessage writes the message to <dir>/<time>-<message id>.eml.
+// The envelope is recorded in X-Envelope-From and X-Envelope-To headers
+// so BCC recipients can be inspected.
+func (m *FileMailer) SendMessage(msg *Message) (*Receipt, error) {
+	data, err := buildMessage(m.from, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "X-Envelope-From: <%s>\r\n", m.from)
+	fmt.Fprintf(&b, "X-Envelope-To: %s\r\n", strings.Join(msg.Recipients(), ", "))
+	b.Write(data)
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000Z"), safeFilename(msg.MessageID))
+	path := filepath.Join(m.dir, name)
+
+	// write to a temporary file first, so watchers never see a partial message
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to write message: %w", err)
+	}
+	return &Receipt{MessageID: msg.MessageID, Response: "written to " + path}, nil
+}
+
+// safeFilename replaces characters that are not safe in file names
+func safeFilename(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_', r == '@':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
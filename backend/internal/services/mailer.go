@@ -0,0 +1,105 @@
+package services
+
+import (
+	"fmt"
+	"go-invoice/internal/auth"
+	"os"
+	"strings"
+)
+
+// Mailer delivers a message through a mail transport
+type Mailer interface {
+	// SendMessage sends msg, assigning a Message-ID when it has none
+	SendMessage(msg *Message) (*Receipt, error)
+}
+
+// Transport selects the Mailer implementation
+type Transport string
+
+const (
+	TransportSMTP     Transport = "smtp"     // SMTP server, see SMTPConfig
+	TransportSendmail Transport = "sendmail" // pipe to a local sendmail-compatible binary
+	TransportFile     Transport = "file"     // write .eml files to a directory, nothing is sent
+)
+
+const DefaultSendmailPath = "/usr/sbin/sendmail"
+
+// ParseTransport parses a MAIL_TRANSPORT value, empty means TransportSMTP
+func ParseTransport(value string) (Transport, error) {
+	switch t := Transport(strings.ToLower(strings.TrimSpace(value))); t {
+	case "":
+		return TransportSMTP, nil
+	case TransportSMTP, TransportSendmail, TransportFile:
+		return t, nil
+	default:
+		return "", fmt.Errorf("invalid mail transport '%s', expected smtp, sendmail or file", value)
+	}
+}
+
+// MailConfig holds the settings of the selected transport
+type MailConfig struct {
+	Transport    Transport
+	SMTP         *SMTPConfig // TransportSMTP only
+	SendmailPath string      // TransportSendmail only
+	DropDir      string      // TransportFile only
+}
+
+// LoadMailConfig reads the transport settings from the environment:
+//
+//	MAIL_TRANSPORT   smtp (default), sendmail or file
+//	SENDMAIL_PATH    sendmail binary, default /usr/sbin/sendmail
+//	MAIL_DROP_DIR    directory receiving .eml files for the file transport
+//
+// The SMTP transport is configured by LoadSMTPConfig with the given defaults.
+func LoadMailConfig(defaultSMTPHost string, defaultSMTPPort int) (*MailConfig, error) {
+	transport, err := ParseTransport(os.Getenv("MAIL_TRANSPORT"))
+	if err != nil {
+		return nil, err
+	}
+	return LoadTransportConfig(transport, defaultSMTPHost, defaultSMTPPort)
+}
+
+// LoadTransportConfig reads the settings of the given transport from the
+// environment like LoadMailConfig, ignoring MAIL_TRANSPORT
+func LoadTransportConfig(transport Transport, defaultSMTPHost string, defaultSMTPPort int) (*MailConfig, error) {
+	var err error
+	cfg := &MailConfig{Transport: transport}
+	switch transport {
+	case TransportSMTP:
+		if cfg.SMTP, err = LoadSMTPConfig(defaultSMTPHost, defaultSMTPPort); err != nil {
+			return nil, err
+		}
+	case TransportSendmail:
+		cfg.SendmailPath = strings.TrimSpace(os.Getenv("SENDMAIL_PATH"))
+		if cfg.SendmailPath == "" {
+			cfg.SendmailPath = DefaultSendmailPath
+		}
+	case TransportFile:
+		cfg.DropDir = strings.TrimSpace(os.Getenv("MAIL_DROP_DIR"))
+		if cfg.DropDir == "" {
+			return nil, fmt.Errorf("MAIL_DROP_DIR is not configured, the file transport needs a directory")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported mail transport '%s'", transport)
+	}
+	return cfg, nil
+}
+
+// NewMailer creates the Mailer for the configured transport.
+// login, credential and authMethod are only used by the SMTP transport.
+func NewMailer(cfg *MailConfig, from, login, credential string, authMethod auth.AuthMethod) (Mailer, error) {
+	switch cfg.Transport {
+	case TransportSMTP:
+		s := NewSMTPService(from, login, cfg.SMTP, credential, authMethod)
+		if s == nil {
+			return nil, fmt.Errorf("unsupported SMTP auth method '%s'", authMethod)
+		}
+		return s, nil
+	case TransportSendmail:
+		return NewSendmailMailer(from, cfg.SendmailPath), nil
+	case TransportFile:
+		return NewFileMailer(from, cfg.DropDir)
+	default:
+		return nil, fmt.Errorf("unsupported mail transport '%s'", cfg.Transport)
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeSendmail writes a script recording its arguments and input to dir, exiting with status
+func fakeSendmail(t *testing.T, dir string, status int) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake sendmail is a shell script")
+	}
+	path := filepath.Join(dir, "sendmail")
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$@" > "%[1]s/args"
+cat > "%[1]s/input"
+[ %[2]d -eq 0 ] || echo 'mailbox unavailable' >&2
+exit %[2]d
+`, dir, status)
+	if err := os.WriteFile(path, []byte(script), 0700); err != nil {
+		t.Fatalf("failed to write fake sendmail: %v", err)
+	}
+	return path
+}
+
+func TestSendmailMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := NewSendmailMailer("me@example.com", fakeSendmail(t, dir, 0))
+
+	receipt, err := m.SendMessage(&Message{
+		To:      []string{"client@example.com"},
+		Bcc:     []string{"archive@example.com"},
+		Subject: "Invoice",
+		Body:    "hello\n.\nstill here",
+	})
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if got := strings.TrimSpace(string(args)); got != "-i -f me@example.com -- client@example.com archive@example.com" {
+		t.Errorf("args = %q", got)
+	}
+	input, _ := os.ReadFile(filepath.Join(dir, "input"))
+	if !strings.Contains(string(input), "Message-ID: <"+receipt.MessageID+">") {
+		t.Errorf("input has no Message-ID header:\n%s", input)
+	}
+	if strings.Contains(string(input), "archive@example.com") {
+		t.Errorf("BCC recipient leaked into the message:\n%s", input)
+	}
+}
+
+func TestSendmailMailer_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		wantTemporary bool
+	}{
+		{"permanent failure", 67, false},
+		{"temporary failure", exTempFail, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewSendmailMailer("me@example.com", fakeSendmail(t, t.TempDir(), tt.status))
+			_, err := m.SendMessage(&Message{To: []string{"client@example.com"}, Subject: "Invoice", Body: "hello"})
+			var sendmailErr *SendmailError
+			if !errors.As(err, &sendmailErr) {
+				t.Fatalf("SendMessage() error = %v, want a SendmailError", err)
+			}
+			if sendmailErr.ExitCode != tt.status || sendmailErr.Temporary() != tt.wantTemporary {
+				t.Errorf("error = %+v, want status %d temporary %v", sendmailErr, tt.status, tt.wantTemporary)
+			}
+			if sendmailErr.Output != "mailbox unavailable" {
+				t.Errorf("Output = %q", sendmailErr.Output)
+			}
+		})
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "drop")
+	m, err := NewFileMailer("me@example.com", dir)
+	if err != nil {
+		t.Fatalf("NewFileMailer() error = %v", err)
+	}
+
+	receipt, err := m.SendMessage(&Message{
+		To:      []string{"client@example.com"},
+		Bcc:     []string{"archive@example.com"},
+		Subject: "Invoice",
+		Body:    "hello",
+	})
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 || !strings.HasSuffix(files[0], ".eml") {
+		t.Fatalf("drop directory = %v, want one .eml file", files)
+	}
+	if receipt.Response != "written to "+files[0] {
+		t.Errorf("Response = %q", receipt.Response)
+	}
+	data, _ := os.ReadFile(files[0])
+	for _, want := range []string{
+		"X-Envelope-From: <me@example.com>\r\n",
+		"X-Envelope-To: client@example.com, archive@example.com\r\n",
+		"Message-ID: <" + receipt.MessageID + ">",
+		"Subject: Invoice",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("message does not contain %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestParseTransport(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Transport
+		wantErr bool
+	}{
+		{"", TransportSMTP, false},
+		{"SMTP", TransportSMTP, false},
+		{" sendmail ", TransportSendmail, false},
+		{"file", TransportFile, false},
+		{"pigeon", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTransport(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseTransport(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"encoding/hex"
+	"fmt"
+	"go-invoice/internal/crypto"
+	"strings"
+)
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Filename    string
+	ContentType AttachmentType
+	Data        []byte
+}
+
+// InlineImage is an image embedded in the HTML body and referenced
+// by its Content-ID, e.g. <img src="cid:provider-logo">
+type InlineImage struct {
+	ContentID   string
+	Filename    string
+	ContentType AttachmentType
+	Data        []byte
+}
+
+// Message is an email with a plain text body and an optional HTML alternative
+type Message struct {
+	FromName    string // (optional) display name of the sender
+	To          []string
+	Cc          []string // (optional) copied recipients, listed in the Cc header
+	Bcc         []string // (optional) blind copied recipients, only part of the envelope
+	ReplyTo     string   // (optional) address replies should go to
+	Subject     string
+	Body        string        // plain text body, always sent
+	HTMLBody    string        // (optional) HTML alternative of the body
+	Inline      []InlineImage // (optional) images referenced from HTMLBody, ignored without HTMLBody
+	Attachments []Attachment
+	MessageID   string // (optional) Message-ID without angle brackets
+}
+
+// Recipients returns the envelope recipients: To, Cc and Bcc without duplicates.
+// Bcc addresses are never written to the message headers.
+func (m *Message) Recipients() []string {
+	seen := make(map[string]bool)
+	var recipients []string
+	for _, list := range [][]string{m.To, m.Cc, m.Bcc} {
+		for _, addr := range list {
+			key := strings.ToLower(strings.TrimSpace(addr))
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			recipients = append(recipients, addr)
+		}
+	}
+	return recipients
+}
+
+// newMessageID returns a unique Message-ID in the domain of the sender address
+func newMessageID(from string) (string, error) {
+	random, err := crypto.GenerateSecureBytes(16)
+	if err != nil {
+		return "", err
+	}
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domain = strings.Trim(from[at+1:], "<> ")
+	}
+	return fmt.Sprintf("%s@%s", hex.EncodeToString(random), domain), nil
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// mimePart is a flattened view of a MIME tree used to compare structures
+type mimePart struct {
+	path      string // content types from the root, joined by '/'
+	contentID string
+	body      string
+}
+
+func flatten(t *testing.T, path, contentType string, header map[string][]string, body io.Reader) []mimePart {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("invalid content type %q: %v", contentType, err)
+	}
+	if path != "" {
+		path += " > "
+	}
+	path += mediaType
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		data, _ := io.ReadAll(body)
+		var contentID string
+		if ids := header["Content-Id"]; len(ids) > 0 {
+			contentID = ids[0]
+		}
+		return []mimePart{{path: path, contentID: contentID, body: string(data)}}
+	}
+
+	var parts []mimePart
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read part of %s: %v", path, err)
+		}
+		parts = append(parts, flatten(t, path, p.Header.Get("Content-Type"), p.Header, p)...)
+	}
+	return parts
+}
+
+func TestBuildMessage(t *testing.T) {
+	pdf := Attachment{Filename: "INV-1.pdf", ContentType: AttachmentTypePDF, Data: []byte("%PDF")}
+	logo := InlineImage{ContentID: "provider-logo", Filename: "logo.png", ContentType: AttachmentTypeImagePNG, Data: []byte("png")}
+
+	tests := []struct {
+		name     string
+		msg      Message
+		expected []mimePart
+	}{
+		{
+			name: "plain text only",
+			msg:  Message{Body: "hello", Attachments: []Attachment{pdf}},
+			expected: []mimePart{
+				{path: "multipart/mixed > text/plain", body: "hello"},
+				{path: "multipart/mixed > application/pdf", body: "JVBERg=="},
+			},
+		},
+		{
+			name: "html alternative",
+			msg:  Message{Body: "hello", HTMLBody: "<p>hello</p>", Attachments: []Attachment{pdf}},
+			expected: []mimePart{
+				{path: "multipart/mixed > multipart/alternative > text/plain", body: "hello"},
+				{path: "multipart/mixed > multipart/alternative > text/html", body: "<p>hello</p>"},
+				{path: "multipart/mixed > application/pdf", body: "JVBERg=="},
+			},
+		},
+		{
+			name: "html with inline image",
+			msg:  Message{Body: "hello", HTMLBody: `<img src="cid:provider-logo">`, Inline: []InlineImage{logo}, Attachments: []Attachment{pdf}},
+			expected: []mimePart{
+				{path: "multipart/mixed > multipart/alternative > text/plain", body: "hello"},
+				{path: "multipart/mixed > multipart/alternative > multipart/related > text/html", body: `<img src="cid:provider-logo">`},
+				{path: "multipart/mixed > multipart/alternative > multipart/related > image/png", contentID: "<provider-logo>", body: "cG5n"},
+				{path: "multipart/mixed > application/pdf", body: "JVBERg=="},
+			},
+		},
+		{
+			name: "inline images are dropped without html",
+			msg:  Message{Body: "hello", Inline: []InlineImage{logo}},
+			expected: []mimePart{
+				{path: "multipart/mixed > text/plain", body: "hello"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.msg.To = []string{"client@example.com"}
+			tt.msg.Subject = "Invoice"
+			data, err := buildMessage("me@example.com", &tt.msg)
+			if err != nil {
+				t.Fatalf("buildMessage() error = %v", err)
+			}
+			m, err := mail.ReadMessage(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("failed to parse message: %v", err)
+			}
+			if to := m.Header.Get("To"); to != "client@example.com" {
+				t.Errorf("To = %q", to)
+			}
+
+			parts := flatten(t, "", m.Header.Get("Content-Type"), m.Header, m.Body)
+			if len(parts) != len(tt.expected) {
+				t.Fatalf("got %d parts %+v, want %d", len(parts), parts, len(tt.expected))
+			}
+			for i, want := range tt.expected {
+				if parts[i] != want {
+					t.Errorf("part %d = %+v, want %+v", i, parts[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildMessage_CcBccReplyTo(t *testing.T) {
+	msg := &Message{
+		To:      []string{"client@example.com"},
+		Cc:      []string{"accounts@example.com"},
+		Bcc:     []string{"archive@example.com"},
+		ReplyTo: "jane@example.com",
+		Subject: "Invoice",
+		Body:    "hello",
+	}
+	data, err := buildMessage("shared@example.com", msg)
+	if err != nil {
+		t.Fatalf("buildMessage() error = %v", err)
+	}
+	m, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+	if cc := m.Header.Get("Cc"); cc != "accounts@example.com" {
+		t.Errorf("Cc = %q", cc)
+	}
+	if replyTo := m.Header.Get("Reply-To"); replyTo != "jane@example.com" {
+		t.Errorf("Reply-To = %q", replyTo)
+	}
+	if strings.Contains(string(data), "archive@example.com") {
+		t.Error("BCC address appears in the message")
+	}
+}
+
+func TestMessageRecipients(t *testing.T) {
+	msg := &Message{
+		To:  []string{"client@example.com", "ap@example.com"},
+		Cc:  []string{"AP@example.com", "accounts@example.com"},
+		Bcc: []string{"archive@example.com", "client@example.com"},
+	}
+	expected := []string{"client@example.com", "ap@example.com", "accounts@example.com", "archive@example.com"}
+	result := msg.Recipients()
+	if strings.Join(result, ",") != strings.Join(expected, ",") {
+		t.Errorf("Recipients() = %v, want %v", result, expected)
+	}
+}
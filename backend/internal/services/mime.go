@@ -0,0 +1,342 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineLength is the line length recommended by RFC 5322 and required
+// for base64 bodies by RFC 2045
+const maxLineLength = 76
+
+// MIMEBuilder assembles RFC 5322 messages with MIME bodies.
+// Headers are written in a fixed order, non-ASCII headers are RFC 2047 encoded
+// and long lines are folded or wrapped.
+type MIMEBuilder struct {
+	Now      func() time.Time // clock for the Date header, time.Now when nil
+	Boundary func() string    // (optional) multipart boundaries, random when nil
+}
+
+// buildMessage builds msg with the default MIMEBuilder
+func buildMessage(from string, msg *Message) ([]byte, error) {
+	data, err := (&MIMEBuilder{}).Build(from, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build message: %v", err)
+	}
+	return data, nil
+}
+
+// Build assembles the message, assigning a Message-ID when msg has none:
+//
+//	multipart/mixed
+//	├── multipart/alternative
+//	│   ├── text/plain
+//	│   └── multipart/related
+//	│       ├── text/html
+//	│       └── inline images
+//	└── attachments
+//
+// multipart/alternative is only used with an HTML body, and multipart/related
+// only when there are inline images.
+func (b *MIMEBuilder) Build(from string, msg *Message) ([]byte, error) {
+	if msg.MessageID == "" {
+		id, err := newMessageID(from)
+		if err != nil {
+			return nil, err
+		}
+		msg.MessageID = id
+	}
+	now := time.Now
+	if b.Now != nil {
+		now = b.Now
+	}
+
+	var buf bytes.Buffer
+	mw := b.newWriter(&buf)
+
+	sender := from
+	if msg.FromName != "" {
+		sender = (&mail.Address{Name: msg.FromName, Address: from}).String()
+	}
+	writeHeader(&buf, "From", encodeAddressList([]string{sender}))
+	writeHeader(&buf, "To", encodeAddressList(msg.To))
+	if len(msg.Cc) > 0 {
+		writeHeader(&buf, "Cc", encodeAddressList(msg.Cc))
+	}
+	if msg.ReplyTo != "" {
+		writeHeader(&buf, "Reply-To", encodeAddressList([]string{msg.ReplyTo}))
+	}
+	writeHeader(&buf, "Subject", encodeText(msg.Subject))
+	writeHeader(&buf, "Date", now().Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", fmt.Sprintf("<%s>", msg.MessageID))
+	writeHeader(&buf, "MIME-Version", "1.0")
+	writeHeader(&buf, "Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mw.Boundary()}))
+	buf.WriteString("\r\n")
+
+	// first part: body
+	if msg.HTMLBody == "" {
+		if err := writeTextPart(mw, "text/plain", msg.Body); err != nil {
+			return nil, err
+		}
+	} else {
+		alternative, boundary, err := b.buildAlternative(msg)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeNestedPart(mw, "multipart/alternative", boundary, alternative); err != nil {
+			return nil, err
+		}
+	}
+
+	// remaining parts: attachments
+	for _, a := range msg.Attachments {
+		partHeaders := textproto.MIMEHeader{}
+		setFolded(partHeaders, "Content-Disposition", formatDisposition("attachment", a.Filename))
+		setFolded(partHeaders, "Content-Type", formatContentType(a.ContentType, a.Filename))
+		if err := writeBase64Part(mw, partHeaders, a.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	// writes the final boundary
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (b *MIMEBuilder) newWriter(w io.Writer) *multipart.Writer {
+	mw := multipart.NewWriter(w)
+	if b.Boundary != nil {
+		mw.SetBoundary(b.Boundary())
+	}
+	return mw
+}
+
+// buildAlternative builds the multipart/alternative body holding the plain text
+// and HTML versions, and returns it with its boundary
+func (b *MIMEBuilder) buildAlternative(msg *Message) ([]byte, string, error) {
+	var buf bytes.Buffer
+	aw := b.newWriter(&buf)
+
+	if err := writeTextPart(aw, "text/plain", msg.Body); err != nil {
+		return nil, "", err
+	}
+
+	// the preferred alternative goes last
+	if len(msg.Inline) == 0 {
+		if err := writeTextPart(aw, "text/html", msg.HTMLBody); err != nil {
+			return nil, "", err
+		}
+	} else {
+		related, boundary, err := b.buildRelated(msg)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := writeNestedPart(aw, "multipart/related", boundary, related); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := aw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), aw.Boundary(), nil
+}
+
+// buildRelated builds the multipart/related body holding the HTML part and the
+// inline images it references, and returns it with its boundary
+func (b *MIMEBuilder) buildRelated(msg *Message) ([]byte, string, error) {
+	var buf bytes.Buffer
+	rw := b.newWriter(&buf)
+
+	if err := writeTextPart(rw, "text/html", msg.HTMLBody); err != nil {
+		return nil, "", err
+	}
+	for _, img := range msg.Inline {
+		partHeaders := textproto.MIMEHeader{}
+		setFolded(partHeaders, "Content-Type", formatContentType(img.ContentType, img.Filename))
+		partHeaders.Set("Content-ID", fmt.Sprintf("<%s>", img.ContentID))
+		setFolded(partHeaders, "Content-Disposition", formatDisposition("inline", img.Filename))
+		if err := writeBase64Part(rw, partHeaders, img.Data); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := rw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), rw.Boundary(), nil
+}
+
+// writeTextPart writes body as quoted-printable, which keeps lines short and
+// non-ASCII text intact through 7-bit relays
+func writeTextPart(mw *multipart.Writer, contentType, body string) error {
+	partHeaders := textproto.MIMEHeader{}
+	partHeaders.Set("Content-Type", contentType+"; charset=utf-8")
+	partHeaders.Set("Content-Transfer-Encoding", "quoted-printable")
+	part, err := mw.CreatePart(partHeaders)
+	if err != nil {
+		return err
+	}
+	qw := quotedprintable.NewWriter(part)
+	if _, err := qw.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+func writeNestedPart(mw *multipart.Writer, contentType, boundary string, body []byte) error {
+	partHeaders := textproto.MIMEHeader{}
+	partHeaders.Set("Content-Type", mime.FormatMediaType(contentType, map[string]string{"boundary": boundary}))
+	part, err := mw.CreatePart(partHeaders)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(body)
+	return err
+}
+
+// writeBase64Part writes data base64 encoded in lines of 76 characters
+func writeBase64Part(mw *multipart.Writer, partHeaders textproto.MIMEHeader, data []byte) error {
+	partHeaders.Set("Content-Transfer-Encoding", "base64")
+	part, err := mw.CreatePart(partHeaders)
+	if err != nil {
+		return err
+	}
+	b64Encoder := base64.NewEncoder(base64.StdEncoding, &lineWrapper{w: part})
+	if _, err := b64Encoder.Write(data); err != nil {
+		return err
+	}
+	return b64Encoder.Close()
+}
+
+// lineWrapper inserts CRLF after every maxLineLength bytes
+type lineWrapper struct {
+	w   io.Writer
+	col int
+}
+
+func (l *lineWrapper) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if l.col == maxLineLength {
+			if _, err := l.w.Write([]byte("\r\n")); err != nil {
+				return written, err
+			}
+			l.col = 0
+		}
+		n := min(maxLineLength-l.col, len(p))
+		if _, err := l.w.Write(p[:n]); err != nil {
+			return written, err
+		}
+		l.col += n
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// formatDisposition returns a Content-Disposition with the filename parameter
+func formatDisposition(disposition, filename string) string {
+	return withFilenameParam(disposition, "filename", filename)
+}
+
+// formatContentType adds the name parameter older clients use as the filename
+func formatContentType(contentType AttachmentType, filename string) string {
+	return withFilenameParam(string(contentType), "name", filename)
+}
+
+// withFilenameParam appends a quoted filename parameter to a header value.
+// Non-ASCII filenames are RFC 2231 encoded.
+func withFilenameParam(value, param, filename string) string {
+	filename = strings.Join(strings.Fields(filename), " ")
+	if filename == "" {
+		return value
+	}
+	if !isASCII(filename) {
+		if v := mime.FormatMediaType(value, map[string]string{param: filename}); v != "" {
+			return v
+		}
+		return value
+	}
+	quoted := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(filename)
+	return fmt.Sprintf(`%s; %s="%s"`, value, param, quoted)
+}
+
+// writeHeader writes a header field, folded by foldHeader
+func writeHeader(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	buf.WriteString(":")
+	buf.WriteString(foldHeader(name, value))
+	buf.WriteString("\r\n")
+}
+
+// foldHeader folds a header value at spaces to keep lines within
+// maxLineLength where possible. The result starts with a space.
+func foldHeader(name, value string) string {
+	var b strings.Builder
+	lineLength := len(name) + 1
+	for i, word := range strings.Split(value, " ") {
+		if i > 0 && lineLength+1+len(word) > maxLineLength {
+			b.WriteString("\r\n")
+			lineLength = 0
+		}
+		b.WriteString(" ")
+		b.WriteString(word)
+		lineLength += 1 + len(word)
+	}
+	return b.String()
+}
+
+// setFolded sets a part header folded by foldHeader, since multipart.Writer
+// writes part headers as they are
+func setFolded(h textproto.MIMEHeader, name, value string) {
+	h.Set(name, strings.TrimPrefix(foldHeader(name, value), " "))
+}
+
+// encodeText removes line breaks and RFC 2047 encodes non-ASCII text
+func encodeText(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if isASCII(s) {
+		return s
+	}
+	return mime.QEncoding.Encode("utf-8", s)
+}
+
+// encodeAddressList formats addresses for a header. Bare addresses are kept as
+// they are, display names are quoted or RFC 2047 encoded as needed.
+func encodeAddressList(addrs []string) string {
+	encoded := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		parsed, err := mail.ParseAddress(addr)
+		switch {
+		case err != nil:
+			encoded = append(encoded, encodeText(addr))
+		case parsed.Name == "":
+			encoded = append(encoded, parsed.Address)
+		default:
+			encoded = append(encoded, parsed.String())
+		}
+	}
+	return strings.Join(encoded, ", ")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// newTestBuilder returns a MIMEBuilder with a fixed clock and numbered boundaries
+func newTestBuilder() *MIMEBuilder {
+	n := 0
+	return &MIMEBuilder{
+		Now: func() time.Time {
+			return time.Date(2025, 11, 1, 9, 30, 0, 0, time.FixedZone("AEDT", 11*60*60))
+		},
+		Boundary: func() string {
+			n++
+			return fmt.Sprintf("boundary-%d", n)
+		},
+	}
+}
+
+func TestMIMEBuilder_Golden(t *testing.T) {
+	pdf := Attachment{Filename: "INV-25110101.pdf", ContentType: AttachmentTypePDF, Data: bytes.Repeat([]byte("%PDF-1.7 invoice "), 8)}
+	logo := InlineImage{ContentID: "provider-logo", Filename: "logo.png", ContentType: AttachmentTypeImagePNG, Data: []byte("\x89PNG\r\n\x1a\n")}
+
+	tests := []struct {
+		name string
+		from string
+		msg  Message
+	}{
+		{
+			name: "plain",
+			from: "jane@example.com",
+			msg: Message{
+				To:          []string{"client@example.com"},
+				Subject:     "Invoice INV-25110101 from Jane Smith",
+				Body:        "Hi Acme,\n\nPlease find the invoice attached.\n",
+				Attachments: []Attachment{pdf},
+			},
+		},
+		{
+			name: "unicode",
+			from: "Zoë Müller <zoe@example.com>",
+			msg: Message{
+				To:          []string{"Société Générale <ap@example.fr>", "client@example.com"},
+				Cc:          []string{"accounts@example.com"},
+				ReplyTo:     "zoe@example.com",
+				Subject:     "Facture № 42 — 1 234,50 €",
+				Body:        "Bonjour, veuillez trouver ci-joint la facture du mois d'octobre pour les prestations réalisées.",
+				Attachments: []Attachment{{Filename: "Facture été 2025.pdf", ContentType: AttachmentTypePDF, Data: []byte("%PDF")}},
+			},
+		},
+		{
+			name: "html",
+			from: "jane@example.com",
+			msg: Message{
+				To: []string{
+					"accounts.payable@example.com", "finance.team@example.com",
+					"operations.manager@example.com", "director@example.com",
+				},
+				Subject:  "Invoice INV-25110101",
+				Body:     "Total: $1,357.95",
+				HTMLBody: `<p>Total: <b>$1,357.95</b></p><img src="cid:provider-logo" alt="Jane Smith">`,
+				Inline:   []InlineImage{logo},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.msg.MessageID = "0123456789abcdef@example.com"
+			data, err := newTestBuilder().Build(tt.from, &tt.msg)
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+
+			golden := filepath.Join("testdata", tt.name+".eml")
+			if *update {
+				if err := os.WriteFile(golden, data, 0644); err != nil {
+					t.Fatalf("failed to update golden file: %v", err)
+				}
+			}
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read golden file, run go test -update: %v", err)
+			}
+			if !bytes.Equal(data, expected) {
+				t.Errorf("Build() differs from %s, run go test -update if the change is intended\ngot:\n%s", golden, data)
+			}
+
+			for i, line := range strings.Split(string(data), "\r\n") {
+				if len(line) > 78 {
+					t.Errorf("line %d is %d characters long: %q", i+1, len(line), line)
+				}
+			}
+		})
+	}
+}
+
+func TestMIMEBuilder_Headers(t *testing.T) {
+	msg := &Message{
+		To:          []string{"Société Générale <ap@example.fr>"},
+		FromName:    "Zoë Facturation",
+		Subject:     "Facture № 42\r\nBcc: victim@example.com",
+		Body:        "é",
+		Attachments: []Attachment{{Filename: "Facture été.pdf", ContentType: AttachmentTypePDF, Data: []byte("%PDF")}},
+	}
+	data, err := newTestBuilder().Build("zoe@example.com", msg)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	m, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+
+	dec := new(mime.WordDecoder)
+	subject, err := dec.DecodeHeader(m.Header.Get("Subject"))
+	if err != nil || subject != "Facture № 42 Bcc: victim@example.com" {
+		t.Errorf("Subject = %q, %v, want line breaks removed", subject, err)
+	}
+	if m.Header.Get("Bcc") != "" {
+		t.Error("line break in the subject injected a header")
+	}
+	from, err := m.Header.AddressList("From")
+	if err != nil || len(from) != 1 || from[0].Name != "Zoë Facturation" || from[0].Address != "zoe@example.com" {
+		t.Errorf("From = %v, %v", from, err)
+	}
+	to, err := m.Header.AddressList("To")
+	if err != nil || len(to) != 1 || to[0].Name != "Société Générale" || to[0].Address != "ap@example.fr" {
+		t.Errorf("To = %v, %v", to, err)
+	}
+	if date, err := m.Header.Date(); err != nil || !date.Equal(newTestBuilder().Now()) {
+		t.Errorf("Date = %v, %v", date, err)
+	}
+	if m.Header.Get("Message-ID") != "<"+msg.MessageID+">" || !strings.HasSuffix(msg.MessageID, "@example.com") {
+		t.Errorf("Message-ID = %q, want a generated id", m.Header.Get("Message-ID"))
+	}
+
+	// header order is fixed
+	var names []string
+	for _, line := range strings.Split(string(data[:bytes.Index(data, []byte("\r\n\r\n"))]), "\r\n") {
+		if name, _, ok := strings.Cut(line, ":"); ok && !strings.HasPrefix(line, " ") {
+			names = append(names, name)
+		}
+	}
+	expected := "From,To,Subject,Date,Message-ID,MIME-Version,Content-Type"
+	if strings.Join(names, ",") != expected {
+		t.Errorf("headers = %v, want %s", names, expected)
+	}
+
+	parts := flatten(t, "", m.Header.Get("Content-Type"), m.Header, m.Body)
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, want 2", len(parts))
+	}
+	if !strings.Contains(string(data), `filename*=utf-8''Facture%20%C3%A9t%C3%A9.pdf`) {
+		t.Errorf("attachment filename is not RFC 2231 encoded:\n%s", data)
+	}
+}
+
+func TestLineWrapper(t *testing.T) {
+	var buf bytes.Buffer
+	w := &lineWrapper{w: &buf}
+	// written in uneven chunks to cross line boundaries
+	for _, chunk := range []string{strings.Repeat("a", 50), strings.Repeat("b", 50), strings.Repeat("c", 52)} {
+		w.Write([]byte(chunk))
+	}
+	lines := strings.Split(buf.String(), "\r\n")
+	if len(lines) != 2 || len(lines[0]) != 76 || len(lines[1]) != 76 {
+		t.Errorf("lines = %q, want two lines of 76 characters", lines)
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	OrientationPortrait  = "portrait"
+	OrientationLandscape = "landscape"
+
+	mmPerInch   = 25.4
+	maxMarginMM = 50
+)
+
+// paperSizes maps the paper names of PageSettings to their sizes
+var paperSizes = map[string]PaperSize{
+	"a4":     PaperSizeA4,
+	"a3":     PaperSizeA3,
+	"letter": PaperSizeLetter,
+}
+
+// DefaultPageSettings are used for PDFs where providers, clients and the
+// request set nothing. The margins match Chrome's default print margins.
+var DefaultPageSettings = PageSettings{
+	PaperSize:   "a3",
+	Orientation: OrientationPortrait,
+	Margins:     &Margins{Top: 10, Right: 10, Bottom: 10, Left: 10},
+}
+
+// Margins are page margins in millimetres
+type Margins struct {
+	Top    float64 `json:"top"`
+	Right  float64 `json:"right"`
+	Bottom float64 `json:"bottom"`
+	Left   float64 `json:"left"`
+}
+
+// PageSettings choose the paper of invoice PDFs. Empty fields inherit from
+// the next level: request, client, provider, DefaultPageSettings.
+type PageSettings struct {
+	PaperSize   string   `json:"paper_size,omitempty"`  // a4, a3 or letter
+	Orientation string   `json:"orientation,omitempty"` // portrait or landscape
+	Margins     *Margins `json:"margins,omitempty"`
+}
+
+// Validate checks the paper size, orientation and margins
+func (s *PageSettings) Validate() error {
+	if s.PaperSize != "" {
+		if _, ok := paperSizes[s.PaperSize]; !ok {
+			return fmt.Errorf("invalid paper_size '%s', expected a4, a3 or letter", s.PaperSize)
+		}
+	}
+	if s.Orientation != "" && s.Orientation != OrientationPortrait && s.Orientation != OrientationLandscape {
+		return fmt.Errorf("invalid orientation '%s', expected portrait or landscape", s.Orientation)
+	}
+	if m := s.Margins; m != nil {
+		for _, margin := range []float64{m.Top, m.Right, m.Bottom, m.Left} {
+			if math.IsNaN(margin) || margin < 0 || margin > maxMarginMM {
+				return fmt.Errorf("invalid margin %gmm, expected 0 to %dmm", margin, maxMarginMM)
+			}
+		}
+	}
+	return nil
+}
+
+// Override returns s with the fields set in o replacing its own
+func (s PageSettings) Override(o *PageSettings) PageSettings {
+	if o == nil {
+		return s
+	}
+	if o.PaperSize != "" {
+		s.PaperSize = o.PaperSize
+	}
+	if o.Orientation != "" {
+		s.Orientation = o.Orientation
+	}
+	if o.Margins != nil {
+		s.Margins = o.Margins
+	}
+	return s
+}
+
+// Layout resolves the settings to a page, taking unset fields from DefaultPageSettings
+func (s PageSettings) Layout() (PageLayout, error) {
+	s = DefaultPageSettings.Override(&s)
+	if err := s.Validate(); err != nil {
+		return PageLayout{}, err
+	}
+	return PageLayout{
+		PaperSize: paperSizes[s.PaperSize],
+		Landscape: s.Orientation == OrientationLandscape,
+		Margins:   *s.Margins,
+	}, nil
+}
+
+// PageLayout is a resolved page of a PDF
+type PageLayout struct {
+	PaperSize PaperSize // portrait size in inches
+	Landscape bool
+	Margins   Margins
+}
+
+// Size returns the paper size in inches, turned for landscape
+func (l PageLayout) Size() PaperSize {
+	if l.Landscape {
+		return PaperSize{Width: l.PaperSize.Height, Height: l.PaperSize.Width}
+	}
+	return l.PaperSize
+}
+
+// MarginsInches returns the margins in inches, the unit of PaperSize
+func (l PageLayout) MarginsInches() Margins {
+	return Margins{
+		Top:    l.Margins.Top / mmPerInch,
+		Right:  l.Margins.Right / mmPerInch,
+		Bottom: l.Margins.Bottom / mmPerInch,
+		Left:   l.Margins.Left / mmPerInch,
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPageSettings_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings PageSettings
+		wantErr  bool
+	}{
+		{"empty", PageSettings{}, false},
+		{"defaults", DefaultPageSettings, false},
+		{"letter landscape", PageSettings{PaperSize: "letter", Orientation: OrientationLandscape}, false},
+		{"unknown paper", PageSettings{PaperSize: "A4"}, true},
+		{"unknown orientation", PageSettings{Orientation: "sideways"}, true},
+		{"negative margin", PageSettings{Margins: &Margins{Left: -1}}, true},
+		{"margin too large", PageSettings{Margins: &Margins{Top: 51}}, true},
+		{"margin not a number", PageSettings{Margins: &Margins{Right: math.NaN()}}, true},
+		{"infinite margin", PageSettings{Margins: &Margins{Bottom: math.Inf(1)}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.settings.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPageSettings_Layout(t *testing.T) {
+	// provider sets the paper, client turns it
+	provider := &PageSettings{PaperSize: "a4", Margins: &Margins{Top: 25.4, Right: 12.7, Bottom: 25.4, Left: 12.7}}
+	client := &PageSettings{Orientation: OrientationLandscape}
+	layout, err := DefaultPageSettings.Override(provider).Override(client).Layout()
+	if err != nil {
+		t.Fatalf("Layout() error = %v", err)
+	}
+	if layout.PaperSize != PaperSizeA4 || !layout.Landscape {
+		t.Errorf("Layout() = %+v, want A4 landscape", layout)
+	}
+	if size := layout.Size(); size.Width != PaperSizeA4.Height || size.Height != PaperSizeA4.Width {
+		t.Errorf("Size() = %+v, want A4 turned", size)
+	}
+	inches := layout.MarginsInches()
+	if math.Abs(inches.Top-1) > 1e-9 || math.Abs(inches.Left-0.5) > 1e-9 {
+		t.Errorf("MarginsInches() = %+v, want 1in top and 0.5in left", inches)
+	}
+
+	// unset fields come from the defaults
+	layout, err = PageSettings{}.Layout()
+	if err != nil {
+		t.Fatalf("Layout() error = %v", err)
+	}
+	if layout.PaperSize != PaperSizeA3 || layout.Landscape || layout.Margins != *DefaultPageSettings.Margins {
+		t.Errorf("Layout() of empty settings = %+v, want the defaults", layout)
+	}
+
+	if _, err := (PageSettings{PaperSize: "tabloid"}).Layout(); err == nil {
+		t.Error("Layout() of an unknown paper size did not fail")
+	}
+}
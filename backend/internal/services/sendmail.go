@@ -0,0 +1,82 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// exTempFail is the sysexits.h code for a temporary failure, the message may be retried
+const exTempFail = 75
+
+// DefaultSendmailTimeout limits how long the sendmail binary may run
+const DefaultSendmailTimeout = time.Minute
+
+// SendmailMailer pipes messages to a local sendmail-compatible binary,
+// e.g. sendmail, postfix or msmtp
+type SendmailMailer struct {
+	from    string
+	path    string
+	Timeout time.Duration
+}
+
+// NewSendmailMailer creates a new SendmailMailer instance
+func NewSendmailMailer(from, path string) *SendmailMailer {
+	return &SendmailMailer{from: from, path: path, Timeout: DefaultSendmailTimeout}
+}
+
+// SendmailError is returned when the sendmail binary exits with an error
+type SendmailError struct {
+	ExitCode int
+	Output   string // combined stdout and stderr
+}
+
+func (e *SendmailError) Error() string {
+	if e.Output == "" {
+		return fmt.Sprintf("sendmail exited with status %d", e.ExitCode)
+	}
+	return fmt.Sprintf("sendmail exited with status %d: %s", e.ExitCode, e.Output)
+}
+
+// Temporary reports whether sendmail asked to retry later
+func (e *SendmailError) Temporary() bool {
+	return e.ExitCode == exTempFail
+}
+
+// SendMessage sends an email through the sendmail binary.
+// The envelope is passed as arguments, so BCC recipients stay out of the headers.
+func (m *SendmailMailer) SendMessage(msg *Message) (*Receipt, error) {
+	data, err := buildMessage(m.from, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.Timeout)
+	defer cancel()
+
+	// -i: a line with a single dot does not end the message
+	args := append([]string{"-i", "-f", m.from, "--"}, msg.Recipients()...)
+	cmd := exec.CommandContext(ctx, m.path, args...)
+	cmd.Stdin = bytes.NewReader(data)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && ctx.Err() == nil {
+			return nil, &SendmailError{ExitCode: exitErr.ExitCode(), Output: strings.TrimSpace(output.String())}
+		}
+		return nil, fmt.Errorf("failed to run sendmail: %w", err)
+	}
+
+	response := strings.TrimSpace(output.String())
+	if response == "" {
+		response = "accepted by " + m.path
+	}
+	return &Receipt{MessageID: msg.MessageID, Response: response}, nil
+}
@@ -1,15 +1,13 @@
 package services
 
 import (
-	"bytes"
-	"encoding/base64"
+	"crypto/tls"
 	"fmt"
 	"go-invoice/internal/auth"
 	"log/slog"
-	"mime/multipart"
+	"net"
 	"net/smtp"
-	"net/textproto"
-	"strings"
+	"time"
 )
 
 // AttachmentType represents the MIME type of the attachment
@@ -58,28 +56,32 @@ func (a *oauth2Auth) Close() error {
 
 // SMTPService is responsible for sending emails via direct SMTP
 type SMTPService struct {
-	from    string
-	address string
-	auth    smtp.Auth
+	from   string
+	config *SMTPConfig
+	auth   smtp.Auth
 }
 
-// NewSMTPService creates a new SMTPService instance
-func NewSMTPService(from, host string, port int, credential string, authMethod auth.AuthMethod) *SMTPService {
-	address := fmt.Sprintf("%s:%d", host, port)
+// NewSMTPService creates a new SMTPService instance sending from the given address.
+// login is the account credential belongs to, empty means from, e.g. the mailbox
+// owning an alias sent from.
+func NewSMTPService(from, login string, config *SMTPConfig, credential string, authMethod auth.AuthMethod) *SMTPService {
+	if login == "" {
+		login = from
+	}
 	switch authMethod {
 	case auth.AuthMethodPlain:
-		auth := smtp.PlainAuth("", from, credential, host)
+		auth := smtp.PlainAuth("", login, credential, config.Host)
 		return &SMTPService{
-			from:    from,
-			address: address,
-			auth:    auth,
+			from:   from,
+			config: config,
+			auth:   auth,
 		}
 	case auth.AuthMethodOAuth2:
-		auth := newOAuth2Auth(from, credential)
+		auth := newOAuth2Auth(login, credential)
 		return &SMTPService{
-			from:    from,
-			address: address,
-			auth:    auth,
+			from:   from,
+			config: config,
+			auth:   auth,
 		}
 	default:
 		return nil
@@ -88,33 +90,8 @@ func NewSMTPService(from, host string, port int, credential string, authMethod a
 
 // Send sends a basic plaintext email via SMTP
 func (s *SMTPService) Send(to []string, subject string, body string) error {
-	header := make(map[string]string)
-	header["From"] = s.from
-	header["To"] = strings.Join(to, ", ")
-	header["Subject"] = subject
-	header["MIME-version"] = "1.0"
-	header["Content-Type"] = "text/plain; charset=utf-8"
-
-	var msgBuilder strings.Builder
-	for k, v := range header {
-		msgBuilder.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
-	}
-	msgBuilder.WriteString("\r\n") // empty line seperates header from body
-	msgBuilder.WriteString(body)
-
-	msg := msgBuilder.String()
-
-	err := smtp.SendMail(
-		s.address,
-		s.auth,
-		s.from,
-		to,
-		[]byte(msg),
-	)
-	if err != nil {
-		return fmt.Errorf("smtp.SendMail failed: %v", err)
-	}
-	return nil
+	_, err := s.SendMessage(&Message{To: to, Subject: subject, Body: body})
+	return err
 }
 
 // SendWithAttachment sends an email with an attachment via SMTP
@@ -126,64 +103,143 @@ func (s *SMTPService) SendWithAttachment(
 	attachmentData []byte,
 	attachmentType AttachmentType) error {
 
-	var emailBuffer bytes.Buffer
+	_, err := s.SendMessage(&Message{
+		To:      to,
+		Subject: subject,
+		Body:    body,
+		Attachments: []Attachment{
+			{Filename: attachmentName, ContentType: attachmentType, Data: attachmentData},
+		},
+	})
+	return err
+}
 
-	mw := multipart.NewWriter(&emailBuffer)
+// Receipt describes an email accepted by the SMTP server
+type Receipt struct {
+	MessageID string // Message-ID header of the sent email, without angle brackets
+	Response  string // final server reply, e.g. "250 2.0.0 OK queued as 1A2B3C"
+}
 
-	header := make(map[string]string)
-	header["From"] = s.from
-	header["To"] = strings.Join(to, ", ")
-	header["Subject"] = subject
-	header["MIME-Version"] = "1.0"
+// SendMessage sends an email with an optional HTML body, inline images and attachments via SMTP.
+// A Message-ID is generated when msg.MessageID is empty.
+func (s *SMTPService) SendMessage(msg *Message) (*Receipt, error) {
+	data, err := buildMessage(s.from, msg)
+	if err != nil {
+		return nil, err
+	}
 
-	header["Content-Type"] = fmt.Sprintf("multipart/mixed; boundary=%s", mw.Boundary())
-	for k, v := range header {
-		emailBuffer.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
+	// send the email
+	response, err := s.send(msg.Recipients(), data)
+	if err != nil {
+		return nil, fmt.Errorf("smtp send failed: %w", err)
 	}
 
-	emailBuffer.WriteString("\r\n")
+	return &Receipt{MessageID: msg.MessageID, Response: response}, nil
+}
 
-	// first part: plain text body
-	partHeaders := textproto.MIMEHeader{}
-	partHeaders.Set("Content-Type", "text/plain; charset=utf-8")
-	part, err := mw.CreatePart(partHeaders)
+// dial connects to the server and secures the connection according to the TLS mode.
+// The returned conn is the underlying connection, used to set deadlines.
+func (s *SMTPService) dial() (*smtp.Client, net.Conn, error) {
+	cfg := s.config
+	dialer := &net.Dialer{Timeout: cfg.DialTimeout}
+	var conn net.Conn
+	var err error
+	if cfg.TLSMode == TLSModeImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", cfg.Address(), cfg.TLSConfig())
+	} else {
+		conn, err = dialer.Dial("tcp", cfg.Address())
+	}
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	part.Write([]byte(body))
-
-	// second part: attachment
-	partHeaders = textproto.MIMEHeader{}
-	partHeaders.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", attachmentName))
-	partHeaders.Set("Content-Type", string(attachmentType))
-	partHeaders.Set("Content-Transfer-Encoding", "base64")
 
-	part, err = mw.CreatePart(partHeaders)
+	// the deadline covers the greeting, EHLO and STARTTLS
+	conn.SetDeadline(time.Now().Add(cfg.CommandTimeout))
+	c, err := smtp.NewClient(conn, cfg.Host)
 	if err != nil {
-		return err
+		conn.Close()
+		return nil, nil, err
 	}
 
-	b64Encoder := base64.NewEncoder(base64.StdEncoding, part)
-	b64Encoder.Write(attachmentData)
-	b64Encoder.Close()
+	switch cfg.TLSMode {
+	case TLSModeOpportunistic, TLSModeStartTLS:
+		ok, _ := c.Extension("STARTTLS")
+		if !ok && cfg.TLSMode == TLSModeStartTLS {
+			c.Close()
+			return nil, nil, fmt.Errorf("server doesn't support STARTTLS, which SMTP_TLS_MODE=starttls requires")
+		}
+		if ok {
+			if err := c.StartTLS(cfg.TLSConfig()); err != nil {
+				c.Close()
+				return nil, nil, err
+			}
+		}
+	}
+	return c, conn, nil
+}
 
-	// finish multipart message
-	if err := mw.Close(); err != nil { // <-- this writes the final boundary
-		return err
+// send delivers data like smtp.SendMail, but returns the server's reply to the message.
+// Each command must complete within the configured command timeout.
+func (s *SMTPService) send(to []string, data []byte) (string, error) {
+	c, conn, err := s.dial()
+	if err != nil {
+		return "", err
 	}
+	defer c.Close()
+	extend := func() { conn.SetDeadline(time.Now().Add(s.config.CommandTimeout)) }
 
-	// send the email
-	err = smtp.SendMail(
-		s.address,
-		s.auth,
-		s.from,
-		to,
-		emailBuffer.Bytes(),
-	)
+	extend()
+	if s.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return "", fmt.Errorf("server doesn't support AUTH")
+		}
+		if err := c.Auth(s.auth); err != nil {
+			return "", err
+		}
+	}
+	extend()
+	if err := c.Mail(s.from); err != nil {
+		return "", err
+	}
+	for _, addr := range to {
+		extend()
+		if err := c.Rcpt(addr); err != nil {
+			return "", err
+		}
+	}
 
+	// DATA is driven through the text connection because smtp.Client
+	// discards the reply that carries the server's queue ID
+	extend()
+	id, err := c.Text.Cmd("DATA")
 	if err != nil {
-		return fmt.Errorf("smtp.SendMail failed: %v", err)
+		return "", err
 	}
+	c.Text.StartResponse(id)
+	_, _, err = c.Text.ReadResponse(354)
+	c.Text.EndResponse(id)
+	if err != nil {
+		return "", err
+	}
+	extend()
+	w := c.Text.DotWriter()
+	if _, err := w.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	extend()
+	code, reply, err := c.Text.ReadResponse(250)
+	if err != nil {
+		return "", err
+	}
+	response := fmt.Sprintf("%d %s", code, reply)
 
-	return nil
+	extend()
+	// the message is accepted at this point, a failing QUIT does not matter
+	if err := c.Quit(); err != nil {
+		slog.Warn("SMTP QUIT failed after message was accepted", "error", err)
+	}
+	return response, nil
 }
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TLSMode selects how the connection to the SMTP server is secured
+type TLSMode string
+
+const (
+	TLSModeOpportunistic TLSMode = "opportunistic" // STARTTLS when the server offers it, plaintext otherwise
+	TLSModeStartTLS      TLSMode = "starttls"      // STARTTLS is required, sending fails without it
+	TLSModeImplicit      TLSMode = "implicit"      // TLS from the first byte, usually port 465
+	TLSModeNone          TLSMode = "none"          // never encrypt, for local relays only
+)
+
+const (
+	DefaultSMTPDialTimeout    = 10 * time.Second
+	DefaultSMTPCommandTimeout = 30 * time.Second
+)
+
+// SMTPConfig describes how to reach the SMTP server
+type SMTPConfig struct {
+	Host           string
+	Port           int
+	TLSMode        TLSMode
+	ServerName     string         // name verified in the server certificate, defaults to Host
+	RootCAs        *x509.CertPool // trusted CAs, nil uses the system roots
+	DialTimeout    time.Duration  // limit for establishing the TCP connection
+	CommandTimeout time.Duration  // limit for each SMTP command and its reply
+}
+
+// LoadSMTPConfig reads the SMTP settings from the environment:
+//
+//	SMTP_HOST, SMTP_PORT       server address
+//	SMTP_TLS_MODE              opportunistic, starttls, implicit or none
+//	                           (default implicit on port 465, opportunistic otherwise)
+//	SMTP_TLS_CA_FILE           PEM bundle of CAs trusted instead of the system roots
+//	SMTP_TLS_SERVER_NAME       name expected in the server certificate
+//	SMTP_DIAL_TIMEOUT          e.g. 10s
+//	SMTP_COMMAND_TIMEOUT       e.g. 30s
+//
+// defaultHost and defaultPort are used when SMTP_HOST and SMTP_PORT are not set,
+// e.g. the server of the OAuth2 provider the sender signed in with.
+func LoadSMTPConfig(defaultHost string, defaultPort int) (*SMTPConfig, error) {
+	host := strings.TrimSpace(os.Getenv("SMTP_HOST"))
+	if host == "" {
+		host = defaultHost
+	}
+	port := defaultPort
+	var err error
+	if portStr := strings.TrimSpace(os.Getenv("SMTP_PORT")); portStr != "" {
+		if port, err = strconv.Atoi(portStr); err != nil {
+			return nil, fmt.Errorf("failed to parse SMTP_PORT to interger")
+		}
+	}
+	if host == "" || port == 0 {
+		return nil, fmt.Errorf("either SMTP_HOST or SMTP_PORT is not configured in environment variables")
+	}
+
+	cfg := &SMTPConfig{
+		Host:       host,
+		Port:       port,
+		TLSMode:    TLSMode(strings.ToLower(strings.TrimSpace(os.Getenv("SMTP_TLS_MODE")))),
+		ServerName: strings.TrimSpace(os.Getenv("SMTP_TLS_SERVER_NAME")),
+	}
+	if cfg.DialTimeout, err = durationEnv("SMTP_DIAL_TIMEOUT", DefaultSMTPDialTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.CommandTimeout, err = durationEnv("SMTP_COMMAND_TIMEOUT", DefaultSMTPCommandTimeout); err != nil {
+		return nil, err
+	}
+	if caFile := strings.TrimSpace(os.Getenv("SMTP_TLS_CA_FILE")); caFile != "" {
+		if cfg.RootCAs, err = LoadCertPool(caFile); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks the settings and fills in defaults
+func (c *SMTPConfig) Validate() error {
+	if c.Host == "" {
+		return fmt.Errorf("SMTP host is empty")
+	}
+	if c.Port <= 0 || c.Port > 65535 {
+		return fmt.Errorf("invalid SMTP port %d", c.Port)
+	}
+	switch c.TLSMode {
+	case "":
+		c.TLSMode = TLSModeOpportunistic
+		if c.Port == 465 {
+			c.TLSMode = TLSModeImplicit
+		}
+	case TLSModeOpportunistic, TLSModeStartTLS, TLSModeImplicit, TLSModeNone:
+	default:
+		return fmt.Errorf("invalid SMTP TLS mode '%s', expected opportunistic, starttls, implicit or none", c.TLSMode)
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = DefaultSMTPDialTimeout
+	}
+	if c.CommandTimeout <= 0 {
+		c.CommandTimeout = DefaultSMTPCommandTimeout
+	}
+	return nil
+}
+
+// Address returns the host:port to dial
+func (c *SMTPConfig) Address() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// TLSConfig returns the client TLS settings for implicit TLS and STARTTLS
+func (c *SMTPConfig) TLSConfig() *tls.Config {
+	serverName := c.ServerName
+	if serverName == "" {
+		serverName = c.Host
+	}
+	return &tls.Config{
+		ServerName: serverName,
+		RootCAs:    c.RootCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+// LoadCertPool reads a PEM bundle of CA certificates
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in CA file '%s'", path)
+	}
+	return pool, nil
+}
+
+// durationEnv parses a duration such as 30s from the environment, returning fallback when unset
+func durationEnv(key string, fallback time.Duration) (time.Duration, error) {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s '%s', expected a duration such as 30s", key, value)
+	}
+	return d, nil
+}
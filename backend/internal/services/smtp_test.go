@@ -0,0 +1,387 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"go-invoice/internal/auth"
+	"io"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer accepts one connection at a time and records the envelope and data
+type fakeSMTPServer struct {
+	listener net.Listener
+	rejectTo string      // recipient answered with 550
+	tls      *tls.Config // offers STARTTLS when set, see newFakeSMTPServerTLS
+	silent   bool        // never sends a greeting
+
+	mu     sync.Mutex
+	login  string // user of the AUTH PLAIN command
+	from   string
+	to     []string
+	data   string
+	sawTLS bool // the message was received over TLS
+}
+
+// newFakeSMTPServer starts a server, configured by setup before it serves
+func newFakeSMTPServer(t *testing.T, setup ...func(*fakeSMTPServer)) *fakeSMTPServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &fakeSMTPServer{listener: l}
+	for _, f := range setup {
+		f(s)
+	}
+	t.Cleanup(func() { l.Close() })
+	go s.serve()
+	return s
+}
+
+// newFakeSMTPServerTLS starts a server with a self-signed certificate for 127.0.0.1
+// and mail.example.test. With implicit set the listener speaks TLS from the first byte,
+// otherwise STARTTLS is offered. The returned pool trusts the certificate.
+func newFakeSMTPServerTLS(t *testing.T, implicit bool) (*fakeSMTPServer, *x509.CertPool) {
+	t.Helper()
+	cert, pool := newTestCertificate(t)
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &fakeSMTPServer{listener: l}
+	if implicit {
+		s.listener = tls.NewListener(l, config)
+	} else {
+		s.tls = config
+	}
+	t.Cleanup(func() { l.Close() })
+	go s.serve()
+	return s, pool
+}
+
+// newTestCertificate creates a self-signed certificate and a pool trusting it
+func newTestCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake smtp"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"mail.example.test"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func (s *fakeSMTPServer) addr() (string, int) {
+	a := s.listener.Addr().(*net.TCPAddr)
+	return a.IP.String(), a.Port
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.handle(conn)
+	}
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+	if s.silent {
+		io.Copy(io.Discard, conn)
+		return
+	}
+	_, secure := conn.(*tls.Conn)
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-fake")
+			if s.tls != nil && !secure {
+				tp.PrintfLine("250-STARTTLS")
+			}
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "STARTTLS":
+			if s.tls == nil || secure {
+				tp.PrintfLine("502 5.5.1 not supported")
+				continue
+			}
+			tp.PrintfLine("220 2.0.0 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, secure = tlsConn, true
+			tp = textproto.NewConn(conn)
+		case "AUTH":
+			// AUTH PLAIN base64("identity\x00user\x00password")
+			if fields := strings.Fields(line); len(fields) == 3 {
+				if decoded, err := base64.StdEncoding.DecodeString(fields[2]); err == nil {
+					if parts := strings.Split(string(decoded), "\x00"); len(parts) == 3 {
+						s.mu.Lock()
+						s.login = parts[1]
+						s.mu.Unlock()
+					}
+				}
+			}
+			tp.PrintfLine("235 2.7.0 Authentication successful")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			s.mu.Unlock()
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			addr := strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>")
+			if addr == s.rejectTo {
+				tp.PrintfLine("550 5.1.1 mailbox unavailable")
+				continue
+			}
+			s.mu.Lock()
+			s.to = append(s.to, addr)
+			s.mu.Unlock()
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = string(data)
+			s.sawTLS = secure
+			s.mu.Unlock()
+			tp.PrintfLine("250 2.0.0 OK queued as 1A2B3C")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("250 OK")
+		}
+	}
+}
+
+func testSMTPConfig(host string, port int) *SMTPConfig {
+	cfg := &SMTPConfig{Host: host, Port: port, CommandTimeout: 2 * time.Second}
+	cfg.Validate()
+	return cfg
+}
+
+func TestSendMessage(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	host, port := server.addr()
+	// smtp.PlainAuth refuses unencrypted connections except to localhost
+	s := NewSMTPService("me@example.com", "", testSMTPConfig(host, port), "secret", auth.AuthMethodPlain)
+
+	receipt, err := s.SendMessage(&Message{
+		To:      []string{"client@example.com"},
+		Bcc:     []string{"archive@example.com"},
+		Subject: "Invoice",
+		Body:    "hello",
+	})
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if receipt.Response != "250 2.0.0 OK queued as 1A2B3C" {
+		t.Errorf("Response = %q", receipt.Response)
+	}
+	if !strings.HasSuffix(receipt.MessageID, "@example.com") {
+		t.Errorf("MessageID = %q, want it in the sender's domain", receipt.MessageID)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.from != "me@example.com" || strings.Join(server.to, ",") != "client@example.com,archive@example.com" {
+		t.Errorf("envelope = %s -> %v, want BCC in the envelope", server.from, server.to)
+	}
+	if !strings.Contains(server.data, "Message-ID: <"+receipt.MessageID+">") {
+		t.Errorf("message has no Message-ID header:\n%s", server.data)
+	}
+}
+
+func TestSendMessage_Alias(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	host, port := server.addr()
+	s := NewSMTPService("billing@example.com", "me@example.com", testSMTPConfig(host, port), "secret", auth.AuthMethodPlain)
+
+	if _, err := s.SendMessage(&Message{To: []string{"client@example.com"}, Subject: "Invoice", Body: "hello"}); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.login != "me@example.com" {
+		t.Errorf("login = %q, want the account owning the alias", server.login)
+	}
+	if server.from != "billing@example.com" || !strings.Contains(server.data, "From: billing@example.com") {
+		t.Errorf("envelope from = %q, want the alias in the envelope and header:\n%s", server.from, server.data)
+	}
+}
+
+func TestSendMessage_Rejected(t *testing.T) {
+	server := newFakeSMTPServer(t, func(s *fakeSMTPServer) { s.rejectTo = "nobody@example.com" })
+	host, port := server.addr()
+	s := NewSMTPService("me@example.com", "", testSMTPConfig(host, port), "secret", auth.AuthMethodPlain)
+
+	_, err := s.SendMessage(&Message{To: []string{"nobody@example.com"}, Subject: "Invoice", Body: "hello"})
+	if err == nil {
+		t.Fatal("SendMessage() error = nil, want rejection")
+	}
+	var reply *textproto.Error
+	if !errors.As(err, &reply) || reply.Code != 550 {
+		t.Errorf("SendMessage() error = %v, want a 550 reply", err)
+	}
+}
+
+func TestSendMessage_TLSModes(t *testing.T) {
+	tests := []struct {
+		name       string
+		implicit   bool // server uses implicit TLS, otherwise it offers STARTTLS
+		plain      bool // server offers no TLS at all
+		mode       TLSMode
+		serverName string
+		trust      bool // client trusts the server certificate
+		wantTLS    bool
+		errPart    string
+	}{
+		{name: "implicit", implicit: true, mode: TLSModeImplicit, trust: true, wantTLS: true},
+		{name: "implicit with server name", implicit: true, mode: TLSModeImplicit, serverName: "mail.example.test", trust: true, wantTLS: true},
+		{name: "implicit with wrong server name", implicit: true, mode: TLSModeImplicit, serverName: "other.example.test", trust: true, errPart: "certificate"},
+		{name: "implicit untrusted", implicit: true, mode: TLSModeImplicit, errPart: "certificate"},
+		{name: "starttls required", mode: TLSModeStartTLS, trust: true, wantTLS: true},
+		{name: "starttls required but not offered", plain: true, mode: TLSModeStartTLS, errPart: "STARTTLS"},
+		{name: "starttls untrusted", mode: TLSModeStartTLS, errPart: "certificate"},
+		{name: "opportunistic upgrades", mode: TLSModeOpportunistic, trust: true, wantTLS: true},
+		{name: "opportunistic without offer", plain: true, mode: TLSModeOpportunistic},
+		{name: "none ignores offer", mode: TLSModeNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var server *fakeSMTPServer
+			var pool *x509.CertPool
+			if tt.plain {
+				server = newFakeSMTPServer(t)
+			} else {
+				server, pool = newFakeSMTPServerTLS(t, tt.implicit)
+			}
+			host, port := server.addr()
+			cfg := testSMTPConfig(host, port)
+			cfg.TLSMode = tt.mode
+			cfg.ServerName = tt.serverName
+			if tt.trust {
+				cfg.RootCAs = pool
+			}
+			s := NewSMTPService("me@example.com", "", cfg, "secret", auth.AuthMethodPlain)
+
+			_, err := s.SendMessage(&Message{To: []string{"client@example.com"}, Subject: "Invoice", Body: "hello"})
+			if tt.errPart != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errPart) {
+					t.Fatalf("SendMessage() error = %v, want error containing %q", err, tt.errPart)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SendMessage() error = %v", err)
+			}
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			if server.sawTLS != tt.wantTLS {
+				t.Errorf("message received over TLS = %v, want %v", server.sawTLS, tt.wantTLS)
+			}
+		})
+	}
+}
+
+func TestSendMessage_CommandTimeout(t *testing.T) {
+	server := newFakeSMTPServer(t, func(s *fakeSMTPServer) { s.silent = true })
+	host, port := server.addr()
+	cfg := testSMTPConfig(host, port)
+	cfg.CommandTimeout = 100 * time.Millisecond
+	s := NewSMTPService("me@example.com", "", cfg, "secret", auth.AuthMethodPlain)
+
+	start := time.Now()
+	_, err := s.SendMessage(&Message{To: []string{"client@example.com"}, Subject: "Invoice", Body: "hello"})
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("SendMessage() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("SendMessage() took %v, want it to give up after the command timeout", elapsed)
+	}
+}
+
+func TestSMTPConfigValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   SMTPConfig
+		wantMode TLSMode
+		errPart  string
+	}{
+		{name: "port 465 defaults to implicit", config: SMTPConfig{Host: "smtp.example.com", Port: 465}, wantMode: TLSModeImplicit},
+		{name: "port 587 defaults to opportunistic", config: SMTPConfig{Host: "smtp.example.com", Port: 587}, wantMode: TLSModeOpportunistic},
+		{name: "explicit mode is kept", config: SMTPConfig{Host: "smtp.example.com", Port: 465, TLSMode: TLSModeStartTLS}, wantMode: TLSModeStartTLS},
+		{name: "unknown mode", config: SMTPConfig{Host: "smtp.example.com", Port: 25, TLSMode: "ssl"}, errPart: "invalid SMTP TLS mode"},
+		{name: "missing host", config: SMTPConfig{Port: 25}, errPart: "host"},
+		{name: "invalid port", config: SMTPConfig{Host: "smtp.example.com", Port: 70000}, errPart: "port"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.errPart != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errPart) {
+					t.Fatalf("Validate() error = %v, want error containing %q", err, tt.errPart)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if tt.config.TLSMode != tt.wantMode {
+				t.Errorf("TLSMode = %q, want %q", tt.config.TLSMode, tt.wantMode)
+			}
+			if tt.config.DialTimeout != DefaultSMTPDialTimeout || tt.config.CommandTimeout != DefaultSMTPCommandTimeout {
+				t.Errorf("timeouts = %v/%v, want defaults", tt.config.DialTimeout, tt.config.CommandTimeout)
+			}
+		})
+	}
+}
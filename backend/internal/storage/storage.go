@@ -1,21 +1,34 @@
 package storage
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/mail"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"go-invoice/internal/invoice"
+	"go-invoice/internal/services"
 )
 
+// DefaultEmailTemplateID is the ID of the built-in email template created on first run
+const DefaultEmailTemplateID = "default"
+
 type StorageDir struct {
 	Root           string
 	Clients        string
 	Providers      string
 	Invoices       string
-	Config         string
+	Config         string // application settings, e.g. the reminder schedule
 	EmailTemplates string
+	Outbox         string // queued outgoing emails, see package outbox
+	EmailLog       string // send attempts per invoice, see package emaillog
+	Sessions       string // encrypted login sessions, see auth.FileSessionStore
+	PDFCache       string // rendered PDFs, see package pdfcache
+	SigningKeys    string // encrypted PDF signing keys, see pdfsign.KeyStore
+	SenderAccounts string // sealed OAuth2 credentials of the providers' sender accounts
 }
 
 // NewStorageDir initializes the storage directory structure.
@@ -27,7 +40,14 @@ func NewStorageDir(rootDir string) (*StorageDir, error) {
 		Clients:        filepath.Join(rootDir, "clients"),
 		Providers:      filepath.Join(rootDir, "providers"),
 		Invoices:       filepath.Join(rootDir, "invoices"),
+		Config:         filepath.Join(rootDir, "config"),
 		EmailTemplates: filepath.Join(rootDir, "email_templates"),
+		Outbox:         filepath.Join(rootDir, "outbox"),
+		EmailLog:       filepath.Join(rootDir, "email_log"),
+		Sessions:       filepath.Join(rootDir, "sessions"),
+		PDFCache:       filepath.Join(rootDir, "pdf_cache"),
+		SigningKeys:    filepath.Join(rootDir, "signing_keys"),
+		SenderAccounts: filepath.Join(rootDir, "sender_accounts"),
 	}
 
 	// Create a list of all paths that must exist.
@@ -36,7 +56,14 @@ func NewStorageDir(rootDir string) (*StorageDir, error) {
 		storage.Clients,
 		storage.Providers,
 		storage.Invoices,
+		storage.Config,
 		storage.EmailTemplates,
+		storage.Outbox,
+		storage.EmailLog,
+		storage.Sessions,
+		storage.PDFCache,
+		storage.SigningKeys,
+		storage.SenderAccounts,
 	}
 
 	// Loop and create each one, using the correct tool (MkdirAll).
@@ -51,7 +78,7 @@ func NewStorageDir(rootDir string) (*StorageDir, error) {
 	}
 
 	// Create default email template if it doesn't exist
-	defaultTemplatePath := filepath.Join(storage.EmailTemplates, "default.json")
+	defaultTemplatePath := filepath.Join(storage.EmailTemplates, DefaultEmailTemplateID+".json")
 	if _, err := os.Stat(defaultTemplatePath); os.IsNotExist(err) {
 		defaultTemplate := NewDefaultEmailTemplateData()
 		if err := defaultTemplate.SaveToFile(defaultTemplatePath); err != nil {
@@ -67,7 +94,13 @@ type ClientData struct {
 	invoice.Party
 	TaxRate         float32 `json:"tax_rate"`
 	EmailTarget     string  `json:"email_target"`
+	EmailCc         string  `json:"email_cc,omitempty"`       // (optional) comma separated CC addresses
+	EmailBcc        string  `json:"email_bcc,omitempty"`      // (optional) comma separated BCC addresses
+	EmailReplyTo    string  `json:"email_reply_to,omitempty"` // (optional) Reply-To address
 	EmailTemplateId string  `json:"email_template_id"`
+	RemindersOptOut bool    `json:"reminders_opt_out,omitempty"` // never send payment reminders to this client
+
+	PDF *services.PageSettings `json:"pdf,omitempty"` // (optional) paper of this client's PDFs, overrides the provider's
 }
 
 // FromJSON deserializes client data from JSON
@@ -77,7 +110,7 @@ func NewClientDataFromJSON(data []byte) (*ClientData, error) {
 		return nil, fmt.Errorf("failed to unmarshal client JSON: %v", err)
 	}
 	if c.EmailTemplateId == "" {
-		c.EmailTemplateId = "default"
+		c.EmailTemplateId = DefaultEmailTemplateID
 	}
 	return &c, nil
 }
@@ -90,10 +123,113 @@ func (c *ClientData) HasRequiredFields() bool {
 	return c.Party.HasRequiredFields()
 }
 
+// Validate checks the PDF page settings
+func (c *ClientData) Validate() error {
+	if c.PDF != nil {
+		return c.PDF.Validate()
+	}
+	return nil
+}
+
+func (c *ClientData) GetParty() invoice.Party {
+	return c.Party
+}
+
+// ApplyTo copies the client details, tax rate and email settings into the invoice
+func (c *ClientData) ApplyTo(inv *invoice.Invoice) {
+	inv.Client = c.Party
+	inv.EmailTarget = c.EmailTarget
+	inv.EmailCc = c.EmailCc
+	inv.EmailBcc = c.EmailBcc
+	inv.EmailReplyTo = c.EmailReplyTo
+	if c.EmailTemplateId != "" {
+		inv.EmailTemplateID = c.EmailTemplateId
+	}
+	inv.Pricing.TaxRate = c.TaxRate
+	inv.Pricing.Update(inv.Pricing.Subtotal)
+}
+
+// LoadClientData reads the client stored under id
+func LoadClientData(clientRoot, id string) (*ClientData, error) {
+	var c ClientData
+	if err := loadJSON(filepath.Join(clientRoot, id+".json"), &c); err != nil {
+		return nil, err
+	}
+	c.SetID(id)
+	return &c, nil
+}
+
 // ProviderData represents service provider data as stored on disk
 type ProviderData struct {
 	invoice.Party
-	Payment invoice.PaymentInfo `json:"payment_info"`
+	Payment invoice.PaymentInfo    `json:"payment_info"`
+	Logo    string                 `json:"logo,omitempty"`    // (optional) logo as a base64 data URI, embedded in HTML emails
+	Sender  *SenderIdentity        `json:"sender,omitempty"`  // (optional) who invoice emails of this provider are sent from
+	PDF     *services.PageSettings `json:"pdf,omitempty"`     // (optional) paper of this provider's PDFs
+	Signing *SigningSettings       `json:"signing,omitempty"` // (optional) digital signature of this provider's PDFs
+}
+
+// SigningSettings configures the digital signature of a provider's PDFs.
+// The signing key is uploaded separately and stored encrypted, see pdfsign.KeyStore.
+type SigningSettings struct {
+	Enabled  bool   `json:"enabled"`
+	Visible  bool   `json:"visible,omitempty"`  // draw a signature block on the last page
+	Reason   string `json:"reason,omitempty"`   // e.g. "Invoice issued by Acme Corp"
+	Location string `json:"location,omitempty"` // e.g. "Sydney, Australia"
+}
+
+// Validate checks the texts written into the signature
+func (s *SigningSettings) Validate() error {
+	for _, f := range []struct{ field, value string }{
+		{"reason", s.Reason},
+		{"location", s.Location},
+	} {
+		if strings.ContainsAny(f.value, "\r\n") {
+			return fmt.Errorf("signing %s must be a single line", f.field)
+		}
+		if len(f.value) > 200 {
+			return fmt.Errorf("signing %s must be at most 200 characters", f.field)
+		}
+	}
+	return nil
+}
+
+// SenderIdentity configures the sender of a provider's invoice emails.
+// Empty fields fall back to the server settings: SMTP_FROM or the signed in
+// account, MAIL_TRANSPORT, and the invoice's reply-to.
+type SenderIdentity struct {
+	FromName  string `json:"from_name,omitempty"`  // display name, e.g. "Acme Billing"
+	FromEmail string `json:"from_email,omitempty"` // with OAuth2 it must be the account or one of its aliases
+	ReplyTo   string `json:"reply_to,omitempty"`
+	Signature string `json:"signature,omitempty"` // plain text appended to the email body
+	Transport string `json:"transport,omitempty"` // smtp, sendmail or file, see services.Transport
+	Account   string `json:"account,omitempty"`   // email of the OAuth2 account to send with, it must save the provider while signed in
+}
+
+// Validate checks the addresses and transport of the identity
+func (s *SenderIdentity) Validate() error {
+	addresses := []struct{ field, addr string }{
+		{"from_email", s.FromEmail},
+		{"reply_to", s.ReplyTo},
+		{"account", s.Account},
+	}
+	for _, a := range addresses {
+		if a.addr == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(a.addr); err != nil {
+			return fmt.Errorf("invalid sender %s '%s': %v", a.field, a.addr, err)
+		}
+	}
+	if strings.ContainsAny(s.FromName, "\r\n") {
+		return fmt.Errorf("sender from_name must be a single line")
+	}
+	if s.Transport != "" {
+		if _, err := services.ParseTransport(s.Transport); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // NewProviderDataFromJSON deserializes provider data from JSON
@@ -113,16 +249,109 @@ func (p *ProviderData) HasRequiredFields() bool {
 	return p.Party.HasRequiredFields() && p.Payment.HasRequiredFields()
 }
 
+// Validate checks the optional settings of the provider
+func (p *ProviderData) Validate() error {
+	if p.Sender != nil {
+		if err := p.Sender.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Signing != nil {
+		if err := p.Signing.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.PDF != nil {
+		return p.PDF.Validate()
+	}
+	return nil
+}
+
+func (p *ProviderData) GetParty() invoice.Party {
+	return p.Party
+}
+
+// ApplyTo copies the provider details and payment information into the invoice
+func (p *ProviderData) ApplyTo(inv *invoice.Invoice) {
+	inv.Provider = p.Party
+	inv.Payment = p.Payment
+}
+
+// DecodeLogo returns the content type and image data of the provider logo.
+// ok is false when the provider has no logo.
+func (p *ProviderData) DecodeLogo() (contentType string, data []byte, ok bool, err error) {
+	if p.Logo == "" {
+		return "", nil, false, nil
+	}
+	// data:[<mediatype>][;base64],<data>
+	rest, isDataURI := strings.CutPrefix(p.Logo, "data:")
+	meta, encoded, found := strings.Cut(rest, ",")
+	if !isDataURI || !found || !strings.HasSuffix(meta, ";base64") {
+		return "", nil, false, fmt.Errorf("logo is not a base64 data URI")
+	}
+	contentType = strings.TrimSuffix(meta, ";base64")
+	if !strings.HasPrefix(contentType, "image/") {
+		return "", nil, false, fmt.Errorf("logo has unsupported media type '%s'", contentType)
+	}
+	data, err = base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("failed to decode logo: %w", err)
+	}
+	return contentType, data, true, nil
+}
+
+// LoadProviderData reads the provider stored under id
+func LoadProviderData(providerRoot, id string) (*ProviderData, error) {
+	var p ProviderData
+	if err := loadJSON(filepath.Join(providerRoot, id+".json"), &p); err != nil {
+		return nil, err
+	}
+	p.SetID(id)
+	return &p, nil
+}
+
+// loadJSON reads and decodes a JSON file. A missing file yields an error
+// matching os.ErrNotExist.
+func loadJSON(filePath string, data any) error {
+	fileData, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read '%s': %w", filePath, err)
+	}
+	if err := json.Unmarshal(fileData, data); err != nil {
+		return fmt.Errorf("failed to decode '%s': %w", filePath, err)
+	}
+	return nil
+}
+
 type EmailTemplate struct {
-	Id      string `json:"id"`
-	Name    string `json:"name"`
-	Subject string `json:"subject"`
-	Body    string `json:"body"`
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Subject  string `json:"subject"`
+	Body     string `json:"body"`                // plain text body
+	HTMLBody string `json:"html_body,omitempty"` // (optional) HTML alternative of the body
+}
+
+func (et *EmailTemplate) SetID(id string) {
+	et.Id = id
+}
+
+func (et *EmailTemplate) HasRequiredFields() bool {
+	return et.Name != "" && et.Subject != "" && et.Body != ""
+}
+
+// LoadEmailTemplate reads the email template stored under id
+func LoadEmailTemplate(templateRoot, id string) (*EmailTemplate, error) {
+	var et EmailTemplate
+	if err := loadJSON(filepath.Join(templateRoot, id+".json"), &et); err != nil {
+		return nil, err
+	}
+	et.SetID(id)
+	return &et, nil
 }
 
 func NewDefaultEmailTemplateData() *EmailTemplate {
 	return &EmailTemplate{
-		Id:      "default",
+		Id:      DefaultEmailTemplateID,
 		Name:    "Default Invoice Email",
 		Subject: "Invoice from {{PROVIDER_NAME}} ({{INVOICE_ID}})",
 		Body:    "Please find the attached invoice for the services rendered.\n\nClient name: {{CLIENT_NAME}}\nSubcontractor email: {{PROVIDER_EMAIL}}\nService type: {{SERVICE_TYPE}}\n\nKind regards,\n{{PROVIDER_NAME}}",
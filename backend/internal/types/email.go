@@ -1,9 +1,14 @@
 package types
 
 type EmailMessage struct {
-	To      []string `json:"to"`
-	Subject string   `json:"subject"`
-	Body    string   `json:"body"`
+	To         []string `json:"to"`
+	Cc         []string `json:"cc,omitempty"`       // defaults to the invoice's CC addresses when omitted
+	Bcc        []string `json:"bcc,omitempty"`      // defaults to the invoice's BCC addresses when omitted
+	ReplyTo    string   `json:"reply_to,omitempty"` // defaults to the invoice's Reply-To, then the provider email
+	Subject    string   `json:"subject"`
+	Body       string   `json:"body"`
+	HTMLBody   string   `json:"html_body,omitempty"`   // (optional) HTML alternative of the body
+	TemplateID string   `json:"template_id,omitempty"` // rendered server-side when subject and body are empty
 }
 
 func NewEmailMessage(to []string, subject, body string) EmailMessage {
@@ -0,0 +1,50 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Period is a calendar duration expressed in years, months and days
+type Period struct {
+	Years  int `json:"years,omitempty"`
+	Months int `json:"months,omitempty"`
+	Days   int `json:"days,omitempty"`
+}
+
+// ParsePeriod parses a period such as "7d", "2w", "1m" or "1y".
+// Units are d (days), w (weeks), m (months) and y (years). The amount may be negative.
+func ParsePeriod(s string) (Period, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return Period{}, fmt.Errorf("invalid period %q", s)
+	}
+	amount, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil {
+		return Period{}, fmt.Errorf("invalid period amount %q: %v", s, err)
+	}
+
+	switch s[len(s)-1] {
+	case 'd':
+		return Period{Days: amount}, nil
+	case 'w':
+		return Period{Days: amount * 7}, nil
+	case 'm':
+		return Period{Months: amount}, nil
+	case 'y':
+		return Period{Years: amount}, nil
+	default:
+		return Period{}, fmt.Errorf("invalid period unit in %q, expected one of d, w, m, y", s)
+	}
+}
+
+// IsZero reports whether the period has no length
+func (p Period) IsZero() bool {
+	return p.Years == 0 && p.Months == 0 && p.Days == 0
+}
+
+// AddPeriod adds the period to the Date and returns a new Date instance
+func (d *Date) AddPeriod(p Period) Date {
+	return d.AddDate(p.Years, p.Months, p.Days)
+}
@@ -9,4 +9,5 @@ type UserSessionData struct {
 	RefreshToken string
 	ExpiresAt    time.Time
 	AvatarURL    string
+	Provider     string // OAuth2 provider the tokens belong to, empty means google
 }
@@ -8,15 +8,20 @@ import (
 	"go-invoice/internal/api"
 	"go-invoice/internal/auth"
 	"go-invoice/internal/crypto"
+	"go-invoice/internal/render"
+	"go-invoice/internal/services"
 	"go-invoice/internal/storage"
 	"go-invoice/internal/types"
 	"go-invoice/internal/ui"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -24,6 +29,9 @@ import (
 // Version is set via ldflags during build
 var Version = "dev"
 
+// shutdownTimeout bounds how long running requests and PDF renders may take on stop
+const shutdownTimeout = 30 * time.Second
+
 func init() {
 	gob.Register(types.UserSessionData{})
 }
@@ -44,15 +52,27 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Initialize storage
+	storageDir, err := storage.NewStorageDir(storagePath)
+	if err != nil {
+		slog.Error("Failed to initialize storage", "error", err)
+		os.Exit(1)
+	}
+
 	// Set up session
-	sessionConfig, err := setupSession()
+	sessionConfig, err := setupSession(storageDir)
 	if err != nil {
 		slog.Error("Failed to set up session", "error", err)
 		os.Exit(1)
 	}
+	sessionStore, err := auth.ConfigureSession(*sessionConfig, storageDir.Sessions)
+	if err != nil {
+		slog.Error("Failed to set up session store", "error", err)
+		os.Exit(1)
+	}
 
 	// Set up authentication
-	authMethod, err := setupAuth(publicURL, sessionConfig)
+	authMethod, err := setupAuth(publicURL)
 	if err != nil {
 		slog.Error("Failed to set up authentication", "error", err)
 		os.Exit(1)
@@ -61,12 +81,17 @@ func main() {
 	// Create the HTTP router (mux)
 	mux := http.NewServeMux()
 
-	// Initialize storage
-	storageDir, err := storage.NewStorageDir(storagePath)
+	// Stop background work and the server on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Browsers for PDF rendering, started on first use
+	chromePoolConfig, err := services.LoadChromePoolConfig()
 	if err != nil {
-		slog.Error("Failed to initialize storage", "error", err)
+		slog.Error("Failed to load chrome pool configuration", "error", err)
 		os.Exit(1)
 	}
+	chromePool := services.NewChromePool(chromePoolConfig)
 
 	// Initialize API handler
 	// CHROME_RENDER_URL is for Docker: Chrome container needs to access app via network
@@ -74,14 +99,46 @@ func main() {
 	if localBaseURL == "" {
 		localBaseURL = fmt.Sprintf("http://127.0.0.1:%d", port)
 	}
+	renderers, err := render.Load(chromePool, localBaseURL)
+	if err != nil {
+		slog.Error("Failed to set up PDF rendering", "error", err)
+		os.Exit(1)
+	}
 	apiHandler := api.Handler{
-		Context:         context.Background(),
+		Context:         ctx,
 		StorageDir:      *storageDir,
 		FrontendBaseURL: frontendURL,
 		LocalBaseURL:    localBaseURL,
 		EmailAuthMethod: authMethod,
 		Version:         Version,
+		Sessions:        sessionStore,
+		PDF:             renderers,
+	}
+	if err := apiHandler.BuildSearchIndex(); err != nil {
+		slog.Error("Failed to build search index", "error", err)
+		os.Exit(1)
+	}
+	if err := apiHandler.OpenPDFCache(); err != nil {
+		slog.Error("Failed to open PDF cache", "error", err)
+		os.Exit(1)
+	}
+	if err := apiHandler.OpenExports(); err != nil {
+		slog.Error("Failed to set up invoice exports", "error", err)
+		os.Exit(1)
+	}
+	if err := apiHandler.OpenSigning(sessionConfig.Key); err != nil {
+		slog.Error("Failed to open PDF signing keys", "error", err)
+		os.Exit(1)
 	}
+	if err := apiHandler.StartOutbox(sessionConfig.Key); err != nil {
+		slog.Error("Failed to start email outbox", "error", err)
+		os.Exit(1)
+	}
+	if err := apiHandler.StartReminders(); err != nil {
+		slog.Error("Failed to start payment reminders", "error", err)
+		os.Exit(1)
+	}
+	go sessionStore.RunCleanup(apiHandler.Context, time.Hour)
 	apiHandler.RegisterRoutesV1(mux)
 
 	// Initialize embedded UI handler
@@ -100,6 +157,7 @@ func main() {
 		"frontend_url", frontendURL,
 		"dev_mode", isDevMode,
 		"storage_path", storagePath,
+		"pdf_renderer", renderers.Default,
 	)
 
 	listenAddr := fmt.Sprintf(":%d", port)
@@ -109,10 +167,27 @@ func main() {
 	)
 
 	corsHandler := api.WithCORS(mux, []string{frontendURL, localBaseURL})
-	if err := http.ListenAndServe(listenAddr, corsHandler); err != nil {
+	server := &http.Server{Addr: listenAddr, Handler: corsHandler}
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- server.ListenAndServe() }()
+
+	select {
+	case err := <-serverErr:
 		slog.Error("Server failed to start", "error", err)
 		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to shut down the server", "error", err)
+	}
+	if err := chromePool.Close(shutdownCtx); err != nil {
+		slog.Error("Failed to shut down chrome", "error", err)
 	}
+	slog.Info("Server stopped")
 }
 
 // loadEnv handles loading the .env file.
@@ -177,73 +252,159 @@ func loadAppConfig(isDevMode bool, dbPathFromFlag string) (
 	return port, publicURL, frontendURL, storagePath, nil
 }
 
-// setupAuth determines and configures the authentication method.
-func setupAuth(publicURL string, sessionConfig *auth.SessionConfig) (auth.AuthMethod, error) {
-	var authMethod auth.AuthMethod
+// setupAuth configures the SMTP login chosen by MAIL_AUTH and fails when its
+// credentials are missing. Without MAIL_AUTH the login is inferred from the
+// credentials that are set.
+func setupAuth(publicURL string) (auth.AuthMethod, error) {
+	// the sendmail and file transports deliver locally and need no login
+	transport, err := services.ParseTransport(os.Getenv("MAIL_TRANSPORT"))
+	if err != nil {
+		return auth.AuthMethodNone, err
+	}
+	if transport != services.TransportSMTP {
+		slog.Info("Using local mail transport, emails are sent from SMTP_FROM.", "transport", transport)
+		return auth.AuthMethodLocal, nil
+	}
 
 	// Read auth-specific env vars here
 	googleOAuthClientID := os.Getenv("GOOGLE_OAUTH_CLIENT_ID")
 	googleOAuthClientSecret := os.Getenv("GOOGLE_OAUTH_CLIENT_SECRET")
+	microsoftOAuthClientID := os.Getenv("MICROSOFT_OAUTH_CLIENT_ID")
+	microsoftOAuthClientSecret := os.Getenv("MICROSOFT_OAUTH_CLIENT_SECRET")
 	smtpPassword := os.Getenv("SMTP_PASSWORD")
 
 	googleAuthAvailable := googleOAuthClientID != "" && googleOAuthClientSecret != ""
+	microsoftAuthAvailable := microsoftOAuthClientID != "" && microsoftOAuthClientSecret != ""
+	oauthAvailable := googleAuthAvailable || microsoftAuthAvailable
 	smtpAuthAvailable := smtpPassword != ""
 
-	if !googleAuthAvailable && !smtpAuthAvailable {
-		slog.Warn("Neither Google OAuth nor SMTP credentials are set. Email functionality will be disabled.")
-		authMethod = auth.AuthMethodNone
-	} else if smtpAuthAvailable {
-		slog.Warn("Using SMTP_PASSWORD is not recommended for security reasons. Prefer Google OAuth.")
-		authMethod = auth.AuthMethodPlain
-	} else {
-		// Google OAuth is available and preferred
-		slog.Info("Google OAuth credentials loaded.")
-		authMethod = auth.AuthMethodOAuth2
-
-		callbackURL := fmt.Sprintf("%s/api/v1/mailer/auth/google/callback", publicURL)
+	authMethod, err := auth.ParseAuthMethod(os.Getenv("MAIL_AUTH"))
+	if err != nil {
+		return auth.AuthMethodNone, err
+	}
+	explicit := authMethod != ""
+	if !explicit {
+		// inferred as before MAIL_AUTH existed, SMTP_PASSWORD wins over OAuth
+		switch {
+		case smtpAuthAvailable:
+			authMethod = auth.AuthMethodPlain
+		case oauthAvailable:
+			authMethod = auth.AuthMethodOAuth2
+		default:
+			authMethod = auth.AuthMethodNone
+		}
+		if authMethod != auth.AuthMethodNone {
+			slog.Warn("MAIL_AUTH not set, inferred from the credentials. This is deprecated, set MAIL_AUTH to choose explicitly.", "mail_auth", authMethod)
+		}
+	}
 
-		auth.ConfigureGoogleOAuth2(
-			googleOAuthClientID,
-			googleOAuthClientSecret,
-			callbackURL,
-			*sessionConfig,
-		)
+	switch authMethod {
+	case auth.AuthMethodNone:
+		slog.Warn("Email login is disabled (MAIL_AUTH=none or no credentials set). Email functionality will be disabled.")
+	case auth.AuthMethodPlain:
+		if explicit && (!smtpAuthAvailable || strings.TrimSpace(os.Getenv("SMTP_FROM")) == "") {
+			return auth.AuthMethodNone, fmt.Errorf("MAIL_AUTH=plain requires SMTP_FROM and SMTP_PASSWORD")
+		}
+		slog.Warn("Using SMTP_PASSWORD is not recommended for security reasons. Prefer OAuth.")
+	case auth.AuthMethodOAuth2:
+		if !oauthAvailable {
+			return auth.AuthMethodNone, fmt.Errorf("MAIL_AUTH=oauth2 requires GOOGLE_OAUTH_CLIENT_ID and GOOGLE_OAUTH_CLIENT_SECRET, or MICROSOFT_OAUTH_CLIENT_ID and MICROSOFT_OAUTH_CLIENT_SECRET")
+		}
+		// several providers can be offered at login
+		callbackURL := func(provider string) string {
+			return fmt.Sprintf("%s/api/v1/mailer/auth/%s/callback", publicURL, provider)
+		}
+		if googleAuthAvailable {
+			slog.Info("Google OAuth credentials loaded.")
+			auth.ConfigureGoogleOAuth2(
+				googleOAuthClientID,
+				googleOAuthClientSecret,
+				callbackURL(auth.ProviderGoogle),
+			)
+		}
+		if microsoftAuthAvailable {
+			tenant := os.Getenv("MICROSOFT_OAUTH_TENANT")
+			if tenant == "" {
+				tenant = "common"
+			}
+			slog.Info("Microsoft OAuth credentials loaded.", "tenant", tenant)
+			auth.ConfigureMicrosoftOAuth2(
+				microsoftOAuthClientID,
+				microsoftOAuthClientSecret,
+				tenant,
+				callbackURL(auth.ProviderMicrosoft),
+			)
+		}
 	}
 
 	return authMethod, nil
 }
 
 // setupSession configures session parameters from the environment.
-func setupSession() (*auth.SessionConfig, error) {
-	var key []byte
-	keyStr := os.Getenv("SESSION_SECRET")
-	if keyStr == "" {
-		slog.Info("SESSION_SECRET not set, generating a temporary random key.")
-		var err error
-		key, err = crypto.GenerateSecureBytes(32)
-		if err != nil {
-			return nil, fmt.Errorf("error generating secure bytes: %w", err)
-		}
-	} else {
-		key = []byte(keyStr)
+func setupSession(storageDir *storage.StorageDir) (*auth.SessionConfig, error) {
+	isProd := strings.ToLower(os.Getenv("IS_PROD")) == "true"
+	key, err := loadSessionKey(storageDir, isProd)
+	if err != nil {
+		return nil, err
 	}
 
 	var maxAge int
 	ageStr := os.Getenv("SESSION_MAX_AGE")
-	maxAge, err := strconv.Atoi(ageStr)
+	maxAge, err = strconv.Atoi(ageStr)
 	if ageStr == "" || err != nil {
 		maxAge = 86400 * 30 // 30 days default
 	}
 
-	var isProd bool
-	prodStr := strings.ToLower(os.Getenv("IS_PROD"))
-	if prodStr == "true" {
-		isProd = true
-	}
-
 	return &auth.SessionConfig{
 		Key:    key,
 		MaxAge: maxAge,
 		IsProd: isProd,
 	}, nil
 }
+
+// loadSessionKey returns the key sealing the sessions, the outbox credentials
+// and the PDF signing keys, all kept in the storage directory. A key stored
+// there too would let anyone with a copy of the data decrypt them, so in
+// production it comes from SESSION_SECRET or a SESSION_KEY_FILE outside the
+// storage directory, and without either a temporary in-memory key is used.
+// Development falls back to a key generated in the storage config directory.
+func loadSessionKey(storageDir *storage.StorageDir, isProd bool) ([]byte, error) {
+	if secret := os.Getenv("SESSION_SECRET"); secret != "" {
+		return []byte(secret), nil
+	}
+	generated := filepath.Join(storageDir.Config, "session.key")
+	keyPath := strings.TrimSpace(os.Getenv("SESSION_KEY_FILE"))
+	switch {
+	case keyPath == "" && isProd:
+		slog.Error("SESSION_SECRET and SESSION_KEY_FILE not set with IS_PROD=true, using a temporary key kept in memory only. "+
+			"Sessions end and sealed outbox credentials and signing keys cannot be decrypted after a restart. "+
+			"Set SESSION_SECRET or SESSION_KEY_FILE outside the storage directory.", "storage_key", generated)
+		key, err := crypto.GenerateSecureBytes(32)
+		if err != nil {
+			return nil, fmt.Errorf("error generating secure bytes: %w", err)
+		}
+		return key, nil
+	case keyPath == "":
+		slog.Warn("SESSION_SECRET not set, using a key stored with the data it encrypts. Set SESSION_SECRET or SESSION_KEY_FILE in production.", "path", generated)
+		keyPath = generated
+	case isProd && insideDir(keyPath, storageDir.Root):
+		return nil, fmt.Errorf("SESSION_KEY_FILE %s is inside the storage directory %s, "+
+			"keep it outside so a copy of the data cannot be decrypted", keyPath, storageDir.Root)
+	}
+	key, err := crypto.LoadOrCreateKey(keyPath, 32)
+	if err != nil {
+		return nil, fmt.Errorf("error loading session key: %w", err)
+	}
+	return key, nil
+}
+
+// insideDir reports whether path is dir or below it
+func insideDir(path, dir string) bool {
+	absPath, err1 := filepath.Abs(path)
+	absDir, err2 := filepath.Abs(dir)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -6,3 +6,6 @@ export { default as ErrorAlert } from './error-alert.svelte';
 export { default as ConfirmDialog } from './confirm-dialog.svelte';
 export { default as EmailDialog } from './email-dialog.svelte';
 export { default as EmailAuthCard } from './email-auth-card.svelte';
+export { default as PageSettingsFields } from './page-settings-fields.svelte';
+export { default as SigningKeyCard } from './signing-key-card.svelte';
+export { default as SignatureVerifyCard } from './signature-verify-card.svelte';
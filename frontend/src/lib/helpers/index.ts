@@ -4,3 +4,4 @@ export * from './invoice-calculations';
 export * from './invoice-generators';
 export * from './date-helpers';
 export * from './validators';
+export * from './page-settings';
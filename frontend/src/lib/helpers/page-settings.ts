@@ -0,0 +1,69 @@
+/**
+ * Page settings - Editing the PDF paper of providers and clients
+ */
+
+import type { Margins, PageSettings } from '@/types/invoice';
+
+export const MAX_MARGIN_MM = 50;
+
+// PageSettingsForm is the editable state of PageSettings, empty fields inherit
+export interface PageSettingsForm {
+	paper_size: '' | NonNullable<PageSettings['paper_size']>;
+	orientation: '' | NonNullable<PageSettings['orientation']>;
+	margins: { [K in keyof Margins]: number | null | undefined };
+}
+
+/**
+ * Create the form state of stored page settings
+ * @param settings - Page settings of a provider or client
+ * @returns Form state with empty fields for unset settings
+ */
+export function toPageSettingsForm(settings?: PageSettings): PageSettingsForm {
+	return {
+		paper_size: settings?.paper_size ?? '',
+		orientation: settings?.orientation ?? '',
+		margins: {
+			top: settings?.margins?.top,
+			right: settings?.margins?.right,
+			bottom: settings?.margins?.bottom,
+			left: settings?.margins?.left
+		}
+	};
+}
+
+function isSet(value: number | null | undefined): value is number {
+	return typeof value === 'number' && !Number.isNaN(value);
+}
+
+/**
+ * Validate the margins of the form, margins are set for all sides or none
+ * @param form - Page settings form state
+ * @returns Error message, undefined when valid
+ */
+export function validatePageSettingsForm(form: PageSettingsForm): string | undefined {
+	const margins = Object.values(form.margins);
+	const set = margins.filter(isSet);
+	if (set.length > 0 && set.length < margins.length) {
+		return 'Set all four margins or leave them all empty';
+	}
+	if (set.some((m) => m < 0 || m > MAX_MARGIN_MM)) {
+		return `Margins must be between 0 and ${MAX_MARGIN_MM}mm`;
+	}
+	return undefined;
+}
+
+/**
+ * Convert valid form state back to page settings
+ * @param form - Page settings form state
+ * @returns Page settings, undefined when nothing is set so the defaults apply
+ */
+export function fromPageSettingsForm(form: PageSettingsForm): PageSettings | undefined {
+	const settings: PageSettings = {};
+	if (form.paper_size) settings.paper_size = form.paper_size;
+	if (form.orientation) settings.orientation = form.orientation;
+	const { top, right, bottom, left } = form.margins;
+	if (isSet(top) && isSet(right) && isSet(bottom) && isSet(left)) {
+		settings.margins = { top, right, bottom, left };
+	}
+	return Object.keys(settings).length > 0 ? settings : undefined;
+}
@@ -41,6 +41,15 @@ export function isValidPhone(phone: string): boolean {
 	return digits.length >= 10;
 }
 
+/**
+ * Validate a country code (ISO 3166-1 alpha-2, e.g. AU)
+ * @param country - Country code to validate
+ * @returns True if valid country code format
+ */
+export function isValidCountryCode(country: string): boolean {
+	return /^[A-Za-z]{2}$/.test(country.trim());
+}
+
 /**
  * Validate a party (provider or client) object
  * @param party - Party object to validate
@@ -62,6 +71,10 @@ export function validateParty(party: Party, type: 'provider' | 'client'): Valida
 		errors.push(`${type} ABN must be 11 digits`);
 	}
 
+	if (party.country && !isValidCountryCode(party.country)) {
+		errors.push(`${type} country must be a two letter code, e.g. AU`);
+	}
+
 	if (party.phone && !isValidPhone(party.phone)) {
 		errors.push(`${type} phone number is invalid`);
 	}
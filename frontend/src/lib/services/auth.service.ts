@@ -1,12 +1,31 @@
 import { http } from '@/api/http';
 import { authStore } from '@/stores/auth';
 
+export interface LoginProvider {
+	name: string;
+	display_name: string;
+}
+
 interface SessionResponse {
 	authenticated: boolean;
 	email?: string;
-	method: 'oauth2' | 'plain' | 'none';
+	method: 'oauth2' | 'plain' | 'local' | 'none';
 	avatar_url?: string;
 	name?: string;
+	provider?: string;
+	providers?: LoginProvider[];
+}
+
+export interface MailerSession {
+	id: string;
+	email: string;
+	name?: string;
+	provider: string;
+	user_agent?: string;
+	created_at: string;
+	last_used_at: string;
+	expires_at: string;
+	current: boolean;
 }
 
 /**
@@ -23,6 +42,7 @@ export async function checkSession(fetchFn: typeof fetch): Promise<SessionRespon
 		}
 
 		authStore.setAuthMethod(response.method);
+		authStore.setLoginProviders(response.providers ?? []);
 		return response;
 	} catch (error) {
 		authStore.setUnauthenticated();
@@ -31,9 +51,9 @@ export async function checkSession(fetchFn: typeof fetch): Promise<SessionRespon
 }
 
 /**
- * Open OAuth login popup and wait for completion
+ * Open OAuth login popup for the given provider (e.g. "google", "microsoft") and wait for completion
  */
-export function loginWithGoogle(): Promise<void> {
+export function loginWithProvider(provider: string): Promise<void> {
 	return new Promise((resolve, reject) => {
 		const width = 500;
 		const height = 600;
@@ -41,7 +61,7 @@ export function loginWithGoogle(): Promise<void> {
 		const top = window.screenY + (window.outerHeight - height) / 2;
 
 		const popup = window.open(
-			'/api/v1/mailer/auth/google',
+			`/api/v1/mailer/auth/${encodeURIComponent(provider)}`,
 			'oauth-login',
 			`width=${width},height=${height},left=${left},top=${top},toolbar=0,menubar=0,location=0`
 		);
@@ -90,3 +110,17 @@ export async function logout(fetchFn: typeof fetch): Promise<void> {
 		throw error;
 	}
 }
+
+/**
+ * List the signed in mailer sessions of all browsers
+ */
+export async function listSessions(fetchFn: typeof fetch): Promise<MailerSession[]> {
+	return http.get<MailerSession[]>(fetchFn, '/mailer/sessions');
+}
+
+/**
+ * Sign out a mailer session, e.g. of a lost device
+ */
+export async function revokeSession(fetchFn: typeof fetch, id: string): Promise<void> {
+	await http.delete(fetchFn, `/mailer/sessions/${id}`);
+}
@@ -4,6 +4,7 @@ import * as providers from './provider.service';
 import * as smtp from './smtp.service';
 import * as version from './version.service';
 import * as auth from './auth.service';
+import * as signatures from './signature.service';
 
 export const api = {
 	invoices,
@@ -11,5 +12,6 @@ export const api = {
 	providers,
 	smtp,
 	version,
-	auth
+	auth,
+	signatures
 };
@@ -1,5 +1,5 @@
 import { http } from '@/api/http';
-import type { EmailConfig, Invoice } from '@/types/invoice';
+import type { EmailConfig, FacturXValidation, Invoice, PdfFormat } from '@/types/invoice';
 
 /**
  * PaginatedInvoices represents a paginated response of invoices
@@ -93,14 +93,33 @@ export async function deleteInvoice(KitFetch: typeof fetch, id: string) {
  * downloads the PDF of an invoice using the provided fetch function.
  * @param KitFetch - `KitFetch` is a parameter that represents the fetch function provided by SvelteKit.
  * @param id - The `id` parameter is a string that represents the unique identifier of the invoice whose PDF you want to download.
+ * @param format - 'facturx' downloads a PDF/A-3 e-invoice with the Factur-X XML attached (default: 'pdf')
  * @returns A Promise that resolves to the response containing the PDF data.
  */
-export async function downloadPdf(KitFetch: typeof fetch, id: string): Promise<Blob> {
-	return http.get<Blob>(KitFetch, `/invoices/${id}/pdf`, {
+export async function downloadPdf(
+	KitFetch: typeof fetch,
+	id: string,
+	format: PdfFormat = 'pdf'
+): Promise<Blob> {
+	const url = format === 'pdf' ? `/invoices/${id}/pdf` : `/invoices/${id}/pdf?format=${format}`;
+	return http.get<Blob>(KitFetch, url, {
 		responseType: 'blob'
 	});
 }
 
+/**
+ * reports the mandatory e-invoice (Factur-X) fields an invoice is missing.
+ * @param KitFetch - `KitFetch` is a parameter that represents the fetch function provided by SvelteKit.
+ * @param id - The `id` parameter is a string that represents the unique identifier of the invoice to validate.
+ * @returns A Promise that resolves to the validation result.
+ */
+export async function validateFacturX(
+	KitFetch: typeof fetch,
+	id: string
+): Promise<FacturXValidation> {
+	return http.get<FacturXValidation>(KitFetch, `/invoices/${id}/facturx/validate`);
+}
+
 /**
  * Sends an email for a specific invoice using the provided fetch function. (this will take some time to process, best to add loading indicator)
  * @param KitFetch - `KitFetch` is a parameter that represents the fetch function provided by SvelteKit.
@@ -0,0 +1,72 @@
+import { http } from '@/api/http';
+import { apiClient } from '@/api/apiClient';
+import type { SignatureReport, SigningKeyInfo } from '@/types/invoice';
+
+/**
+ * retrieves the certificate of a provider's signing key.
+ * @param KitFetch - `KitFetch` is SvelteKit's built-in fetch function that is used to make HTTP requests.
+ * @param providerId - The ID of the provider.
+ * @returns A Promise that resolves to the key's certificate, rejects when the provider has no key.
+ */
+export async function getSigningKey(
+	KitFetch: typeof fetch,
+	providerId: string
+): Promise<SigningKeyInfo> {
+	return http.get<SigningKeyInfo>(KitFetch, `/providers/${providerId}/signing_key`);
+}
+
+/**
+ * uploads the signing key of a provider, replacing any previous one.
+ * @param KitFetch - `KitFetch` is SvelteKit's built-in fetch function that is used to make HTTP requests.
+ * @param providerId - The ID of the provider.
+ * @param file - A PKCS#12 file (.p12, .pfx) or a PEM file with the private key and certificates.
+ * @param password - The password of the PKCS#12 file or encrypted key, empty otherwise.
+ * @returns A Promise that resolves to the certificate of the stored key.
+ */
+export async function uploadSigningKey(
+	KitFetch: typeof fetch,
+	providerId: string,
+	file: File,
+	password: string
+): Promise<SigningKeyInfo> {
+	const data = await toBase64(file);
+	return http.put<SigningKeyInfo>(KitFetch, `/providers/${providerId}/signing_key`, {
+		data,
+		password
+	});
+}
+
+/**
+ * deletes the signing key of a provider.
+ * @param KitFetch - `KitFetch` is SvelteKit's built-in fetch function that is used to make HTTP requests.
+ * @param providerId - The ID of the provider.
+ */
+export async function deleteSigningKey(KitFetch: typeof fetch, providerId: string) {
+	return http.delete(KitFetch, `/providers/${providerId}/signing_key`);
+}
+
+/**
+ * checks the signatures of a PDF against the server's trusted certificates.
+ * @param KitFetch - `KitFetch` is SvelteKit's built-in fetch function that is used to make HTTP requests.
+ * @param file - The PDF to verify.
+ * @returns A Promise that resolves to the verification of each signature.
+ */
+export async function verifyPDF(KitFetch: typeof fetch, file: File): Promise<SignatureReport> {
+	const form = new FormData();
+	form.append('file', file);
+	return apiClient<SignatureReport>(KitFetch, '/signatures/verify', {
+		method: 'POST',
+		body: form,
+		timeout: 60000
+	});
+}
+
+// toBase64 reads a file as base64 without the data URL prefix
+function toBase64(file: File): Promise<string> {
+	return new Promise((resolve, reject) => {
+		const reader = new FileReader();
+		reader.onload = () => resolve(String(reader.result).split(',', 2)[1] ?? '');
+		reader.onerror = () => reject(reader.error ?? new Error('failed to read file'));
+		reader.readAsDataURL(file);
+	});
+}
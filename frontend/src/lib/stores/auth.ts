@@ -1,11 +1,13 @@
 import { writable, derived } from 'svelte/store';
+import type { LoginProvider } from '@/services/auth.service';
 
 export interface AuthState {
 	isAuthenticated: boolean;
 	userEmail: string | null;
 	userAvatarURL: string | null;
 	userName: string | null;
-	authMethod: 'oauth2' | 'plain' | 'none' | null;
+	authMethod: 'oauth2' | 'plain' | 'local' | 'none' | null;
+	loginProviders: LoginProvider[];
 	loading: boolean;
 }
 
@@ -16,6 +18,7 @@ function createAuthStore() {
 		userAvatarURL: null,
 		userName: null,
 		authMethod: null,
+		loginProviders: [],
 		loading: true
 	});
 
@@ -39,11 +42,16 @@ function createAuthStore() {
 				userName: null,
 				loading: false
 			})),
-		setAuthMethod: (method: 'oauth2' | 'plain' | 'none') =>
+		setAuthMethod: (method: 'oauth2' | 'plain' | 'local' | 'none') =>
 			update((state) => ({
 				...state,
 				authMethod: method
 			})),
+		setLoginProviders: (loginProviders: LoginProvider[]) =>
+			update((state) => ({
+				...state,
+				loginProviders
+			})),
 		setLoading: (loading: boolean) =>
 			update((state) => ({
 				...state,
@@ -56,6 +64,7 @@ function createAuthStore() {
 				userAvatarURL: null,
 				userName: null,
 				authMethod: state.authMethod, // Preserve authMethod so UI doesn't disappear
+				loginProviders: state.loginProviders,
 				loading: false
 			}))
 	};
@@ -69,3 +78,4 @@ export const currentUserEmail = derived(authStore, ($auth) => $auth.userEmail);
 export const currentUserAvatarURL = derived(authStore, ($auth) => $auth.userAvatarURL);
 export const currentUserName = derived(authStore, ($auth) => $auth.userName);
 export const authMethod = derived(authStore, ($auth) => $auth.authMethod);
+export const loginProviders = derived(authStore, ($auth) => $auth.loginProviders);
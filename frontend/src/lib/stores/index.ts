@@ -12,7 +12,8 @@ export {
 	currentUserEmail,
 	authMethod,
 	currentUserAvatarURL,
-	currentUserName
+	currentUserName,
+	loginProviders
 } from './auth';
 
 // Provider store
@@ -1,14 +1,89 @@
 // Invoice status types - matching Go backend
-export type InvoiceStatus = 'draft' | 'send';
+export type InvoiceStatus = 'draft' | 'send' | 'paid';
 
 export interface ClientData extends Party {
 	tax_rate: number;
 	email_target?: string;
 	email_template_id: string;
+	pdf?: PageSettings;
 }
 
 export interface ProviderData extends Party {
 	payment_info: PaymentInfo;
+	sender?: SenderIdentity;
+	pdf?: PageSettings;
+	signing?: SigningSettings;
+}
+
+// PageSettings choose the paper of invoice PDFs. Empty fields inherit:
+// client from provider, provider from the server defaults (A3 portrait, 10mm margins).
+export interface PageSettings {
+	paper_size?: 'a4' | 'a3' | 'letter';
+	orientation?: 'portrait' | 'landscape';
+	margins?: Margins;
+}
+
+// Margins of a PDF page in millimetres
+export interface Margins {
+	top: number;
+	right: number;
+	bottom: number;
+	left: number;
+}
+
+// SenderIdentity configures who a provider's invoice emails are sent from.
+// Empty fields fall back to the server settings.
+export interface SenderIdentity {
+	from_name?: string;
+	from_email?: string;
+	reply_to?: string;
+	signature?: string; // plain text appended to the email body
+	transport?: 'smtp' | 'sendmail' | 'file';
+	account?: string; // email of the OAuth2 account to send with, stored when it saves the provider
+}
+
+// SigningSettings configure the digital signature of a provider's PDFs.
+// The signing key is uploaded separately, see SigningKeyInfo.
+export interface SigningSettings {
+	enabled: boolean;
+	visible?: boolean; // draw a signature block on the last page
+	reason?: string; // e.g. "Invoice issued by Acme Corp"
+	location?: string; // e.g. "Sydney, Australia"
+}
+
+// SigningKeyInfo describes the certificate of a provider's signing key
+export interface SigningKeyInfo {
+	subject: string;
+	issuer: string;
+	serial: string;
+	not_before: string;
+	not_after: string;
+	fingerprint: string; // SHA-256 of the certificate
+	self_signed: boolean;
+	chain: number; // number of certificates
+}
+
+// SignatureResult is the verification of one signature of a PDF
+export interface SignatureResult {
+	signer?: string;
+	issuer?: string;
+	fingerprint?: string;
+	name?: string;
+	reason?: string;
+	location?: string;
+	signing_time?: string; // claimed by the signer
+	intact: boolean; // the signed bytes are unchanged
+	covers_document: boolean; // nothing was appended after signing
+	trusted: boolean; // the certificate chains to a trusted CA now
+	valid_at_claimed_time?: boolean; // the certificate chained at signing_time, not timestamped
+	error?: string;
+	trust_error?: string;
+}
+
+// SignatureReport is the verification of all signatures of a PDF
+export interface SignatureReport {
+	valid: boolean;
+	signatures: SignatureResult[];
 }
 
 // Party represents either the service provider or the client/customer
@@ -20,6 +95,8 @@ export interface Party {
 	phone?: string;
 	abn?: string; // Australian Business Number
 	url?: string; // website URL
+	country?: string; // ISO 3166-1 alpha-2 code, e.g. AU, required for e-invoices
+	vat_id?: string; // VAT identifier with country prefix, e.g. DE123456789
 }
 
 // Service item represents a single line item in the invoice
@@ -63,6 +140,22 @@ export interface Invoice {
 	email_template_id?: string; // optional email template ID
 }
 
+// Output format of an invoice PDF, facturx is a PDF/A-3 e-invoice with the XML attached
+export type PdfFormat = 'pdf' | 'facturx';
+
+// A mandatory EN 16931 field the invoice is missing
+export interface FacturXProblem {
+	field: string; // JSON path in the invoice, e.g. provider.country
+	term: string; // EN 16931 business term, e.g. BT-40
+	message: string;
+}
+
+// Result of validating an invoice for Factur-X
+export interface FacturXValidation {
+	valid: boolean;
+	problems: FacturXProblem[];
+}
+
 // Form data for creating/editing invoices
 export interface InvoiceFormData {
 	id: string;